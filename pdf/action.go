@@ -0,0 +1,122 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Actions (12.6) of outline items and annotations.
+
+package pdf
+
+import (
+	"errors"
+)
+
+// An action as specified by an action dictionary.  The fields relevant
+// to the action type are set, the action dictionary is available for
+// other types.
+type PdfAction struct {
+	// Action type (S), e.g. GoTo, GoToR, URI or Named.
+	Type PdfObjectName
+	// GoTo: the destination within the document, named destinations
+	// are resolved.
+	Dest *PdfObjectArray
+	// GoToR: the remote file specification (F) and the destination (D)
+	// within the remote file, as is.
+	File       PdfObject
+	RemoteDest PdfObject
+	// URI: the target URI.
+	URI string
+	// Named: the action name (N), e.g. NextPage.
+	Name PdfObjectName
+	// JavaScript: the script (JS), from a text string or a stream.
+	JS string
+
+	Dict *PdfObjectDictionary
+}
+
+// Resolve an action from an action dictionary, which can also be an
+// indirect object or a reference.
+func (this *PdfReader) resolveAction(actionObj PdfObject) (*PdfAction, error) {
+	obj, err := this.traceToDirectObject(actionObj)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := obj.(*PdfObjectDictionary)
+	if !ok {
+		log.Error("Action not a dictionary (%T)", obj)
+		return nil, errors.New("Action not a dictionary")
+	}
+
+	s, ok := (*dict)["S"].(*PdfObjectName)
+	if !ok {
+		log.Error("Action missing type (S)")
+		return nil, errors.New("Action missing type (S)")
+	}
+
+	action := PdfAction{}
+	action.Type = *s
+	action.Dict = dict
+
+	switch *s {
+	case "GoTo":
+		if dObj, hasD := (*dict)["D"]; hasD {
+			action.Dest, err = this.resolveDestination(dObj)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case "GoToR":
+		action.File, err = this.traceToDirectObject((*dict)["F"])
+		if err != nil {
+			return nil, err
+		}
+		action.RemoteDest, err = this.traceToDirectObject((*dict)["D"])
+		if err != nil {
+			return nil, err
+		}
+	case "URI":
+		obj, err := this.traceToDirectObject((*dict)["URI"])
+		if err != nil {
+			return nil, err
+		}
+		if uri, ok := asString(obj); ok {
+			action.URI = string(*uri)
+		}
+	case "Named":
+		obj, err := this.traceToDirectObject((*dict)["N"])
+		if err != nil {
+			return nil, err
+		}
+		if name, ok := obj.(*PdfObjectName); ok {
+			action.Name = *name
+		}
+	case "JavaScript":
+		action.JS, err = this.loadJavaScript((*dict)["JS"])
+		if err != nil {
+			return nil, err
+		}
+	default:
+		log.Debug("Action type %s not resolved", *s)
+	}
+
+	return &action, nil
+}
+
+// Get the action of an outline item.  Returns nil if the outline item
+// does not have an action (A).
+func (this *PdfReader) GetOutlineAction(outline *PdfIndirectObject) (*PdfAction, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+
+	dict, ok := outline.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		log.Error("Invalid outlines entry")
+		return nil, errors.New("Invalid outlines entry")
+	}
+	actionObj, hasA := (*dict)["A"]
+	if !hasA {
+		return nil, nil
+	}
+	return this.resolveAction(actionObj)
+}
@@ -0,0 +1,93 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOutlineActions(t *testing.T) {
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R /Outlines 5 0 R /Dests << /Named2 [4 0 R /Fit] >> >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+		"<< /Type /Outlines /First 6 0 R /Last 12 0 R /Count 7 >>",
+		"<< /Title (GoTo) /Parent 5 0 R /Next 7 0 R /A << /S /GoTo /D [3 0 R /Fit] >> >>",
+		"<< /Title (GoTo indirect) /Parent 5 0 R /Next 8 0 R /A 13 0 R >>",
+		"<< /Title (GoToR) /Parent 5 0 R /Next 9 0 R /A << /S /GoToR /F (other.pdf) /D [0 /Fit] >> >>",
+		"<< /Title (URI) /Parent 5 0 R /Next 10 0 R /A << /S /URI /URI (http://unidoc.io) >> >>",
+		"<< /Title (Named) /Parent 5 0 R /Next 11 0 R /A << /S /Named /N /NextPage >> >>",
+		"<< /Title (JavaScript) /Parent 5 0 R /Next 12 0 R /A << /S /JavaScript /JS (app.alert(1)) >> >>",
+		"<< /Title (No action) /Parent 5 0 R /Dest /Named2 >>",
+		"<< /S /GoTo /D /Named2 >>",
+	})
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	page1, _ := reader.GetPage(1)
+	page2, _ := reader.GetPage(2)
+
+	outlines, err := reader.GetOutlines()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(outlines) != 7 {
+		t.Fatalf("Expected 7 outlines (%d)", len(outlines))
+	}
+
+	actions := []*PdfAction{}
+	for _, outline := range outlines {
+		action, err := reader.GetOutlineAction(outline)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		actions = append(actions, action)
+	}
+
+	if actions[0].Type != "GoTo" || actions[0].Dest == nil || (*actions[0].Dest)[0] != page1 {
+		t.Errorf("Invalid GoTo action %+v", actions[0])
+	}
+	if actions[1].Type != "GoTo" || actions[1].Dest == nil || (*actions[1].Dest)[0] != page2 {
+		t.Errorf("Invalid indirect GoTo action %+v", actions[1])
+	}
+	if file, ok := actions[2].File.(*PdfObjectString); actions[2].Type != "GoToR" || !ok || string(*file) != "other.pdf" {
+		t.Errorf("Invalid GoToR action %+v", actions[2])
+	}
+	if _, ok := actions[2].RemoteDest.(*PdfObjectArray); !ok {
+		t.Errorf("Invalid GoToR destination %+v", actions[2].RemoteDest)
+	}
+	if actions[3].Type != "URI" || actions[3].URI != "http://unidoc.io" {
+		t.Errorf("Invalid URI action %+v", actions[3])
+	}
+	if actions[4].Type != "Named" || actions[4].Name != "NextPage" {
+		t.Errorf("Invalid Named action %+v", actions[4])
+	}
+	if actions[5].Type != "JavaScript" || actions[5].Dict == nil {
+		t.Errorf("Invalid JavaScript action %+v", actions[5])
+	}
+	if actions[6] != nil {
+		t.Errorf("Outline without an action should have nil action")
+	}
+
+	pageOutlines, err := reader.GetOutlinesForPage(page1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(pageOutlines) != 1 || pageOutlines[0] != outlines[0] {
+		t.Errorf("Invalid outlines for page 1 (%v)", pageOutlines)
+	}
+	pageOutlines, err = reader.GetOutlinesForPage(page2)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(pageOutlines) != 2 {
+		t.Errorf("Invalid outlines for page 2 (%v)", pageOutlines)
+	}
+}
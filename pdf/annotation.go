@@ -0,0 +1,115 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Link annotations (12.5.6.5) created by the writer.
+
+package pdf
+
+import (
+	"errors"
+)
+
+// A rectangle in default user space, given by the lower left (Llx, Lly)
+// and upper right (Urx, Ury) corners.
+type PdfRectangle struct {
+	Llx float64
+	Lly float64
+	Urx float64
+	Ury float64
+}
+
+// Make a rectangle array [llx lly urx ury].
+func (this PdfRectangle) toArray() *PdfObjectArray {
+	arr := PdfObjectArray{}
+	for _, val := range []float64{this.Llx, this.Lly, this.Urx, this.Ury} {
+		arr = append(arr, makeReal(val))
+	}
+	return &arr
+}
+
+// Add a link annotation on a page (from 1) going to the destination page
+// displayed with the fit mode.
+func (this *PdfWriter) AddLinkAnnotation(pageNumber int, rect PdfRectangle, destPage int, fit FitMode) error {
+	target, err := this.getPage(destPage)
+	if err != nil {
+		return err
+	}
+	dest, err := fit.makeDestination(target)
+	if err != nil {
+		return err
+	}
+
+	action := PdfObjectDictionary{}
+	action["S"] = makeName("GoTo")
+	action["D"] = dest
+	return this.addLink(pageNumber, rect, &action)
+}
+
+// Add a link annotation on a page (from 1) opening the URI.
+func (this *PdfWriter) AddURILink(pageNumber int, rect PdfRectangle, uri string) error {
+	if uri == "" {
+		return errors.New("Empty URI")
+	}
+
+	action := PdfObjectDictionary{}
+	action["S"] = makeName("URI")
+	action["URI"] = makeString(uri)
+	return this.addLink(pageNumber, rect, &action)
+}
+
+// Get the Annots array of a page for adding annotations, creating it if
+// missing.  Annots can be a direct array or an indirect object holding
+// the array.
+func getPageAnnotsForUpdate(pDict *PdfObjectDictionary) (*PdfObjectArray, error) {
+	switch t := (*pDict)["Annots"].(type) {
+	case nil:
+		annots := &PdfObjectArray{}
+		(*pDict)["Annots"] = annots
+		return annots, nil
+	case *PdfObjectArray:
+		return t, nil
+	case *PdfIndirectObject:
+		if annots, ok := t.PdfObject.(*PdfObjectArray); ok {
+			return annots, nil
+		}
+		return nil, errors.New("Invalid page Annots")
+	default:
+		log.Error("Invalid page Annots (%T)", t)
+		return nil, errors.New("Invalid page Annots")
+	}
+}
+
+// Add a link annotation with an action to the Annots of a page.
+func (this *PdfWriter) addLink(pageNumber int, rect PdfRectangle, action *PdfObjectDictionary) error {
+	page, err := this.getPage(pageNumber)
+	if err != nil {
+		return err
+	}
+	pDict, ok := page.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return errors.New("Page not a dictionary")
+	}
+
+	annots, err := getPageAnnotsForUpdate(pDict)
+	if err != nil {
+		return err
+	}
+
+	// No border by default.
+	border := PdfObjectArray{makeInteger(0), makeInteger(0), makeInteger(0)}
+
+	annotDict := PdfObjectDictionary{}
+	annotDict["Type"] = makeName("Annot")
+	annotDict["Subtype"] = makeName("Link")
+	annotDict["Rect"] = rect.toArray()
+	annotDict["Border"] = &border
+	annotDict["A"] = action
+
+	annot := PdfIndirectObject{}
+	annot.PdfObject = &annotDict
+	*annots = append(*annots, &annot)
+
+	return this.addObjects(&annot)
+}
@@ -0,0 +1,87 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLinkAnnotationsRoundTrip(t *testing.T) {
+	w := NewPdfWriter()
+	for i := 0; i < 2; i++ {
+		err := w.AddPage(loadMinimalPage(t))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+
+	rect := PdfRectangle{10, 20, 110, 40}
+	if err := w.AddLinkAnnotation(1, rect, 3, FitPage()); err == nil {
+		t.Errorf("Invalid destination page should fail")
+	}
+	if err := w.AddURILink(5, rect, "https://example.com"); err == nil {
+		t.Errorf("Invalid page should fail")
+	}
+	err := w.AddLinkAnnotation(1, rect, 2, FitPage())
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	err = w.AddURILink(1, rect, "https://example.com")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error reading output: %v", err)
+	}
+	page, err := reader.GetPage(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	pDict := page.(*PdfIndirectObject).PdfObject.(*PdfObjectDictionary)
+	obj, err := reader.traceToDirectObject((*pDict)["Annots"])
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	annots, ok := obj.(*PdfObjectArray)
+	if !ok || len(*annots) != 2 {
+		t.Fatalf("Expected 2 annotations (%v)", obj)
+	}
+
+	actions := []*PdfAction{}
+	for _, annotObj := range *annots {
+		obj, err := reader.traceToDirectObject(annotObj)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		annot := obj.(*PdfObjectDictionary)
+		if subtype, ok := (*annot)["Subtype"].(*PdfObjectName); !ok || *subtype != "Link" {
+			t.Errorf("Invalid annotation subtype %v", (*annot)["Subtype"])
+		}
+		if border, ok := (*annot)["Border"].(*PdfObjectArray); !ok || border.DefaultWriteString() != "[0 0 0]" {
+			t.Errorf("Invalid annotation border %v", (*annot)["Border"])
+		}
+		action, err := reader.resolveAction((*annot)["A"])
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		actions = append(actions, action)
+	}
+
+	pageNumber, _, err := reader.GetDestinationPage(actions[0].Dest)
+	if err != nil || pageNumber != 2 {
+		t.Errorf("Invalid link destination page %d (%v)", pageNumber, err)
+	}
+	if actions[1].Type != "URI" || actions[1].URI != "https://example.com" {
+		t.Errorf("Invalid URI action %+v", actions[1])
+	}
+}
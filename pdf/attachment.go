@@ -0,0 +1,209 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Embedded files (7.11.4).
+
+package pdf
+
+import (
+	"crypto/md5"
+	"errors"
+	"sort"
+	"unicode/utf16"
+)
+
+// A file embedded in the document.
+type EmbeddedFile struct {
+	Name string
+	Data []byte
+}
+
+// Decode a text string, either UTF-16BE with a byte order mark or a
+// single byte encoded string.
+func decodeTextString(str *PdfObjectString) string {
+	b := []byte(*str)
+	if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+		return utf16BytesToString(b[2:])
+	}
+	return string(b)
+}
+
+// Encode a text string, as UTF-16BE with a byte order mark if it
+// contains non-ASCII characters.
+func encodeTextString(s string) *PdfObjectString {
+	for _, r := range s {
+		if r >= 0x80 {
+			b := []byte{0xfe, 0xff}
+			for _, unit := range utf16.Encode([]rune(s)) {
+				b = append(b, byte(unit>>8), byte(unit))
+			}
+			return makeString(string(b))
+		}
+	}
+	return makeString(s)
+}
+
+// Get the files embedded in the document via the EmbeddedFiles name tree
+// in the catalog Names dictionary.  The file name is taken from the
+// Unicode file name (UF) of the file specification when present,
+// otherwise from F or the name tree key.
+func (this *PdfReader) GetAttachments() ([]EmbeddedFile, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+
+	files := []EmbeddedFile{}
+
+	namesObj, err := this.traceToDirectObject((*this.catalog)["Names"])
+	if err != nil {
+		return nil, err
+	}
+	names, ok := namesObj.(*PdfObjectDictionary)
+	if !ok {
+		return files, nil
+	}
+	tree, hasTree := (*names)["EmbeddedFiles"]
+	if !hasTree {
+		return files, nil
+	}
+	entries, err := this.getNameTreeEntries(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		file, err := this.loadEmbeddedFile(entry.key, entry.value)
+		if err != nil {
+			log.Error("Unable to load embedded file %s (%s)", entry.key, err)
+			return nil, err
+		}
+		files = append(files, *file)
+	}
+
+	return files, nil
+}
+
+// Load an embedded file from a file specification dictionary.
+func (this *PdfReader) loadEmbeddedFile(key string, filespecObj PdfObject) (*EmbeddedFile, error) {
+	obj, err := this.traceToDirectObject(filespecObj)
+	if err != nil {
+		return nil, err
+	}
+	filespec, ok := obj.(*PdfObjectDictionary)
+	if !ok {
+		return nil, errors.New("File specification not a dictionary")
+	}
+
+	file := EmbeddedFile{Name: key}
+	if uf, ok := asString((*filespec)["UF"]); ok {
+		file.Name = decodeTextString(uf)
+	} else if f, ok := asString((*filespec)["F"]); ok {
+		file.Name = decodeTextString(f)
+	}
+
+	obj, err = this.traceToDirectObject((*filespec)["EF"])
+	if err != nil {
+		return nil, err
+	}
+	ef, ok := obj.(*PdfObjectDictionary)
+	if !ok {
+		return nil, errors.New("File specification missing EF")
+	}
+	streamObj, hasStream := (*ef)["F"]
+	if !hasStream {
+		streamObj, hasStream = (*ef)["UF"]
+	}
+	if !hasStream {
+		return nil, errors.New("Embedded file stream missing")
+	}
+	if ref, isRef := streamObj.(*PdfObjectReference); isRef {
+		streamObj, _, err = this.resolveReference(ref)
+		if err != nil {
+			return nil, err
+		}
+	}
+	stream, ok := streamObj.(*PdfObjectStream)
+	if !ok {
+		return nil, errors.New("Embedded file not a stream")
+	}
+
+	file.Data, err = this.parser.decodeStream(stream)
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// Attach a file to the document.  Creates the embedded file stream and
+// file specification and registers it in the EmbeddedFiles name tree of
+// the catalog Names dictionary.  The MIME type (e.g. text/xml) is
+// optional.
+func (this *PdfWriter) AttachFile(name string, data []byte, mimeType string) error {
+	if name == "" {
+		return errors.New("Attachment name missing")
+	}
+	if this.embeddedFiles == nil {
+		this.embeddedFiles = map[string]*PdfIndirectObject{}
+	}
+	if _, exists := this.embeddedFiles[name]; exists {
+		log.Error("Attachment %s already exists", name)
+		return errors.New("Attachment already exists")
+	}
+
+	checksum := md5.Sum(data)
+	params := PdfObjectDictionary{}
+	params["Size"] = makeInteger(int64(len(data)))
+	params["CheckSum"] = makeString(string(checksum[:]))
+
+	streamDict := PdfObjectDictionary{}
+	streamDict["Type"] = makeName("EmbeddedFile")
+	if mimeType != "" {
+		streamDict["Subtype"] = makeName(mimeType)
+	}
+	streamDict["Params"] = &params
+	streamDict["Length"] = makeInteger(int64(len(data)))
+	stream := PdfObjectStream{}
+	stream.PdfObjectDictionary = &streamDict
+	stream.Stream = data
+
+	ef := PdfObjectDictionary{}
+	ef["F"] = &stream
+	ef["UF"] = &stream
+	filespecDict := PdfObjectDictionary{}
+	filespecDict["Type"] = makeName("Filespec")
+	filespecDict["F"] = makeString(name)
+	filespecDict["UF"] = encodeTextString(name)
+	filespecDict["EF"] = &ef
+	filespec := PdfIndirectObject{}
+	filespec.PdfObject = &filespecDict
+
+	err := this.addObjects(&filespec)
+	if err != nil {
+		return err
+	}
+	this.embeddedFiles[name] = &filespec
+
+	// Rebuild the name tree, keys in sorted order.
+	keys := []string{}
+	for key := range this.embeddedFiles {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	names := PdfObjectArray{}
+	for _, key := range keys {
+		names = append(names, makeString(key), this.embeddedFiles[key])
+	}
+	tree := PdfObjectDictionary{}
+	tree["Names"] = &names
+
+	namesDict, ok := (*this.catalog)["Names"].(*PdfObjectDictionary)
+	if !ok {
+		namesDict = &PdfObjectDictionary{}
+		(*this.catalog)["Names"] = namesDict
+	}
+	(*namesDict)["EmbeddedFiles"] = &tree
+
+	return nil
+}
@@ -0,0 +1,107 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"testing"
+)
+
+func TestGetAttachments(t *testing.T) {
+	xml := "<invoice>42</invoice>"
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte(xml))
+	zw.Close()
+
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R /Names << /EmbeddedFiles 4 0 R >> >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+		"<< /Kids [5 0 R 6 0 R] >>",
+		"<< /Limits [(a.txt) (a.txt)] /Names [(a.txt) 7 0 R] >>",
+		"<< /Limits [(b.xml) (b.xml)] /Names [(b.xml) << /Type /Filespec /F (b.xml) /UF <FEFF00DF002E0078006D006C> /EF << /F 8 0 R >> >>] >>",
+		"<< /Type /Filespec /F (a.txt) /EF << /F 9 0 R >> >>",
+		fmt.Sprintf("<< /Type /EmbeddedFile /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+			compressed.Len(), compressed.Bytes()),
+		"<< /Type /EmbeddedFile /Length 5 >>\nstream\nhello\nendstream",
+	})
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	files, err := reader.GetAttachments()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 attachments, got %d", len(files))
+	}
+	if files[0].Name != "a.txt" || string(files[0].Data) != "hello" {
+		t.Errorf("Invalid attachment %q: %q", files[0].Name, files[0].Data)
+	}
+	if files[1].Name != "ß.xml" || string(files[1].Data) != xml {
+		t.Errorf("Invalid attachment %q: %q", files[1].Name, files[1].Data)
+	}
+
+	// No attachments.
+	reader = loadMinimalReader(t)
+	files, err = reader.GetAttachments()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("Expected no attachments, got %d", len(files))
+	}
+}
+
+func TestAttachFileRoundTrip(t *testing.T) {
+	w := NewPdfWriter()
+	err := w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	err = w.AttachFile("zugferd-invoice.xml", []byte("<invoice/>"), "text/xml")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	err = w.AttachFile("données.txt", []byte("abc"), "")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if err := w.AttachFile("données.txt", []byte("abc"), ""); err == nil {
+		t.Errorf("Duplicate attachment should fail")
+	}
+
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error reading output: %v", err)
+	}
+	files, err := reader.GetAttachments()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 attachments, got %d", len(files))
+	}
+	if files[0].Name != "données.txt" || string(files[0].Data) != "abc" {
+		t.Errorf("Invalid attachment %q: %q", files[0].Name, files[0].Data)
+	}
+	if files[1].Name != "zugferd-invoice.xml" || string(files[1].Data) != "<invoice/>" {
+		t.Errorf("Invalid attachment %q: %q", files[1].Name, files[1].Data)
+	}
+	if !bytes.Contains(out, []byte("/Subtype /text#2fxml")) {
+		t.Errorf("Missing MIME subtype")
+	}
+}
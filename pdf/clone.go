@@ -0,0 +1,71 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Deep copies of the writer state.
+
+package pdf
+
+import (
+	"errors"
+)
+
+// Make a deep copy of the writer, such as a template with common pages
+// and settings, so that changes to the copy do not affect the original
+// and vice versa.  Encrypted writers cannot be cloned.
+func (this *PdfWriter) Clone() (*PdfWriter, error) {
+	if this.crypter != nil {
+		return nil, errors.New("Cannot clone an encrypted writer")
+	}
+
+	copies := map[PdfObject]PdfObject{}
+	w := PdfWriter{}
+	w.objectsMap = map[PdfObject]bool{}
+	w.objects = []PdfObject{}
+	for _, obj := range this.objects {
+		w.objects = append(w.objects, deepCopy(obj, copies))
+	}
+
+	w.root = deepCopy(this.root, copies).(*PdfIndirectObject)
+	w.pages = deepCopy(this.pages, copies).(*PdfIndirectObject)
+	w.catalog = deepCopy(this.catalog, copies).(*PdfObjectDictionary)
+	w.infoObj = deepCopy(this.infoObj, copies).(*PdfIndirectObject)
+	w.outlines = []*PdfIndirectObject{}
+	for _, outline := range this.outlines {
+		w.outlines = append(w.outlines, deepCopy(outline, copies).(*PdfIndirectObject))
+	}
+	for _, field := range this.fields {
+		w.fields = append(w.fields, deepCopy(field, copies))
+	}
+	w.sigFlags = this.sigFlags
+	if this.embeddedFiles != nil {
+		w.embeddedFiles = map[string]*PdfIndirectObject{}
+		for name, filespec := range this.embeddedFiles {
+			w.embeddedFiles[name] = deepCopy(filespec, copies).(*PdfIndirectObject)
+		}
+	}
+	if this.namedDests != nil {
+		w.namedDests = map[string]*PdfObjectArray{}
+		for name, dest := range this.namedDests {
+			w.namedDests[name] = deepCopy(dest, copies).(*PdfObjectArray)
+		}
+	}
+	w.destsNameTree = this.destsNameTree
+	for _, font := range this.fonts {
+		fontCopy := *font
+		fontCopy.obj = deepCopy(font.obj, copies).(*PdfIndirectObject)
+		w.fonts = append(w.fonts, &fontCopy)
+	}
+	if this.ids != nil {
+		w.ids = deepCopy(this.ids, copies).(*PdfObjectArray)
+	}
+	w.pageTreeBranching = this.pageTreeBranching
+	w.compressStreams = this.compressStreams
+	w.compressionLevel = this.compressionLevel
+	w.linearize = this.linearize
+	w.progress = this.progress
+	w.binaryComment = this.binaryComment
+
+	return &w, nil
+}
@@ -0,0 +1,85 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriterClone(t *testing.T) {
+	w := NewPdfWriter()
+	err := w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	err = w.AttachFile("template.txt", []byte("template"), "text/plain")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	clone, err := w.Clone()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	err = clone.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	err = clone.SetPageRotation(1, 90)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	err = clone.AttachFile("clone.txt", []byte("clone"), "text/plain")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	(*clone.infoObj.PdfObject.(*PdfObjectDictionary))["Title"] = makeString("Clone")
+
+	clonePage, _ := clone.getPage(1)
+	if (*clonePage.PdfObject.(*PdfObjectDictionary))["Parent"] != clone.pages {
+		t.Errorf("Cloned page parent not remapped")
+	}
+
+	// The original is unchanged.
+	page, _ := w.getPage(1)
+	if page == clonePage {
+		t.Fatalf("Page shared by the clone")
+	}
+	if _, has := (*page.PdfObject.(*PdfObjectDictionary))["Rotate"]; has {
+		t.Errorf("Original page rotated")
+	}
+	if _, has := (*w.infoObj.PdfObject.(*PdfObjectDictionary))["Title"]; has {
+		t.Errorf("Original info changed")
+	}
+
+	expected := []struct {
+		writer      *PdfWriter
+		pages       int
+		attachments int
+	}{
+		{&w, 1, 1},
+		{clone, 2, 2},
+	}
+	for i, exp := range expected {
+		out, err := writePdfToBytes(exp.writer)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		reader, err := NewPdfReader(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("Error reading output: %v", err)
+		}
+		numPages, _ := reader.GetNumPages()
+		files, err := reader.GetAttachments()
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if numPages != exp.pages || len(files) != exp.attachments {
+			t.Errorf("Writer %d: %d pages %d attachments (expected %d %d)", i, numPages, len(files), exp.pages, exp.attachments)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// ToUnicode CMap support for mapping character codes to text (9.10.3).
+
+package pdf
+
+import (
+	"errors"
+	"unicode/utf16"
+)
+
+// Limit on the number of codes in a single bfrange.
+const maxCMapRangeSize = 65536
+
+type codespaceRange struct {
+	low  []byte
+	high []byte
+}
+
+// Check whether a code is within the codespace range.
+func (this codespaceRange) contains(code []byte) bool {
+	if len(code) != len(this.low) || len(code) != len(this.high) {
+		return false
+	}
+	for i := range code {
+		if code[i] < this.low[i] || code[i] > this.high[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// A ToUnicode CMap.  Maps character codes (as byte strings) to unicode
+// text.
+type cmap struct {
+	codespaces []codespaceRange
+	codeMap    map[string]string
+}
+
+// Parse a ToUnicode CMap from decoded stream data.  Supports the
+// codespacerange, bfchar and bfrange sections.
+func parseCMap(data []byte) (*cmap, error) {
+	cm := cmap{}
+	cm.codeMap = map[string]string{}
+
+	csp := NewContentStreamParser(data)
+	operations, err := csp.Parse()
+	if err != nil {
+		log.Error("Unable to parse CMap (%s)", err)
+		return nil, err
+	}
+
+	for _, op := range operations {
+		switch op.Operator {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(op.Operands); i += 2 {
+				low, ok1 := asString(op.Operands[i])
+				high, ok2 := asString(op.Operands[i+1])
+				if !ok1 || !ok2 || len(*low) != len(*high) {
+					log.Error("Invalid codespace range")
+					return nil, errors.New("Invalid codespace range")
+				}
+				cm.codespaces = append(cm.codespaces, codespaceRange{[]byte(*low), []byte(*high)})
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(op.Operands); i += 2 {
+				src, ok1 := asString(op.Operands[i])
+				dst, ok2 := asString(op.Operands[i+1])
+				if !ok1 || !ok2 {
+					// Destination can also be a glyph name, not supported.
+					log.Debug("Skipping unsupported bfchar entry")
+					continue
+				}
+				cm.codeMap[string(*src)] = utf16BytesToString([]byte(*dst))
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(op.Operands); i += 3 {
+				err := cm.addRange(op.Operands[i], op.Operands[i+1], op.Operands[i+2])
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return &cm, nil
+}
+
+// Add a bfrange entry.  The destination is either a string which is
+// incremented for each code in the range, or an array of strings.
+func (this *cmap) addRange(lowObj, highObj, dstObj PdfObject) error {
+	low, ok1 := asString(lowObj)
+	high, ok2 := asString(highObj)
+	if !ok1 || !ok2 || len(*low) != len(*high) || len(*low) > 4 {
+		log.Error("Invalid bfrange")
+		return errors.New("Invalid bfrange")
+	}
+
+	lowCode := bytesToCode([]byte(*low))
+	highCode := bytesToCode([]byte(*high))
+	if highCode < lowCode || highCode-lowCode >= maxCMapRangeSize {
+		log.Error("Invalid bfrange (%d-%d)", lowCode, highCode)
+		return errors.New("Invalid bfrange")
+	}
+
+	if str, isString := asString(dstObj); isString {
+		dstObj = str
+	}
+	for code := lowCode; code <= highCode; code++ {
+		offset := int(code - lowCode)
+		key := string(codeToBytes(code, len(*low)))
+
+		switch dst := dstObj.(type) {
+		case *PdfObjectString:
+			units := bytesToUTF16([]byte(*dst))
+			if len(units) > 0 {
+				units[len(units)-1] += uint16(offset)
+			}
+			this.codeMap[key] = string(utf16.Decode(units))
+		case *PdfObjectArray:
+			if offset >= len(*dst) {
+				break
+			}
+			if str, ok := asString((*dst)[offset]); ok {
+				this.codeMap[key] = utf16BytesToString([]byte(*str))
+			}
+		default:
+			log.Error("Invalid bfrange destination (%T)", dstObj)
+			return errors.New("Invalid bfrange destination")
+		}
+	}
+
+	return nil
+}
+
+// Get the length of the next code in the data based on the codespace
+// ranges.  Uses the default length if no codespace ranges are defined.
+func (this *cmap) codeLength(data []byte, defaultLength int) int {
+	if len(this.codespaces) == 0 {
+		return defaultLength
+	}
+	for n := 1; n <= 4 && n <= len(data); n++ {
+		for _, codespace := range this.codespaces {
+			if codespace.contains(data[:n]) {
+				return n
+			}
+		}
+	}
+	return 1
+}
+
+// Decode the character codes in data to text.  Codes not in the CMap are
+// decoded with the fallback function.
+func (this *cmap) decode(data []byte, defaultLength int, fallback func(code []byte) string) string {
+	text := ""
+	for len(data) > 0 {
+		n := this.codeLength(data, defaultLength)
+		if n > len(data) {
+			n = len(data)
+		}
+		code := data[:n]
+		data = data[n:]
+
+		if str, has := this.codeMap[string(code)]; has {
+			text += str
+		} else {
+			text += fallback(code)
+		}
+	}
+	return text
+}
+
+func bytesToCode(b []byte) uint32 {
+	code := uint32(0)
+	for _, c := range b {
+		code = code<<8 | uint32(c)
+	}
+	return code
+}
+
+func codeToBytes(code uint32, length int) []byte {
+	b := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		b[i] = byte(code & 0xff)
+		code >>= 8
+	}
+	return b
+}
+
+// Convert UTF-16BE encoded bytes to 16 bit units.
+func bytesToUTF16(b []byte) []uint16 {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return units
+}
+
+// Convert UTF-16BE encoded bytes to a string.
+func utf16BytesToString(b []byte) string {
+	return string(utf16.Decode(bytesToUTF16(b)))
+}
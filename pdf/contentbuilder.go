@@ -0,0 +1,170 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Building content streams for new page content.
+
+package pdf
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// Builds content stream data operation by operation, e.g.
+//
+//	builder := NewContentStreamBuilder()
+//	builder.BeginText().SetFont("F1", 12).MoveText(72, 720).ShowText("Hello").EndText()
+//
+// The resulting Bytes are used as the data of a content stream, with the
+// resources it refers to (fonts etc.) added to the page Resources.
+type ContentStreamBuilder struct {
+	buf bytes.Buffer
+}
+
+func NewContentStreamBuilder() *ContentStreamBuilder {
+	return &ContentStreamBuilder{}
+}
+
+// Format a number for a content stream, without unnecessary digits.
+func formatNumber(val float64) string {
+	// Negative zero, e.g. from a rotation, is written as 0.
+	if val == 0 {
+		val = 0
+	}
+	return strconv.FormatFloat(val, 'f', -1, 64)
+}
+
+// Add an operation with the operands.
+func (this *ContentStreamBuilder) Add(operator string, operands ...PdfObject) *ContentStreamBuilder {
+	for _, operand := range operands {
+		this.buf.WriteString(operand.DefaultWriteString())
+		this.buf.WriteByte(' ')
+	}
+	this.buf.WriteString(operator)
+	this.buf.WriteByte('\n')
+	return this
+}
+
+// Add an operation with numeric operands.
+func (this *ContentStreamBuilder) addNumeric(operator string, operands ...float64) *ContentStreamBuilder {
+	for _, operand := range operands {
+		this.buf.WriteString(formatNumber(operand))
+		this.buf.WriteByte(' ')
+	}
+	this.buf.WriteString(operator)
+	this.buf.WriteByte('\n')
+	return this
+}
+
+// Save the graphics state (q).
+func (this *ContentStreamBuilder) SaveState() *ContentStreamBuilder {
+	return this.Add("q")
+}
+
+// Restore the graphics state (Q).
+func (this *ContentStreamBuilder) RestoreState() *ContentStreamBuilder {
+	return this.Add("Q")
+}
+
+// Modify the transformation matrix (cm).
+func (this *ContentStreamBuilder) Transform(m PdfMatrix) *ContentStreamBuilder {
+	return this.addNumeric("cm", m[:]...)
+}
+
+// Begin a text object (BT).
+func (this *ContentStreamBuilder) BeginText() *ContentStreamBuilder {
+	return this.Add("BT")
+}
+
+// End a text object (ET).
+func (this *ContentStreamBuilder) EndText() *ContentStreamBuilder {
+	return this.Add("ET")
+}
+
+// Set the font by its name in the Font resources and the size (Tf).
+func (this *ContentStreamBuilder) SetFont(name string, size float64) *ContentStreamBuilder {
+	this.buf.WriteString(makeName(name).DefaultWriteString())
+	this.buf.WriteByte(' ')
+	return this.addNumeric("Tf", size)
+}
+
+// Move to the start of the next line, offset from the start of the
+// current line (Td).
+func (this *ContentStreamBuilder) MoveText(x, y float64) *ContentStreamBuilder {
+	return this.addNumeric("Td", x, y)
+}
+
+// Set the text leading, the distance between lines (TL).
+func (this *ContentStreamBuilder) SetLeading(leading float64) *ContentStreamBuilder {
+	return this.addNumeric("TL", leading)
+}
+
+// Move to the start of the next line (T*).
+func (this *ContentStreamBuilder) NextLine() *ContentStreamBuilder {
+	return this.Add("T*")
+}
+
+// Show a text string (Tj).  The text is written as is and should be
+// encoded with the font encoding, e.g. WinAnsiEncoding.
+func (this *ContentStreamBuilder) ShowText(text string) *ContentStreamBuilder {
+	return this.Add("Tj", makeString(text))
+}
+
+// Set the stroking and nonstroking color as RGB components in the range
+// 0-1 (RG and rg).
+func (this *ContentStreamBuilder) SetColor(r, g, b float64) *ContentStreamBuilder {
+	this.addNumeric("RG", r, g, b)
+	return this.addNumeric("rg", r, g, b)
+}
+
+// Set the stroking color as RGB components (RG).
+func (this *ContentStreamBuilder) SetStrokeColor(r, g, b float64) *ContentStreamBuilder {
+	return this.addNumeric("RG", r, g, b)
+}
+
+// Set the nonstroking (fill) color as RGB components (rg).
+func (this *ContentStreamBuilder) SetFillColor(r, g, b float64) *ContentStreamBuilder {
+	return this.addNumeric("rg", r, g, b)
+}
+
+// Set the line width (w).
+func (this *ContentStreamBuilder) SetLineWidth(width float64) *ContentStreamBuilder {
+	return this.addNumeric("w", width)
+}
+
+// Begin a new subpath at a point (m).
+func (this *ContentStreamBuilder) MoveTo(x, y float64) *ContentStreamBuilder {
+	return this.addNumeric("m", x, y)
+}
+
+// Append a line to a point to the current path (l).
+func (this *ContentStreamBuilder) LineTo(x, y float64) *ContentStreamBuilder {
+	return this.addNumeric("l", x, y)
+}
+
+// Append a rectangle to the current path (re).
+func (this *ContentStreamBuilder) DrawRectangle(x, y, width, height float64) *ContentStreamBuilder {
+	return this.addNumeric("re", x, y, width, height)
+}
+
+// Stroke the path (S).
+func (this *ContentStreamBuilder) Stroke() *ContentStreamBuilder {
+	return this.Add("S")
+}
+
+// Fill the path with the nonzero winding number rule (f).
+func (this *ContentStreamBuilder) Fill() *ContentStreamBuilder {
+	return this.Add("f")
+}
+
+// Fill and then stroke the path (B).
+func (this *ContentStreamBuilder) FillStroke() *ContentStreamBuilder {
+	return this.Add("B")
+}
+
+// Get the content stream data.
+func (this *ContentStreamBuilder) Bytes() []byte {
+	return this.buf.Bytes()
+}
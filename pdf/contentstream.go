@@ -0,0 +1,288 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Content stream parsing.  Splits a decoded content stream into a list
+// of operations, each consisting of an operator and its operands.
+
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// A content stream operation: the operator with the preceding operands.
+type ContentStreamOperation struct {
+	Operands []PdfObject
+	Operator string
+}
+
+type ContentStreamParser struct {
+	parser *PdfParser
+}
+
+// Create a new content stream parser for decoded content stream data,
+// such as returned by PdfReader.GetPageContents.
+func NewContentStreamParser(content []byte) *ContentStreamParser {
+	parser := PdfParser{}
+	parser.reader = bufio.NewReader(bytes.NewReader(content))
+
+	csp := ContentStreamParser{}
+	csp.parser = &parser
+	return &csp
+}
+
+// Parse the content stream into a list of operations.
+// Inline images (BI ... ID ... EI) are returned as a single operation with
+// the operator BI and two operands: the image parameters dictionary and
+// the raw image data as a string.
+func (this *ContentStreamParser) Parse() ([]*ContentStreamOperation, error) {
+	operations := []*ContentStreamOperation{}
+	operands := []PdfObject{}
+
+	for {
+		obj, operator, err := this.parseToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if obj != nil {
+			operands = append(operands, obj)
+			continue
+		}
+
+		op := ContentStreamOperation{}
+		op.Operator = operator
+		op.Operands = operands
+		if operator == "BI" {
+			params, data, err := this.parseInlineImage()
+			if err != nil {
+				return nil, err
+			}
+			op.Operands = []PdfObject{params, data}
+		}
+		operations = append(operations, &op)
+		operands = []PdfObject{}
+	}
+
+	if len(operands) > 0 {
+		log.Debug("Ignoring trailing operands without an operator (%s)", operands)
+	}
+
+	return operations, nil
+}
+
+// Skip over white space and comments.
+func (this *ContentStreamParser) skipSpacesAndComments() error {
+	for {
+		_, err := this.parser.skipSpaces()
+		if err != nil {
+			return err
+		}
+
+		bb, err := this.parser.reader.Peek(1)
+		if err != nil {
+			return err
+		}
+		if bb[0] != '%' {
+			return nil
+		}
+
+		// Comment until the end of line.
+		for {
+			b, err := this.parser.reader.ReadByte()
+			if err != nil {
+				return err
+			}
+			if b == '\r' || b == '\n' {
+				break
+			}
+		}
+	}
+}
+
+// Parse the next token, either an operand object or an operator.
+// Returns the object if an operand was read, otherwise the operator name.
+// Returns io.EOF if the end of the content stream has been reached.
+func (this *ContentStreamParser) parseToken() (PdfObject, string, error) {
+	err := this.skipSpacesAndComments()
+	if err != nil {
+		return nil, "", err
+	}
+
+	obj, operator, err := this.parseTokenData()
+	if err == io.EOF {
+		// The token was cut short by the end of the stream.
+		return nil, "", io.ErrUnexpectedEOF
+	}
+	return obj, operator, err
+}
+
+func (this *ContentStreamParser) parseTokenData() (PdfObject, string, error) {
+	bb, _ := this.parser.reader.Peek(2)
+	if len(bb) == 0 {
+		return nil, "", io.EOF
+	}
+
+	switch {
+	case bb[0] == '/':
+		name, err := this.parser.parseName()
+		return &name, "", err
+	case bb[0] == '(':
+		str, err := this.parser.parseString()
+		return &str, "", err
+	case bb[0] == '[':
+		arr, err := this.parseArray()
+		return arr, "", err
+	case bb[0] == '<' && len(bb) > 1 && bb[1] == '<':
+		dict, err := this.parser.parseDict()
+		return dict, "", err
+	case bb[0] == '<':
+		str, err := this.parser.parseHexString()
+		return &str, "", err
+	case isDecimalDigit(bb[0]) || bb[0] == '-' || bb[0] == '+' || bb[0] == '.':
+		num, err := this.parser.parseNumber()
+		return num, "", err
+	case isDelimiter(bb[0]):
+		log.Error("Unexpected delimiter in content stream (%c)", bb[0])
+		return nil, "", fmt.Errorf("Unexpected delimiter in content stream (%c)", bb[0])
+	}
+
+	// Keyword: an operator or one of true, false and null.
+	keyword := []byte{}
+	for {
+		bb, err := this.parser.reader.Peek(1)
+		if err != nil || isWhiteSpace(bb[0]) || isDelimiter(bb[0]) {
+			break
+		}
+		b, _ := this.parser.reader.ReadByte()
+		keyword = append(keyword, b)
+	}
+
+	switch string(keyword) {
+	case "true":
+		return makeBool(true), "", nil
+	case "false":
+		return makeBool(false), "", nil
+	case "null":
+		return makeNull(), "", nil
+	}
+
+	return nil, string(keyword), nil
+}
+
+// Starts with '[' ends with ']'.  Parsed separately from the regular
+// parser as sequences of numbers in content streams could be mistaken
+// for references.
+func (this *ContentStreamParser) parseArray() (*PdfObjectArray, error) {
+	arr := PdfObjectArray{}
+
+	this.parser.reader.ReadByte()
+	for {
+		err := this.skipSpacesAndComments()
+		if err != nil {
+			return nil, err
+		}
+
+		bb, err := this.parser.reader.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if bb[0] == ']' {
+			this.parser.reader.ReadByte()
+			break
+		}
+
+		obj, operator, err := this.parseTokenData()
+		if err != nil {
+			return nil, err
+		}
+		if obj == nil {
+			log.Error("Operator inside an array (%s)", operator)
+			return nil, fmt.Errorf("Operator inside an array (%s)", operator)
+		}
+		arr = append(arr, obj)
+	}
+
+	return &arr, nil
+}
+
+// Parse an inline image following the BI operator.  The image parameters
+// come first as key value pairs up to the ID operator, which is followed
+// by a single white space character and the image data up to EI.
+func (this *ContentStreamParser) parseInlineImage() (*PdfObjectDictionary, *PdfObjectString, error) {
+	params := PdfObjectDictionary{}
+
+	for {
+		obj, operator, err := this.parseToken()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if obj == nil {
+			if operator == "ID" {
+				break
+			}
+			log.Error("Unexpected operator in inline image parameters (%s)", operator)
+			return nil, nil, fmt.Errorf("Unexpected operator in inline image parameters (%s)", operator)
+		}
+
+		key, ok := obj.(*PdfObjectName)
+		if !ok {
+			log.Error("Inline image parameter key not a name (%s)", obj)
+			return nil, nil, fmt.Errorf("Inline image parameter key not a name (%s)", obj)
+		}
+
+		val, operator, err := this.parseToken()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if val == nil {
+			log.Error("Missing inline image parameter value (%s %s)", *key, operator)
+			return nil, nil, fmt.Errorf("Missing inline image parameter value (%s)", *key)
+		}
+		params[*key] = val
+	}
+
+	// Skip the single white space after ID.
+	this.parser.reader.ReadByte()
+
+	// Read until EI surrounded by white space (or at the end).
+	data := []byte{}
+	for {
+		b, err := this.parser.reader.ReadByte()
+		if err != nil {
+			log.Error("Inline image missing EI")
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		data = append(data, b)
+
+		n := len(data)
+		if n >= 2 && data[n-2] == 'E' && data[n-1] == 'I' && (n == 2 || isWhiteSpace(data[n-3])) {
+			bb, err := this.parser.reader.Peek(1)
+			if err == io.EOF || (err == nil && (isWhiteSpace(bb[0]) || isDelimiter(bb[0]))) {
+				if n == 2 {
+					data = data[:0]
+				} else {
+					data = data[:n-3]
+				}
+				break
+			}
+		}
+	}
+
+	str := PdfObjectString(data)
+	return &params, &str, nil
+}
@@ -0,0 +1,187 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestContentStreamParser(t *testing.T) {
+	content := `% A comment
+q 1 0 0 1 72 720 cm
+BT /F1 12 Tf 0 0 Td (Hello \(World\)) Tj
+[(A) -120 (B)] TJ <414243> Tj ET
+0 0 100 50 re f
+/Im1 Do
+/OC << /MCID 0 >> BDC EMC
+true false null d0
+Q`
+
+	csp := NewContentStreamParser([]byte(content))
+	operations, err := csp.Parse()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	expected := []struct {
+		Operator    string
+		NumOperands int
+	}{
+		{"q", 0}, {"cm", 6}, {"BT", 0}, {"Tf", 2}, {"Td", 2}, {"Tj", 1},
+		{"TJ", 1}, {"Tj", 1}, {"ET", 0}, {"re", 4}, {"f", 0}, {"Do", 1},
+		{"BDC", 2}, {"EMC", 0}, {"d0", 3}, {"Q", 0},
+	}
+	if len(operations) != len(expected) {
+		t.Fatalf("Expected %d operations, got %d (%v)", len(expected), len(operations), operations)
+	}
+	for i, exp := range expected {
+		op := operations[i]
+		if op.Operator != exp.Operator || len(op.Operands) != exp.NumOperands {
+			t.Errorf("Operation %d: got %s with %d operands, expected %s with %d",
+				i, op.Operator, len(op.Operands), exp.Operator, exp.NumOperands)
+		}
+	}
+
+	// cm operands should be numbers, not mistaken for references.
+	if _, ok := operations[1].Operands[0].(*PdfObjectInteger); !ok {
+		t.Errorf("cm operand not an integer (%T)", operations[1].Operands[0])
+	}
+	if str, ok := operations[5].Operands[0].(*PdfObjectString); !ok || string(*str) != "Hello (World)" {
+		t.Errorf("Invalid Tj operand (%v)", operations[5].Operands[0])
+	}
+	arr, ok := operations[6].Operands[0].(*PdfObjectArray)
+	if !ok || len(*arr) != 3 {
+		t.Fatalf("Invalid TJ array (%v)", operations[6].Operands[0])
+	}
+	if str, ok := operations[7].Operands[0].(*PdfObjectString); !ok || string(*str) != "ABC" {
+		t.Errorf("Invalid hex string operand (%v)", operations[7].Operands[0])
+	}
+	if _, ok := operations[12].Operands[1].(*PdfObjectDictionary); !ok {
+		t.Errorf("BDC operand not a dictionary (%T)", operations[12].Operands[1])
+	}
+	if _, ok := operations[14].Operands[2].(*PdfObjectNull); !ok {
+		t.Errorf("null operand not parsed (%T)", operations[14].Operands[2])
+	}
+}
+
+func TestContentStreamInlineImage(t *testing.T) {
+	content := "q BI /W 2 /H 2 /BPC 8 /CS /G ID \x00EI\xff\x10 EI Q"
+
+	csp := NewContentStreamParser([]byte(content))
+	operations, err := csp.Parse()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(operations) != 3 {
+		t.Fatalf("Expected 3 operations, got %d", len(operations))
+	}
+
+	op := operations[1]
+	if op.Operator != "BI" || len(op.Operands) != 2 {
+		t.Fatalf("Invalid inline image operation (%s %v)", op.Operator, op.Operands)
+	}
+	params, ok := op.Operands[0].(*PdfObjectDictionary)
+	if !ok || len(*params) != 4 {
+		t.Errorf("Invalid inline image parameters (%v)", op.Operands[0])
+	}
+	data, ok := op.Operands[1].(*PdfObjectString)
+	if !ok || string(*data) != "\x00EI\xff\x10" {
+		t.Errorf("Invalid inline image data (%q)", op.Operands[1])
+	}
+	if operations[2].Operator != "Q" {
+		t.Errorf("Expected Q after inline image (%s)", operations[2].Operator)
+	}
+}
+
+func TestContentStreamUnterminated(t *testing.T) {
+	for _, content := range []string{"(Hello Tj", "[1 2", "BI /W 1 ID abc"} {
+		csp := NewContentStreamParser([]byte(content))
+		_, err := csp.Parse()
+		if err == nil {
+			t.Errorf("Unterminated content should fail (%q)", content)
+		}
+	}
+}
+
+func TestContentStreamBuilder(t *testing.T) {
+	builder := NewContentStreamBuilder()
+	builder.SaveState().
+		SetColor(1, 0, 0.5).
+		DrawRectangle(10, 10, 100, 50.5).
+		Fill().
+		RestoreState().
+		BeginText().
+		SetFont("F1", 18).
+		MoveText(72, 720).
+		ShowText("Hello (World)").
+		EndText()
+
+	expected := "q\n1 0 0.5 RG\n1 0 0.5 rg\n10 10 100 50.5 re\nf\nQ\nBT\n/F1 18 Tf\n72 720 Td\n(Hello \\(World\\)) Tj\nET\n"
+	if string(builder.Bytes()) != expected {
+		t.Errorf("Unexpected content %q", builder.Bytes())
+	}
+
+	operations, err := NewContentStreamParser(builder.Bytes()).Parse()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(operations) != 11 || operations[9].Operator != "Tj" {
+		t.Fatalf("Unexpected operations %v", operations)
+	}
+	if str, ok := operations[9].Operands[0].(*PdfObjectString); !ok || string(*str) != "Hello (World)" {
+		t.Errorf("Invalid text operand %v", operations[9].Operands)
+	}
+}
+
+func TestContentStreamBuilderPage(t *testing.T) {
+	content := NewContentStreamBuilder().
+		BeginText().SetFont("F1", 24).MoveText(72, 720).ShowText("Hello World").EndText().
+		Bytes()
+
+	font := PdfObjectDictionary{}
+	font["Type"] = makeName("Font")
+	font["Subtype"] = makeName("Type1")
+	font["BaseFont"] = makeName("Helvetica")
+	resources := PdfObjectDictionary{}
+	resources["Font"] = &PdfObjectDictionary{"F1": &font}
+
+	pageDict := PdfObjectDictionary{}
+	pageDict["Type"] = makeName("Page")
+	pageDict["MediaBox"] = PdfRectangle{0, 0, 612, 792}.toArray()
+	pageDict["Resources"] = &resources
+	pageDict["Contents"] = makeContentStream(content)
+	page := PdfIndirectObject{}
+	page.PdfObject = &pageDict
+
+	w := NewPdfWriter()
+	if err := w.AddPage(&page); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	contents, err := reader.GetPageContents(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if !bytes.Equal(contents, content) {
+		t.Errorf("Content not read back intact (%q)", contents)
+	}
+	text, err := reader.ExtractPageText(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if text != "Hello World" {
+		t.Errorf("Unexpected text %q", text)
+	}
+}
@@ -0,0 +1,454 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Copying documents and pages from a reader to a writer.
+
+package pdf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Catalog entries maintained by the writer itself.
+var writerCatalogKeys = map[PdfObjectName]bool{
+	"Type":     true,
+	"Version":  true,
+	"Pages":    true,
+	"Outlines": true,
+	"AcroForm": true,
+}
+
+// Create a writer with a copy of the whole document of a reader: the
+// pages, outlines, form fields and other catalog entries, as well as the
+// document information and ID.
+//
+// An encrypted document needs to be decrypted first.  All the objects are
+// resolved, and thus decrypted, and copied so that writing does not
+// modify the reader's objects.  To change the passwords of a document:
+//
+//	reader.Decrypt(ownerPass)
+//	w, err := NewPdfWriterFromReader(reader)
+//	w.Encrypt(newUserPass, newOwnerPass, options)
+//	w.Write(out)
+func NewPdfWriterFromReader(reader *PdfReader) (*PdfWriter, error) {
+	if reader.parser.crypter != nil && !reader.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+
+	w := NewPdfWriter()
+	copies := map[PdfObject]PdfObject{}
+
+	numPages, err := reader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+	// Load all the pages before copying, the pages are also copied with
+	// the Kids of the page tree nodes.
+	pages := []PdfObject{}
+	for i := 1; i <= numPages; i++ {
+		page, err := reader.GetPage(i)
+		if err != nil {
+			return nil, err
+		}
+		err = reader.resolveParentReferences(page, map[PdfObject]bool{})
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+	}
+	for _, page := range pages {
+		err = w.AddPage(deepCopy(page, copies))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	outlines, err := reader.GetOutlines()
+	if err != nil {
+		return nil, err
+	}
+	for _, outline := range outlines {
+		err = reader.resolveParentReferences(outline, map[PdfObject]bool{})
+		if err != nil {
+			return nil, err
+		}
+		w.outlines = append(w.outlines, deepCopy(outline, copies).(*PdfIndirectObject))
+	}
+
+	forms, err := reader.GetForms()
+	if err != nil {
+		return nil, err
+	}
+	if forms != nil {
+		err = reader.resolveParentReferences(forms, map[PdfObject]bool{})
+		if err != nil {
+			return nil, err
+		}
+		err = w.AddForms(deepCopy(forms, copies).(*PdfObjectDictionary))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range reader.catalog.sortedKeys() {
+		if writerCatalogKeys[key] {
+			continue
+		}
+		obj, err := reader.resolveObject((*reader.catalog)[key])
+		if err != nil {
+			return nil, err
+		}
+		objCopy := deepCopy(obj, copies)
+		(*w.catalog)[key] = objCopy
+		err = w.addObjects(objCopy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	infoObj, err := reader.resolveObject((*reader.parser.trailer)["Info"])
+	if err != nil {
+		return nil, err
+	}
+	if info, ok := infoObj.(*PdfIndirectObject); ok {
+		infoDict, ok := info.PdfObject.(*PdfObjectDictionary)
+		if !ok {
+			return nil, errors.New("Invalid document information dictionary")
+		}
+		wInfoDict := w.infoObj.PdfObject.(*PdfObjectDictionary)
+		for key, val := range *infoDict {
+			// The producer is the writer.
+			if key != "Producer" {
+				(*wInfoDict)[key] = deepCopy(val, copies)
+			}
+		}
+	}
+
+	if ids, ok := (*reader.parser.trailer)["ID"].(*PdfObjectArray); ok && len(*ids) == 2 {
+		id0, ok0 := asString((*ids)[0])
+		id1, ok1 := asString((*ids)[1])
+		if ok0 && ok1 && len(*id0) > 0 && len(*id1) > 0 {
+			w.ids = makeIDArray([]byte(*id0), []byte(*id1))
+		}
+	}
+
+	return &w, nil
+}
+
+// Options for copying pages from a reader.
+type PageCopyOptions struct {
+	// Bake the page rotation into the page content, so that the copied
+	// pages are upright with Rotate 0: the content is transformed with a
+	// cm operator and the page boxes and annotation rectangles are
+	// rotated, e.g. swapping the MediaBox width and height for 90 and 270
+	// degrees.
+	NormalizeRotation bool
+}
+
+// Add a range of pages of a reader, from and to (starting from 1)
+// inclusive, with the objects used by the pages, e.g. to extract pages
+// into a new document.  References to the pages not in the range, such as
+// link destinations, are replaced by null so that the pages are not
+// pulled in.  Document level objects such as outlines are not added.  An
+// encrypted document needs to be decrypted first.
+func (this *PdfWriter) AddReaderPageRange(reader *PdfReader, from, to int) error {
+	return this.AddReaderPageRangeWithOptions(reader, from, to, PageCopyOptions{})
+}
+
+// Add a range of pages of a reader with the specified options.
+func (this *PdfWriter) AddReaderPageRangeWithOptions(reader *PdfReader, from, to int, options PageCopyOptions) error {
+	if reader.parser.crypter != nil && !reader.parser.crypter.authenticated {
+		return ErrEncrypted
+	}
+	numPages, err := reader.GetNumPages()
+	if err != nil {
+		return err
+	}
+	if from < 1 || to > numPages || from > to {
+		return fmt.Errorf("Invalid page range %d-%d (valid range 1-%d)", from, to, numPages)
+	}
+
+	pages := []PdfObject{}
+	for i := from; i <= to; i++ {
+		page, err := reader.GetPage(i)
+		if err != nil {
+			return err
+		}
+		err = reader.resolveParentReferences(page, map[PdfObject]bool{})
+		if err != nil {
+			return err
+		}
+		pages = append(pages, page)
+	}
+
+	// The pages not in the range are copied as null, also avoids copying
+	// them with the Kids of the page tree nodes.
+	copies := map[PdfObject]PdfObject{}
+	for i, page := range reader.pageList {
+		if page != nil && (i+1 < from || i+1 > to) {
+			copies[page] = makeNull()
+		}
+	}
+	pageCopies := []*PdfIndirectObject{}
+	selected := map[PdfObject]bool{}
+	for _, page := range pages {
+		pageCopy := deepCopy(page, copies).(*PdfIndirectObject)
+		pageCopies = append(pageCopies, pageCopy)
+		selected[pageCopy] = true
+	}
+	for _, pageCopy := range pageCopies {
+		// Pages not loaded yet when copying (lazy loading).
+		replacePageReferences(pageCopy.PdfObject, selected, map[PdfObject]bool{})
+		err = this.AddPage(pageCopy)
+		if err != nil {
+			return err
+		}
+		if options.NormalizeRotation {
+			err = this.normalizePageRotation(pageCopy)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Bake the rotation of a page added to the writer into its content.  The
+// content is rotated by a cm operator prepended to the Contents, mapping
+// the rotated media box to the origin, and the page boxes and annotation
+// rectangles are transformed in the same way.
+func (this *PdfWriter) normalizePageRotation(page *PdfIndirectObject) error {
+	pDict, ok := page.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return errors.New("Page not a dictionary")
+	}
+
+	// Inherited attributes are copied to the page when added.
+	rotateObj := (*pDict)["Rotate"]
+	if ind, isIndirect := rotateObj.(*PdfIndirectObject); isIndirect {
+		rotateObj = ind.PdfObject
+	}
+	if rotateObj == nil {
+		return nil
+	}
+	rotate, ok := rotateObj.(*PdfObjectInteger)
+	if !ok {
+		log.Error("Invalid Rotate object (%T)", rotateObj)
+		return errors.New("Invalid Rotate object")
+	}
+	rotation, err := normalizeRotation(int(*rotate))
+	if err != nil {
+		return err
+	}
+	delete(*pDict, "Rotate")
+	if rotation == 0 {
+		return nil
+	}
+
+	mediaObj, has := (*pDict)["MediaBox"]
+	if !has {
+		return errors.New("Page missing MediaBox")
+	}
+	mediaBox, err := getDirectRectangle(mediaObj)
+	if err != nil {
+		return err
+	}
+	m := uprightPageMatrix(*mediaBox, rotation)
+
+	for _, name := range []PdfObjectName{"MediaBox", "CropBox", "BleedBox", "TrimBox", "ArtBox"} {
+		if obj, has := (*pDict)[name]; has {
+			box, err := getDirectRectangle(obj)
+			if err != nil {
+				return err
+			}
+			(*pDict)[name] = box.Transform(m).toArray()
+		}
+	}
+
+	annotsObj := (*pDict)["Annots"]
+	if ind, isIndirect := annotsObj.(*PdfIndirectObject); isIndirect {
+		annotsObj = ind.PdfObject
+	}
+	if annots, isArray := annotsObj.(*PdfObjectArray); isArray {
+		for _, annotObj := range *annots {
+			if ind, isIndirect := annotObj.(*PdfIndirectObject); isIndirect {
+				annotObj = ind.PdfObject
+			}
+			annot, isDict := annotObj.(*PdfObjectDictionary)
+			if !isDict {
+				continue
+			}
+			if rectObj, has := (*annot)["Rect"]; has {
+				rect, err := getDirectRectangle(rectObj)
+				if err != nil {
+					return err
+				}
+				(*annot)["Rect"] = rect.Transform(m).toArray()
+			}
+		}
+	}
+
+	contents, err := getPageContentsForUpdate(pDict)
+	if err != nil {
+		return err
+	}
+	transform := NewContentStreamBuilder().Transform(m).Bytes()
+	*contents = append(PdfObjectArray{makeContentStream(transform)}, *contents...)
+
+	return this.addObjects(pDict)
+}
+
+// Replace the references to pages not selected by null, not following
+// Parent references.
+func replacePageReferences(obj PdfObject, selected map[PdfObject]bool, visited map[PdfObject]bool) {
+	if visited[obj] {
+		return
+	}
+	visited[obj] = true
+
+	replace := func(val PdfObject) PdfObject {
+		if ind, ok := val.(*PdfIndirectObject); ok && !selected[ind] {
+			if dict, ok := ind.PdfObject.(*PdfObjectDictionary); ok {
+				if t, ok := (*dict)["Type"].(*PdfObjectName); ok && *t == "Page" {
+					return makeNull()
+				}
+			}
+		}
+		replacePageReferences(val, selected, visited)
+		return val
+	}
+	switch t := obj.(type) {
+	case *PdfIndirectObject:
+		replacePageReferences(t.PdfObject, selected, visited)
+	case *PdfObjectStream:
+		replacePageReferences(t.PdfObjectDictionary, selected, visited)
+	case *PdfObjectDictionary:
+		for key, val := range *t {
+			if key != "Parent" {
+				(*t)[key] = replace(val)
+			}
+		}
+	case *PdfObjectArray:
+		for i, val := range *t {
+			(*t)[i] = replace(val)
+		}
+	}
+}
+
+// Resolve a reference and all the objects referenced from the object.
+func (this *PdfReader) resolveObject(obj PdfObject) (PdfObject, error) {
+	if ref, isRef := obj.(*PdfObjectReference); isRef {
+		resolved, _, err := this.resolveReference(ref)
+		if err != nil {
+			return nil, err
+		}
+		obj = resolved
+	}
+	if obj == nil {
+		return nil, nil
+	}
+	err := this.traverseObjectData(obj, map[PdfObjectName]bool{"Parent": true})
+	if err != nil {
+		return nil, err
+	}
+	err = this.resolveParentReferences(obj, map[PdfObject]bool{})
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// Resolve the Parent references, which are not followed when traversing
+// the object data, so that they refer to the resolved objects.
+func (this *PdfReader) resolveParentReferences(obj PdfObject, visited map[PdfObject]bool) error {
+	if visited[obj] {
+		return nil
+	}
+	switch t := obj.(type) {
+	case *PdfIndirectObject:
+		visited[obj] = true
+		return this.resolveParentReferences(t.PdfObject, visited)
+	case *PdfObjectStream:
+		visited[obj] = true
+		return this.resolveParentReferences(t.PdfObjectDictionary, visited)
+	case *PdfObjectDictionary:
+		visited[obj] = true
+		for key, val := range *t {
+			if key == "Parent" {
+				if ref, isRef := val.(*PdfObjectReference); isRef {
+					resolved, _, err := this.resolveReference(ref)
+					if err != nil {
+						return err
+					}
+					(*t)[key] = resolved
+				}
+				continue
+			}
+			err := this.resolveParentReferences(val, visited)
+			if err != nil {
+				return err
+			}
+		}
+	case *PdfObjectArray:
+		visited[obj] = true
+		for _, val := range *t {
+			err := this.resolveParentReferences(val, visited)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Remove the encryption of a document: decrypt it with the password,
+// normally the owner password, and write an unencrypted copy.  All the
+// strings and streams of the copy are decrypted.
+func WriteDecryptedCopy(rs io.ReadSeeker, password []byte, out io.Writer) error {
+	reader, err := NewPdfReader(rs)
+	if err != nil {
+		return err
+	}
+	isEncrypted, err := reader.IsEncrypted()
+	if err != nil {
+		return err
+	}
+	if isEncrypted {
+		success, err := reader.Decrypt(password)
+		if err != nil {
+			return err
+		}
+		if !success {
+			return errors.New("Invalid password")
+		}
+	}
+
+	w, err := NewPdfWriterFromReader(reader)
+	if err != nil {
+		return err
+	}
+	return w.WriteToWriter(out)
+}
+
+// Write a copy of a document with all the pages rotated by an angle in
+// degrees (clockwise), added to the existing rotation of each page.  The
+// angle must be a multiple of 90.
+func WriteRotatedCopy(rs io.ReadSeeker, angle int, out io.Writer) error {
+	reader, err := NewPdfReader(rs)
+	if err != nil {
+		return err
+	}
+	w, err := NewPdfWriterFromReader(reader)
+	if err != nil {
+		return err
+	}
+	err = w.RotateAllPages(angle)
+	if err != nil {
+		return err
+	}
+	return w.WriteToWriter(out)
+}
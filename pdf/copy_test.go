@@ -0,0 +1,316 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestChangePasswords(t *testing.T) {
+	out := writeEncryptedMinimal(t, []byte("user"), []byte("owner"), nil)
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if _, err := NewPdfWriterFromReader(reader); err != ErrEncrypted {
+		t.Errorf("Expected ErrEncrypted (%v)", err)
+	}
+	success, err := reader.Decrypt([]byte("owner"))
+	if err != nil || !success {
+		t.Fatalf("Unable to decrypt (%v)", err)
+	}
+
+	w, err := NewPdfWriterFromReader(reader)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	err = w.Encrypt([]byte("newuser"), []byte("newowner"), nil)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	reencrypted, err := writePdfToBytes(w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	// The source objects are not encrypted by writing.
+	text, err := reader.ExtractPageText(1)
+	if err != nil || text != "Hello World" {
+		t.Errorf("Source document modified (%q, %v)", text, err)
+	}
+
+	reader, err = NewPdfReader(bytes.NewReader(reencrypted))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	for _, password := range []string{"user", "owner"} {
+		success, err := reader.Decrypt([]byte(password))
+		if err != nil || success {
+			t.Errorf("Old password %q should not decrypt (%v)", password, err)
+		}
+	}
+	for _, password := range []string{"newuser", "newowner"} {
+		reader, err := NewPdfReader(bytes.NewReader(reencrypted))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		success, err := reader.Decrypt([]byte(password))
+		if err != nil || !success {
+			t.Fatalf("Unable to decrypt with %q (%v)", password, err)
+		}
+		text, err := reader.ExtractPageText(1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if text != "Hello World" {
+			t.Errorf("Unexpected text %q", text)
+		}
+	}
+}
+
+func TestWriteDecryptedCopy(t *testing.T) {
+	title := "Confidential report"
+	w := NewPdfWriter()
+	err := w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	infoDict := w.infoObj.PdfObject.(*PdfObjectDictionary)
+	(*infoDict)["Title"] = makeString(title)
+	err = w.Encrypt([]byte("user"), []byte("owner"), nil)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	encrypted, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if bytes.Contains(encrypted, []byte(title)) {
+		t.Fatalf("Title not encrypted")
+	}
+
+	var out bytes.Buffer
+	if err := WriteDecryptedCopy(bytes.NewReader(encrypted), []byte("wrong"), &out); err == nil {
+		t.Errorf("Wrong password should fail")
+	}
+	out.Reset()
+	if err := WriteDecryptedCopy(bytes.NewReader(encrypted), []byte("owner"), &out); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	decrypted := out.Bytes()
+	if bytes.Contains(decrypted, []byte("/Encrypt")) {
+		t.Errorf("Copy still encrypted")
+	}
+	if !bytes.Contains(decrypted, []byte(title)) {
+		t.Errorf("Title not decrypted")
+	}
+
+	reader, err := NewPdfReader(bytes.NewReader(decrypted))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	isEncrypted, err := reader.IsEncrypted()
+	if err != nil || isEncrypted {
+		t.Fatalf("Copy should open without a password (%v)", err)
+	}
+	contents, err := reader.GetPageContents(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	original, err := loadMinimalReader(t).GetPageContents(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if !bytes.Equal(contents, original) {
+		t.Errorf("Content differs from the original (%q)", contents)
+	}
+}
+
+func TestAddReaderPageRange(t *testing.T) {
+	src := makeLinearizeTestWriter(t, 4)
+	// Link on page 2 to page 4, outline to page 4.
+	page2, _ := src.getPage(2)
+	page4, _ := src.getPage(4)
+	link := PdfObjectDictionary{}
+	link["Type"] = makeName("Annot")
+	link["Subtype"] = makeName("Link")
+	link["Rect"] = &PdfObjectArray{makeInteger(0), makeInteger(0), makeInteger(10), makeInteger(10)}
+	link["Dest"] = &PdfObjectArray{page4, makeName("Fit")}
+	linkObj := PdfIndirectObject{}
+	linkObj.PdfObject = &link
+	(*page2.PdfObject.(*PdfObjectDictionary))["Annots"] = &PdfObjectArray{&linkObj}
+	if err := src.addObjects(&linkObj); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	outline := PdfObjectDictionary{}
+	outline["Title"] = makeString("Last page")
+	outline["Dest"] = &PdfObjectArray{page4, makeName("Fit")}
+	outlineObj := PdfIndirectObject{}
+	outlineObj.PdfObject = &outline
+	if err := src.AddOutlines([]*PdfIndirectObject{&outlineObj}); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	srcData, err := writePdfToBytes(src)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	for _, lazy := range []bool{false, true} {
+		reader, err := NewPdfReaderWithOptions(bytes.NewReader(srcData), ReaderOptions{LazyPages: lazy})
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		w := NewPdfWriter()
+		for _, r := range [][2]int{{0, 2}, {2, 5}, {3, 2}} {
+			if err := w.AddReaderPageRange(reader, r[0], r[1]); err == nil {
+				t.Errorf("Invalid range %v should fail", r)
+			}
+		}
+		if err := w.AddReaderPageRange(reader, 2, 3); err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		out, err := writePdfToBytes(&w)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if bytes.Contains(out, []byte("Page 4")) || bytes.Contains(out, []byte("Page 1")) {
+			t.Errorf("Lazy %v: content of pages not in the range", lazy)
+		}
+		if bytes.Contains(out, []byte("Last page")) {
+			t.Errorf("Lazy %v: outline copied", lazy)
+		}
+
+		extracted, err := NewPdfReader(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		numPages, err := extracted.GetNumPages()
+		if err != nil || numPages != 2 {
+			t.Fatalf("Lazy %v: %d pages (%v)", lazy, numPages, err)
+		}
+		for i, expected := range []string{"Page 2\nTimes", "Page 3\nTimes"} {
+			text, err := extracted.ExtractPageText(i + 1)
+			if err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+			if text != expected {
+				t.Errorf("Lazy %v: page %d text %q", lazy, i+1, text)
+			}
+		}
+		if bytes.Count(out, []byte("/BaseFont /Times-Roman")) != 1 {
+			t.Errorf("Lazy %v: shared font not copied once", lazy)
+		}
+		if !bytes.Contains(out, []byte("/Dest [null /Fit]")) {
+			t.Errorf("Lazy %v: link to a page not in the range not removed", lazy)
+		}
+	}
+}
+
+func TestAddReaderPageRangeNormalizeRotation(t *testing.T) {
+	// The text origin (72, 720) of a letter page is shown rotated
+	// clockwise at these positions of the upright page.
+	tests := []struct {
+		rotate     int64
+		mediaBox   PdfRectangle
+		textOrigin [2]float64
+	}{
+		{90, PdfRectangle{0, 0, 792, 612}, [2]float64{720, 540}},
+		{180, PdfRectangle{0, 0, 612, 792}, [2]float64{540, 72}},
+		{-90, PdfRectangle{0, 0, 792, 612}, [2]float64{72, 72}},
+	}
+	for _, test := range tests {
+		src := NewPdfWriter()
+		page := NewPage(PageWidthLetter, PageHeightLetter)
+		helv, err := src.AddStandardFont(page, "Helvetica")
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		content := NewContentStreamBuilder().
+			BeginText().SetFont(string(helv), 12).MoveText(72, 720).ShowText("Rotated").EndText().Bytes()
+		pDict := page.PdfObject.(*PdfObjectDictionary)
+		(*pDict)["Contents"] = makeContentStream(content)
+		(*pDict)["Rotate"] = makeInteger(test.rotate)
+		if err := src.AddPage(page); err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		srcData, err := writePdfToBytes(&src)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		reader, err := NewPdfReader(bytes.NewReader(srcData))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+
+		w := NewPdfWriter()
+		err = w.AddReaderPageRangeWithOptions(reader, 1, 1, PageCopyOptions{NormalizeRotation: true})
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		out, err := writePdfToBytes(&w)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+
+		normalized, err := NewPdfReader(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if rotation, err := normalized.GetPageRotation(1); err != nil || rotation != 0 {
+			t.Errorf("Rotate %d: rotation %d (%v)", test.rotate, rotation, err)
+		}
+		mediaBox, _, err := normalized.GetPageMediaBoxSource(1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if *mediaBox != test.mediaBox {
+			t.Errorf("Rotate %d: MediaBox %+v", test.rotate, *mediaBox)
+		}
+		text, err := normalized.ExtractPageText(1)
+		if err != nil || text != "Rotated" {
+			t.Errorf("Rotate %d: text %q (%v)", test.rotate, text, err)
+		}
+
+		// The cm transform comes first and places the text as shown on
+		// the rotated page.
+		contents, err := normalized.GetPageContents(1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		operations, err := NewContentStreamParser(contents).Parse()
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if len(operations) == 0 || operations[0].Operator != "cm" {
+			t.Fatalf("Rotate %d: no cm transform first", test.rotate)
+		}
+		m := PdfMatrix{}
+		for i, operand := range operations[0].Operands {
+			m[i], err = getNumberAsFloat(operand)
+			if err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+		}
+		if x, y := m.Transform(72, 720); x != test.textOrigin[0] || y != test.textOrigin[1] {
+			t.Errorf("Rotate %d: text at (%v, %v)", test.rotate, x, y)
+		}
+	}
+
+	// Pages without rotation are not transformed.
+	w := NewPdfWriter()
+	err := w.AddReaderPageRangeWithOptions(loadMinimalReader(t), 1, 1, PageCopyOptions{NormalizeRotation: true})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if bytes.Contains(out, []byte(" cm")) {
+		t.Errorf("Unrotated page transformed")
+	}
+}
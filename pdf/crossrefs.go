@@ -65,7 +65,7 @@ func (this *PdfParser) lookupObjectViaOS(sobjNumber int, objNum int) (PdfObject,
 		}
 
 		if this.crypter != nil && !this.crypter.isDecrypted(so) {
-			return nil, errors.New("Need to decrypt the stream !")
+			return nil, wrapError(ErrEncrypted, "Need to decrypt the stream !")
 		}
 
 		sod := so.PdfObjectDictionary
@@ -227,6 +227,9 @@ func (this *PdfParser) lookupByNumber(objNumber int, attemptRepairs bool) (PdfOb
 	}
 
 	log.Debug("Lookup obj number %d", objNumber)
+	if err := this.countObject(); err != nil {
+		return nil, false, err
+	}
 	if xref.xtype == XREF_TABLE_ENTRY {
 		log.Debug("xrefobj obj num %d", xref.objectNumber)
 		log.Debug("xrefobj gen %d", xref.generation)
@@ -269,7 +272,7 @@ func (this *PdfParser) lookupByNumber(objNumber int, attemptRepairs bool) (PdfOb
 
 		if xref.osObjNumber == objNumber {
 			log.Error("Circular reference!?!")
-			return nil, true, errors.New("Xref circular reference")
+			return nil, true, wrapError(ErrCorruptXref, "Xref circular reference")
 		}
 		_, exists := this.xrefs[xref.osObjNumber]
 		if exists {
@@ -288,10 +291,10 @@ func (this *PdfParser) lookupByNumber(objNumber int, attemptRepairs bool) (PdfOb
 			return optr, true, nil
 		} else {
 			log.Debug("?? Belongs to a non-cross referenced object ...!")
-			return nil, true, errors.New("OS belongs to a non cross referenced object")
+			return nil, true, wrapError(ErrCorruptXref, "OS belongs to a non cross referenced object")
 		}
 	}
-	return nil, false, errors.New("Unknown xref type")
+	return nil, false, wrapError(ErrCorruptXref, "Unknown xref type")
 }
 
 // LookupByReference
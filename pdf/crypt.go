@@ -41,14 +41,18 @@ type PdfCrypt struct {
 	stringFilter string
 }
 
+// Access permissions of an encrypted document, the user access
+// permission bits of the P entry in the encryption dictionary (Table 22).
 type AccessPermissions struct {
-	Printing          bool
-	Modify            bool
-	ExtractGraphics   bool
-	Annotate          bool
-	FillForms         bool
-	DisabilityExtract bool // not clear what this means!
-	RotateInsert      bool
+	Printing          bool // Bit 3: print the document.
+	Modify            bool // Bit 4: modify the contents.
+	ExtractGraphics   bool // Bit 5: copy or extract text and graphics.
+	Annotate          bool // Bit 6: add or modify annotations, fill forms.
+	FillForms         bool // Bit 9: fill in form fields.
+	DisabilityExtract bool // Bit 10: extract text and graphics for accessibility.
+	RotateInsert      bool // Bit 11: assemble (insert, rotate, delete pages).
+	// Bit 12: print at full quality.  Printing is limited to a low level
+	// representation when not set.
 	LimitPrintQuality bool
 }
 
@@ -173,7 +177,7 @@ func PdfCryptMakeNew(ed, trailer *PdfObjectDictionary) (PdfCrypt, error) {
 	}
 	crypter.Filter = string(*filter)
 
-	subfilter, ok := (*ed)["SubFilter"].(*PdfObjectString)
+	subfilter, ok := asString((*ed)["SubFilter"])
 	if ok {
 		crypter.Subfilter = string(*subfilter)
 		log.Debug("Using subfilter %s", subfilter)
@@ -218,7 +222,7 @@ func PdfCryptMakeNew(ed, trailer *PdfObjectDictionary) (PdfCrypt, error) {
 	}
 	crypter.R = int(*R)
 
-	O, ok := (*ed)["O"].(*PdfObjectString)
+	O, ok := asString((*ed)["O"])
 	if !ok {
 		return crypter, errors.New("Encrypt dictionary missing O")
 	}
@@ -227,7 +231,7 @@ func PdfCryptMakeNew(ed, trailer *PdfObjectDictionary) (PdfCrypt, error) {
 	}
 	crypter.O = []byte(*O)
 
-	U, ok := (*ed)["U"].(*PdfObjectString)
+	U, ok := asString((*ed)["U"])
 	if !ok {
 		return crypter, errors.New("Encrypt dictionary missing U")
 	}
@@ -255,7 +259,7 @@ func PdfCryptMakeNew(ed, trailer *PdfObjectDictionary) (PdfCrypt, error) {
 	id0 := PdfObjectString("")
 	if idArray, ok := (*trailer)["ID"].(*PdfObjectArray); ok {
 		log.Debug("Trailer ID array missing!")
-		id0obj, ok := (*idArray)[0].(*PdfObjectString)
+		id0obj, ok := asString((*idArray)[0])
 		if !ok {
 			return crypter, errors.New("Invalid trailer ID")
 		}
@@ -266,6 +270,21 @@ func PdfCryptMakeNew(ed, trailer *PdfObjectDictionary) (PdfCrypt, error) {
 	return crypter, nil
 }
 
+// Permissions with all access granted.
+func fullAccessPermissions() AccessPermissions {
+	return AccessPermissions{
+		Printing:          true,
+		Modify:            true,
+		ExtractGraphics:   true,
+		Annotate:          true,
+		FillForms:         true,
+		DisabilityExtract: true,
+		RotateInsert:      true,
+		LimitPrintQuality: true,
+	}
+}
+
+// Unpack the access permissions from the P value.
 func (this *PdfCrypt) GetAccessPermissions() AccessPermissions {
 	perms := AccessPermissions{}
 
@@ -297,8 +316,11 @@ func (this *PdfCrypt) GetAccessPermissions() AccessPermissions {
 	return perms
 }
 
+// Pack the permissions to the P value.  The reserved bits 7-8 and 13-32
+// are set and bits 1-2 cleared, so that no permissions gives -3904 and
+// all permissions -4.
 func (perms AccessPermissions) GetP() int32 {
-	var P int32 = 0
+	var P int32 = -3904 // 0xFFFFF0C0: reserved bits.
 
 	if perms.Printing { // bit 3
 		P |= (1 << 2)
@@ -316,7 +338,7 @@ func (perms AccessPermissions) GetP() int32 {
 		P |= (1 << 8) // bit 9
 	}
 	if perms.DisabilityExtract {
-		P |= (1 << 9) // bit 10, what means?
+		P |= (1 << 9) // bit 10
 	}
 	if perms.RotateInsert {
 		P |= (1 << 10) // bit 11
@@ -350,7 +372,7 @@ func (this *PdfCrypt) authenticate(password []byte) (bool, error) {
 	// May not be necessary if only want to get all contents.
 	// (user pass needs to be known or empty).
 	log.Debug("Debugging authentication - owner pass")
-	authenticated, err = this.alg7(password, password)
+	authenticated, err = this.alg7(password)
 	if err != nil {
 		return false, err
 	}
@@ -520,15 +542,15 @@ func (this *PdfCrypt) Decrypt(obj PdfObject, parentObjNum, parentGenNum int64) e
 		return nil
 	}
 
-	if io, isIndirect := obj.(*PdfIndirectObject); isIndirect {
-		this.decryptedObjects[io] = true
+	if ind, isIndirect := obj.(*PdfIndirectObject); isIndirect {
+		this.decryptedObjects[ind] = true
 
-		log.Debug("Decrypting indirect %d %d obj!", io.ObjectNumber, io.GenerationNumber)
+		log.Debug("Decrypting indirect %d %d obj!", ind.ObjectNumber, ind.GenerationNumber)
 
-		objNum := (*io).ObjectNumber
-		genNum := (*io).GenerationNumber
+		objNum := (*ind).ObjectNumber
+		genNum := (*ind).GenerationNumber
 
-		err := this.Decrypt(io.PdfObject, objNum, genNum)
+		err := this.Decrypt(ind.PdfObject, objNum, genNum)
 		if err != nil {
 			return err
 		}
@@ -543,6 +565,11 @@ func (this *PdfCrypt) Decrypt(obj PdfObject, parentObjNum, parentGenNum int64) e
 		genNum := (*so).GenerationNumber
 		log.Debug("Decrypting stream %d %d !", objNum, genNum)
 
+		if this.isUnencryptedMetadata(so) {
+			log.Debug("Metadata stream not encrypted")
+			return nil
+		}
+
 		// TODO: Check for crypt filter (V4).
 		// The Crypt filter shall be the first filter in the Filter array entry.
 
@@ -600,7 +627,7 @@ func (this *PdfCrypt) Decrypt(obj PdfObject, parentObjNum, parentGenNum int64) e
 
 		return nil
 	}
-	if s, isString := obj.(*PdfObjectString); isString {
+	if s, isString := asString(obj); isString {
 		log.Debug("Decrypting string!")
 
 		stringFilter := "Default"
@@ -662,6 +689,16 @@ func (this *PdfCrypt) Decrypt(obj PdfObject, parentObjNum, parentGenNum int64) e
 	return nil
 }
 
+// Check if the stream is a metadata stream that is left unencrypted
+// (EncryptMetadata false).
+func (this *PdfCrypt) isUnencryptedMetadata(so *PdfObjectStream) bool {
+	if this.encryptMetadata {
+		return false
+	}
+	t, ok := (*so.PdfObjectDictionary)["Type"].(*PdfObjectName)
+	return ok && *t == "Metadata"
+}
+
 // Check if object has already been processed.
 func (this *PdfCrypt) isEncrypted(obj PdfObject) bool {
 	_, ok := this.encryptedObjects[obj]
@@ -755,15 +792,16 @@ func (this *PdfCrypt) Encrypt(obj PdfObject, parentObjNum, parentGenNum int64) e
 		return nil
 	}
 
-	if io, isIndirect := obj.(*PdfIndirectObject); isIndirect {
-		this.encryptedObjects[io] = true
+	if ind, isIndirect := obj.(*PdfIndirectObject); isIndirect {
+		this.encryptedObjects[ind] = true
 
-		log.Debug("Encrypting indirect %d %d obj!", io.ObjectNumber, io.GenerationNumber)
+		log.Debug("Encrypting indirect %d %d obj!", ind.ObjectNumber, ind.GenerationNumber)
 
-		objNum := (*io).ObjectNumber
-		genNum := (*io).GenerationNumber
+		objNum := (*ind).ObjectNumber
+		genNum := (*ind).GenerationNumber
 
-		err := this.Encrypt(io.PdfObject, objNum, genNum)
+		ind.PdfObject = this.hexStringIfEncrypted(ind.PdfObject)
+		err := this.Encrypt(ind.PdfObject, objNum, genNum)
 		if err != nil {
 			return err
 		}
@@ -777,6 +815,11 @@ func (this *PdfCrypt) Encrypt(obj PdfObject, parentObjNum, parentGenNum int64) e
 		genNum := (*so).GenerationNumber
 		log.Debug("Encrypting stream %d %d !", objNum, genNum)
 
+		if this.isUnencryptedMetadata(so) {
+			log.Debug("Metadata stream not encrypted")
+			return nil
+		}
+
 		// TODO: Check for crypt filter (V4).
 		// The Crypt filter shall be the first filter in the Filter array entry.
 
@@ -837,40 +880,26 @@ func (this *PdfCrypt) Encrypt(obj PdfObject, parentObjNum, parentGenNum int64) e
 		return nil
 	}
 	if s, isString := obj.(*PdfObjectString); isString {
-		log.Debug("Encrypting string!")
-
-		stringFilter := "Default"
-		if this.V >= 4 {
-			log.Debug("with %s filter", this.stringFilter)
-			if this.stringFilter == "Identity" {
-				// Identity: pass unchanged: No action.
-				return nil
-			} else {
-				stringFilter = this.stringFilter
-			}
-		}
-
-		key, err := this.makeKey(stringFilter, uint32(parentObjNum), uint32(parentGenNum), this.encryptionKey)
+		encrypted, err := this.encryptString(string(*s), parentObjNum, parentGenNum)
 		if err != nil {
 			return err
 		}
-
-		encrypted := make([]byte, len(*s))
-		for i := 0; i < len(*s); i++ {
-			encrypted[i] = (*s)[i]
-		}
-		log.Debug("Encrypt string: %s : % x", encrypted, encrypted)
-		encrypted, err = this.encryptBytes(encrypted, stringFilter, key)
+		*s = PdfObjectString(encrypted)
+		return nil
+	}
+	if s, isString := obj.(*PdfObjectHexString); isString {
+		encrypted, err := this.encryptString(string(*s), parentObjNum, parentGenNum)
 		if err != nil {
 			return err
 		}
-		*s = PdfObjectString(encrypted)
-
+		*s = PdfObjectHexString(encrypted)
 		return nil
 	}
 
 	if a, isArray := obj.(*PdfObjectArray); isArray {
-		for _, o := range *a {
+		for idx, o := range *a {
+			o = this.hexStringIfEncrypted(o)
+			(*a)[idx] = o
 			err := this.Encrypt(o, parentObjNum, parentGenNum)
 			if err != nil {
 				return err
@@ -884,6 +913,8 @@ func (this *PdfCrypt) Encrypt(obj PdfObject, parentObjNum, parentGenNum int64) e
 			// How can we avoid this check, i.e. implement a more smart
 			// traversal system?
 			if string(keyidx) != "Parent" && string(keyidx) != "Prev" && string(keyidx) != "Last" { // Check not needed?
+				o = this.hexStringIfEncrypted(o)
+				(*d)[keyidx] = o
 				err := this.Encrypt(o, parentObjNum, parentGenNum)
 				if err != nil {
 					return err
@@ -896,6 +927,47 @@ func (this *PdfCrypt) Encrypt(obj PdfObject, parentObjNum, parentGenNum int64) e
 	return nil
 }
 
+// Encrypt a string with the string filter.  Unchanged with the Identity
+// filter.
+func (this *PdfCrypt) encryptString(s string, parentObjNum, parentGenNum int64) (string, error) {
+	log.Debug("Encrypting string!")
+
+	stringFilter := "Default"
+	if this.V >= 4 {
+		log.Debug("with %s filter", this.stringFilter)
+		if this.stringFilter == "Identity" {
+			// Identity: pass unchanged: No action.
+			return s, nil
+		} else {
+			stringFilter = this.stringFilter
+		}
+	}
+
+	key, err := this.makeKey(stringFilter, uint32(parentObjNum), uint32(parentGenNum), this.encryptionKey)
+	if err != nil {
+		return "", err
+	}
+
+	encrypted := []byte(s)
+	log.Debug("Encrypt string: %s : % x", encrypted, encrypted)
+	encrypted, err = this.encryptBytes(encrypted, stringFilter, key)
+	if err != nil {
+		return "", err
+	}
+	return string(encrypted), nil
+}
+
+// Get a string to be encrypted as a hex string, as the encrypted data is
+// binary.  Other objects and strings left unencrypted (Identity filter)
+// are returned unchanged.
+func (this *PdfCrypt) hexStringIfEncrypted(obj PdfObject) PdfObject {
+	s, isString := obj.(*PdfObjectString)
+	if !isString || (this.V >= 4 && this.stringFilter == "Identity") {
+		return obj
+	}
+	return makeHexString(string(*s))
+}
+
 // Algorithm 2: Computing an encryption key.
 func (this *PdfCrypt) alg2(pass []byte) []byte {
 	log.Debug("Alg2")
@@ -1077,10 +1149,8 @@ func (this *PdfCrypt) alg5(upass []byte) (PdfObjectString, []byte, error) {
 	// Append 16 bytes of arbitrary padding to the output from the final
 	// invocation of the RC4 function and store the 32-byte result as
 	// the value of the U entry in the encryption dictionary.
-	_, err = rand.Read(bb[16:32])
-	if err != nil {
-		return U, ekey, errors.New("Failed to gen rand number")
-	}
+	// Zero padding is used (bb is zero initialized), keeping the output
+	// reproducible for a given document ID.
 
 	U = PdfObjectString(bb)
 	return U, ekey, nil
@@ -1121,8 +1191,10 @@ func (this *PdfCrypt) alg6(upass []byte) (bool, error) {
 	}
 }
 
-// Algorithm 7: Authenticating the owner password.
-func (this *PdfCrypt) alg7(upass, opass []byte) (bool, error) {
+// Algorithm 7: Authenticating the owner password.  Decrypts the user
+// password from O with the owner password and authenticates it (algorithm
+// 6), which also sets the encryption key.
+func (this *PdfCrypt) alg7(opass []byte) (bool, error) {
 	encKey := this.alg3_key(opass)
 
 	decrypted := make([]byte, len(this.O))
@@ -1133,27 +1205,21 @@ func (this *PdfCrypt) alg7(upass, opass []byte) (bool, error) {
 		}
 		ciph.XORKeyStream(decrypted, this.O)
 	} else if this.R >= 3 {
-		s := this.O
-		newKey := encKey
-		for i := 0; i < 20; i++ {
+		copy(decrypted, this.O)
+		newKey := make([]byte, len(encKey))
+		for i := 19; i >= 0; i-- {
 			for j := 0; j < len(encKey); j++ {
-				newKey[j] ^= byte(i)
+				newKey[j] = encKey[j] ^ byte(i)
 			}
 			ciph, err := rc4.NewCipher(newKey)
 			if err != nil {
 				return false, errors.New("Failed cipher")
 			}
-			ciph.XORKeyStream(decrypted, s)
-			s = decrypted
+			ciph.XORKeyStream(decrypted, decrypted)
 		}
 	} else {
 		return false, errors.New("invalid R")
 	}
 
-	if string(decrypted) == string(upass) {
-		// Correct.
-		return true, nil
-	} else {
-		return false, nil
-	}
+	return this.alg6(decrypted)
 }
@@ -205,3 +205,31 @@ func TestDecryption1(t *testing.T) {
 		return
 	}
 }
+
+func TestAccessPermissionsP(t *testing.T) {
+	// Values as in the spec: reserved bits 7-8 and 13-32 set.
+	if P := (AccessPermissions{}).GetP(); P != -3904 {
+		t.Errorf("No permissions P = %d (expected -3904)", P)
+	}
+	if P := fullAccessPermissions().GetP(); P != -4 {
+		t.Errorf("All permissions P = %d (expected -4)", P)
+	}
+	perms := AccessPermissions{Printing: true, LimitPrintQuality: true}
+	if P := perms.GetP(); P != -1852 {
+		t.Errorf("Printing P = %d (expected -1852)", P)
+	}
+
+	// Unpacking.
+	crypter := PdfCrypt{P: -1852}
+	if crypter.GetAccessPermissions() != perms {
+		t.Errorf("Unexpected permissions %+v", crypter.GetAccessPermissions())
+	}
+	crypter.P = -3904
+	if crypter.GetAccessPermissions() != (AccessPermissions{}) {
+		t.Errorf("Unexpected permissions %+v", crypter.GetAccessPermissions())
+	}
+	crypter.P = int(fullAccessPermissions().GetP())
+	if crypter.GetAccessPermissions() != fullAccessPermissions() {
+		t.Errorf("Unexpected permissions %+v", crypter.GetAccessPermissions())
+	}
+}
@@ -0,0 +1,237 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Explicit and named destinations (12.3.2) and the document open action.
+
+package pdf
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// How a destination page is displayed.
+type FitMode struct {
+	// XYZ, Fit, FitH, FitV, FitR, FitB, FitBH or FitBV.
+	Type PdfObjectName
+	// Parameters of the type: left, top and zoom for XYZ, top for FitH
+	// and FitBH, left for FitV and FitBV, left, bottom, right and top for
+	// FitR.  Null values are read as 0.
+	Params []float64
+}
+
+// Number of parameters for each fit type.
+var fitModeParams = map[PdfObjectName]int{
+	"XYZ": 3, "Fit": 0, "FitH": 1, "FitV": 1, "FitR": 4, "FitB": 0, "FitBH": 1, "FitBV": 1,
+}
+
+// Fit the whole page in the window.
+func FitPage() FitMode {
+	return FitMode{Type: "Fit"}
+}
+
+// Fit the page width in the window, with top at the top of the window.
+func FitHorizontal(top float64) FitMode {
+	return FitMode{Type: "FitH", Params: []float64{top}}
+}
+
+// Position (left, top) at the upper left corner of the window, magnified
+// by zoom (0 keeps the current zoom).
+func FitXYZ(left, top, zoom float64) FitMode {
+	return FitMode{Type: "XYZ", Params: []float64{left, top, zoom}}
+}
+
+func (this FitMode) validate() error {
+	num, ok := fitModeParams[this.Type]
+	if !ok {
+		return fmt.Errorf("Invalid fit type (%s)", this.Type)
+	}
+	if len(this.Params) != num {
+		return fmt.Errorf("Fit type %s requires %d parameters (%d)", this.Type, num, len(this.Params))
+	}
+	return nil
+}
+
+// Make an explicit destination array [page /Type params...].
+func (this FitMode) makeDestination(page PdfObject) (*PdfObjectArray, error) {
+	if err := this.validate(); err != nil {
+		return nil, err
+	}
+	dest := PdfObjectArray{page, makeName(string(this.Type))}
+	for _, val := range this.Params {
+		dest = append(dest, makeReal(val))
+	}
+	return &dest, nil
+}
+
+// Set the open action of the document, going to the specified page (from
+// 1) displayed with the fit mode.
+func (this *PdfWriter) SetOpenAction(pageNumber int, fit FitMode) error {
+	page, err := this.getPage(pageNumber)
+	if err != nil {
+		return err
+	}
+	dest, err := fit.makeDestination(page)
+	if err != nil {
+		return err
+	}
+	(*this.catalog)["OpenAction"] = dest
+	return nil
+}
+
+// Add a named destination going to the specified page (from 1) displayed
+// with the fit mode, for links and outlines referring to the destination
+// by name.  Written in the catalog Dests dictionary, see
+// SetNamedDestinationsNameTree.
+func (this *PdfWriter) AddNamedDestination(name string, pageNumber int, fit FitMode) error {
+	if name == "" {
+		return errors.New("Destination name missing")
+	}
+	if _, exists := this.namedDests[name]; exists {
+		log.Error("Named destination %s already exists", name)
+		return errors.New("Named destination already exists")
+	}
+	page, err := this.getPage(pageNumber)
+	if err != nil {
+		return err
+	}
+	dest, err := fit.makeDestination(page)
+	if err != nil {
+		return err
+	}
+	if this.namedDests == nil {
+		this.namedDests = map[string]*PdfObjectArray{}
+	}
+	this.namedDests[name] = dest
+	this.updateNamedDestinations()
+	return nil
+}
+
+// Write the named destinations in the Dests name tree of the catalog
+// Names dictionary (PDF 1.2) rather than the catalog Dests dictionary.
+func (this *PdfWriter) SetNamedDestinationsNameTree(enable bool) {
+	this.destsNameTree = enable
+	this.updateNamedDestinations()
+}
+
+// Rebuild the catalog Dests dictionary or the Dests name tree, keys in
+// sorted order.
+func (this *PdfWriter) updateNamedDestinations() {
+	namesDict, hasNames := (*this.catalog)["Names"].(*PdfObjectDictionary)
+	if len(this.namedDests) == 0 {
+		return
+	}
+
+	if !this.destsNameTree {
+		dests := PdfObjectDictionary{}
+		for name, dest := range this.namedDests {
+			dests[PdfObjectName(name)] = dest
+		}
+		(*this.catalog)["Dests"] = &dests
+		if hasNames {
+			delete(*namesDict, "Dests")
+		}
+		return
+	}
+
+	keys := []string{}
+	for key := range this.namedDests {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	names := PdfObjectArray{}
+	for _, key := range keys {
+		names = append(names, makeString(key), this.namedDests[key])
+	}
+	tree := PdfObjectDictionary{}
+	tree["Names"] = &names
+
+	if !hasNames {
+		namesDict = &PdfObjectDictionary{}
+		(*this.catalog)["Names"] = namesDict
+	}
+	(*namesDict)["Dests"] = &tree
+	delete(*this.catalog, "Dests")
+}
+
+// Get the open action of the document.  A destination is returned as a
+// GoTo action.  Returns nil if the document has no open action.
+func (this *PdfReader) GetOpenAction() (*PdfAction, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+
+	obj, err := this.traceToDirectObject((*this.catalog)["OpenAction"])
+	if err != nil {
+		return nil, err
+	}
+	switch t := obj.(type) {
+	case nil:
+		return nil, nil
+	case *PdfObjectArray:
+		return &PdfAction{Type: "GoTo", Dest: t}, nil
+	case *PdfObjectDictionary:
+		return this.resolveAction(t)
+	}
+	log.Error("Invalid OpenAction (%T)", obj)
+	return nil, errors.New("Invalid OpenAction")
+}
+
+// Get the page number (from 1) and the fit mode of an explicit
+// destination within the document.
+func (this *PdfReader) GetDestinationPage(dest *PdfObjectArray) (int, FitMode, error) {
+	fit := FitMode{}
+	if len(*dest) < 2 {
+		return 0, fit, errors.New("Invalid destination")
+	}
+
+	pageObj := (*dest)[0]
+	if ref, isRef := pageObj.(*PdfObjectReference); isRef {
+		var err error
+		pageObj, _, err = this.resolveReference(ref)
+		if err != nil {
+			return 0, fit, err
+		}
+	}
+	page, ok := pageObj.(*PdfIndirectObject)
+	if !ok {
+		log.Error("Destination page not an indirect object (%T)", pageObj)
+		return 0, fit, errors.New("Destination page not an indirect object")
+	}
+
+	pageNumber := 0
+	for i := range this.pageList {
+		candidate, err := this.GetPage(i + 1)
+		if err != nil {
+			return 0, fit, err
+		}
+		if candidate == page {
+			pageNumber = i + 1
+			break
+		}
+	}
+	if pageNumber == 0 {
+		return 0, fit, errors.New("Destination page not found")
+	}
+
+	fitType, ok := (*dest)[1].(*PdfObjectName)
+	if !ok {
+		return 0, fit, errors.New("Invalid destination fit type")
+	}
+	fit.Type = *fitType
+	for _, obj := range (*dest)[2:] {
+		if _, isNull := obj.(*PdfObjectNull); isNull {
+			fit.Params = append(fit.Params, 0)
+			continue
+		}
+		val, err := getNumberAsFloat(obj)
+		if err != nil {
+			return 0, fit, err
+		}
+		fit.Params = append(fit.Params, val)
+	}
+	return pageNumber, fit, nil
+}
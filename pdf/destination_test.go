@@ -0,0 +1,145 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestOpenActionRoundTrip(t *testing.T) {
+	w := NewPdfWriter()
+	for i := 0; i < 2; i++ {
+		err := w.AddPage(loadMinimalPage(t))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+
+	if err := w.SetOpenAction(3, FitPage()); err == nil {
+		t.Errorf("Invalid page number should fail")
+	}
+	if err := w.SetOpenAction(1, FitMode{Type: "FitH"}); err == nil {
+		t.Errorf("Missing fit parameters should fail")
+	}
+	fit := FitXYZ(10, 700, 1.5)
+	err := w.SetOpenAction(2, fit)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error reading output: %v", err)
+	}
+	action, err := reader.GetOpenAction()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if action == nil || action.Type != "GoTo" || action.Dest == nil {
+		t.Fatalf("Invalid open action %+v", action)
+	}
+	pageNumber, readFit, err := reader.GetDestinationPage(action.Dest)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if pageNumber != 2 || !reflect.DeepEqual(readFit, fit) {
+		t.Errorf("Open action page %d fit %+v (expected 2 %+v)", pageNumber, readFit, fit)
+	}
+
+	action, err = loadMinimalReader(t).GetOpenAction()
+	if err != nil || action != nil {
+		t.Errorf("Expected no open action (%v, %v)", action, err)
+	}
+}
+
+func TestGetOpenActionDictionary(t *testing.T) {
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R /OpenAction << /S /GoTo /D [3 0 R /FitH null] >> >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+	})
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	action, err := reader.GetOpenAction()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if action == nil || action.Type != "GoTo" {
+		t.Fatalf("Invalid open action %+v", action)
+	}
+	pageNumber, fit, err := reader.GetDestinationPage(action.Dest)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if pageNumber != 1 || !reflect.DeepEqual(fit, FitHorizontal(0)) {
+		t.Errorf("Unexpected destination page %d fit %+v", pageNumber, fit)
+	}
+}
+
+func TestAddNamedDestination(t *testing.T) {
+	for _, nameTree := range []bool{false, true} {
+		w := NewPdfWriter()
+		for i := 0; i < 2; i++ {
+			err := w.AddPage(loadMinimalPage(t))
+			if err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+		}
+
+		if err := w.AddNamedDestination("intro", 3, FitPage()); err == nil {
+			t.Errorf("Invalid page number should fail")
+		}
+		if err := w.AddNamedDestination("intro", 1, FitMode{Type: "FitR", Params: []float64{0}}); err == nil {
+			t.Errorf("Invalid fit parameters should fail")
+		}
+		fits := map[string]FitMode{"intro": FitPage(), "chapter 2": FitHorizontal(500)}
+		if err := w.AddNamedDestination("intro", 1, fits["intro"]); err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if err := w.AddNamedDestination("intro", 2, FitPage()); err == nil {
+			t.Errorf("Duplicate name should fail")
+		}
+		w.SetNamedDestinationsNameTree(nameTree)
+		if err := w.AddNamedDestination("chapter 2", 2, fits["chapter 2"]); err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+
+		out, err := writePdfToBytes(&w)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		reader, err := NewPdfReader(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("Error reading output: %v", err)
+		}
+		_, hasDests := (*reader.catalog)["Dests"]
+		_, hasNames := (*reader.catalog)["Names"]
+		if hasDests == nameTree || hasNames != nameTree {
+			t.Errorf("Name tree %v: Dests %v Names %v", nameTree, hasDests, hasNames)
+		}
+		for name, pageNumber := range map[string]int{"intro": 1, "chapter 2": 2} {
+			destObj, err := reader.ResolveNamedDestination(name)
+			if err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+			readPage, readFit, err := reader.GetDestinationPage(destObj.(*PdfObjectArray))
+			if err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+			if readPage != pageNumber || !reflect.DeepEqual(readFit, fits[name]) {
+				t.Errorf("%s: page %d fit %+v (expected %d %+v)", name, readPage, readFit, pageNumber, fits[name])
+			}
+		}
+	}
+}
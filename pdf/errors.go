@@ -0,0 +1,46 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// General errors returned by the reader and the parser, usually wrapped
+// with a more detailed message.  Check with errors.Is.
+var (
+	// The file is encrypted and needs to be decrypted first.
+	ErrEncrypted = errors.New("File need to be decrypted first")
+	// The document catalog or the page tree root is missing or invalid.
+	ErrInvalidCatalog = errors.New("Invalid catalog")
+	// The cross reference table or stream cannot be read.
+	ErrCorruptXref = errors.New("Corrupt xref table")
+	// The stream uses a filter that is not supported.
+	ErrUnsupportedFilter = errors.New("Unsupported filter")
+	// A limit of the parser options was exceeded.
+	ErrLimitExceeded = errors.New("Parser limit exceeded")
+)
+
+// An error with a detailed message, wrapping one of the general errors.
+type pdfError struct {
+	err error
+	msg string
+}
+
+func (this *pdfError) Error() string {
+	return this.msg
+}
+
+func (this *pdfError) Unwrap() error {
+	return this.err
+}
+
+// Wrap an error, usually one of the general errors, with a detailed
+// message which replaces the message of the wrapped error.
+func wrapError(err error, format string, args ...interface{}) error {
+	return &pdfError{err: err, msg: fmt.Sprintf(format, args...)}
+}
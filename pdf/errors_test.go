@@ -0,0 +1,44 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestReaderErrorTypes(t *testing.T) {
+	encrypted := writeEncryptedMinimal(t, []byte("user"), []byte("owner"), nil)
+	reader, err := NewPdfReader(bytes.NewReader(encrypted))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	_, err = reader.GetPage(1)
+	if !errors.Is(err, ErrEncrypted) {
+		t.Errorf("Expected ErrEncrypted (%v)", err)
+	}
+
+	data := makePdfFile([]string{
+		"[/Not /A /Catalog]",
+	})
+	_, err = NewPdfReader(bytes.NewReader(data))
+	if !errors.Is(err, ErrInvalidCatalog) || err.Error() != "Invalid catalog" {
+		t.Errorf("Expected ErrInvalidCatalog (%v)", err)
+	}
+
+	_, err = NewPdfReader(bytes.NewReader([]byte("%PDF-1.4\nnot a pdf\n%%EOF\n")))
+	if !errors.Is(err, ErrCorruptXref) || err.Error() != "Startxref not found" {
+		t.Errorf("Expected ErrCorruptXref (%v)", err)
+	}
+
+	stream := PdfObjectStream{}
+	stream.PdfObjectDictionary = &PdfObjectDictionary{"Filter": makeName("JBIG9Decode")}
+	_, err = (&PdfParser{}).decodeStream(&stream)
+	if !errors.Is(err, ErrUnsupportedFilter) || errors.Is(err, ErrCorruptXref) {
+		t.Errorf("Expected ErrUnsupportedFilter (%v)", err)
+	}
+}
@@ -0,0 +1,370 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Font resources for writing text.
+
+package pdf
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"sort"
+	"unicode/utf16"
+)
+
+// The standard 14 Type 1 fonts (9.6.2.2), available without embedding.
+var standardFonts = map[string]bool{
+	"Courier":               true,
+	"Courier-Bold":          true,
+	"Courier-Oblique":       true,
+	"Courier-BoldOblique":   true,
+	"Helvetica":             true,
+	"Helvetica-Bold":        true,
+	"Helvetica-Oblique":     true,
+	"Helvetica-BoldOblique": true,
+	"Times-Roman":           true,
+	"Times-Bold":            true,
+	"Times-Italic":          true,
+	"Times-BoldItalic":      true,
+	"Symbol":                true,
+	"ZapfDingbats":          true,
+}
+
+// A font registered with the writer.  The font object is shared by the
+// pages using it, under the same resource name.
+type writerFont struct {
+	name PdfObjectName
+	// Identifies the font for reusing the registration, e.g. the base
+	// font name of a standard font.
+	key string
+	obj *PdfIndirectObject
+	// Embedded TrueType font, nil for standard fonts.
+	trueType *trueTypeFont
+}
+
+// Get a registered font by its key.  Returns nil if not registered.
+func (this *PdfWriter) findFont(key string) *writerFont {
+	for _, font := range this.fonts {
+		if font.key == key {
+			return font
+		}
+	}
+	return nil
+}
+
+// Check whether a Font resource entry is the font object, directly or by
+// reference.
+func (this *writerFont) isObject(obj PdfObject) bool {
+	switch t := obj.(type) {
+	case *PdfIndirectObject:
+		return t == this.obj || t.PdfObject == this.obj.PdfObject
+	case *PdfObjectReference:
+		return this.obj.ObjectNumber != 0 && t.ObjectNumber == this.obj.ObjectNumber &&
+			t.GenerationNumber == this.obj.GenerationNumber
+	}
+	return false
+}
+
+// Get the Font resources of a page.  Returns an empty dictionary if none.
+func getPageFonts(pDict *PdfObjectDictionary) PdfObjectDictionary {
+	if resources, err := getPageResourcesForUpdate(pDict); err == nil {
+		switch t := (*resources)["Font"].(type) {
+		case *PdfObjectDictionary:
+			return *t
+		case *PdfIndirectObject:
+			if dict, ok := t.PdfObject.(*PdfObjectDictionary); ok {
+				return *dict
+			}
+		}
+	}
+	return PdfObjectDictionary{}
+}
+
+// Register a font object under a resource name that is not used by the
+// page or the other registered fonts.
+func (this *PdfWriter) registerFont(key string, obj *PdfIndirectObject, page *PdfIndirectObject) (*writerFont, error) {
+	pDict, ok := page.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return nil, errors.New("Page not a dictionary")
+	}
+	pageFonts := getPageFonts(pDict)
+
+	used := map[PdfObjectName]bool{}
+	for _, font := range this.fonts {
+		used[font.name] = true
+	}
+	for i := len(this.fonts) + 1; ; i++ {
+		name := PdfObjectName(fmt.Sprintf("F%d", i))
+		if _, onPage := pageFonts[name]; !onPage && !used[name] {
+			font := &writerFont{name: name, key: key, obj: obj}
+			this.fonts = append(this.fonts, font)
+			return font, nil
+		}
+	}
+}
+
+// Add a registered font to the Font resources of a page.  A page already
+// having the font object under the font name is left as is.
+func (this *PdfWriter) addPageFont(page *PdfIndirectObject, font *writerFont) error {
+	pDict, ok := page.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return errors.New("Page not a dictionary")
+	}
+	resources, err := getPageResourcesForUpdate(pDict)
+	if err != nil {
+		return err
+	}
+	if !font.isObject(getPageFonts(pDict)[font.name]) {
+		fonts := PdfObjectDictionary{}
+		fonts[font.name] = font.obj
+		fontResources := PdfObjectDictionary{}
+		fontResources["Font"] = &fonts
+		err = mergeResources(resources, &fontResources)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Pages not yet added get the objects added with the page.
+	if this.hasObject(page) {
+		return this.addObjects(font.obj)
+	}
+	return nil
+}
+
+// Add one of the standard 14 fonts, e.g. Helvetica or Times-Bold, to the
+// Font resources of a page.  Returns the resource name of the font for
+// use in content streams.  A font is registered once and shared by the
+// pages it is added to.  Text fonts use WinAnsiEncoding, Symbol and
+// ZapfDingbats their built-in encodings.
+func (this *PdfWriter) AddStandardFont(page *PdfIndirectObject, name string) (PdfObjectName, error) {
+	if !standardFonts[name] {
+		log.Error("Not a standard font (%s)", name)
+		return "", fmt.Errorf("Not a standard font (%s)", name)
+	}
+	if page == nil {
+		return "", errors.New("Page required")
+	}
+
+	font := this.findFont(name)
+	if font == nil {
+		fontDict := PdfObjectDictionary{}
+		fontDict["Type"] = makeName("Font")
+		fontDict["Subtype"] = makeName("Type1")
+		fontDict["BaseFont"] = makeName(name)
+		if name != "Symbol" && name != "ZapfDingbats" {
+			fontDict["Encoding"] = makeName("WinAnsiEncoding")
+		}
+		fontObj := PdfIndirectObject{}
+		fontObj.PdfObject = &fontDict
+
+		var err error
+		font, err = this.registerFont(name, &fontObj, page)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err := this.addPageFont(page, font)
+	if err != nil {
+		return "", err
+	}
+	return font.name, nil
+}
+
+// Embed a TrueType font and add it to the Font resources of a page.  The
+// font is written as a composite font (Type0) with the glyph IDs as
+// character codes (Identity-H) and a ToUnicode CMap for text extraction.
+// Returns the resource name of the font, text shown with the font needs
+// to be encoded with EncodeText.
+func (this *PdfWriter) EmbedTrueTypeFont(page *PdfIndirectObject, fontData []byte) (PdfObjectName, error) {
+	if page == nil {
+		return "", errors.New("Page required")
+	}
+
+	key := fmt.Sprintf("TrueType %x", md5.Sum(fontData))
+	font := this.findFont(key)
+	if font == nil {
+		ttf, err := parseTrueType(fontData)
+		if err != nil {
+			return "", err
+		}
+		font, err = this.registerFont(key, makeTrueTypeFontObject(ttf, fontData), page)
+		if err != nil {
+			return "", err
+		}
+		font.trueType = ttf
+	}
+
+	err := this.addPageFont(page, font)
+	if err != nil {
+		return "", err
+	}
+	return font.name, nil
+}
+
+// Make the Type0 font object for an embedded TrueType font.
+func makeTrueTypeFontObject(ttf *trueTypeFont, fontData []byte) *PdfIndirectObject {
+	fontFile := makeContentStream(fontData)
+	(*fontFile.PdfObjectDictionary)["Length1"] = makeInteger(int64(len(fontData)))
+
+	bbox := PdfObjectArray{}
+	for _, val := range ttf.bbox {
+		bbox = append(bbox, makeInteger(ttf.scale(val)))
+	}
+	descriptor := PdfObjectDictionary{}
+	descriptor["Type"] = makeName("FontDescriptor")
+	descriptor["FontName"] = makeName(ttf.postScriptName)
+	// Nonsymbolic.
+	descriptor["Flags"] = makeInteger(32)
+	descriptor["FontBBox"] = &bbox
+	descriptor["ItalicAngle"] = makeInteger(0)
+	descriptor["Ascent"] = makeInteger(ttf.scale(ttf.ascender))
+	descriptor["Descent"] = makeInteger(ttf.scale(ttf.descender))
+	descriptor["CapHeight"] = makeInteger(ttf.scale(ttf.ascender))
+	descriptor["StemV"] = makeInteger(80)
+	descriptor["FontFile2"] = fontFile
+	descriptorObj := PdfIndirectObject{}
+	descriptorObj.PdfObject = &descriptor
+
+	// Widths of all the glyphs, starting from glyph 0.
+	widths := PdfObjectArray{}
+	for _, width := range ttf.advanceWidths {
+		widths = append(widths, makeInteger(ttf.scale(width)))
+	}
+	systemInfo := PdfObjectDictionary{}
+	systemInfo["Registry"] = makeString("Adobe")
+	systemInfo["Ordering"] = makeString("Identity")
+	systemInfo["Supplement"] = makeInteger(0)
+
+	cidFont := PdfObjectDictionary{}
+	cidFont["Type"] = makeName("Font")
+	cidFont["Subtype"] = makeName("CIDFontType2")
+	cidFont["BaseFont"] = makeName(ttf.postScriptName)
+	cidFont["CIDSystemInfo"] = &systemInfo
+	cidFont["FontDescriptor"] = &descriptorObj
+	cidFont["DW"] = makeInteger(ttf.scale(ttf.advanceWidths[0]))
+	cidFont["W"] = &PdfObjectArray{makeInteger(0), &widths}
+	cidFont["CIDToGIDMap"] = makeName("Identity")
+	cidFontObj := PdfIndirectObject{}
+	cidFontObj.PdfObject = &cidFont
+
+	fontDict := PdfObjectDictionary{}
+	fontDict["Type"] = makeName("Font")
+	fontDict["Subtype"] = makeName("Type0")
+	fontDict["BaseFont"] = makeName(ttf.postScriptName)
+	fontDict["Encoding"] = makeName("Identity-H")
+	fontDict["DescendantFonts"] = &PdfObjectArray{&cidFontObj}
+	fontDict["ToUnicode"] = makeContentStream(makeToUnicodeCMap(ttf.runeToGID))
+	fontObj := PdfIndirectObject{}
+	fontObj.PdfObject = &fontDict
+	return &fontObj
+}
+
+// Make a ToUnicode CMap mapping 2 byte glyph IDs to the characters.  When
+// several characters map to the same glyph, the lowest is used.
+func makeToUnicodeCMap(runeToGID map[rune]int) []byte {
+	gidToRune := map[int]rune{}
+	for r, gid := range runeToGID {
+		if existing, has := gidToRune[gid]; !has || r < existing {
+			gidToRune[gid] = r
+		}
+	}
+	gids := []int{}
+	for gid := range gidToRune {
+		gids = append(gids, gid)
+	}
+	sort.Ints(gids)
+
+	var buf bytes.Buffer
+	buf.WriteString("/CIDInit /ProcSet findresource begin\n")
+	buf.WriteString("12 dict begin\nbegincmap\n")
+	buf.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	buf.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	buf.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	// At most 100 entries per section.
+	for start := 0; start < len(gids); start += 100 {
+		end := start + 100
+		if end > len(gids) {
+			end = len(gids)
+		}
+		buf.WriteString(fmt.Sprintf("%d beginbfchar\n", end-start))
+		for _, gid := range gids[start:end] {
+			buf.WriteString(fmt.Sprintf("<%04X> <", gid))
+			for _, unit := range utf16.Encode([]rune{gidToRune[gid]}) {
+				buf.WriteString(fmt.Sprintf("%04X", unit))
+			}
+			buf.WriteString(">\n")
+		}
+		buf.WriteString("endbfchar\n")
+	}
+	buf.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return buf.Bytes()
+}
+
+// Get a registered font by its resource name.
+func (this *PdfWriter) getFont(fontRef PdfObjectName) (*writerFont, error) {
+	for _, font := range this.fonts {
+		if font.name == fontRef {
+			return font, nil
+		}
+	}
+	return nil, fmt.Errorf("Font %s not registered", fontRef)
+}
+
+// Encode text for showing with a registered font (e.g. with
+// ContentStreamBuilder.ShowText): WinAnsiEncoding for standard text fonts,
+// glyph IDs for embedded TrueType fonts.  Returns an error if a character
+// is not supported by the font.
+func (this *PdfWriter) EncodeText(fontRef PdfObjectName, text string) (string, error) {
+	font, err := this.getFont(fontRef)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	for _, r := range text {
+		if font.trueType != nil {
+			gid, has := font.trueType.runeToGID[r]
+			if !has {
+				return "", fmt.Errorf("Character %q not in font %s", r, fontRef)
+			}
+			buf.WriteByte(byte(gid >> 8))
+			buf.WriteByte(byte(gid))
+			continue
+		}
+
+		code, ok := encodeSimpleRune(font.key, r)
+		if !ok {
+			return "", fmt.Errorf("Character %q not supported by %s", r, font.key)
+		}
+		buf.WriteByte(code)
+	}
+	return buf.String(), nil
+}
+
+// Encode a character for a standard font: WinAnsiEncoding for the text
+// fonts, single byte codes as is for Symbol and ZapfDingbats.
+func encodeSimpleRune(baseFont string, r rune) (byte, bool) {
+	if baseFont == "Symbol" || baseFont == "ZapfDingbats" {
+		return byte(r), r < 256
+	}
+	return encodeWinAnsiRune(r)
+}
+
+// Encode a character with WinAnsiEncoding.
+func encodeWinAnsiRune(r rune) (byte, bool) {
+	if r < 0x80 || (r >= 0xA0 && r <= 0xFF) {
+		return byte(r), true
+	}
+	for code, val := range winAnsiEncoding {
+		if val == r {
+			return code, true
+		}
+	}
+	return 0, false
+}
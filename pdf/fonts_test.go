@@ -0,0 +1,192 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestAddStandardFont(t *testing.T) {
+	w := NewPdfWriter()
+	page1 := loadMinimalPage(t).(*PdfIndirectObject)
+	if err := w.AddPage(page1); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	page2 := NewPage(PageWidthA4, PageHeightA4)
+
+	if _, err := w.AddStandardFont(page1, "Arial"); err == nil {
+		t.Errorf("Non standard font should fail")
+	}
+
+	// F1 is used by the existing page font.
+	helv, err := w.AddStandardFont(page1, "Helvetica")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if helv != "F2" {
+		t.Errorf("Unexpected resource name %s", helv)
+	}
+	again, err := w.AddStandardFont(page2, "Helvetica")
+	if err != nil || again != helv {
+		t.Errorf("Registration not reused (%s, %v)", again, err)
+	}
+	symbol, err := w.AddStandardFont(page2, "Symbol")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if symbol == helv {
+		t.Errorf("Resource name reused for a different font")
+	}
+	if err := w.AddPage(page2); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	content := NewContentStreamBuilder().
+		BeginText().SetFont(string(helv), 12).MoveText(10, 10).ShowText("Stamped").EndText().Bytes()
+	if err := w.StampPage(1, content, nil); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if bytes.Count(out, []byte("/BaseFont /Helvetica")) != 1 {
+		t.Errorf("Expected a single Helvetica font object")
+	}
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	text, err := reader.ExtractPageText(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if text != "Hello World\nStamped" {
+		t.Errorf("Unexpected text %q", text)
+	}
+	resources, err := reader.GetPageResources(2)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	fonts, ok := (*resources)["Font"].(*PdfObjectDictionary)
+	if !ok || len(*fonts) != 2 {
+		t.Errorf("Invalid page 2 fonts %v", (*resources)["Font"])
+	}
+}
+
+// Pages already having the font object under the font name, directly or
+// by reference, are taken as registered.
+func TestAddStandardFontAlreadyOnPage(t *testing.T) {
+	w := NewPdfWriter()
+	page1 := NewPage(PageWidthA4, PageHeightA4)
+	if err := w.AddPage(page1); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	helv, err := w.AddStandardFont(page1, "Helvetica")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	font := w.findFont("Helvetica")
+	w.updateObjectNumbers()
+
+	ref := &PdfObjectReference{ObjectNumber: font.obj.ObjectNumber}
+	entries := []PdfObject{
+		font.obj,
+		&PdfIndirectObject{PdfObject: font.obj.PdfObject},
+		ref,
+	}
+	for i, entry := range entries {
+		page := NewPage(PageWidthA4, PageHeightA4)
+		fonts := PdfObjectDictionary{}
+		fonts[helv] = entry
+		(*page.PdfObject.(*PdfObjectDictionary))["Resources"] = &PdfObjectDictionary{"Font": &fonts}
+
+		name, err := w.AddStandardFont(page, "Helvetica")
+		if err != nil || name != helv {
+			t.Errorf("%d: unexpected name %s (%v)", i, name, err)
+		}
+		if len(fonts) != 1 || fonts[helv] != entry {
+			t.Errorf("%d: font entry changed (%v)", i, fonts)
+		}
+	}
+
+	// Another object under the name is still a conflict.
+	page := NewPage(PageWidthA4, PageHeightA4)
+	fonts := PdfObjectDictionary{}
+	fonts[helv] = &PdfObjectReference{ObjectNumber: font.obj.ObjectNumber + 1}
+	(*page.PdfObject.(*PdfObjectDictionary))["Resources"] = &PdfObjectDictionary{"Font": &fonts}
+	if _, err := w.AddStandardFont(page, "Helvetica"); err == nil {
+		t.Errorf("Conflicting font entry should fail")
+	}
+}
+
+func TestMeasureText(t *testing.T) {
+	w := NewPdfWriter()
+	page := NewPage(PageWidthA4, PageHeightA4)
+	helv, err := w.AddStandardFont(page, "Helvetica")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	courier, err := w.AddStandardFont(page, "Courier")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	times, err := w.AddStandardFont(page, "Times-Roman")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	symbol, err := w.AddStandardFont(page, "Symbol")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	ttf, err := w.EmbedTrueTypeFont(page, makeTestTrueType())
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	testcases := []struct {
+		font     PdfObjectName
+		size     float64
+		text     string
+		expected float64
+	}{
+		// H 722 + e 556 + l 222 + l 222 + o 556.
+		{helv, 12, "Hello", 27.336},
+		{helv, 10, "W.", 12.22},
+		// WinAnsiEncoding characters: eacute 556 + udieresis 556 + Euro 556.
+		{helv, 10, "éü€", 16.68},
+		// eacute 444 + udieresis 500 + Euro 500 + nbsp 250.
+		{times, 10, "éü€\u00a0", 16.94},
+		// Missing width for characters without metrics.
+		{helv, 10, "\u0394", 5.56},
+		{times, 10, "\u0394", 5},
+		{courier, 10, "abc", 18},
+		{ttf, 12, "Hi!", 9},
+		// Glyph 0 for characters not in the font.
+		{ttf, 10, "éa", 7.5},
+		{helv, 12, "", 0},
+	}
+	for _, tcase := range testcases {
+		width, err := w.MeasureText(tcase.font, tcase.size, tcase.text)
+		if err != nil {
+			t.Errorf("%q: error %v", tcase.text, err)
+			continue
+		}
+		if math.Abs(width-tcase.expected) > 1e-9 {
+			t.Errorf("%q: width %v (expected %v)", tcase.text, width, tcase.expected)
+		}
+	}
+
+	if _, err := w.MeasureText(symbol, 12, "a"); err == nil {
+		t.Errorf("Font without metrics should fail")
+	}
+	if _, err := w.MeasureText("F99", 12, "a"); err == nil {
+		t.Errorf("Unregistered font should fail")
+	}
+}
@@ -0,0 +1,294 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Appearance streams of variable text form fields (12.7.3.3).
+
+package pdf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Text field flags (Ff).
+const (
+	textFieldMultiline = 1 << 12
+	textFieldComb      = 1 << 24
+)
+
+// Padding between the widget border and the text.
+const fieldTextPadding = 2.0
+
+// Regenerate the appearance streams (AP) of the widgets of a text field
+// from the field value (V) and its default appearance (DA), e.g. after
+// setting the value, so that it displays in all the viewers.  The font of
+// the default appearance must be a simple font in the form default
+// resources (DR).  Multiline fields are split at the line breaks and comb
+// fields spread the characters over MaxLen cells.  The widgets are
+// updated in place.
+func (this *PdfReader) RegenerateFieldAppearance(field FormField) error {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return ErrEncrypted
+	}
+	if field.Type != "Tx" {
+		return fmt.Errorf("Not a text field (%s)", field.Type)
+	}
+
+	da, err := this.GetFieldDefaultAppearance(field)
+	if err != nil {
+		return err
+	}
+	resources, err := this.GetFormDefaultResources()
+	if err != nil {
+		return err
+	}
+	if resources == nil {
+		return errors.New("Form without default resources")
+	}
+	fontsObj, err := this.traceToDirectObject((*resources)["Font"])
+	if err != nil {
+		return err
+	}
+	fonts, ok := fontsObj.(*PdfObjectDictionary)
+	if !ok {
+		return errors.New("Default resources without fonts")
+	}
+	fontObj, has := (*fonts)[da.Font]
+	if !has {
+		log.Error("Font %s not in the default resources", da.Font)
+		return fmt.Errorf("Font %s not in the default resources", da.Font)
+	}
+	fontDictObj, err := this.traceToDirectObject(fontObj)
+	if err != nil {
+		return err
+	}
+	fontDict, ok := fontDictObj.(*PdfObjectDictionary)
+	if !ok {
+		return errors.New("Font not a dictionary")
+	}
+	if subtype, _ := fontDict.GetName("Subtype"); subtype == "Type0" {
+		return errors.New("Composite fonts not supported")
+	}
+	baseFont, _ := fontDict.GetName("BaseFont")
+
+	value := ""
+	valueObj, err := this.getInheritedAttribute(field.Object, "V")
+	if err != nil {
+		return err
+	}
+	if str, ok := asString(valueObj); ok {
+		value = decodeTextString(str)
+	}
+	quadding := int64(0)
+	qObj, err := this.getInheritedAttribute(field.Object, "Q")
+	if err != nil {
+		return err
+	}
+	if qObj == nil && this.forms != nil {
+		qObj = (*this.forms)["Q"]
+	}
+	if q, ok := qObj.(*PdfObjectInteger); ok {
+		quadding = int64(*q)
+	}
+	maxLen := int64(0)
+	maxLenObj, err := this.getInheritedAttribute(field.Object, "MaxLen")
+	if err != nil {
+		return err
+	}
+	if m, ok := maxLenObj.(*PdfObjectInteger); ok {
+		maxLen = int64(*m)
+	}
+
+	for _, widget := range field.Widgets {
+		dict, ok := widget.PdfObject.(*PdfObjectDictionary)
+		if !ok {
+			return errors.New("Widget not a dictionary")
+		}
+		rect, err := getDirectRectangle((*dict)["Rect"])
+		if err != nil {
+			return err
+		}
+		rect = rect.normalized()
+		width, height := rect.Urx-rect.Llx, rect.Ury-rect.Lly
+
+		layout := fieldTextLayout{
+			text: value, font: da.Font, fontSize: da.FontSize, color: da.Color,
+			metrics: getStandardFontMetrics(string(baseFont)), baseFont: string(baseFont),
+			width: width, height: height, quadding: quadding,
+		}
+		var content []byte
+		switch {
+		case field.Flags&textFieldComb != 0 && maxLen > 0:
+			content = layout.comb(int(maxLen))
+		case field.Flags&textFieldMultiline != 0:
+			content = layout.multiline()
+		default:
+			content = layout.singleLine()
+		}
+
+		streamFonts := PdfObjectDictionary{}
+		streamFonts[da.Font] = fontObj
+		streamResources := PdfObjectDictionary{}
+		streamResources["Font"] = &streamFonts
+		stream := makeContentStream(content)
+		(*stream.PdfObjectDictionary)["Type"] = makeName("XObject")
+		(*stream.PdfObjectDictionary)["Subtype"] = makeName("Form")
+		(*stream.PdfObjectDictionary)["BBox"] = PdfRectangle{0, 0, width, height}.toArray()
+		(*stream.PdfObjectDictionary)["Resources"] = &streamResources
+
+		ap := PdfObjectDictionary{}
+		ap["N"] = stream
+		(*dict)["AP"] = &ap
+	}
+	return nil
+}
+
+// Layout of the text of a text field widget in its bounding box.
+type fieldTextLayout struct {
+	text     string
+	font     PdfObjectName
+	fontSize float64
+	color    []float64
+	// Standard font metrics for measuring the text, nil if not known.
+	metrics  *standardFontMetrics
+	baseFont string
+	width    float64
+	height   float64
+	// Alignment (Q): 0 left, 1 centered, 2 right.
+	quadding int64
+}
+
+// Encode text for the font, replacing the characters not supported.
+func (this *fieldTextLayout) encode(text string) string {
+	var encoded []byte
+	for _, r := range text {
+		code, ok := encodeSimpleRune(this.baseFont, r)
+		if !ok {
+			code = '?'
+		}
+		encoded = append(encoded, code)
+	}
+	return string(encoded)
+}
+
+// Measure text at a font size, 0 if the font metrics are not known.
+func (this *fieldTextLayout) measure(text string, size float64) float64 {
+	if this.metrics == nil {
+		return 0
+	}
+	total := 0
+	for _, r := range text {
+		total += this.metrics.width(r)
+	}
+	return float64(total) * size / 1000
+}
+
+// Get the font size, fitting a line of text in the field if auto-sized.
+func (this *fieldTextLayout) size(line string, multiline bool) float64 {
+	if this.fontSize > 0 {
+		return this.fontSize
+	}
+	if multiline {
+		return 12
+	}
+	size := this.height - 2*fieldTextPadding
+	if textWidth := this.measure(line, size); textWidth > this.width-2*fieldTextPadding {
+		size *= (this.width - 2*fieldTextPadding) / textWidth
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// Get the horizontal offset of a line by the alignment.
+func (this *fieldTextLayout) offset(line string, size float64) float64 {
+	free := this.width - 2*fieldTextPadding - this.measure(line, size)
+	switch this.quadding {
+	case 1:
+		return fieldTextPadding + free/2
+	case 2:
+		return fieldTextPadding + free
+	}
+	return fieldTextPadding
+}
+
+// Begin the content: marked content, clipping and text state.
+func (this *fieldTextLayout) begin(size float64) *ContentStreamBuilder {
+	builder := NewContentStreamBuilder()
+	builder.Add("BMC", makeName("Tx")).SaveState()
+	builder.addNumeric("re", 1, 1, this.width-2, this.height-2)
+	builder.Add("W").Add("n")
+	builder.BeginText().SetFont(string(this.font), size)
+	switch len(this.color) {
+	case 1:
+		builder.addNumeric("g", this.color...)
+	case 3:
+		builder.addNumeric("rg", this.color...)
+	case 4:
+		builder.addNumeric("k", this.color...)
+	}
+	return builder
+}
+
+func (this *fieldTextLayout) end(builder *ContentStreamBuilder) []byte {
+	return builder.EndText().RestoreState().Add("EMC").Bytes()
+}
+
+// Lay out the text on a single line, centered vertically.
+func (this *fieldTextLayout) singleLine() []byte {
+	size := this.size(this.text, false)
+	builder := this.begin(size)
+	// Baseline with room for the descenders.
+	builder.MoveText(this.offset(this.text, size), (this.height-size)/2+0.2*size)
+	builder.ShowText(this.encode(this.text))
+	return this.end(builder)
+}
+
+// Lay out the lines of the text from the top of the field.
+func (this *fieldTextLayout) multiline() []byte {
+	lines := strings.Split(strings.Replace(strings.Replace(this.text, "\r\n", "\n", -1), "\r", "\n", -1), "\n")
+	size := this.size("", true)
+	leading := 1.15 * size
+	builder := this.begin(size)
+	x, y := 0.0, this.height-fieldTextPadding-size
+	for _, line := range lines {
+		lineX := this.offset(line, size)
+		builder.MoveText(lineX-x, y)
+		builder.ShowText(this.encode(line))
+		x, y = lineX, -leading
+	}
+	return this.end(builder)
+}
+
+// Lay out the characters of the text in the cells of a comb field.
+func (this *fieldTextLayout) comb(cells int) []byte {
+	cellWidth := this.width / float64(cells)
+	size := this.fontSize
+	if size <= 0 {
+		size = this.height - 2*fieldTextPadding
+		if size > cellWidth {
+			size = cellWidth
+		}
+	}
+	builder := this.begin(size)
+	x := 0.0
+	for i, r := range []rune(this.text) {
+		if i >= cells {
+			break
+		}
+		char := string(r)
+		charX := float64(i)*cellWidth + (cellWidth-this.measure(char, size))/2
+		y := 0.0
+		if i == 0 {
+			y = (this.height-size)/2 + 0.2*size
+		}
+		builder.MoveText(charX-x, y)
+		builder.ShowText(this.encode(char))
+		x = charX
+	}
+	return this.end(builder)
+}
@@ -0,0 +1,122 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRegenerateFieldAppearance(t *testing.T) {
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [4 0 R 5 0 R 6 0 R 7 0 R] /DR << /Font << /Helv 8 0 R >> >> /DA (/Helv 0 Tf 0 g) >> >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 200 200] >>",
+		"<< /Type /Page /Parent 2 0 R /Annots [4 0 R 5 0 R 6 0 R 7 0 R] >>",
+		"<< /T (Name) /FT /Tx /Q 1 /Type /Annot /Subtype /Widget /P 3 0 R /Rect [10 10 110 30] >>",
+		"<< /T (Address) /FT /Tx /Ff 4096 /DA (/Helv 10 Tf 0 0 1 rg) /Type /Annot /Subtype /Widget /P 3 0 R /Rect [10 40 110 80] >>",
+		"<< /T (Zip) /FT /Tx /Ff 16777216 /MaxLen 5 /Type /Annot /Subtype /Widget /P 3 0 R /Rect [10 90 60 110] >>",
+		"<< /T (Agree) /FT /Btn /Type /Annot /Subtype /Widget /P 3 0 R /Rect [10 120 20 130] >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	})
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	fields, err := reader.GetFormFieldsForPage(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	values := []string{"Jane Doe", "1 Main St\nSpringfield", "12345"}
+	for i, value := range values {
+		(*fields[i].Object.PdfObject.(*PdfObjectDictionary))["V"] = makeString(value)
+		if err := reader.RegenerateFieldAppearance(fields[i]); err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+	if err := reader.RegenerateFieldAppearance(fields[3]); err == nil {
+		t.Errorf("Regenerating a button appearance should fail")
+	}
+
+	// Write the filled form and read the appearances back.
+	w := NewPdfWriter()
+	page, err := reader.GetPage(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if err := w.AddPage(page); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	forms, err := reader.GetForms()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if err := w.AddForms(forms); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	reader, err = NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	fields, err = reader.GetFormFieldsForPage(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	expected := []struct {
+		texts []string
+		size  float64
+		bbox  []float64
+	}{
+		{[]string{"Jane Doe"}, 16, []float64{0, 0, 100, 20}},
+		{[]string{"1 Main St", "Springfield"}, 10, []float64{0, 0, 100, 40}},
+		{[]string{"1", "2", "3", "4", "5"}, 10, []float64{0, 0, 50, 20}},
+	}
+	for i, exp := range expected {
+		dict := fields[i].Object.PdfObject.(*PdfObjectDictionary)
+		ap, ok := (*dict)["AP"].(*PdfObjectDictionary)
+		if !ok {
+			t.Fatalf("%s: appearance missing", fields[i].Name)
+		}
+		stream, ok := (*ap)["N"].(*PdfObjectStream)
+		if !ok {
+			t.Fatalf("%s: normal appearance not a stream (%T)", fields[i].Name, (*ap)["N"])
+		}
+		bbox := []float64{}
+		for _, obj := range *(*stream.PdfObjectDictionary)["BBox"].(*PdfObjectArray) {
+			val, _ := getNumberAsFloat(obj)
+			bbox = append(bbox, val)
+		}
+		if !reflect.DeepEqual(bbox, exp.bbox) {
+			t.Errorf("%s: BBox %v (expected %v)", fields[i].Name, bbox, exp.bbox)
+		}
+		content, err := reader.parser.decodeStream(stream)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		operations, err := NewContentStreamParser(content).Parse()
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		texts := []string{}
+		size := 0.0
+		for _, op := range operations {
+			switch op.Operator {
+			case "Tj":
+				texts = append(texts, string(*op.Operands[0].(*PdfObjectString)))
+			case "Tf":
+				size, _ = getNumberAsFloat(op.Operands[1])
+			}
+		}
+		if !reflect.DeepEqual(texts, exp.texts) || size != exp.size {
+			t.Errorf("%s: text %q size %v (expected %q %v)", fields[i].Name, texts, size, exp.texts, exp.size)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Interactive form fields (12.7.3).
+
+package pdf
+
+import (
+	"errors"
+)
+
+// A terminal form field, i.e. a field with widget annotations rather than
+// child fields.
+type FormField struct {
+	// Fully qualified name, the partial names (T) of the field and its
+	// ancestors separated by periods.
+	Name string
+	// Field type (FT): Btn, Tx, Ch or Sig.  Inherited.
+	Type PdfObjectName
+	// Field flags (Ff).  Inherited.
+	Flags int64
+	// Field value (V), nil if not set.  Inherited.
+	Value PdfObject
+	// The field dictionary, merged with the widget annotation if the
+	// field has a single widget.
+	Object *PdfIndirectObject
+	// The widget annotations of the field.
+	Widgets []*PdfIndirectObject
+}
+
+// Get the terminal fields of the AcroForm in the order of the field tree.
+func (this *PdfReader) getFormFields() ([]FormField, error) {
+	fields := []FormField{}
+	if this.forms == nil {
+		return fields, nil
+	}
+
+	obj, err := this.traceToDirectObject((*this.forms)["Fields"])
+	if err != nil {
+		return nil, err
+	}
+	fieldsArray, ok := obj.(*PdfObjectArray)
+	if !ok {
+		return fields, nil
+	}
+	traversed := map[*PdfIndirectObject]bool{}
+	for _, fieldObj := range *fieldsArray {
+		err := this.collectFormFields(fieldObj, FormField{}, &fields, traversed)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// Resolve a field or widget annotation, which must be an indirect object.
+func (this *PdfReader) resolveFieldObject(obj PdfObject) (*PdfIndirectObject, *PdfObjectDictionary, error) {
+	if ref, isRef := obj.(*PdfObjectReference); isRef {
+		var err error
+		obj, _, err = this.resolveReference(ref)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	node, ok := obj.(*PdfIndirectObject)
+	if !ok {
+		log.Error("Form field not an indirect object (%T)", obj)
+		return nil, nil, errors.New("Form field not an indirect object")
+	}
+	dict, ok := node.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return nil, nil, errors.New("Form field not a dictionary")
+	}
+	return node, dict, nil
+}
+
+// Collect the terminal fields of a field subtree.  The parent holds the
+// name and the inherited attributes of the parent field.
+func (this *PdfReader) collectFormFields(obj PdfObject, parent FormField, fields *[]FormField, traversed map[*PdfIndirectObject]bool) error {
+	node, dict, err := this.resolveFieldObject(obj)
+	if err != nil {
+		return err
+	}
+	if _, alreadyTraversed := traversed[node]; alreadyTraversed {
+		log.Error("Circular form field reference")
+		return errors.New("Circular form field reference")
+	}
+	traversed[node] = true
+
+	field := FormField{Name: parent.Name, Type: parent.Type, Flags: parent.Flags, Value: parent.Value}
+	field.Object = node
+	if t, ok := asString((*dict)["T"]); ok {
+		if field.Name != "" {
+			field.Name += "."
+		}
+		field.Name += decodeTextString(t)
+	}
+	if ft, ok := (*dict)["FT"].(*PdfObjectName); ok {
+		field.Type = *ft
+	}
+	if ff, ok := (*dict)["Ff"].(*PdfObjectInteger); ok {
+		field.Flags = int64(*ff)
+	}
+	if v, hasV := (*dict)["V"]; hasV {
+		field.Value, err = this.traceToDirectObject(v)
+		if err != nil {
+			return err
+		}
+	}
+
+	kidsObj, err := this.traceToDirectObject((*dict)["Kids"])
+	if err != nil {
+		return err
+	}
+	hasKidFields := false
+	if kids, ok := kidsObj.(*PdfObjectArray); ok {
+		for _, kidObj := range *kids {
+			kid, kidDict, err := this.resolveFieldObject(kidObj)
+			if err != nil {
+				return err
+			}
+			// Kids without a name that are widget annotations are the
+			// widgets of a terminal field, otherwise child fields.
+			_, hasT := (*kidDict)["T"]
+			if subtype, ok := (*kidDict)["Subtype"].(*PdfObjectName); ok && *subtype == "Widget" && !hasT {
+				field.Widgets = append(field.Widgets, kid)
+				continue
+			}
+			hasKidFields = true
+			err = this.collectFormFields(kid, field, fields, traversed)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if subtype, ok := (*dict)["Subtype"].(*PdfObjectName); ok && *subtype == "Widget" {
+		// Field merged with its widget annotation.
+		field.Widgets = append(field.Widgets, node)
+	}
+	if !hasKidFields || len(field.Widgets) > 0 {
+		*fields = append(*fields, field)
+	}
+	return nil
+}
+
+// Check if a widget annotation is on a page, i.e. its P entry refers to
+// the page or it is listed in the page annotations.
+func (this *PdfReader) isWidgetOnPage(widget *PdfIndirectObject, page *PdfIndirectObject, annots *PdfObjectArray) (bool, error) {
+	if dict, ok := widget.PdfObject.(*PdfObjectDictionary); ok {
+		p := (*dict)["P"]
+		if ref, isRef := p.(*PdfObjectReference); isRef {
+			var err error
+			p, _, err = this.resolveReference(ref)
+			if err != nil {
+				return false, err
+			}
+		}
+		if p == page {
+			return true, nil
+		}
+	}
+	if annots != nil {
+		for _, annot := range *annots {
+			if annot == widget {
+				return true, nil
+			}
+			if ref, isRef := annot.(*PdfObjectReference); isRef && ref.ObjectNumber == widget.ObjectNumber {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// Get the terminal form fields with widget annotations on a page (from 1).
+// The widgets of the returned fields are limited to those on the page.
+func (this *PdfReader) GetFormFieldsForPage(pageNumber int) ([]FormField, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+
+	pageObj, err := this.GetPage(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	page, ok := pageObj.(*PdfIndirectObject)
+	if !ok {
+		return nil, errors.New("Page not an indirect object")
+	}
+	var annots *PdfObjectArray
+	if pDict, ok := page.PdfObject.(*PdfObjectDictionary); ok {
+		obj, err := this.traceToDirectObject((*pDict)["Annots"])
+		if err != nil {
+			return nil, err
+		}
+		annots, _ = obj.(*PdfObjectArray)
+	}
+
+	allFields, err := this.getFormFields()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []FormField{}
+	for _, field := range allFields {
+		widgets := []*PdfIndirectObject{}
+		for _, widget := range field.Widgets {
+			onPage, err := this.isWidgetOnPage(widget, page, annots)
+			if err != nil {
+				return nil, err
+			}
+			if onPage {
+				widgets = append(widgets, widget)
+			}
+		}
+		if len(widgets) > 0 {
+			field.Widgets = widgets
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+// Get the default resources (DR) of the AcroForm, with the fonts referred
+// to by the default appearance strings (DA).  Returns nil if the document
+// has no form or the form has no default resources.
+func (this *PdfReader) GetFormDefaultResources() (*PdfObjectDictionary, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+	if this.forms == nil {
+		return nil, nil
+	}
+
+	obj, err := this.traceToDirectObject((*this.forms)["DR"])
+	if err != nil {
+		return nil, err
+	}
+	switch t := obj.(type) {
+	case nil, *PdfObjectNull:
+		return nil, nil
+	case *PdfObjectDictionary:
+		return t, nil
+	}
+	log.Error("Invalid form default resources (%T)", obj)
+	return nil, errors.New("Invalid form default resources")
+}
+
+// Default appearance of the text of a variable text field (12.7.3.3).
+type DefaultAppearance struct {
+	// Font resource name in the default resources (DR).
+	Font PdfObjectName
+	// Font size, 0 to fit the text in the field.
+	FontSize float64
+	// Color components: gray, RGB or CMYK.  Nil if not set.
+	Color []float64
+}
+
+// Parse a default appearance (DA) string such as "/Helv 12 Tf 0 g".  The
+// font (Tf) is required, operators other than Tf, g, rg and k are ignored.
+func ParseDefaultAppearance(da string) (DefaultAppearance, error) {
+	appearance := DefaultAppearance{}
+	operations, err := NewContentStreamParser([]byte(da)).Parse()
+	if err != nil {
+		return appearance, err
+	}
+
+	hasFont := false
+	for _, op := range operations {
+		switch op.Operator {
+		case "Tf":
+			if len(op.Operands) != 2 {
+				return appearance, errors.New("Invalid Tf operands")
+			}
+			name, ok := op.Operands[0].(*PdfObjectName)
+			if !ok {
+				return appearance, errors.New("Invalid font name")
+			}
+			size, err := getNumberAsFloat(op.Operands[1])
+			if err != nil {
+				return appearance, err
+			}
+			appearance.Font = *name
+			appearance.FontSize = size
+			hasFont = true
+		case "g", "rg", "k":
+			num := map[string]int{"g": 1, "rg": 3, "k": 4}[op.Operator]
+			color, err := getNumericOperands(op, num)
+			if err != nil {
+				return appearance, err
+			}
+			appearance.Color = color
+		}
+	}
+	if !hasFont {
+		log.Error("Default appearance without font (%s)", da)
+		return appearance, errors.New("Default appearance without font")
+	}
+	return appearance, nil
+}
+
+// Get the default appearance of a field, inherited from the ancestor
+// fields or else the AcroForm.
+func (this *PdfReader) GetFieldDefaultAppearance(field FormField) (DefaultAppearance, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return DefaultAppearance{}, ErrEncrypted
+	}
+
+	obj, err := this.getInheritedAttribute(field.Object, "DA")
+	if err != nil {
+		return DefaultAppearance{}, err
+	}
+	if obj == nil && this.forms != nil {
+		obj, err = this.traceToDirectObject((*this.forms)["DA"])
+		if err != nil {
+			return DefaultAppearance{}, err
+		}
+	}
+	da, ok := asString(obj)
+	if !ok {
+		return DefaultAppearance{}, errors.New("Field without default appearance")
+	}
+	return ParseDefaultAppearance(string(*da))
+}
@@ -0,0 +1,143 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestGetFormFieldsForPage(t *testing.T) {
+	reader, err := NewPdfReader(bytes.NewReader(makeFormPdf()))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	expected := []struct {
+		name    string
+		ftype   PdfObjectName
+		widgets int
+	}{
+		{"Choice", "Btn", 7},
+		{"Choice", "Btn", 8},
+		{"Name", "Tx", 9},
+	}
+	for i, exp := range expected {
+		fields, err := reader.GetFormFieldsForPage(i + 1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if len(fields) != 1 {
+			t.Fatalf("Page %d: expected 1 field, got %d", i+1, len(fields))
+		}
+		field := fields[0]
+		if field.Name != exp.name || field.Type != exp.ftype {
+			t.Errorf("Page %d: unexpected field %+v", i+1, field)
+		}
+		if exp.ftype == "Btn" && field.Flags != 49152 {
+			t.Errorf("Page %d: radio button flags not set (%d)", i+1, field.Flags)
+		}
+		if len(field.Widgets) != 1 || field.Widgets[0].ObjectNumber != int64(exp.widgets) {
+			t.Errorf("Page %d: unexpected widgets %v", i+1, field.Widgets)
+		}
+	}
+}
+
+func TestGetFormFieldsHierarchy(t *testing.T) {
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [4 0 R 6 0 R] >> >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 100 100] >>",
+		"<< /Type /Page /Parent 2 0 R /Annots [6 0 R] >>",
+		"<< /T (Address) /FT /Tx /Ff 4096 /Kids [5 0 R] >>",
+		"<< /T (City) /Parent 4 0 R /Type /Annot /Subtype /Widget /P 3 0 R /V (Paris) /Rect [0 0 10 10] >>",
+		"<< /T (Zip) /FT /Tx /Type /Annot /Subtype /Widget /Rect [0 20 10 30] >>",
+	})
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	fields, err := reader.GetFormFieldsForPage(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(fields) != 2 {
+		t.Fatalf("Expected 2 fields, got %d", len(fields))
+	}
+
+	city := fields[0]
+	if city.Name != "Address.City" || city.Type != "Tx" || city.Flags != 4096 {
+		t.Errorf("Unexpected field %+v", city)
+	}
+	if value, ok := city.Value.(*PdfObjectString); !ok || string(*value) != "Paris" {
+		t.Errorf("Unexpected field value %v", city.Value)
+	}
+	if len(city.Widgets) != 1 || city.Widgets[0] != city.Object {
+		t.Errorf("Merged field widget not found")
+	}
+
+	// On the page through Annots only.
+	if fields[1].Name != "Zip" || len(fields[1].Widgets) != 1 {
+		t.Errorf("Unexpected field %+v", fields[1])
+	}
+}
+
+func TestFormDefaultAppearance(t *testing.T) {
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [4 0 R 5 0 R] /DR << /Font << /Helv 6 0 R >> >> /DA (/Helv 0 Tf 0 g) >> >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 100 100] >>",
+		"<< /Type /Page /Parent 2 0 R /Annots [4 0 R 5 0 R] >>",
+		"<< /T (Name) /FT /Tx /Type /Annot /Subtype /Widget /P 3 0 R /Rect [0 0 10 10] >>",
+		"<< /T (City) /FT /Tx /DA (0.5 0 1 rg /Helv 10.5 Tf) /Type /Annot /Subtype /Widget /P 3 0 R /Rect [0 20 10 30] >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	})
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	resources, err := reader.GetFormDefaultResources()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	fonts, ok := (*resources)["Font"].(*PdfObjectDictionary)
+	if !ok {
+		t.Fatalf("Default resources without fonts (%s)", resources)
+	}
+	if _, ok := (*fonts)["Helv"].(*PdfIndirectObject); !ok {
+		t.Errorf("Font not resolved (%T)", (*fonts)["Helv"])
+	}
+
+	fields, err := reader.GetFormFieldsForPage(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	expected := []DefaultAppearance{
+		{Font: "Helv", FontSize: 0, Color: []float64{0}},
+		{Font: "Helv", FontSize: 10.5, Color: []float64{0.5, 0, 1}},
+	}
+	for i, field := range fields {
+		da, err := reader.GetFieldDefaultAppearance(field)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if !reflect.DeepEqual(da, expected[i]) {
+			t.Errorf("%s: default appearance %+v (expected %+v)", field.Name, da, expected[i])
+		}
+	}
+
+	if _, err := ParseDefaultAppearance("0 g"); err == nil {
+		t.Errorf("Default appearance without font should fail")
+	}
+	if _, err := ParseDefaultAppearance("/Helv 12 Tf 0 0 rg"); err == nil {
+		t.Errorf("Too few color components should fail")
+	}
+
+	// No default resources.
+	resources, err = loadMinimalReader(t).GetFormDefaultResources()
+	if err != nil || resources != nil {
+		t.Errorf("Expected no default resources (%v, %v)", resources, err)
+	}
+}
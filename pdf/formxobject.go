@@ -0,0 +1,148 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Form XObjects made from pages, e.g. for overlays, backgrounds and
+// imposition.
+
+package pdf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Convert a page (from 1) of a reader into a Form XObject (8.10), which can
+// be painted on any page with the Do operator.  The content streams of the
+// page are concatenated and its resources copied.  The bounding box is the
+// crop box of the page, and the matrix shows the page upright with the
+// lower left corner at the origin taking the page rotation into account:
+// painted with the identity transform, the form covers the page as
+// displayed from (0, 0).  An encrypted document needs to be decrypted
+// first.
+func PageAsFormXObject(reader *PdfReader, pageNumber int) (*PdfObjectStream, error) {
+	return pageAsFormXObject(reader, pageNumber, map[PdfObject]PdfObject{})
+}
+
+// Convert a page into a Form XObject, copying the resources with deepCopy
+// and the shared copies so that the objects shared by several pages are
+// copied once.
+func pageAsFormXObject(reader *PdfReader, pageNumber int, copies map[PdfObject]PdfObject) (*PdfObjectStream, error) {
+	if reader.parser.crypter != nil && !reader.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+
+	content, err := reader.GetPageContents(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	cropBox, err := reader.GetPageCropBox(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	rotation, err := reader.GetPageRotation(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := reader.GetPageResources(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	resourcesObj, err := reader.resolveObject(resources)
+	if err != nil {
+		return nil, err
+	}
+
+	dict := PdfObjectDictionary{}
+	dict["Type"] = makeName("XObject")
+	dict["Subtype"] = makeName("Form")
+	dict["FormType"] = makeInteger(1)
+	dict["BBox"] = cropBox.toArray()
+	dict["Matrix"] = uprightPageMatrix(*cropBox, rotation).toArray()
+	dict["Resources"] = deepCopy(resourcesObj, copies)
+	dict["Length"] = makeInteger(int64(len(content)))
+
+	stream := PdfObjectStream{}
+	stream.PdfObjectDictionary = &dict
+	stream.Stream = content
+	return &stream, nil
+}
+
+// Impose the pages of a reader N-up: add sheets of the page size, with the
+// source pages arranged on a grid of columns and rows, left to right and
+// top to bottom.  Each page is painted as a Form XObject, scaled to fit
+// its cell keeping the aspect ratio and centered.  The last sheet is
+// partially filled if the number of pages is not a multiple of the cells
+// per sheet.  An encrypted document needs to be decrypted first.
+func (this *PdfWriter) ImposeNUp(reader *PdfReader, cols, rows int, pageSize PdfRectangle) error {
+	if cols < 1 || rows < 1 {
+		return fmt.Errorf("Invalid grid %dx%d", cols, rows)
+	}
+	sheet := pageSize.normalized()
+	sheetWidth := sheet.Urx - sheet.Llx
+	sheetHeight := sheet.Ury - sheet.Lly
+	if sheetWidth <= 0 || sheetHeight <= 0 {
+		return errors.New("Invalid page size")
+	}
+	cellWidth := sheetWidth / float64(cols)
+	cellHeight := sheetHeight / float64(rows)
+
+	numPages, err := reader.GetNumPages()
+	if err != nil {
+		return err
+	}
+	copies := map[PdfObject]PdfObject{}
+	perSheet := cols * rows
+	for first := 1; first <= numPages; first += perSheet {
+		page := NewPage(sheetWidth, sheetHeight)
+		pDict := page.PdfObject.(*PdfObjectDictionary)
+		(*pDict)["MediaBox"] = sheet.toArray()
+		xobjects := PdfObjectDictionary{}
+		(*pDict)["Resources"] = &PdfObjectDictionary{"XObject": &xobjects}
+
+		builder := NewContentStreamBuilder()
+		for cell := 0; cell < perSheet && first+cell <= numPages; cell++ {
+			xobj, err := pageAsFormXObject(reader, first+cell, copies)
+			if err != nil {
+				return err
+			}
+			bbox, err := getDirectRectangle((*xobj.PdfObjectDictionary)["BBox"])
+			if err != nil {
+				return err
+			}
+			rotation, err := reader.GetPageRotation(first + cell)
+			if err != nil {
+				return err
+			}
+			// The form is shown upright from the origin.
+			shown := bbox.Transform(uprightPageMatrix(*bbox, rotation))
+			width := shown.Urx - shown.Llx
+			height := shown.Ury - shown.Lly
+			if width <= 0 || height <= 0 {
+				return fmt.Errorf("Page %d has an empty crop box", first+cell)
+			}
+
+			scale := math.Min(cellWidth/width, cellHeight/height)
+			col := cell % cols
+			row := cell / cols
+			x := sheet.Llx + float64(col)*cellWidth + (cellWidth-width*scale)/2
+			y := sheet.Ury - float64(row+1)*cellHeight + (cellHeight-height*scale)/2
+
+			name := PdfObjectName(fmt.Sprintf("Page%d", first+cell))
+			xobjects[name] = xobj
+			builder.SaveState().
+				Transform(IdentityMatrix().Scale(scale, scale).Translate(x, y)).
+				Add("Do", &name).
+				RestoreState()
+		}
+		(*pDict)["Contents"] = makeContentStream(builder.Bytes())
+
+		err = this.AddPage(page)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
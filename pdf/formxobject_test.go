@@ -0,0 +1,181 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPageAsFormXObject(t *testing.T) {
+	src := makeLinearizeTestWriter(t, 2)
+	if err := src.SetPageRotation(2, 90); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	srcData, err := writePdfToBytes(src)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	reader, err := NewPdfReader(bytes.NewReader(srcData))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if _, err := PageAsFormXObject(reader, 3); err == nil {
+		t.Errorf("Invalid page number should fail")
+	}
+	xobj, err := PageAsFormXObject(reader, 2)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	// Stamp the landscape page on a landscape page.
+	w := NewPdfWriter()
+	if err := w.AddPage(NewPage(PageHeightA4, PageWidthA4)); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	xobjects := PdfObjectDictionary{}
+	xobjects["Tpl"] = xobj
+	resources := PdfObjectDictionary{}
+	resources["XObject"] = &xobjects
+	if err := w.StampPage(1, []byte("/Tpl Do"), &resources); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	stamped, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	pageResources, err := stamped.GetPageResources(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	tplObj, err := stamped.resolveObject((*(*pageResources)["XObject"].(*PdfObjectDictionary))["Tpl"])
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	tpl, ok := tplObj.(*PdfObjectStream)
+	if !ok {
+		t.Fatalf("XObject not a stream (%T)", tplObj)
+	}
+	if subtype, ok := (*tpl.PdfObjectDictionary)["Subtype"].(*PdfObjectName); !ok || *subtype != "Form" {
+		t.Errorf("Invalid Subtype %v", (*tpl.PdfObjectDictionary)["Subtype"])
+	}
+	bbox, err := getDirectRectangle((*tpl.PdfObjectDictionary)["BBox"])
+	if err != nil || *bbox != (PdfRectangle{0, 0, PageWidthA4, PageHeightA4}) {
+		t.Errorf("Invalid BBox %v (%v)", bbox, err)
+	}
+
+	// The form shows the page upright, covering the landscape page.
+	matrix := (*tpl.PdfObjectDictionary)["Matrix"].(*PdfObjectArray)
+	m := PdfMatrix{}
+	for i, val := range *matrix {
+		m[i], err = getNumberAsFloat(val)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+	if shown := bbox.Transform(m); shown != (PdfRectangle{0, 0, PageHeightA4, PageWidthA4}) {
+		t.Errorf("Form shown at %+v", shown)
+	}
+
+	data, err := stamped.parser.decodeStream(tpl)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if !bytes.Contains(data, []byte("(Page 2) Tj")) {
+		t.Errorf("Page content not in the form: %q", data)
+	}
+	fonts, ok := (*tpl.PdfObjectDictionary)["Resources"].(*PdfObjectDictionary)
+	if !ok {
+		t.Fatalf("Form Resources missing")
+	}
+	if len(*(*fonts)["Font"].(*PdfObjectDictionary)) != 2 {
+		t.Errorf("Page fonts not in the form resources")
+	}
+}
+
+func TestImposeNUp(t *testing.T) {
+	src := makeLinearizeTestWriter(t, 5)
+	srcData, err := writePdfToBytes(src)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	reader, err := NewPdfReader(bytes.NewReader(srcData))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	w := NewPdfWriter()
+	if err := w.ImposeNUp(reader, 0, 2, PdfRectangle{0, 0, 100, 100}); err == nil {
+		t.Errorf("Invalid grid should fail")
+	}
+	if err := w.ImposeNUp(reader, 2, 2, PdfRectangle{0, 0, 0, 100}); err == nil {
+		t.Errorf("Empty page size should fail")
+	}
+
+	tests := []struct {
+		cols, rows int
+		sheets     int
+	}{
+		{2, 1, 3},
+		{2, 2, 2},
+		{1, 1, 5},
+		{3, 3, 1},
+	}
+	for _, test := range tests {
+		w := NewPdfWriter()
+		sheetSize := PdfRectangle{0, 0, 2 * PageWidthA4, PageHeightA4}
+		if err := w.ImposeNUp(reader, test.cols, test.rows, sheetSize); err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		out, err := writePdfToBytes(&w)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		imposed, err := NewPdfReader(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		numPages, err := imposed.GetNumPages()
+		if err != nil || numPages != test.sheets {
+			t.Errorf("%dx%d: %d sheets (%v)", test.cols, test.rows, numPages, err)
+			continue
+		}
+
+		// The last sheet has the remaining pages.
+		contents, err := imposed.GetPageContents(numPages)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		remaining := 5 - (test.sheets-1)*test.cols*test.rows
+		if count := bytes.Count(contents, []byte(" Do")); count != remaining {
+			t.Errorf("%dx%d: %d pages on the last sheet", test.cols, test.rows, count)
+		}
+		if bytes.Count(out, []byte("/BaseFont /Helvetica")) != 1 {
+			t.Errorf("%dx%d: shared font not copied once", test.cols, test.rows)
+		}
+	}
+
+	// 2-up on a sheet twice the page width: the pages side by side at
+	// full size.
+	w = NewPdfWriter()
+	if err := w.ImposeNUp(reader, 2, 1, PdfRectangle{0, 0, 2 * PageWidthA4, PageHeightA4}); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	sheet, err := w.getPage(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	data := (*sheet.PdfObject.(*PdfObjectDictionary))["Contents"].(*PdfObjectStream).Stream
+	expected := "q\n1 0 0 1 0 0 cm\n/Page1 Do\nQ\nq\n1 0 0 1 595.28 0 cm\n/Page2 Do\nQ\n"
+	if string(data) != expected {
+		t.Errorf("Unexpected sheet content %q", data)
+	}
+}
@@ -0,0 +1,388 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Extraction of image XObjects from pages.
+
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// An image XObject extracted from a page.
+type PdfImage struct {
+	// Name of the image in the page XObject resources.
+	Name             PdfObjectName
+	Width            int
+	Height           int
+	BitsPerComponent int
+	// Color space family, e.g. DeviceRGB or Indexed.  Empty for image masks.
+	ColorSpace PdfObjectName
+	ImageMask  bool
+	// Base color space and color lookup table for Indexed color spaces.
+	BaseColorSpace PdfObjectName
+	Palette        []byte
+	// Data is JPEG encoded if IsJPEG is set (DCTDecode), otherwise
+	// the decoded pixel data unless Filter is set.  JPEG data can be
+	// written as is to a .jpg file.
+	IsJPEG bool
+	Data   []byte
+	// Set for CMYK JPEG data with an Adobe marker (APP14), commonly
+	// stored inverted: the components are then to be inverted, unless
+	// done by the Decode array of the image, for the correct colors.
+	InvertedCMYK bool
+	// Filter of the image data if returned encoded for an external
+	// decoder: DCTDecode, CCITTFaxDecode or JBIG2Decode.  Empty if the
+	// data is decoded.
+	Filter PdfObjectName
+	// Decode parameters of the filter (DecodeParms), nil if none.
+	DecodeParms *PdfObjectDictionary
+	// Parameters of CCITTFaxDecode data, nil for other filters.
+	CCITTFax *CCITTFaxParams
+	// Global segments of JBIG2Decode data (JBIG2Globals), nil if none.
+	JBIG2Globals []byte
+}
+
+// Parameters of CCITT facsimile encoded image data (Table 11).  Rows is
+// the image height if not set.
+type CCITTFaxParams struct {
+	// Encoding: negative for Group 4, 0 for Group 3 one-dimensional and
+	// positive for Group 3 two-dimensional.
+	K                int
+	Columns          int
+	Rows             int
+	BlackIs1         bool
+	EncodedByteAlign bool
+	EndOfLine        bool
+	EndOfBlock       bool
+}
+
+// Image filters not decoded, the data is returned encoded.
+var imagePassthroughFilters = map[PdfObjectName]bool{
+	"DCTDecode": true, "CCITTFaxDecode": true, "JBIG2Decode": true,
+}
+
+// Get the images in the XObject resources of a page.
+func (this *PdfReader) GetPageImages(pageNumber int) ([]PdfImage, error) {
+	pageObj, err := this.GetPage(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	page, ok := pageObj.(*PdfIndirectObject)
+	if !ok {
+		return nil, errors.New("Page not an indirect object")
+	}
+
+	images := []PdfImage{}
+
+	resources, err := this.getInheritedAttribute(page, "Resources")
+	if err != nil {
+		return nil, err
+	}
+	resDict, ok := resources.(*PdfObjectDictionary)
+	if !ok {
+		return images, nil
+	}
+	xobjs, err := this.traceToDirectObject((*resDict)["XObject"])
+	if err != nil {
+		return nil, err
+	}
+	xobjDict, ok := xobjs.(*PdfObjectDictionary)
+	if !ok {
+		return images, nil
+	}
+
+	for _, name := range xobjDict.sortedKeys() {
+		obj := (*xobjDict)[name]
+		if ref, isRef := obj.(*PdfObjectReference); isRef {
+			obj, _, err = this.resolveReference(ref)
+			if err != nil {
+				return nil, err
+			}
+		}
+		stream, ok := obj.(*PdfObjectStream)
+		if !ok {
+			continue
+		}
+		if subtype, ok := (*stream.PdfObjectDictionary)["Subtype"].(*PdfObjectName); !ok || *subtype != "Image" {
+			continue
+		}
+
+		img, err := this.loadImage(stream)
+		if err != nil {
+			log.Error("Unable to load image %s (%s)", name, err)
+			return nil, err
+		}
+		img.Name = name
+		images = append(images, *img)
+	}
+
+	return images, nil
+}
+
+// Load an image from an image XObject stream.
+func (this *PdfReader) loadImage(stream *PdfObjectStream) (*PdfImage, error) {
+	dict := stream.PdfObjectDictionary
+	img := PdfImage{}
+
+	getInt := func(key PdfObjectName) (int, error) {
+		obj, err := this.traceToDirectObject((*dict)[key])
+		if err != nil {
+			return 0, err
+		}
+		val, ok := obj.(*PdfObjectInteger)
+		if !ok {
+			return 0, fmt.Errorf("Invalid image %s", key)
+		}
+		return int(*val), nil
+	}
+
+	var err error
+	img.Width, err = getInt("Width")
+	if err != nil {
+		return nil, err
+	}
+	img.Height, err = getInt("Height")
+	if err != nil {
+		return nil, err
+	}
+
+	if mask, ok := (*dict)["ImageMask"].(*PdfObjectBool); ok && bool(*mask) {
+		img.ImageMask = true
+		img.BitsPerComponent = 1
+	} else {
+		img.BitsPerComponent, err = getInt("BitsPerComponent")
+		if err != nil {
+			// Not required for JPX images.
+			log.Debug("Image missing BitsPerComponent")
+		}
+		err = this.loadImageColorSpace(&img, (*dict)["ColorSpace"])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	filters, decodeParams, err := getStreamFilters(dict)
+	if err != nil {
+		return nil, err
+	}
+	img.Data = stream.Stream
+	for idx, filter := range filters {
+		// Image filters come last, preceded by e.g. ASCII filters.
+		if imagePassthroughFilters[filter] && idx == len(filters)-1 {
+			err = this.loadImageFilter(&img, filter, decodeParams[idx])
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		img.Data, err = decodeStreamData(filter, img.Data, decodeParams[idx])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &img, nil
+}
+
+// Set the filter of image data returned encoded, with the parameters.
+func (this *PdfReader) loadImageFilter(img *PdfImage, filter PdfObjectName, decodeParams *PdfObjectDictionary) error {
+	img.Filter = filter
+	img.DecodeParms = decodeParams
+	switch filter {
+	case "DCTDecode":
+		img.IsJPEG = true
+		img.InvertedCMYK = img.ColorSpace == "DeviceCMYK" && hasAdobeMarker(img.Data)
+	case "CCITTFaxDecode":
+		params := CCITTFaxParams{Columns: 1728, EndOfBlock: true}
+		if decodeParams != nil {
+			getInt := func(key string, val *int) {
+				if i, ok := decodeParams.GetInt(key); ok {
+					*val = int(i)
+				}
+			}
+			getBool := func(key string, val *bool) {
+				if b, ok := (*decodeParams)[PdfObjectName(key)].(*PdfObjectBool); ok {
+					*val = bool(*b)
+				}
+			}
+			getInt("K", &params.K)
+			getInt("Columns", &params.Columns)
+			getInt("Rows", &params.Rows)
+			getBool("BlackIs1", &params.BlackIs1)
+			getBool("EncodedByteAlign", &params.EncodedByteAlign)
+			getBool("EndOfLine", &params.EndOfLine)
+			getBool("EndOfBlock", &params.EndOfBlock)
+		}
+		if params.Rows == 0 {
+			params.Rows = img.Height
+		}
+		img.CCITTFax = &params
+	case "JBIG2Decode":
+		if decodeParams == nil {
+			return nil
+		}
+		globalsObj, hasGlobals := (*decodeParams)["JBIG2Globals"]
+		if !hasGlobals {
+			return nil
+		}
+		if ref, isRef := globalsObj.(*PdfObjectReference); isRef {
+			var err error
+			globalsObj, _, err = this.resolveReference(ref)
+			if err != nil {
+				return err
+			}
+		}
+		globals, ok := globalsObj.(*PdfObjectStream)
+		if !ok {
+			log.Error("JBIG2Globals not a stream (%T)", globalsObj)
+			return errors.New("JBIG2Globals not a stream")
+		}
+		var err error
+		img.JBIG2Globals, err = this.parser.decodeStream(globals)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check if JPEG data has an Adobe marker, an APP14 segment starting with
+// "Adobe", before the image scan.
+func hasAdobeMarker(data []byte) bool {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return false
+	}
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xFF {
+			return false
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			// Fill byte.
+			pos++
+			continue
+		}
+		// Start of scan.
+		if marker == 0xDA {
+			return false
+		}
+		length := int(data[pos+2])<<8 | int(data[pos+3])
+		if length < 2 || pos+2+length > len(data) {
+			return false
+		}
+		if marker == 0xEE && bytes.HasPrefix(data[pos+4:pos+2+length], []byte("Adobe")) {
+			return true
+		}
+		pos += 2 + length
+	}
+	return false
+}
+
+// Load the color space of an image, either a name or an array with the
+// family name first.
+func (this *PdfReader) loadImageColorSpace(img *PdfImage, obj PdfObject) error {
+	csObj, err := this.traceToDirectObject(obj)
+	if err != nil {
+		return err
+	}
+
+	if name, isName := csObj.(*PdfObjectName); isName {
+		img.ColorSpace = *name
+		return nil
+	}
+	arr, isArray := csObj.(*PdfObjectArray)
+	if !isArray || len(*arr) == 0 {
+		log.Debug("Image without a color space (%T)", csObj)
+		return nil
+	}
+	family, ok := (*arr)[0].(*PdfObjectName)
+	if !ok {
+		return errors.New("Invalid color space")
+	}
+	img.ColorSpace = *family
+	if *family != "Indexed" {
+		return nil
+	}
+
+	// [/Indexed base hival lookup]
+	if len(*arr) != 4 {
+		return errors.New("Invalid Indexed color space")
+	}
+	baseObj, err := this.traceToDirectObject((*arr)[1])
+	if err != nil {
+		return err
+	}
+	if base, ok := baseObj.(*PdfObjectName); ok {
+		img.BaseColorSpace = *base
+	} else if baseArr, ok := baseObj.(*PdfObjectArray); ok && len(*baseArr) > 0 {
+		if base, ok := (*baseArr)[0].(*PdfObjectName); ok {
+			img.BaseColorSpace = *base
+		}
+	}
+
+	lookupObj := (*arr)[3]
+	if ref, isRef := lookupObj.(*PdfObjectReference); isRef {
+		lookupObj, _, err = this.resolveReference(ref)
+		if err != nil {
+			return err
+		}
+	}
+	if str, isString := asString(lookupObj); isString {
+		lookupObj = str
+	}
+	switch lookup := lookupObj.(type) {
+	case *PdfObjectString:
+		img.Palette = []byte(*lookup)
+	case *PdfObjectStream:
+		img.Palette, err = this.parser.decodeStream(lookup)
+		if err != nil {
+			return err
+		}
+	case *PdfIndirectObject:
+		if str, ok := asString(lookup.PdfObject); ok {
+			img.Palette = []byte(*str)
+		}
+	default:
+		return errors.New("Invalid Indexed color space lookup")
+	}
+
+	return nil
+}
+
+// Get the thumbnail image of a page (Thumb), decoded like an image
+// XObject.  Returns nil if the page has no thumbnail.
+func (this *PdfReader) GetPageThumbnail(pageNumber int) (*PdfImage, error) {
+	pageObj, err := this.GetPage(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	page, ok := pageObj.(*PdfIndirectObject)
+	if !ok {
+		return nil, errors.New("Page not an indirect object")
+	}
+	pDict, ok := page.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return nil, errors.New("Page not a dictionary")
+	}
+
+	obj, hasThumb := (*pDict)["Thumb"]
+	if !hasThumb {
+		return nil, nil
+	}
+	if ref, isRef := obj.(*PdfObjectReference); isRef {
+		obj, _, err = this.resolveReference(ref)
+		if err != nil {
+			return nil, err
+		}
+	}
+	stream, ok := obj.(*PdfObjectStream)
+	if !ok {
+		log.Error("Thumbnail not a stream (%T)", obj)
+		return nil, errors.New("Thumbnail not a stream")
+	}
+	return this.loadImage(stream)
+}
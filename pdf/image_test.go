@@ -0,0 +1,211 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+func TestGetPageImages(t *testing.T) {
+	pixels := []byte{0, 1, 1, 0}
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(pixels)
+	zw.Close()
+
+	jpeg := "\xff\xd8\xff\xe0fakejpeg\xff\xd9"
+
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] " +
+			"/Resources << /XObject << /Im1 4 0 R /Im2 5 0 R /Im3 6 0 R /Fm1 7 0 R >> >> >>",
+		fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width 2 /Height 2 /BitsPerComponent 8 "+
+			"/ColorSpace [/Indexed /DeviceRGB 1 <FF000000FF00>] /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+			compressed.Len(), compressed.Bytes()),
+		fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width 3 /Height 4 /BitsPerComponent 8 "+
+			"/ColorSpace /DeviceRGB /Filter /DCTDecode /Length %d >>\nstream\n%s\nendstream", len(jpeg), jpeg),
+		"<< /Type /XObject /Subtype /Image /Width 8 /Height 1 /ImageMask true /Length 1 >>\nstream\n\xaa\nendstream",
+		"<< /Type /XObject /Subtype /Form /BBox [0 0 10 10] /Length 0 >>\nstream\n\nendstream",
+	})
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	images, err := reader.GetPageImages(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(images) != 3 {
+		t.Fatalf("Expected 3 images, got %d", len(images))
+	}
+
+	img := images[0]
+	if img.Name != "Im1" || img.Width != 2 || img.Height != 2 || img.BitsPerComponent != 8 {
+		t.Errorf("Invalid indexed image %+v", img)
+	}
+	if img.ColorSpace != "Indexed" || img.BaseColorSpace != "DeviceRGB" || len(img.Palette) != 6 {
+		t.Errorf("Invalid indexed color space %+v", img)
+	}
+	if img.IsJPEG || !bytes.Equal(img.Data, pixels) {
+		t.Errorf("Invalid indexed image data % x", img.Data)
+	}
+
+	img = images[1]
+	if !img.IsJPEG || string(img.Data) != jpeg || img.ColorSpace != "DeviceRGB" {
+		t.Errorf("Invalid JPEG image %+v", img)
+	}
+
+	img = images[2]
+	if !img.ImageMask || img.BitsPerComponent != 1 || !bytes.Equal(img.Data, []byte{0xaa}) {
+		t.Errorf("Invalid image mask %+v", img)
+	}
+}
+
+func TestGetPageThumbnail(t *testing.T) {
+	pixels := []byte{0xff, 0, 0, 0, 0xff, 0, 0, 0, 0xff, 0xff, 0xff, 0xff}
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /MediaBox [0 0 100 100] >>",
+		"<< /Type /Page /Parent 2 0 R /Thumb 5 0 R >>",
+		"<< /Type /Page /Parent 2 0 R >>",
+		fmt.Sprintf("<< /Width 2 /Height 2 /BitsPerComponent 8 /ColorSpace /DeviceRGB /Filter /ASCIIHexDecode /Length %d >>\nstream\n%x>\nendstream",
+			2*len(pixels)+1, pixels),
+	})
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	thumb, err := reader.GetPageThumbnail(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if thumb == nil || thumb.Width != 2 || thumb.Height != 2 || thumb.ColorSpace != "DeviceRGB" {
+		t.Fatalf("Invalid thumbnail %+v", thumb)
+	}
+	if !bytes.Equal(thumb.Data, pixels) {
+		t.Errorf("Invalid thumbnail data % x", thumb.Data)
+	}
+
+	thumb, err = reader.GetPageThumbnail(2)
+	if err != nil || thumb != nil {
+		t.Errorf("Expected no thumbnail (%v, %v)", thumb, err)
+	}
+}
+
+func TestGetPageImagesEncoded(t *testing.T) {
+	fax := "\x26\xa0\x12\x34"
+	jbig2 := "\x00\x00\x00\x01jbig2"
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] " +
+			"/Resources << /XObject << /Im1 4 0 R /Im2 5 0 R /Im3 6 0 R >> >> >>",
+		fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width 16 /Height 2 /BitsPerComponent 1 /ColorSpace /DeviceGray "+
+			"/Filter /CCITTFaxDecode /DecodeParms << /K -1 /Columns 16 /BlackIs1 true >> /Length %d >>\nstream\n%s\nendstream", len(fax), fax),
+		"<< /Type /XObject /Subtype /Image /Width 16 /Height 2 /BitsPerComponent 1 /ColorSpace /DeviceGray " +
+			"/Filter [/ASCIIHexDecode /CCITTFaxDecode] /DecodeParms [null << /Rows 1 >>] /Length 9 >>\nstream\n26a01234>\nendstream",
+		fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width 8 /Height 8 /BitsPerComponent 1 /ColorSpace /DeviceGray "+
+			"/Filter /JBIG2Decode /DecodeParms << /JBIG2Globals 7 0 R >> /Length %d >>\nstream\n%s\nendstream", len(jbig2), jbig2),
+		"<< /Length 7 >>\nstream\nglobals\nendstream",
+	})
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	images, err := reader.GetPageImages(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(images) != 3 {
+		t.Fatalf("Expected 3 images, got %d", len(images))
+	}
+
+	expected := []CCITTFaxParams{
+		{K: -1, Columns: 16, Rows: 2, BlackIs1: true, EndOfBlock: true},
+		{K: 0, Columns: 1728, Rows: 1, EndOfBlock: true},
+	}
+	for i, params := range expected {
+		img := images[i]
+		if img.Filter != "CCITTFaxDecode" || string(img.Data) != fax || img.IsJPEG {
+			t.Errorf("Invalid CCITT image %+v", img)
+		}
+		if img.CCITTFax == nil || *img.CCITTFax != params {
+			t.Errorf("CCITT parameters %+v (expected %+v)", img.CCITTFax, params)
+		}
+	}
+
+	img := images[2]
+	if img.Filter != "JBIG2Decode" || string(img.Data) != jbig2 || string(img.JBIG2Globals) != "globals" || img.CCITTFax != nil {
+		t.Errorf("Invalid JBIG2 image %+v", img)
+	}
+}
+
+func TestGetPageImagesJPEG(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 6, 4))
+	for i := range src.Pix {
+		src.Pix[i] = byte(i * 7)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, nil); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	rgb := buf.Bytes()
+	// The same data with an Adobe marker after the start of image.
+	adobe := append([]byte{0xFF, 0xD8, 0xFF, 0xEE, 0, 14}, "Adobe\x00\x64\x00\x00\x00\x00\x02"...)
+	adobe = append(adobe, rgb[2:]...)
+
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] " +
+			"/Resources << /XObject << /Im1 4 0 R /Im2 5 0 R /Im3 6 0 R >> >> >>",
+		fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width 6 /Height 4 /BitsPerComponent 8 "+
+			"/ColorSpace /DeviceRGB /Filter /DCTDecode /Length %d >>\nstream\n%s\nendstream", len(rgb), rgb),
+		fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width 6 /Height 4 /BitsPerComponent 8 "+
+			"/ColorSpace /DeviceCMYK /Filter /DCTDecode /Length %d >>\nstream\n%s\nendstream", len(adobe), adobe),
+		fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width 6 /Height 4 /BitsPerComponent 8 "+
+			"/ColorSpace /DeviceCMYK /Filter /DCTDecode /Length %d >>\nstream\n%s\nendstream", len(rgb), rgb),
+	})
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	images, err := reader.GetPageImages(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(images) != 3 {
+		t.Fatalf("Expected 3 images, got %d", len(images))
+	}
+
+	img := images[0]
+	if !img.IsJPEG || img.Filter != "DCTDecode" || img.ColorSpace != "DeviceRGB" || img.InvertedCMYK {
+		t.Errorf("Invalid JPEG image %+v", img)
+	}
+	decoded, err := jpeg.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		t.Fatalf("Exported JPEG invalid: %v", err)
+	}
+	if decoded.Bounds() != src.Bounds() {
+		t.Errorf("Exported JPEG bounds %v (expected %v)", decoded.Bounds(), src.Bounds())
+	}
+
+	if !images[1].InvertedCMYK {
+		t.Errorf("Adobe CMYK JPEG not flagged")
+	}
+	if images[2].InvertedCMYK {
+		t.Errorf("CMYK JPEG without Adobe marker flagged")
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Document level JavaScript (12.6.4.16).
+
+package pdf
+
+import (
+	"errors"
+)
+
+// A document level script.
+type NamedScript struct {
+	// Name in the JavaScript name tree, empty for the open action.
+	Name string
+	JS   string
+	// Executed when the document is opened (OpenAction).
+	OpenAction bool
+}
+
+// Load a script, either a text string or a stream.
+func (this *PdfReader) loadJavaScript(jsObj PdfObject) (string, error) {
+	if ref, isRef := jsObj.(*PdfObjectReference); isRef {
+		var err error
+		jsObj, _, err = this.resolveReference(ref)
+		if err != nil {
+			return "", err
+		}
+	}
+	if io, isIndirect := jsObj.(*PdfIndirectObject); isIndirect {
+		jsObj = io.PdfObject
+	}
+
+	if str, isString := asString(jsObj); isString {
+		jsObj = str
+	}
+	switch t := jsObj.(type) {
+	case *PdfObjectString:
+		return decodeTextString(t), nil
+	case *PdfObjectStream:
+		data, err := this.parser.decodeStream(t)
+		if err != nil {
+			return "", err
+		}
+		str := PdfObjectString(data)
+		return decodeTextString(&str), nil
+	}
+	log.Error("Invalid JavaScript (%T)", jsObj)
+	return "", errors.New("Invalid JavaScript")
+}
+
+// Get the document level scripts from the JavaScript name tree in the
+// catalog Names dictionary, followed by the script of the open action if
+// it is a JavaScript action.
+func (this *PdfReader) GetDocumentJavaScript() ([]NamedScript, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+
+	scripts := []NamedScript{}
+
+	namesObj, err := this.traceToDirectObject((*this.catalog)["Names"])
+	if err != nil {
+		return nil, err
+	}
+	if names, ok := namesObj.(*PdfObjectDictionary); ok {
+		if tree, hasTree := (*names)["JavaScript"]; hasTree {
+			entries, err := this.getNameTreeEntries(tree)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				action, err := this.resolveAction(entry.value)
+				if err != nil {
+					return nil, err
+				}
+				if action.Type != "JavaScript" {
+					log.Debug("Not a JavaScript action (%s)", action.Type)
+					continue
+				}
+				scripts = append(scripts, NamedScript{Name: entry.key, JS: action.JS})
+			}
+		}
+	}
+
+	action, err := this.GetOpenAction()
+	if err != nil {
+		return nil, err
+	}
+	if action != nil && action.Type == "JavaScript" {
+		scripts = append(scripts, NamedScript{JS: action.JS, OpenAction: true})
+	}
+
+	return scripts, nil
+}
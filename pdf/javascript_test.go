@@ -0,0 +1,48 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestGetDocumentJavaScript(t *testing.T) {
+	script := "app.alert('From stream');"
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R /Names << /JavaScript 4 0 R >> " +
+			"/OpenAction << /S /JavaScript /JS (this.print\\(\\);) >> >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+		"<< /Names [(Init) << /S /JavaScript /JS (var x = 1;) >> (Stream) 5 0 R] >>",
+		"<< /S /JavaScript /JS 6 0 R >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(script), script),
+	})
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	scripts, err := reader.GetDocumentJavaScript()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	expected := []NamedScript{
+		{Name: "Init", JS: "var x = 1;"},
+		{Name: "Stream", JS: script},
+		{JS: "this.print();", OpenAction: true},
+	}
+	if !reflect.DeepEqual(scripts, expected) {
+		t.Errorf("Unexpected scripts %+v", scripts)
+	}
+
+	scripts, err = loadMinimalReader(t).GetDocumentJavaScript()
+	if err != nil || scripts == nil || len(scripts) != 0 {
+		t.Errorf("Expected no scripts (%v, %v)", scripts, err)
+	}
+}
@@ -0,0 +1,577 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Linearized PDF files for fast web view (Annex F).
+
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Write the PDF file linearized (web optimized), so a viewer can display
+// the first page before the whole file has been downloaded.  The first
+// page and the objects it uses are written first, after a linearization
+// parameter dictionary and a hint stream.  The objects are serialized in
+// memory before writing.
+func (this *PdfWriter) SetLinearize(linearize bool) {
+	this.linearize = linearize
+}
+
+// Check whether the document is linearized: the first object of the file,
+// within the first 1024 bytes, is a linearization parameter dictionary
+// (Linearized) with the file length (L) matching the length of the file.
+// A linearized file updated incrementally is no longer linearized.
+func (this *PdfReader) IsLinearized() (bool, error) {
+	this.cacheMu.Lock()
+	defer this.cacheMu.Unlock()
+	parser := this.parser
+	offset := parser.GetFileOffset()
+	defer parser.SetFileOffset(offset)
+
+	parser.SetFileOffset(0)
+	head := make([]byte, 1024)
+	n, err := io.ReadFull(parser.reader, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	loc := reIndirectObject.FindIndex(head[:n])
+	if loc == nil {
+		return false, nil
+	}
+	parser.SetFileOffset(int64(loc[0]))
+	obj, err := parser.parseIndirectObject()
+	if err != nil {
+		return false, err
+	}
+	ind, ok := obj.(*PdfIndirectObject)
+	if !ok {
+		return false, nil
+	}
+	dict, ok := ind.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return false, nil
+	}
+	if _, linearized := (*dict)["Linearized"]; !linearized {
+		return false, nil
+	}
+	length, ok := (*dict)["L"].(*PdfObjectInteger)
+	if !ok {
+		log.Error("Invalid linearization file length (%T)", (*dict)["L"])
+		return false, errors.New("Invalid linearization file length")
+	}
+	fileSize, err := parser.fileSize()
+	if err != nil {
+		return false, err
+	}
+	return int64(*length) == fileSize, nil
+}
+
+// Writes the bits of the hint tables, most significant bit first.
+type hintBitWriter struct {
+	buf   bytes.Buffer
+	cur   byte
+	nbits uint
+}
+
+func (this *hintBitWriter) write(val int, nbits int) {
+	for i := nbits - 1; i >= 0; i-- {
+		this.cur = this.cur<<1 | byte((val>>uint(i))&1)
+		this.nbits++
+		if this.nbits == 8 {
+			this.buf.WriteByte(this.cur)
+			this.cur, this.nbits = 0, 0
+		}
+	}
+}
+
+// Pad to a byte boundary.
+func (this *hintBitWriter) flush() {
+	if this.nbits > 0 {
+		this.write(0, int(8-this.nbits))
+	}
+}
+
+// Number of bits needed to represent a value.
+func bitsNeeded(val int) int {
+	n := 0
+	for ; val > 0; val >>= 1 {
+		n++
+	}
+	return n
+}
+
+// Get the minimum and maximum of values.
+func minMax(vals []int) (int, int) {
+	if len(vals) == 0 {
+		return 0, 0
+	}
+	min, max := vals[0], vals[0]
+	for _, val := range vals[1:] {
+		if val < min {
+			min = val
+		}
+		if val > max {
+			max = val
+		}
+	}
+	return min, max
+}
+
+// Get the pages under a page tree node in order, and the intermediate
+// nodes.
+func collectPageTree(node *PdfIndirectObject, pages *[]*PdfIndirectObject, nodes map[PdfObject]bool) {
+	dict, ok := node.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return
+	}
+	if t, ok := (*dict)["Type"].(*PdfObjectName); ok && *t == "Page" {
+		*pages = append(*pages, node)
+		return
+	}
+	nodes[node] = true
+	kids, ok := (*dict)["Kids"].(*PdfObjectArray)
+	if !ok {
+		return
+	}
+	for _, kid := range *kids {
+		if kidNode, ok := kid.(*PdfIndirectObject); ok && !nodes[kidNode] {
+			collectPageTree(kidNode, pages, nodes)
+		}
+	}
+}
+
+// Collect the added objects referenced from an object, in traversal
+// order.  Parent references and the skipped objects (other pages, page
+// tree nodes and document level objects) are not followed.
+func collectReferencedObjects(obj PdfObject, added, skip, visited map[PdfObject]bool, objs *[]PdfObject) {
+	switch t := obj.(type) {
+	case *PdfIndirectObject:
+		if visited[t] || skip[t] || !added[t] {
+			return
+		}
+		visited[t] = true
+		*objs = append(*objs, t)
+		collectReferencedObjects(t.PdfObject, added, skip, visited, objs)
+	case *PdfObjectStream:
+		if visited[t] || skip[t] || !added[t] {
+			return
+		}
+		visited[t] = true
+		*objs = append(*objs, t)
+		collectReferencedObjects(t.PdfObjectDictionary, added, skip, visited, objs)
+	case *PdfObjectDictionary:
+		for _, key := range t.sortedKeys() {
+			if key != "Parent" {
+				collectReferencedObjects((*t)[key], added, skip, visited, objs)
+			}
+		}
+	case *PdfObjectArray:
+		for _, o := range *t {
+			collectReferencedObjects(o, added, skip, visited, objs)
+		}
+	}
+}
+
+// Set the object number of an indirect or stream object.
+func setObjectNumber(obj PdfObject, num int) {
+	switch t := obj.(type) {
+	case *PdfIndirectObject:
+		t.ObjectNumber = int64(num)
+		t.GenerationNumber = 0
+	case *PdfObjectStream:
+		t.ObjectNumber = int64(num)
+		t.GenerationNumber = 0
+	}
+}
+
+// Compress, encrypt and serialize an object.
+func (this *PdfWriter) serializeObject(num int, obj PdfObject) ([]byte, error) {
+	if so, isStream := obj.(*PdfObjectStream); isStream && this.compressStreams {
+		err := this.compressStream(so)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if this.crypter != nil && obj != this.encryptObj {
+		err := this.crypter.Encrypt(obj, int64(num), 0)
+		if err != nil {
+			log.Error("Failed encrypting (%s)", err)
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	this.writer = bufio.NewWriter(&buf)
+	err := this.writeObject(num, obj)
+	if err != nil {
+		return nil, err
+	}
+	err = this.writer.Flush()
+	return buf.Bytes(), err
+}
+
+// Layout of the linearized file parts (F.3) holding objects.
+type linearizedLayout struct {
+	// Document catalog and encryption dictionary (part 4).
+	docObjects []PdfObject
+	// First page objects, starting with the page (part 6).
+	firstPage []PdfObject
+	// Objects of the other pages, each starting with the page (part 7).
+	otherPages [][]PdfObject
+	// Objects shared by the other pages (part 8).
+	shared []PdfObject
+	// Objects not used by the pages (part 9).
+	other []PdfObject
+	// Shared object hint table identifiers referenced by each page.
+	sharedRefs [][]int
+}
+
+// Assign the objects to the parts of the linearized file.
+func (this *PdfWriter) layoutLinearized(pages []*PdfIndirectObject, nodes map[PdfObject]bool) *linearizedLayout {
+	layout := linearizedLayout{}
+	layout.docObjects = []PdfObject{this.root}
+	if this.crypter != nil {
+		layout.docObjects = append(layout.docObjects, this.encryptObj)
+	}
+
+	added := map[PdfObject]bool{}
+	for _, obj := range this.objects {
+		added[obj] = true
+	}
+	skip := map[PdfObject]bool{this.root: true, this.infoObj: true}
+	if this.encryptObj != nil {
+		skip[this.encryptObj] = true
+	}
+	for node := range nodes {
+		skip[node] = true
+	}
+	for _, page := range pages {
+		skip[page] = true
+	}
+
+	// Objects used by each page and the number of pages using them.
+	pageObjects := make([][]PdfObject, len(pages))
+	usage := map[PdfObject]int{}
+	for i, page := range pages {
+		objs := []PdfObject{page}
+		visited := map[PdfObject]bool{page: true}
+		collectReferencedObjects(page.PdfObject, added, skip, visited, &objs)
+		pageObjects[i] = objs
+		for _, obj := range objs {
+			usage[obj]++
+		}
+	}
+
+	assigned := map[PdfObject]bool{}
+	for _, obj := range layout.docObjects {
+		assigned[obj] = true
+	}
+	// All the objects used by the first page are in the first page part.
+	sharedIndex := map[PdfObject]int{}
+	for _, obj := range pageObjects[0] {
+		sharedIndex[obj] = len(layout.firstPage)
+		layout.firstPage = append(layout.firstPage, obj)
+		assigned[obj] = true
+	}
+	for _, objs := range pageObjects[1:] {
+		for _, obj := range objs {
+			if !assigned[obj] && usage[obj] > 1 {
+				sharedIndex[obj] = len(pageObjects[0]) + len(layout.shared)
+				layout.shared = append(layout.shared, obj)
+				assigned[obj] = true
+			}
+		}
+	}
+	layout.sharedRefs = make([][]int, len(pages))
+	for i, objs := range pageObjects {
+		if i == 0 {
+			continue
+		}
+		private := []PdfObject{}
+		for _, obj := range objs {
+			if !assigned[obj] {
+				private = append(private, obj)
+				assigned[obj] = true
+			} else if idx, isShared := sharedIndex[obj]; isShared {
+				layout.sharedRefs[i] = append(layout.sharedRefs[i], idx)
+			}
+		}
+		layout.otherPages = append(layout.otherPages, private)
+	}
+	for _, obj := range this.objects {
+		if !assigned[obj] {
+			layout.other = append(layout.other, obj)
+		}
+	}
+	return &layout
+}
+
+// Make the page offset and shared object hint tables (F.4).  The offsets
+// and lengths are computed as if the hint stream was not present.
+func makeHintTables(layout *linearizedLayout, objNums map[PdfObject]int, lengths map[PdfObject]int, offsets map[PdfObject]int64) ([]byte, int) {
+	sumLengths := func(objs []PdfObject) int {
+		total := 0
+		for _, obj := range objs {
+			total += lengths[obj]
+		}
+		return total
+	}
+
+	numObjects := []int{len(layout.firstPage)}
+	pageLengths := []int{sumLengths(layout.firstPage)}
+	for _, objs := range layout.otherPages {
+		numObjects = append(numObjects, len(objs))
+		pageLengths = append(pageLengths, sumLengths(objs))
+	}
+	numShared := []int{}
+	maxSharedID := 0
+	for _, refs := range layout.sharedRefs {
+		numShared = append(numShared, len(refs))
+		for _, ref := range refs {
+			if ref > maxSharedID {
+				maxSharedID = ref
+			}
+		}
+	}
+	minObjects, maxObjects := minMax(numObjects)
+	minLength, maxLength := minMax(pageLengths)
+	_, maxNumShared := minMax(numShared)
+
+	// Page offset hint table (Tables F.3 and F.4).  The content stream
+	// offsets and lengths are not used by viewers and are written as 0.
+	bw := hintBitWriter{}
+	bw.write(minObjects, 32)
+	bw.write(int(offsets[layout.firstPage[0]]), 32)
+	objectsBits := bitsNeeded(maxObjects - minObjects)
+	bw.write(objectsBits, 16)
+	bw.write(minLength, 32)
+	lengthBits := bitsNeeded(maxLength - minLength)
+	bw.write(lengthBits, 16)
+	bw.write(0, 32)
+	bw.write(0, 16)
+	bw.write(0, 32)
+	bw.write(0, 16)
+	numSharedBits := bitsNeeded(maxNumShared)
+	bw.write(numSharedBits, 16)
+	sharedIDBits := bitsNeeded(maxSharedID)
+	bw.write(sharedIDBits, 16)
+	bw.write(0, 16)
+	bw.write(1, 16)
+
+	for _, n := range numObjects {
+		bw.write(n-minObjects, objectsBits)
+	}
+	bw.flush()
+	for _, length := range pageLengths {
+		bw.write(length-minLength, lengthBits)
+	}
+	bw.flush()
+	for _, n := range numShared {
+		bw.write(n, numSharedBits)
+	}
+	bw.flush()
+	for _, refs := range layout.sharedRefs {
+		for _, ref := range refs {
+			bw.write(ref, sharedIDBits)
+		}
+	}
+	bw.flush()
+	sharedOffset := bw.buf.Len()
+
+	// Shared object hint table (Tables F.5 and F.6), with a group for
+	// each object of the first page and the shared objects.
+	groups := append(append([]PdfObject{}, layout.firstPage...), layout.shared...)
+	groupLengths := []int{}
+	for _, obj := range groups {
+		groupLengths = append(groupLengths, lengths[obj])
+	}
+	minGroup, maxGroup := minMax(groupLengths)
+	if len(layout.shared) > 0 {
+		first := layout.shared[0]
+		bw.write(objNums[first], 32)
+		bw.write(int(offsets[first]), 32)
+	} else {
+		bw.write(0, 32)
+		bw.write(0, 32)
+	}
+	bw.write(len(layout.firstPage), 32)
+	bw.write(len(groups), 32)
+	bw.write(0, 16)
+	bw.write(minGroup, 32)
+	groupBits := bitsNeeded(maxGroup - minGroup)
+	bw.write(groupBits, 16)
+
+	for _, length := range groupLengths {
+		bw.write(length-minGroup, groupBits)
+	}
+	bw.flush()
+	// No MD5 signatures.
+	for range groups {
+		bw.write(0, 1)
+	}
+	bw.flush()
+
+	return bw.buf.Bytes(), sharedOffset
+}
+
+// Write the PDF file linearized.  The file parts (F.3) are: header,
+// linearization parameter dictionary, first page xref table and trailer,
+// document catalog, hint stream, first page objects, the other pages,
+// shared objects, other objects and the main xref table and trailer.  The
+// main part objects are numbered from 1, followed by the objects in the
+// first page xref table.
+func (this *PdfWriter) writeLinearized(out io.Writer, startOffset int64) error {
+	pages := []*PdfIndirectObject{}
+	nodes := map[PdfObject]bool{}
+	collectPageTree(this.pages, &pages, nodes)
+	if len(pages) == 0 {
+		return errors.New("Cannot linearize a document without pages")
+	}
+	layout := this.layoutLinearized(pages, nodes)
+
+	mainObjects := []PdfObject{}
+	for _, objs := range layout.otherPages {
+		mainObjects = append(mainObjects, objs...)
+	}
+	mainObjects = append(mainObjects, layout.shared...)
+	mainObjects = append(mainObjects, layout.other...)
+
+	linObj := PdfIndirectObject{}
+	hintStream := PdfObjectStream{}
+	firstObjects := []PdfObject{&linObj}
+	firstObjects = append(firstObjects, layout.docObjects...)
+	firstObjects = append(firstObjects, &hintStream)
+	firstObjects = append(firstObjects, layout.firstPage...)
+
+	objNums := map[PdfObject]int{}
+	for idx, obj := range mainObjects {
+		objNums[obj] = idx + 1
+	}
+	firstNum := len(mainObjects) + 1
+	for idx, obj := range firstObjects {
+		objNums[obj] = firstNum + idx
+	}
+	for obj, num := range objNums {
+		setObjectNumber(obj, num)
+	}
+	size := firstNum + len(firstObjects)
+
+	// Objects following the first page xref table in file order.
+	fileOrder := append([]PdfObject{}, firstObjects[1:]...)
+	fileOrder = append(fileOrder, mainObjects...)
+
+	// Serialize the objects, hashing the content for the document ID.
+	data := map[PdfObject][]byte{}
+	hasher := md5.New()
+	for _, obj := range fileOrder {
+		if obj == &hintStream {
+			continue
+		}
+		b, err := this.serializeObject(objNums[obj], obj)
+		if err != nil {
+			return err
+		}
+		data[obj] = b
+		hasher.Write(b)
+		if this.progress != nil {
+			this.progress(len(data), len(this.objects))
+		}
+	}
+	ids := this.ids
+	if ids == nil {
+		contentHash := hasher.Sum(nil)
+		ids = generateDocumentIDs(contentHash, contentHash)
+	}
+
+	// The values depending on the layout are padded to a fixed width, so
+	// the sizes are known before the layout.
+	header := this.header()
+	makeLinDict := func(length, hintOffset, hintLength, endOfFirstPage, mainXrefEntries int64) []byte {
+		return []byte(fmt.Sprintf("%d 0 obj\n<< /Linearized 1 /L %10d /H [ %10d %10d ] /O %d /E %10d /N %d /T %10d >>\nendobj\n",
+			objNums[&linObj], length, hintOffset, hintLength, objNums[pages[0]], endOfFirstPage, len(pages), mainXrefEntries))
+	}
+	trailerEntries := fmt.Sprintf("/Root %d 0 R /Info %d 0 R /ID %s", objNums[this.root], objNums[this.infoObj], ids.DefaultWriteString())
+	if this.crypter != nil {
+		trailerEntries += fmt.Sprintf(" /Encrypt %d 0 R", objNums[this.encryptObj])
+	}
+	makeFirstXref := func(offsets map[PdfObject]int64, mainXrefOffset int64) []byte {
+		var buf bytes.Buffer
+		buf.WriteString(fmt.Sprintf("xref\n%d %d\n", firstNum, len(firstObjects)))
+		for _, obj := range firstObjects {
+			buf.WriteString(fmt.Sprintf("%.10d %.5d n\r\n", offsets[obj], 0))
+		}
+		buf.WriteString(fmt.Sprintf("trailer\n<< /Size %d %s /Prev %10d >>\n", size, trailerEntries, mainXrefOffset))
+		buf.WriteString("startxref\n0\n%%EOF\n")
+		return buf.Bytes()
+	}
+	data[&linObj] = makeLinDict(0, 0, 0, 0, 0)
+	firstXref := makeFirstXref(nil, 0)
+
+	// Get the object offsets and the end offset of the objects.
+	layoutOffsets := func() (map[PdfObject]int64, int64) {
+		offsets := map[PdfObject]int64{}
+		offset := startOffset + int64(len(header))
+		offsets[&linObj] = offset
+		offset += int64(len(data[&linObj]) + len(firstXref))
+		for _, obj := range fileOrder {
+			offsets[obj] = offset
+			offset += int64(len(data[obj]))
+		}
+		return offsets, offset
+	}
+
+	// The hint tables use the offsets without the hint stream.
+	offsets, _ := layoutOffsets()
+	lengths := map[PdfObject]int{}
+	for obj, b := range data {
+		lengths[obj] = len(b)
+	}
+	hintData, sharedOffset := makeHintTables(layout, objNums, lengths, offsets)
+	hintDict := PdfObjectDictionary{}
+	hintDict["Length"] = makeInteger(int64(len(hintData)))
+	hintDict["S"] = makeInteger(int64(sharedOffset))
+	hintStream.PdfObjectDictionary = &hintDict
+	hintStream.Stream = hintData
+	b, err := this.serializeObject(objNums[&hintStream], &hintStream)
+	if err != nil {
+		return err
+	}
+	data[&hintStream] = b
+
+	offsets, mainXrefOffset := layoutOffsets()
+	lastFirstPageObj := layout.firstPage[len(layout.firstPage)-1]
+	endOfFirstPage := offsets[lastFirstPageObj] + int64(len(data[lastFirstPageObj]))
+
+	var mainXref bytes.Buffer
+	mainXref.WriteString(fmt.Sprintf("xref\n0 %d\n", firstNum))
+	// Offset of the end of line before the first entry.
+	mainXrefEntries := mainXrefOffset + int64(mainXref.Len()) - 1
+	mainXref.WriteString(fmt.Sprintf("%.10d %.5d f\r\n", 0, 65535))
+	for _, obj := range mainObjects {
+		mainXref.WriteString(fmt.Sprintf("%.10d %.5d n\r\n", offsets[obj], 0))
+	}
+	mainXref.WriteString(fmt.Sprintf("trailer\n<< /Size %d >>\n", firstNum))
+	mainXref.WriteString(fmt.Sprintf("startxref\n%d\n%%%%EOF\n", offsets[&linObj]+int64(len(data[&linObj]))))
+	fileLength := mainXrefOffset + int64(mainXref.Len()) - startOffset
+
+	data[&linObj] = makeLinDict(fileLength, offsets[&hintStream], int64(len(data[&hintStream])), endOfFirstPage, mainXrefEntries)
+	firstXref = makeFirstXref(offsets, mainXrefOffset)
+
+	w := bufio.NewWriter(out)
+	w.WriteString(header)
+	w.Write(data[&linObj])
+	w.Write(firstXref)
+	for _, obj := range fileOrder {
+		w.Write(data[obj])
+	}
+	w.Write(mainXref.Bytes())
+	return w.Flush()
+}
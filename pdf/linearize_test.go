@@ -0,0 +1,256 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Make a writer with pages sharing fonts: Helvetica on all the pages and
+// Times-Roman on pages 2 and up.
+func makeLinearizeTestWriter(t *testing.T, numPages int) *PdfWriter {
+	w := NewPdfWriter()
+	for i := 1; i <= numPages; i++ {
+		page := NewPage(PageWidthA4, PageHeightA4)
+		helv, err := w.AddStandardFont(page, "Helvetica")
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		builder := NewContentStreamBuilder().
+			BeginText().SetFont(string(helv), 12).MoveText(72, 720).ShowText(fmt.Sprintf("Page %d", i)).EndText()
+		if i > 1 {
+			times, err := w.AddStandardFont(page, "Times-Roman")
+			if err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+			builder.BeginText().SetFont(string(times), 10).MoveText(72, 700).ShowText("Times").EndText()
+		}
+		(*page.PdfObject.(*PdfObjectDictionary))["Contents"] = makeContentStream(builder.Bytes())
+		if err := w.AddPage(page); err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+	return &w
+}
+
+var reLinearized = regexp.MustCompile(`^%PDF-1\.\d\n%[^\n]*\n(\d+) 0 obj\n<< /Linearized 1 /L +(\d+) /H \[ +(\d+) +(\d+) \] /O (\d+) /E +(\d+) /N (\d+) /T +(\d+) >>`)
+
+// Check the linearization of a file: the parameters, the first page
+// objects preceding the other objects and the hint table offsets.
+func checkLinearized(t *testing.T, data []byte, numPages int) {
+	m := reLinearized.FindSubmatch(data)
+	if m == nil {
+		t.Fatalf("Linearization dictionary not found")
+	}
+	param := func(i int) int64 {
+		val, _ := strconv.ParseInt(string(m[i]), 10, 64)
+		return val
+	}
+	fileLength, hintOffset, hintLength, firstPageNum, endOfFirstPage, numPagesParam, mainXrefEntries :=
+		param(2), param(3), param(4), param(5), param(6), param(7), param(8)
+	if fileLength != int64(len(data)) {
+		t.Errorf("L %d (file length %d)", fileLength, len(data))
+	}
+	if numPagesParam != int64(numPages) {
+		t.Errorf("N %d (expected %d)", numPagesParam, numPages)
+	}
+	if !bytes.HasPrefix(data[mainXrefEntries:], []byte("\n0000000000 65535 f")) {
+		t.Errorf("T not at the main xref table entries")
+	}
+	if !bytes.HasPrefix(data[hintOffset:], []byte(fmt.Sprintf("%d 0 obj", param(1)+2))) {
+		t.Errorf("H not at the hint stream")
+	}
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	offsetOf := func(obj PdfObject) int64 {
+		var num int64
+		switch o := obj.(type) {
+		case *PdfIndirectObject:
+			num = o.ObjectNumber
+		case *PdfObjectStream:
+			num = o.ObjectNumber
+		}
+		return reader.parser.xrefs[int(num)].offset
+	}
+
+	page1, err := reader.GetPage(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if page1.(*PdfIndirectObject).ObjectNumber != firstPageNum {
+		t.Errorf("O %d is not the first page", firstPageNum)
+	}
+	firstPageOffset := offsetOf(page1)
+	if firstPageOffset != hintOffset+hintLength {
+		t.Errorf("First page not after the hint stream")
+	}
+	for i := 2; i <= numPages; i++ {
+		page, err := reader.GetPage(i)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if offsetOf(page) < endOfFirstPage {
+			t.Errorf("Page %d in the first page part", i)
+		}
+	}
+	contentsObj := (*page1.(*PdfIndirectObject).PdfObject.(*PdfObjectDictionary))["Contents"]
+	if ref, isRef := contentsObj.(*PdfObjectReference); isRef {
+		contentsObj, _, err = reader.resolveReference(ref)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+	if offset := offsetOf(contentsObj); offset < firstPageOffset || offset >= endOfFirstPage {
+		t.Errorf("First page contents not in the first page part")
+	}
+
+	// The hint table offsets are without the hint stream: the first page
+	// object location in the page offset hint table header.
+	hintObj, _, err := reader.resolveReference(&PdfObjectReference{ObjectNumber: param(1) + 2})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	hintData, err := reader.parser.decodeStream(hintObj.(*PdfObjectStream))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if int64(binary.BigEndian.Uint32(hintData[4:])) != firstPageOffset-hintLength {
+		t.Errorf("Invalid first page location in the hint table")
+	}
+
+	for i := 1; i <= numPages; i++ {
+		text, err := reader.ExtractPageText(i)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if strings.Split(text, "\n")[0] != fmt.Sprintf("Page %d", i) {
+			t.Errorf("Page %d: unexpected text %q", i, text)
+		}
+	}
+}
+
+func TestWriteLinearized(t *testing.T) {
+	w := makeLinearizeTestWriter(t, 3)
+	w.SetLinearize(true)
+	data, err := writePdfToBytes(w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	checkLinearized(t, data, 3)
+
+	// Page tree nodes and compression.
+	w = makeLinearizeTestWriter(t, 12)
+	w.SetLinearize(true)
+	if err := w.SetBalancedPageTree(4); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if err := w.SetCompressionLevel(-1); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	data, err = writePdfToBytes(w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	checkLinearized(t, data, 12)
+
+	// Not linearized by default.
+	w = makeLinearizeTestWriter(t, 1)
+	data, err = writePdfToBytes(w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if bytes.Contains(data, []byte("/Linearized")) {
+		t.Errorf("Linearized unless set")
+	}
+
+	w = makeLinearizeTestWriter(t, 0)
+	w.SetLinearize(true)
+	if _, err := writePdfToBytes(w); err == nil {
+		t.Errorf("Linearizing without pages should fail")
+	}
+}
+
+func TestWriteLinearizedEncrypted(t *testing.T) {
+	w := makeLinearizeTestWriter(t, 2)
+	w.SetLinearize(true)
+	if err := w.Encrypt([]byte("user"), []byte("owner"), nil); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	data, err := writePdfToBytes(w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if !reLinearized.Match(data) {
+		t.Fatalf("Linearization dictionary not found")
+	}
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if ok, err := reader.Decrypt([]byte("user")); err != nil || !ok {
+		t.Fatalf("Decrypt failed (%v)", err)
+	}
+	text, err := reader.ExtractPageText(2)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if text != "Page 2\nTimes" {
+		t.Errorf("Unexpected text %q", text)
+	}
+}
+
+func TestIsLinearized(t *testing.T) {
+	w := makeLinearizeTestWriter(t, 2)
+	w.SetLinearize(true)
+	linearized, err := writePdfToBytes(w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	w.SetLinearize(false)
+	plain, err := writePdfToBytes(w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	// An incremental update changes the file length.
+	updated := append(append([]byte{}, linearized...), "% update\n"...)
+	tests := []struct {
+		data     []byte
+		expected bool
+	}{
+		{linearized, true},
+		{plain, false},
+		{updated, false},
+	}
+	for i, test := range tests {
+		reader, err := NewPdfReader(bytes.NewReader(test.data))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		isLinearized, err := reader.IsLinearized()
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if isLinearized != test.expected {
+			t.Errorf("File %d: linearized %v (expected %v)", i, isLinearized, test.expected)
+		}
+		// Reading continues after checking.
+		text, err := reader.ExtractPageText(2)
+		if err != nil || text != "Page 2\nTimes" {
+			t.Errorf("File %d: text %q (%v)", i, text, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Transformation matrices (8.3.3).
+
+package pdf
+
+import (
+	"math"
+)
+
+// An affine transformation matrix [a b c d e f], mapping a point (x, y)
+// to (a*x + c*y + e, b*x + d*y + f).
+type PdfMatrix [6]float64
+
+// The identity matrix.
+func IdentityMatrix() PdfMatrix {
+	return PdfMatrix{1, 0, 0, 1, 0, 0}
+}
+
+// Concatenate two matrices: the result transforms by this matrix first,
+// then by the other (this x other).
+func (this PdfMatrix) Mult(other PdfMatrix) PdfMatrix {
+	a, b := this, other
+	return PdfMatrix{
+		a[0]*b[0] + a[1]*b[2],
+		a[0]*b[1] + a[1]*b[3],
+		a[2]*b[0] + a[3]*b[2],
+		a[2]*b[1] + a[3]*b[3],
+		a[4]*b[0] + a[5]*b[2] + b[4],
+		a[4]*b[1] + a[5]*b[3] + b[5],
+	}
+}
+
+// Followed by a translation by (tx, ty).
+func (this PdfMatrix) Translate(tx, ty float64) PdfMatrix {
+	return this.Mult(PdfMatrix{1, 0, 0, 1, tx, ty})
+}
+
+// Followed by scaling by (sx, sy).
+func (this PdfMatrix) Scale(sx, sy float64) PdfMatrix {
+	return this.Mult(PdfMatrix{sx, 0, 0, sy, 0, 0})
+}
+
+// Followed by a counterclockwise rotation by an angle in degrees.
+// Multiples of 90 degrees are exact.
+func (this PdfMatrix) Rotate(angle float64) PdfMatrix {
+	var sin, cos float64
+	switch math.Mod(math.Mod(angle, 360)+360, 360) {
+	case 0:
+		sin, cos = 0, 1
+	case 90:
+		sin, cos = 1, 0
+	case 180:
+		sin, cos = 0, -1
+	case 270:
+		sin, cos = -1, 0
+	default:
+		sin, cos = math.Sincos(angle * math.Pi / 180)
+	}
+	return this.Mult(PdfMatrix{cos, sin, -sin, cos, 0, 0})
+}
+
+// Transform a point.
+func (this PdfMatrix) Transform(x, y float64) (float64, float64) {
+	return this[0]*x + this[2]*y + this[4], this[1]*x + this[3]*y + this[5]
+}
+
+// Transform a rectangle, giving the bounding box of the transformed
+// corners.
+func (this PdfRectangle) Transform(m PdfMatrix) PdfRectangle {
+	xs := []float64{}
+	ys := []float64{}
+	for _, corner := range [][2]float64{
+		{this.Llx, this.Lly}, {this.Urx, this.Lly}, {this.Llx, this.Ury}, {this.Urx, this.Ury},
+	} {
+		x, y := m.Transform(corner[0], corner[1])
+		xs = append(xs, x)
+		ys = append(ys, y)
+	}
+
+	rect := PdfRectangle{xs[0], ys[0], xs[0], ys[0]}
+	for i := 1; i < 4; i++ {
+		rect.Llx = math.Min(rect.Llx, xs[i])
+		rect.Lly = math.Min(rect.Lly, ys[i])
+		rect.Urx = math.Max(rect.Urx, xs[i])
+		rect.Ury = math.Max(rect.Ury, ys[i])
+	}
+	return rect
+}
+
+// Make a matrix array [a b c d e f].
+func (this PdfMatrix) toArray() *PdfObjectArray {
+	arr := PdfObjectArray{}
+	for _, val := range this {
+		arr = append(arr, makeReal(val))
+	}
+	return &arr
+}
+
+// Get the matrix showing a page box upright, for a page shown rotated
+// clockwise by the rotation (Rotate).  The rotated box is moved to the
+// origin.
+func uprightPageMatrix(box PdfRectangle, rotation int) PdfMatrix {
+	m := IdentityMatrix().Rotate(float64(-rotation))
+	rotated := box.Transform(m)
+	return m.Translate(-rotated.Llx, -rotated.Lly)
+}
@@ -0,0 +1,59 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMatrixTransform(t *testing.T) {
+	testcases := []struct {
+		name   string
+		m      PdfMatrix
+		x, y   float64
+		tx, ty float64
+	}{
+		{"identity", IdentityMatrix(), 3, 4, 3, 4},
+		{"translate", IdentityMatrix().Translate(10, -5), 3, 4, 13, -1},
+		{"scale", IdentityMatrix().Scale(2, 0.5), 3, 4, 6, 2},
+		{"rotate 90", IdentityMatrix().Rotate(90), 3, 4, -4, 3},
+		{"rotate -90", IdentityMatrix().Rotate(-90), 3, 4, 4, -3},
+		{"rotate 180", IdentityMatrix().Rotate(180), 3, 4, -3, -4},
+		{"rotate 45", IdentityMatrix().Rotate(45), 1, 0, math.Sqrt2 / 2, math.Sqrt2 / 2},
+		// Scaled first, then translated.
+		{"scale translate", IdentityMatrix().Scale(2, 2).Translate(1, 1), 3, 4, 7, 9},
+		{"translate scale", IdentityMatrix().Translate(1, 1).Scale(2, 2), 3, 4, 8, 10},
+		{"mult", PdfMatrix{1, 2, 3, 4, 5, 6}.Mult(PdfMatrix{0, 1, 1, 0, 0, 0}), 1, 1, 12, 9},
+	}
+
+	for _, tcase := range testcases {
+		x, y := tcase.m.Transform(tcase.x, tcase.y)
+		if math.Abs(x-tcase.tx) > 1e-9 || math.Abs(y-tcase.ty) > 1e-9 {
+			t.Errorf("%s: (%v, %v) (expected (%v, %v))", tcase.name, x, y, tcase.tx, tcase.ty)
+		}
+	}
+}
+
+func TestRectangleTransform(t *testing.T) {
+	mediaBox := PdfRectangle{0, 0, 612, 792}
+	testcases := []struct {
+		m        PdfMatrix
+		expected PdfRectangle
+	}{
+		{IdentityMatrix(), mediaBox},
+		{IdentityMatrix().Rotate(90), PdfRectangle{-792, 0, 0, 612}},
+		{IdentityMatrix().Rotate(90).Translate(792, 0), PdfRectangle{0, 0, 792, 612}},
+		{IdentityMatrix().Scale(0.5, 0.5).Translate(10, 20), PdfRectangle{10, 20, 316, 416}},
+	}
+
+	for _, tcase := range testcases {
+		rect := mediaBox.Transform(tcase.m)
+		if rect != tcase.expected {
+			t.Errorf("%v: %+v (expected %+v)", tcase.m, rect, tcase.expected)
+		}
+	}
+}
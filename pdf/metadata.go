@@ -0,0 +1,141 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// XMP metadata streams (14.3.2), the document information dictionary
+// (14.3.3) and the document language (14.9.2).
+
+package pdf
+
+import (
+	"errors"
+)
+
+// Get the XMP metadata of the document, the decoded contents of the
+// catalog Metadata stream.  Returns nil if there is no metadata stream.
+func (this *PdfReader) GetXMPMetadata() ([]byte, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+
+	obj, hasMetadata := (*this.catalog)["Metadata"]
+	if !hasMetadata {
+		return nil, nil
+	}
+	if ref, isRef := obj.(*PdfObjectReference); isRef {
+		var err error
+		obj, _, err = this.resolveReference(ref)
+		if err != nil {
+			return nil, err
+		}
+	}
+	stream, ok := obj.(*PdfObjectStream)
+	if !ok {
+		log.Error("Metadata not a stream (%T)", obj)
+		return nil, errors.New("Metadata not a stream")
+	}
+
+	return this.parser.decodeStream(stream)
+}
+
+// Set the XMP metadata of the document.  The metadata is written as an
+// uncompressed stream referenced from the catalog.
+func (this *PdfWriter) SetXMPMetadata(xml []byte) error {
+	dict := PdfObjectDictionary{}
+	dict["Type"] = makeName("Metadata")
+	dict["Subtype"] = makeName("XML")
+	dict["Length"] = makeInteger(int64(len(xml)))
+
+	stream := PdfObjectStream{}
+	stream.PdfObjectDictionary = &dict
+	stream.Stream = xml
+
+	(*this.catalog)["Metadata"] = &stream
+	return this.addObjects(&stream)
+}
+
+// Entries of the document information dictionary.  The dates are as
+// written, e.g. D:20170102150405+01'00'.  Empty if not set.
+type DocumentInfo struct {
+	Title        string
+	Author       string
+	Subject      string
+	Keywords     string
+	Creator      string
+	Producer     string
+	CreationDate string
+	ModDate      string
+	// The information dictionary, e.g. for custom entries.
+	Dict *PdfObjectDictionary
+}
+
+// Get the document information dictionary, the trailer Info entry, either
+// a reference (usually) or a direct dictionary.  Returns nil if the
+// document has no information dictionary.
+func (this *PdfReader) GetDocumentInfo() (*DocumentInfo, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+
+	obj, err := this.traceToDirectObject((*this.parser.trailer)["Info"])
+	if err != nil {
+		return nil, err
+	}
+	var dict *PdfObjectDictionary
+	switch t := obj.(type) {
+	case nil, *PdfObjectNull:
+		return nil, nil
+	case *PdfObjectDictionary:
+		dict = t
+	default:
+		log.Error("Info not a dictionary (%T)", obj)
+		return nil, errors.New("Info not a dictionary")
+	}
+
+	info := DocumentInfo{Dict: dict}
+	for key, val := range map[PdfObjectName]*string{
+		"Title": &info.Title, "Author": &info.Author, "Subject": &info.Subject, "Keywords": &info.Keywords,
+		"Creator": &info.Creator, "Producer": &info.Producer, "CreationDate": &info.CreationDate, "ModDate": &info.ModDate,
+	} {
+		strObj, err := this.traceToDirectObject((*dict)[key])
+		if err != nil {
+			return nil, err
+		}
+		if str, ok := asString(strObj); ok {
+			*val = decodeTextString(str)
+		} else if strObj != nil {
+			log.Debug("Info %s not a string (%T)", key, strObj)
+		}
+	}
+	return &info, nil
+}
+
+// Get the natural language of the document, the catalog Lang entry (a
+// BCP 47 language tag such as en-US).  Returns an empty string if not
+// specified.
+func (this *PdfReader) GetDocumentLanguage() (string, error) {
+	obj, err := this.GetCatalogEntry("Lang")
+	if err != nil {
+		return "", err
+	}
+	if obj == nil {
+		return "", nil
+	}
+	lang, ok := asString(obj)
+	if !ok {
+		log.Error("Lang not a string (%T)", obj)
+		return "", errors.New("Lang not a string")
+	}
+	return decodeTextString(lang), nil
+}
+
+// Set the natural language of the document as a BCP 47 language tag,
+// e.g. en-US.
+func (this *PdfWriter) SetDocumentLanguage(lang string) error {
+	if lang == "" {
+		return errors.New("Language tag cannot be empty")
+	}
+	(*this.catalog)["Lang"] = encodeTextString(lang)
+	return nil
+}
@@ -0,0 +1,273 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+const testXMP = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Test</dc:title></rdf:Description>
+</rdf:RDF></x:xmpmeta>
+<?xpacket end="w"?>`
+
+func TestGetXMPMetadata(t *testing.T) {
+	reader := loadMinimalReader(t)
+	xmp, err := reader.GetXMPMetadata()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if xmp != nil {
+		t.Errorf("Expected no metadata, got %q", xmp)
+	}
+
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R /Metadata 4 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+		fmt.Sprintf("<< /Type /Metadata /Subtype /XML /Length %d >>\nstream\n%s\nendstream", len(testXMP), testXMP),
+	})
+	reader, err = NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	xmp, err = reader.GetXMPMetadata()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if string(xmp) != testXMP {
+		t.Errorf("Unexpected metadata %q", xmp)
+	}
+}
+
+func TestXMPMetadataRoundTrip(t *testing.T) {
+	w := NewPdfWriter()
+	err := w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	err = w.SetXMPMetadata([]byte(testXMP))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if !bytes.Contains(out, []byte("<dc:title>Test</dc:title>")) {
+		t.Errorf("Metadata stream should be written uncompressed")
+	}
+
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error reading output: %v", err)
+	}
+	xmp, err := reader.GetXMPMetadata()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if string(xmp) != testXMP {
+		t.Errorf("Unexpected metadata %q", xmp)
+	}
+}
+
+func TestUnencryptedMetadataStream(t *testing.T) {
+	crypter := PdfCrypt{}
+	crypter.encryptedObjects = map[PdfObject]bool{}
+	crypter.cryptFilters = CryptFilters{"Default": CryptFilter{cfm: "V2", length: 128}}
+	crypter.V = 2
+	crypter.R = 3
+	crypter.length = 128
+	crypter.encryptionKey = []byte("0123456789abcdef")
+
+	makeStream := func(typ string) *PdfObjectStream {
+		dict := PdfObjectDictionary{}
+		dict["Type"] = makeName(typ)
+		dict["Length"] = makeInteger(5)
+		return &PdfObjectStream{PdfObjectDictionary: &dict, Stream: []byte("hello")}
+	}
+
+	metadata := makeStream("Metadata")
+	other := makeStream("XObject")
+	for _, so := range []*PdfObjectStream{metadata, other} {
+		if err := crypter.Encrypt(so, 1, 0); err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+	if string(metadata.Stream) != "hello" {
+		t.Errorf("Metadata stream should not be encrypted")
+	}
+	if string(other.Stream) == "hello" {
+		t.Errorf("Other streams should be encrypted")
+	}
+
+	crypter.encryptMetadata = true
+	metadata = makeStream("Metadata")
+	if err := crypter.Encrypt(metadata, 1, 0); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if string(metadata.Stream) == "hello" {
+		t.Errorf("Metadata stream should be encrypted with EncryptMetadata")
+	}
+}
+
+func TestDocumentLanguageRoundTrip(t *testing.T) {
+	w := NewPdfWriter()
+	err := w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if err := w.SetDocumentLanguage(""); err == nil {
+		t.Errorf("Empty language should fail")
+	}
+	err = w.SetDocumentLanguage("en-US")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error reading output: %v", err)
+	}
+	lang, err := reader.GetDocumentLanguage()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if lang != "en-US" {
+		t.Errorf("Unexpected language %q", lang)
+	}
+
+	lang, err = loadMinimalReader(t).GetDocumentLanguage()
+	if err != nil || lang != "" {
+		t.Errorf("Expected no language (%q, %v)", lang, err)
+	}
+}
+
+func TestUnencryptedMetadataRoundTrip(t *testing.T) {
+	xmp := []byte(testXMP)
+
+	w := NewPdfWriter()
+	err := w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if err := w.SetXMPMetadata(xmp); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	err = w.Encrypt([]byte("user"), []byte("owner"), &EncryptOptions{Algorithm: RC4_40bit, LeaveMetadataUnencrypted: true})
+	if err == nil {
+		t.Errorf("Unencrypted metadata with 40 bit encryption should fail")
+	}
+	err = w.Encrypt([]byte("user"), []byte("owner"), &EncryptOptions{LeaveMetadataUnencrypted: true})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if !bytes.Contains(out, xmp) {
+		t.Errorf("Metadata not written in plain text")
+	}
+	if !bytes.Contains(out, []byte("/EncryptMetadata false")) {
+		t.Errorf("EncryptMetadata false missing from the encryption dictionary")
+	}
+
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	success, err := reader.Decrypt([]byte("user"))
+	if err != nil || !success {
+		t.Fatalf("Unable to decrypt (%v)", err)
+	}
+	if reader.parser.crypter.encryptMetadata {
+		t.Errorf("Reader should not decrypt the metadata")
+	}
+	readXmp, err := reader.GetXMPMetadata()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if !bytes.Equal(readXmp, xmp) {
+		t.Errorf("Metadata not read back intact (%q)", readXmp)
+	}
+	text, err := reader.ExtractPageText(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if text != "Hello World" {
+		t.Errorf("Unexpected text %q", text)
+	}
+}
+
+func TestGetDocumentInfo(t *testing.T) {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+		"<< /Title (Annual report) /Author 5 0 R /CreationDate (D:20170102150405Z) /Trapped /False >>",
+		"(Jane Doe)",
+	}
+	testcases := []struct {
+		info   string
+		title  string
+		author string
+	}{
+		{"/Info 4 0 R", "Annual report", "Jane Doe"},
+		{"/Info << /Title <FEFF00C9007400E9> >>", "Été", ""},
+		{"", "", ""},
+	}
+	for _, tcase := range testcases {
+		data := bytes.Replace(makePdfFile(objects), []byte("/Root 1 0 R"), []byte("/Root 1 0 R "+tcase.info), 1)
+		reader, err := NewPdfReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		info, err := reader.GetDocumentInfo()
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if tcase.info == "" {
+			if info != nil {
+				t.Errorf("Expected no information dictionary (%+v)", info)
+			}
+			continue
+		}
+		if info == nil || info.Title != tcase.title || info.Author != tcase.author {
+			t.Errorf("%s: unexpected info %+v", tcase.info, info)
+		}
+	}
+
+	// Written by the writer.
+	w := NewPdfWriter()
+	w.SetCreator("Report generator")
+	if err := w.AddPage(loadMinimalPage(t)); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	info, err := reader.GetDocumentInfo()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if info.Creator != "Report generator" || info.Producer == "" {
+		t.Errorf("Unexpected info %+v", info)
+	}
+}
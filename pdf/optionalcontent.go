@@ -0,0 +1,123 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Optional content groups, also known as layers (8.11).
+
+package pdf
+
+import (
+	"errors"
+)
+
+// An optional content group (layer) with its visibility in the default
+// configuration.
+type OCG struct {
+	Name    string
+	Visible bool
+}
+
+// Get the optional content groups of the document, listed in the OCGs
+// array of the catalog OCProperties.  The default visibility is determined
+// by the BaseState, ON and OFF entries of the default configuration (D).
+// Returns an empty list if the document has no optional content.
+func (this *PdfReader) GetOptionalContentGroups() ([]OCG, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+
+	groups := []OCG{}
+
+	obj, err := this.traceToDirectObject((*this.catalog)["OCProperties"])
+	if err != nil {
+		return nil, err
+	}
+	properties, ok := obj.(*PdfObjectDictionary)
+	if !ok {
+		return groups, nil
+	}
+	ocgs, err := this.resolveOCGList((*properties)["OCGs"])
+	if err != nil {
+		return nil, err
+	}
+
+	// All the groups are on by default, unless the base state is OFF.
+	baseState := PdfObjectName("ON")
+	on := []PdfObject{}
+	off := []PdfObject{}
+	obj, err = this.traceToDirectObject((*properties)["D"])
+	if err != nil {
+		return nil, err
+	}
+	if config, ok := obj.(*PdfObjectDictionary); ok {
+		if state, ok := (*config)["BaseState"].(*PdfObjectName); ok {
+			baseState = *state
+		}
+		on, err = this.resolveOCGList((*config)["ON"])
+		if err != nil {
+			return nil, err
+		}
+		off, err = this.resolveOCGList((*config)["OFF"])
+		if err != nil {
+			return nil, err
+		}
+	}
+	visible := map[PdfObject]bool{}
+	for _, ocg := range ocgs {
+		visible[ocg] = baseState != "OFF"
+	}
+	for _, ocg := range on {
+		visible[ocg] = true
+	}
+	for _, ocg := range off {
+		visible[ocg] = false
+	}
+
+	for _, ocg := range ocgs {
+		dict, ok := ocg.(*PdfIndirectObject).PdfObject.(*PdfObjectDictionary)
+		if !ok {
+			log.Error("Optional content group not a dictionary (%T)", ocg)
+			return nil, errors.New("Optional content group not a dictionary")
+		}
+		group := OCG{Visible: visible[ocg]}
+		if name, ok := asString((*dict)["Name"]); ok {
+			group.Name = decodeTextString(name)
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// Resolve an array of optional content group references.  The groups are
+// indirect objects, resolved to the same object for the same reference.
+func (this *PdfReader) resolveOCGList(obj PdfObject) ([]PdfObject, error) {
+	list := []PdfObject{}
+	obj, err := this.traceToDirectObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return list, nil
+	}
+	arr, ok := obj.(*PdfObjectArray)
+	if !ok {
+		log.Error("Invalid optional content group array (%T)", obj)
+		return nil, errors.New("Invalid optional content group array")
+	}
+	for _, elem := range *arr {
+		if ref, isRef := elem.(*PdfObjectReference); isRef {
+			elem, _, err = this.resolveReference(ref)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if _, isIndirect := elem.(*PdfIndirectObject); !isIndirect {
+			log.Error("Optional content group not an indirect object (%T)", elem)
+			return nil, errors.New("Optional content group not an indirect object")
+		}
+		list = append(list, elem)
+	}
+	return list, nil
+}
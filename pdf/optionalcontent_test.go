@@ -0,0 +1,62 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGetOptionalContentGroups(t *testing.T) {
+	pages := "<< /Type /Pages /Kids [3 0 R] /Count 1 >>"
+	page := "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>"
+	tests := []struct {
+		config   string
+		expected []OCG
+	}{
+		{"<< /OFF [5 0 R] >>", []OCG{{"Text", true}, {"Images", false}, {"Notes", true}}},
+		{"<< /BaseState /OFF /ON [6 0 R] >>", []OCG{{"Text", false}, {"Images", false}, {"Notes", true}}},
+		{"<< >>", []OCG{{"Text", true}, {"Images", true}, {"Notes", true}}},
+	}
+	for _, test := range tests {
+		data := makePdfFile([]string{
+			"<< /Type /Catalog /Pages 2 0 R /OCProperties << /OCGs [4 0 R 5 0 R 6 0 R] /D " + test.config + " >> >>",
+			pages,
+			page,
+			"<< /Type /OCG /Name (Text) >>",
+			"<< /Type /OCG /Name (Images) >>",
+			"<< /Type /OCG /Name (Notes) >>",
+		})
+		reader, err := NewPdfReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		groups, err := reader.GetOptionalContentGroups()
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if len(groups) != len(test.expected) {
+			t.Errorf("%s: unexpected groups %v", test.config, groups)
+			continue
+		}
+		for i, group := range groups {
+			if group != test.expected[i] {
+				t.Errorf("%s: group %d %+v (expected %+v)", test.config, i, group, test.expected[i])
+			}
+		}
+	}
+
+	// No layers.
+	data := makePdfFile([]string{"<< /Type /Catalog /Pages 2 0 R >>", pages, page})
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	groups, err := reader.GetOptionalContentGroups()
+	if err != nil || groups == nil || len(groups) != 0 {
+		t.Errorf("Unexpected groups %v (%v)", groups, err)
+	}
+}
@@ -0,0 +1,32 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Creating new pages.
+
+package pdf
+
+// Common page sizes (width x height) in points.
+const (
+	PageWidthA4      = 595.28
+	PageHeightA4     = 841.89
+	PageWidthLetter  = 612.0
+	PageHeightLetter = 792.0
+	PageWidthLegal   = 612.0
+	PageHeightLegal  = 1008.0
+)
+
+// Create a blank page with the size in points, with empty Resources and
+// an empty Contents stream, to be added with PdfWriter.AddPage.
+func NewPage(width, height float64) *PdfIndirectObject {
+	pageDict := PdfObjectDictionary{}
+	pageDict["Type"] = makeName("Page")
+	pageDict["MediaBox"] = PdfRectangle{0, 0, width, height}.toArray()
+	pageDict["Resources"] = &PdfObjectDictionary{}
+	pageDict["Contents"] = makeContentStream([]byte{})
+
+	page := PdfIndirectObject{}
+	page.PdfObject = &pageDict
+	return &page
+}
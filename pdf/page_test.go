@@ -0,0 +1,56 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewPage(t *testing.T) {
+	w := NewPdfWriter()
+	sizes := [][2]float64{
+		{PageWidthA4, PageHeightA4},
+		{PageWidthLetter, PageHeightLetter},
+		{PageWidthLegal, PageHeightLegal},
+	}
+	for _, size := range sizes {
+		err := w.AddPage(NewPage(size[0], size[1]))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+	pagesDict := w.pages.PdfObject.(*PdfObjectDictionary)
+	if count, ok := (*pagesDict)["Count"].(*PdfObjectInteger); !ok || *count != 3 {
+		t.Errorf("Invalid page count %v", (*pagesDict)["Count"])
+	}
+
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	numPages, err := reader.GetNumPages()
+	if err != nil || numPages != 3 {
+		t.Fatalf("Expected 3 pages, got %d (%v)", numPages, err)
+	}
+	for i, size := range sizes {
+		mediaBox, _, err := reader.GetPageMediaBoxSource(i + 1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if mediaBox.Urx != size[0] || mediaBox.Ury != size[1] {
+			t.Errorf("Page %d: invalid media box %+v", i+1, mediaBox)
+		}
+		contents, err := reader.GetPageContents(i + 1)
+		if err != nil || len(contents) != 0 {
+			t.Errorf("Page %d: expected empty contents (%q, %v)", i+1, contents, err)
+		}
+	}
+}
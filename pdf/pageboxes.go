@@ -0,0 +1,323 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Page boundaries (14.11.2) and the user space unit.
+
+package pdf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Make a rectangle normalized to the lower left and upper right corners
+// from the values of a rectangle array [llx lly urx ury].
+func rectangleFromValues(vals []float64) *PdfRectangle {
+	rect := PdfRectangle{Llx: vals[0], Lly: vals[1], Urx: vals[2], Ury: vals[3]}
+	return rect.normalized()
+}
+
+func (this PdfRectangle) normalized() *PdfRectangle {
+	if this.Llx > this.Urx {
+		this.Llx, this.Urx = this.Urx, this.Llx
+	}
+	if this.Lly > this.Ury {
+		this.Lly, this.Ury = this.Ury, this.Lly
+	}
+	return &this
+}
+
+// Check if the rectangle lies within another rectangle (both normalized).
+func (this *PdfRectangle) within(other *PdfRectangle) bool {
+	return this.Llx >= other.Llx && this.Lly >= other.Lly && this.Urx <= other.Urx && this.Ury <= other.Ury
+}
+
+// Get the intersection with another rectangle (both normalized).  Empty
+// intersections are collapsed to a zero width or height.
+func (this *PdfRectangle) intersect(other *PdfRectangle) *PdfRectangle {
+	rect := *this
+	if other.Llx > rect.Llx {
+		rect.Llx = other.Llx
+	}
+	if other.Lly > rect.Lly {
+		rect.Lly = other.Lly
+	}
+	if other.Urx < rect.Urx {
+		rect.Urx = other.Urx
+	}
+	if other.Ury < rect.Ury {
+		rect.Ury = other.Ury
+	}
+	if rect.Urx < rect.Llx {
+		rect.Urx = rect.Llx
+	}
+	if rect.Ury < rect.Lly {
+		rect.Ury = rect.Lly
+	}
+	return &rect
+}
+
+// Make a rectangle from a rectangle array, resolving references.
+func (this *PdfReader) newPdfRectangle(obj PdfObject) (*PdfRectangle, error) {
+	obj, err := this.traceToDirectObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := obj.(*PdfObjectArray)
+	if !ok || len(*arr) != 4 {
+		log.Error("Invalid rectangle (%v)", obj)
+		return nil, errors.New("Invalid rectangle")
+	}
+
+	vals := []float64{}
+	for _, elem := range *arr {
+		elem, err := this.traceToDirectObject(elem)
+		if err != nil {
+			return nil, err
+		}
+		val, err := getNumberAsFloat(elem)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, val)
+	}
+	return rectangleFromValues(vals), nil
+}
+
+// Get a page (from 1) and its dictionary.
+func (this *PdfReader) getPageDict(pageNumber int) (*PdfIndirectObject, *PdfObjectDictionary, error) {
+	pageObj, err := this.GetPage(pageNumber)
+	if err != nil {
+		return nil, nil, err
+	}
+	page, ok := pageObj.(*PdfIndirectObject)
+	if !ok {
+		return nil, nil, errors.New("Page not an indirect object")
+	}
+	pDict, ok := page.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return nil, nil, errors.New("Page not a dictionary")
+	}
+	return page, pDict, nil
+}
+
+// Get the media box of a page (from 1) and whether it is inherited from
+// an ancestor Pages node rather than defined by the page itself.
+func (this *PdfReader) GetPageMediaBoxSource(pageNumber int) (rect *PdfRectangle, inherited bool, err error) {
+	page, pDict, err := this.getPageDict(pageNumber)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if obj, has := (*pDict)["MediaBox"]; has {
+		rect, err = this.newPdfRectangle(obj)
+		return rect, false, err
+	}
+
+	obj, err := this.getInheritedAttribute(page, "MediaBox")
+	if err != nil {
+		return nil, false, err
+	}
+	if obj == nil {
+		return nil, false, fmt.Errorf("Page %d missing MediaBox", pageNumber)
+	}
+	rect, err = this.newPdfRectangle(obj)
+	return rect, true, err
+}
+
+// Get the crop box of a page (from 1).  The crop box is inheritable and
+// defaults to the media box.  It is reduced to its intersection with the
+// media box.
+func (this *PdfReader) GetPageCropBox(pageNumber int) (*PdfRectangle, error) {
+	mediaBox, _, err := this.GetPageMediaBoxSource(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	page, _, err := this.getPageDict(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := this.getInheritedAttribute(page, "CropBox")
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return mediaBox, nil
+	}
+	cropBox, err := this.newPdfRectangle(obj)
+	if err != nil {
+		return nil, err
+	}
+	return cropBox.intersect(mediaBox), nil
+}
+
+// Get a bleed, trim or art box of a page, which is not inheritable and
+// defaults to the crop box.  The box is reduced to its intersection with
+// the media box.
+func (this *PdfReader) getPageBox(pageNumber int, name PdfObjectName) (*PdfRectangle, error) {
+	cropBox, err := this.GetPageCropBox(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	_, pDict, err := this.getPageDict(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, has := (*pDict)[name]
+	if !has {
+		return cropBox, nil
+	}
+	box, err := this.newPdfRectangle(obj)
+	if err != nil {
+		return nil, err
+	}
+	mediaBox, _, err := this.GetPageMediaBoxSource(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	return box.intersect(mediaBox), nil
+}
+
+// Get the bleed box of a page (from 1), defaults to the crop box.
+func (this *PdfReader) GetPageBleedBox(pageNumber int) (*PdfRectangle, error) {
+	return this.getPageBox(pageNumber, "BleedBox")
+}
+
+// Get the trim box of a page (from 1), defaults to the crop box.
+func (this *PdfReader) GetPageTrimBox(pageNumber int) (*PdfRectangle, error) {
+	return this.getPageBox(pageNumber, "TrimBox")
+}
+
+// Get the art box of a page (from 1), defaults to the crop box.
+func (this *PdfReader) GetPageArtBox(pageNumber int) (*PdfRectangle, error) {
+	return this.getPageBox(pageNumber, "ArtBox")
+}
+
+// Get a rectangle from a rectangle array in the writer, where the
+// references have been resolved to indirect objects.
+func getDirectRectangle(obj PdfObject) (*PdfRectangle, error) {
+	if io, isIndirect := obj.(*PdfIndirectObject); isIndirect {
+		obj = io.PdfObject
+	}
+	arr, ok := obj.(*PdfObjectArray)
+	if !ok || len(*arr) != 4 {
+		return nil, errors.New("Invalid rectangle")
+	}
+	vals := []float64{}
+	for _, elem := range *arr {
+		if io, isIndirect := elem.(*PdfIndirectObject); isIndirect {
+			elem = io.PdfObject
+		}
+		val, err := getNumberAsFloat(elem)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, val)
+	}
+	return rectangleFromValues(vals), nil
+}
+
+// Set a page boundary of a page (from 1) added to the writer.  The box
+// must lie within the media box of the page.
+func (this *PdfWriter) setPageBox(pageNumber int, name PdfObjectName, rect PdfRectangle) error {
+	page, err := this.getPage(pageNumber)
+	if err != nil {
+		return err
+	}
+	pDict, ok := page.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return errors.New("Page not a dictionary")
+	}
+
+	// Inherited media boxes are copied to the page when added.
+	mediaObj, has := (*pDict)["MediaBox"]
+	if !has {
+		return errors.New("Page missing MediaBox")
+	}
+	mediaBox, err := getDirectRectangle(mediaObj)
+	if err != nil {
+		return err
+	}
+	box := rect.normalized()
+	if !box.within(mediaBox) {
+		log.Error("%s %+v outside of MediaBox %+v", name, *box, *mediaBox)
+		return fmt.Errorf("%s outside of MediaBox", name)
+	}
+
+	(*pDict)[name] = box.toArray()
+	return nil
+}
+
+// Set the crop box of a page (from 1).
+func (this *PdfWriter) SetPageCropBox(pageNumber int, rect PdfRectangle) error {
+	return this.setPageBox(pageNumber, "CropBox", rect)
+}
+
+// Set the bleed box of a page (from 1).
+func (this *PdfWriter) SetPageBleedBox(pageNumber int, rect PdfRectangle) error {
+	return this.setPageBox(pageNumber, "BleedBox", rect)
+}
+
+// Set the trim box of a page (from 1).
+func (this *PdfWriter) SetPageTrimBox(pageNumber int, rect PdfRectangle) error {
+	return this.setPageBox(pageNumber, "TrimBox", rect)
+}
+
+// Set the art box of a page (from 1).
+func (this *PdfWriter) SetPageArtBox(pageNumber int, rect PdfRectangle) error {
+	return this.setPageBox(pageNumber, "ArtBox", rect)
+}
+
+// Get the user space unit of a page (from 1), the size of a user space
+// unit in multiples of 1/72 inch (UserUnit).  Defaults to 1.  Physical
+// dimensions are the page box dimensions multiplied by the unit, e.g. for
+// large format pages exceeding 200 inches.
+func (this *PdfReader) GetPageUserUnit(pageNumber int) (float64, error) {
+	_, pDict, err := this.getPageDict(pageNumber)
+	if err != nil {
+		return 0, err
+	}
+	obj, err := this.traceToDirectObject((*pDict)["UserUnit"])
+	if err != nil {
+		return 0, err
+	}
+	if obj == nil {
+		return 1, nil
+	}
+	unit, err := getNumberAsFloat(obj)
+	if err != nil {
+		return 0, err
+	}
+	if unit <= 0 {
+		log.Error("Invalid UserUnit %v", unit)
+		return 0, errors.New("Invalid UserUnit")
+	}
+	return unit, nil
+}
+
+// Set the user space unit of a page (from 1) in multiples of 1/72 inch,
+// which must be positive.  A unit of 1 removes the entry.
+func (this *PdfWriter) SetPageUserUnit(pageNumber int, unit float64) error {
+	if unit <= 0 {
+		return fmt.Errorf("Invalid user unit %v", unit)
+	}
+	page, err := this.getPage(pageNumber)
+	if err != nil {
+		return err
+	}
+	pDict, ok := page.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return errors.New("Page not a dictionary")
+	}
+
+	if unit == 1 {
+		delete(*pDict, "UserUnit")
+		return nil
+	}
+	(*pDict)["UserUnit"] = makeReal(unit)
+	return nil
+}
@@ -0,0 +1,169 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGetPageMediaBoxSource(t *testing.T) {
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /MediaBox [0 0 612 792] >>",
+		"<< /Type /Page /Parent 2 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [595 842 0 0] >>",
+	})
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	testcases := []struct {
+		rect      PdfRectangle
+		inherited bool
+	}{
+		{PdfRectangle{0, 0, 612, 792}, true},
+		{PdfRectangle{0, 0, 595, 842}, false},
+	}
+	for i, tcase := range testcases {
+		rect, inherited, err := reader.GetPageMediaBoxSource(i + 1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if *rect != tcase.rect || inherited != tcase.inherited {
+			t.Errorf("Page %d: media box %+v inherited %v (expected %+v %v)", i+1, *rect, inherited, tcase.rect, tcase.inherited)
+		}
+	}
+}
+
+func TestGetPageBoxes(t *testing.T) {
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /MediaBox [0 0 612 792] /CropBox [10 10 602 782] >>",
+		"<< /Type /Page /Parent 2 0 R /TrimBox [20 20 592 772] >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 300 300] /CropBox [-10 -10 400 250] /ArtBox [50 50 350 100] >>",
+	})
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	getters := []func(int) (*PdfRectangle, error){
+		reader.GetPageCropBox, reader.GetPageBleedBox, reader.GetPageTrimBox, reader.GetPageArtBox,
+	}
+	expected := [][]PdfRectangle{
+		// Inherited crop box, trim box on the page, others default to the crop box.
+		{{10, 10, 602, 782}, {10, 10, 602, 782}, {20, 20, 592, 772}, {10, 10, 602, 782}},
+		// Crop and art boxes reduced to the media box.
+		{{0, 0, 300, 250}, {0, 0, 300, 250}, {0, 0, 300, 250}, {50, 50, 300, 100}},
+	}
+	for i, boxes := range expected {
+		for j, getter := range getters {
+			rect, err := getter(i + 1)
+			if err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+			if *rect != boxes[j] {
+				t.Errorf("Page %d box %d: %+v (expected %+v)", i+1, j, *rect, boxes[j])
+			}
+		}
+	}
+}
+
+func TestSetPageBoxes(t *testing.T) {
+	w := NewPdfWriter()
+	err := w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	page, _ := w.getPage(1)
+	pDict := page.PdfObject.(*PdfObjectDictionary)
+	mediaBox, err := getDirectRectangle((*pDict)["MediaBox"])
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	if err := w.SetPageTrimBox(1, PdfRectangle{-1, 0, 10, 10}); err == nil {
+		t.Errorf("Box outside of the media box should fail")
+	}
+	if err := w.SetPageArtBox(2, PdfRectangle{0, 0, 10, 10}); err == nil {
+		t.Errorf("Invalid page number should fail")
+	}
+
+	bleed := PdfRectangle{mediaBox.Llx + 5, mediaBox.Lly + 5, mediaBox.Urx - 5, mediaBox.Ury - 5}
+	trim := PdfRectangle{mediaBox.Llx + 10, mediaBox.Lly + 10, mediaBox.Urx - 10, mediaBox.Ury - 10}
+	setters := []func(int, PdfRectangle) error{w.SetPageCropBox, w.SetPageBleedBox, w.SetPageTrimBox}
+	for i, rect := range []PdfRectangle{*mediaBox, bleed, trim} {
+		err := setters[i](1, rect)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error reading output: %v", err)
+	}
+	getters := []func(int) (*PdfRectangle, error){reader.GetPageBleedBox, reader.GetPageTrimBox, reader.GetPageArtBox}
+	for i, rect := range []PdfRectangle{bleed, trim, *mediaBox} {
+		readRect, err := getters[i](1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if *readRect != rect {
+			t.Errorf("Box %d: %+v (expected %+v)", i, *readRect, rect)
+		}
+	}
+}
+
+func TestPageUserUnit(t *testing.T) {
+	w := makeLinearizeTestWriter(t, 2)
+	if err := w.SetPageUserUnit(1, 0); err == nil {
+		t.Errorf("Zero user unit should fail")
+	}
+	if err := w.SetPageUserUnit(3, 2); err == nil {
+		t.Errorf("Invalid page number should fail")
+	}
+	if err := w.SetPageUserUnit(1, 2.0); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	for i, expected := range []float64{2, 1} {
+		unit, err := reader.GetPageUserUnit(i + 1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if unit != expected {
+			t.Errorf("Page %d: user unit %v (expected %v)", i+1, unit, expected)
+		}
+	}
+
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] /UserUnit -1 >>",
+	})
+	reader, err = NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if _, err := reader.GetPageUserUnit(1); err == nil {
+		t.Errorf("Negative UserUnit should fail")
+	}
+}
@@ -0,0 +1,261 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Page labels (12.4.2).
+
+package pdf
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An entry in a number tree.
+type numberTreeEntry struct {
+	key   int64
+	value PdfObject
+}
+
+// Collect all the entries of a number tree (7.9.7), sorted by the key.
+func (this *PdfReader) getNumberTreeEntries(node PdfObject) ([]numberTreeEntry, error) {
+	entries := []numberTreeEntry{}
+	err := this.collectNumberTreeEntries(node, &entries, map[*PdfObjectDictionary]bool{})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	return entries, nil
+}
+
+func (this *PdfReader) collectNumberTreeEntries(node PdfObject, entries *[]numberTreeEntry, traversed map[*PdfObjectDictionary]bool) error {
+	obj, err := this.traceToDirectObject(node)
+	if err != nil {
+		return err
+	}
+	dict, ok := obj.(*PdfObjectDictionary)
+	if !ok {
+		log.Error("Invalid number tree node (%T)", obj)
+		return errors.New("Invalid number tree node")
+	}
+	if _, alreadyTraversed := traversed[dict]; alreadyTraversed {
+		log.Error("Circular number tree reference")
+		return errors.New("Circular number tree reference")
+	}
+	traversed[dict] = true
+
+	if numsObj, hasNums := (*dict)["Nums"]; hasNums {
+		obj, err := this.traceToDirectObject(numsObj)
+		if err != nil {
+			return err
+		}
+		nums, ok := obj.(*PdfObjectArray)
+		if !ok {
+			return errors.New("Invalid number tree Nums array")
+		}
+		for i := 0; i+1 < len(*nums); i += 2 {
+			key, ok := (*nums)[i].(*PdfObjectInteger)
+			if !ok {
+				return errors.New("Invalid number tree key")
+			}
+			*entries = append(*entries, numberTreeEntry{int64(*key), (*nums)[i+1]})
+		}
+	}
+
+	if kidsObj, hasKids := (*dict)["Kids"]; hasKids {
+		obj, err := this.traceToDirectObject(kidsObj)
+		if err != nil {
+			return err
+		}
+		kids, ok := obj.(*PdfObjectArray)
+		if !ok {
+			return errors.New("Invalid number tree Kids array")
+		}
+		for _, kid := range *kids {
+			err := this.collectNumberTreeEntries(kid, entries, traversed)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Format a number in roman numerals (lower case).
+func formatRoman(num int) string {
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"m", "cm", "d", "cd", "c", "xc", "l", "xl", "x", "ix", "v", "iv", "i"}
+
+	roman := ""
+	for i, val := range values {
+		for num >= val {
+			roman += symbols[i]
+			num -= val
+		}
+	}
+	return roman
+}
+
+// Format a number in letters (lower case): a to z for 1-26, aa to zz
+// for 27-52 and so on.
+func formatLetters(num int) string {
+	if num < 1 {
+		return ""
+	}
+	letter := string(rune('a' + (num-1)%26))
+	return strings.Repeat(letter, (num-1)/26+1)
+}
+
+// Format a page label number with a numbering style (D, R, r, A, a).
+func formatPageLabelNumber(style PdfObjectName, num int) (string, error) {
+	switch style {
+	case "D":
+		return strconv.Itoa(num), nil
+	case "R":
+		return strings.ToUpper(formatRoman(num)), nil
+	case "r":
+		return formatRoman(num), nil
+	case "A":
+		return strings.ToUpper(formatLetters(num)), nil
+	case "a":
+		return formatLetters(num), nil
+	}
+	return "", fmt.Errorf("Invalid page label style (%s)", style)
+}
+
+// Get the label of a page (starting from 1) as defined by the PageLabels
+// number tree in the catalog.  Pages not covered by a label range are
+// labelled by the decimal page number.
+func (this *PdfReader) GetPageLabel(pageNumber int) (string, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return "", ErrEncrypted
+	}
+	if pageNumber < 1 || pageNumber > len(this.pageList) {
+		return "", fmt.Errorf("Invalid page number %d (valid range 1-%d)", pageNumber, len(this.pageList))
+	}
+
+	labelsObj, hasLabels := (*this.catalog)["PageLabels"]
+	if !hasLabels {
+		return strconv.Itoa(pageNumber), nil
+	}
+	entries, err := this.getNumberTreeEntries(labelsObj)
+	if err != nil {
+		return "", err
+	}
+
+	// Find the range containing the page (page index starts from 0).
+	pageIndex := int64(pageNumber - 1)
+	var labelEntry *numberTreeEntry
+	for i := range entries {
+		if entries[i].key > pageIndex {
+			break
+		}
+		labelEntry = &entries[i]
+	}
+	if labelEntry == nil {
+		return strconv.Itoa(pageNumber), nil
+	}
+
+	obj, err := this.traceToDirectObject(labelEntry.value)
+	if err != nil {
+		return "", err
+	}
+	labelDict, ok := obj.(*PdfObjectDictionary)
+	if !ok {
+		log.Error("Invalid page label dictionary (%T)", obj)
+		return "", errors.New("Invalid page label dictionary")
+	}
+
+	label := ""
+	if prefix, ok := asString((*labelDict)["P"]); ok {
+		label = string(*prefix)
+	}
+
+	// Without a numbering style, the label consists of the prefix only.
+	style, hasStyle := (*labelDict)["S"].(*PdfObjectName)
+	if !hasStyle {
+		return label, nil
+	}
+
+	start := int64(1)
+	if st, ok := (*labelDict)["St"].(*PdfObjectInteger); ok {
+		start = int64(*st)
+	}
+
+	numStr, err := formatPageLabelNumber(*style, int(start+pageIndex-labelEntry.key))
+	if err != nil {
+		return "", err
+	}
+	return label + numStr, nil
+}
+
+// A page label range for the writer.  The range applies from the page
+// index StartIndex (starting from 0) up to the start of the next range.
+type PageLabelRange struct {
+	StartIndex int
+	// Numbering style: D (decimal), R/r (upper/lower case roman), A/a
+	// (upper/lower case letters) or empty for labels with a prefix only.
+	Style  string
+	Prefix string
+	// Number of the first page in the range, 1 if not set.
+	Start int
+}
+
+// Set the page labels, written as the PageLabels number tree in the
+// catalog.  The ranges must be sorted by the start index.  If no range
+// starts at the first page, the pages before the first range are
+// labelled with decimal numbers.
+func (this *PdfWriter) SetPageLabels(ranges []PageLabelRange) error {
+	if len(ranges) == 0 {
+		delete(*this.catalog, "PageLabels")
+		return nil
+	}
+
+	nums := PdfObjectArray{}
+	if ranges[0].StartIndex != 0 {
+		// The number tree must include the first page.
+		ranges = append([]PageLabelRange{{StartIndex: 0, Style: "D"}}, ranges...)
+	}
+
+	for i, labelRange := range ranges {
+		if labelRange.StartIndex < 0 {
+			return fmt.Errorf("Invalid page label start index (%d)", labelRange.StartIndex)
+		}
+		if i > 0 && labelRange.StartIndex <= ranges[i-1].StartIndex {
+			log.Error("Page label ranges not sorted (%d after %d)", labelRange.StartIndex, ranges[i-1].StartIndex)
+			return errors.New("Page label ranges should be sorted and non-overlapping")
+		}
+		if labelRange.Start < 0 {
+			return fmt.Errorf("Invalid page label start number (%d)", labelRange.Start)
+		}
+
+		labelDict := PdfObjectDictionary{}
+		labelDict["Type"] = makeName("PageLabel")
+		if labelRange.Style != "" {
+			_, err := formatPageLabelNumber(PdfObjectName(labelRange.Style), 1)
+			if err != nil {
+				return err
+			}
+			labelDict["S"] = makeName(labelRange.Style)
+		}
+		if labelRange.Prefix != "" {
+			labelDict["P"] = makeString(labelRange.Prefix)
+		}
+		if labelRange.Start > 1 {
+			labelDict["St"] = makeInteger(int64(labelRange.Start))
+		}
+
+		nums = append(nums, makeInteger(int64(labelRange.StartIndex)), &labelDict)
+	}
+
+	labels := PdfObjectDictionary{}
+	labels["Nums"] = &nums
+	(*this.catalog)["PageLabels"] = &labels
+
+	return nil
+}
@@ -0,0 +1,142 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFormatPageLabelNumber(t *testing.T) {
+	testcases := []struct {
+		Style    PdfObjectName
+		Num      int
+		Expected string
+	}{
+		{"D", 12, "12"},
+		{"r", 4, "iv"},
+		{"R", 1994, "MCMXCIV"},
+		{"a", 1, "a"},
+		{"A", 28, "BB"},
+		{"a", 52, "zz"},
+	}
+	for _, testcase := range testcases {
+		str, err := formatPageLabelNumber(testcase.Style, testcase.Num)
+		if err != nil || str != testcase.Expected {
+			t.Errorf("%s %d: got %q (%v), expected %q", testcase.Style, testcase.Num, str, err, testcase.Expected)
+		}
+	}
+	if _, err := formatPageLabelNumber("X", 1); err == nil {
+		t.Errorf("Invalid style should fail")
+	}
+}
+
+// Make a document with numPages pages and the given page labels tree.
+func makePageLabelsPdf(numPages int, pageLabels string) []byte {
+	kids := []string{}
+	for i := 0; i < numPages; i++ {
+		kids = append(kids, fmt.Sprintf("%d 0 R", i+3))
+	}
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R " + pageLabels + " >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), numPages),
+	}
+	for i := 0; i < numPages; i++ {
+		objects = append(objects, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>")
+	}
+	return makePdfFile(objects)
+}
+
+func TestGetPageLabel(t *testing.T) {
+	// Pages 1-2 uncovered, 3-4 roman, 5-6 with prefix from 10, 7 prefix
+	// only, 8-9 letters (in a kid node).
+	labels := "/PageLabels << /Kids [<< /Limits [2 6] /Nums [2 << /S /r >> 4 << /S /D /P (A-) /St 10 >> 6 << /P (Cover) >>] >> " +
+		"<< /Limits [7 7] /Nums [7 << /S /A >>] >>] >>"
+	data := makePageLabelsPdf(9, labels)
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	expected := []string{"1", "2", "i", "ii", "A-10", "A-11", "Cover", "A", "B"}
+	for i, exp := range expected {
+		label, err := reader.GetPageLabel(i + 1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if label != exp {
+			t.Errorf("Page %d label %q (expected %q)", i+1, label, exp)
+		}
+	}
+
+	if _, err := reader.GetPageLabel(10); err == nil {
+		t.Errorf("Invalid page number should fail")
+	}
+
+	// Without page labels.
+	reader, err = NewPdfReader(bytes.NewReader(makePageLabelsPdf(2, "")))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	label, err := reader.GetPageLabel(2)
+	if err != nil || label != "2" {
+		t.Errorf("Unexpected label %q (%v)", label, err)
+	}
+}
+
+func TestSetPageLabels(t *testing.T) {
+	w := NewPdfWriter()
+	for i := 0; i < 6; i++ {
+		err := w.AddPage(loadMinimalPage(t))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+
+	err := w.SetPageLabels([]PageLabelRange{
+		{StartIndex: 1, Style: "r"},
+		{StartIndex: 3, Style: "D", Prefix: "P-", Start: 5},
+		{StartIndex: 5, Prefix: "Back"},
+	})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error reading output: %v", err)
+	}
+
+	expected := []string{"1", "i", "ii", "P-5", "P-6", "Back"}
+	for i, exp := range expected {
+		label, err := reader.GetPageLabel(i + 1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if label != exp {
+			t.Errorf("Page %d label %q (expected %q)", i+1, label, exp)
+		}
+	}
+
+	invalid := [][]PageLabelRange{
+		{{StartIndex: 2, Style: "D"}, {StartIndex: 1, Style: "D"}},
+		{{StartIndex: 0, Style: "D"}, {StartIndex: 0, Style: "r"}},
+		{{StartIndex: 0, Style: "X"}},
+		{{StartIndex: -1, Style: "D"}},
+	}
+	for _, ranges := range invalid {
+		if err := w.SetPageLabels(ranges); err == nil {
+			t.Errorf("Invalid ranges should fail (%v)", ranges)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Balanced page tree for the writer output.
+
+package pdf
+
+import (
+	"fmt"
+)
+
+// Write the page tree as a balanced tree of intermediate Pages nodes with
+// at most branching kids each, rather than a flat list of pages under the
+// root.  A branching of 0 disables balancing (default).
+func (this *PdfWriter) SetBalancedPageTree(branching int) error {
+	if branching != 0 && branching < 2 {
+		return fmt.Errorf("Invalid page tree branching factor (%d)", branching)
+	}
+	this.pageTreeBranching = branching
+	return nil
+}
+
+// Rebuild the page tree into a balanced tree for writing.  The returned
+// function restores the flat page list after writing.
+func (this *PdfWriter) balancePageTree() (func(), error) {
+	kids, err := this.getPageTreeKids()
+	if err != nil {
+		return nil, err
+	}
+	flatKids := *kids
+	branching := this.pageTreeBranching
+	if branching == 0 || len(flatKids) <= branching {
+		return func() {}, nil
+	}
+
+	nodes := []*PdfIndirectObject{}
+	level := flatKids
+	counts := make([]int64, len(level))
+	for i := range counts {
+		counts[i] = 1
+	}
+	for len(level) > branching {
+		nextLevel := PdfObjectArray{}
+		nextCounts := []int64{}
+		for start := 0; start < len(level); start += branching {
+			end := start + branching
+			if end > len(level) {
+				end = len(level)
+			}
+
+			nodeKids := PdfObjectArray{}
+			count := int64(0)
+			node := PdfIndirectObject{}
+			for i := start; i < end; i++ {
+				setParent(level[i], &node)
+				nodeKids = append(nodeKids, level[i])
+				count += counts[i]
+			}
+			nodeDict := PdfObjectDictionary{}
+			nodeDict["Type"] = makeName("Pages")
+			nodeDict["Parent"] = this.pages
+			nodeDict["Kids"] = &nodeKids
+			nodeDict["Count"] = makeInteger(count)
+			node.PdfObject = &nodeDict
+
+			nodes = append(nodes, &node)
+			this.addObject(&node)
+			nextLevel = append(nextLevel, &node)
+			nextCounts = append(nextCounts, count)
+		}
+		level = nextLevel
+		counts = nextCounts
+	}
+	*kids = level
+
+	return func() {
+		*kids = flatKids
+		for _, page := range flatKids {
+			setParent(page, this.pages)
+		}
+		isNode := map[PdfObject]bool{}
+		for _, node := range nodes {
+			isNode[node] = true
+		}
+		objects := []PdfObject{}
+		for _, obj := range this.objects {
+			if !isNode[obj] {
+				objects = append(objects, obj)
+			}
+		}
+		this.objects = objects
+	}, nil
+}
+
+// Set the Parent of a page tree node.
+func setParent(obj PdfObject, parent *PdfIndirectObject) {
+	if node, ok := obj.(*PdfIndirectObject); ok {
+		if dict, ok := node.PdfObject.(*PdfObjectDictionary); ok {
+			(*dict)["Parent"] = parent
+		}
+	}
+}
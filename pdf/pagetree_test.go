@@ -0,0 +1,69 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBalancedPageTree(t *testing.T) {
+	w := NewPdfWriter()
+	if err := w.SetBalancedPageTree(1); err == nil {
+		t.Errorf("Branching factor 1 should fail")
+	}
+	err := w.SetBalancedPageTree(3)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	numPages := 25
+	for i := 0; i < numPages; i++ {
+		page := loadMinimalPage(t)
+		pDict := page.(*PdfIndirectObject).PdfObject.(*PdfObjectDictionary)
+		(*pDict)["PageIndex"] = makeInteger(int64(i))
+		err := w.AddPage(page)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+
+	// The writer keeps the flat page list after writing, written again.
+	for pass := 0; pass < 2; pass++ {
+		out, err := writePdfToBytes(&w)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+
+		reader, err := NewPdfReader(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("Error reading output: %v", err)
+		}
+		kids, ok := (*reader.pages)["Kids"].(*PdfObjectArray)
+		if !ok || len(*kids) > 3 {
+			t.Errorf("Root page tree node not balanced (%v)", (*reader.pages)["Kids"])
+		}
+		if len(reader.pageList) != numPages {
+			t.Fatalf("Expected %d pages, got %d", numPages, len(reader.pageList))
+		}
+		for i, page := range reader.pageList {
+			pDict := page.PdfObject.(*PdfObjectDictionary)
+			index, ok := (*pDict)["PageIndex"].(*PdfObjectInteger)
+			if !ok || int(*index) != i {
+				t.Errorf("Page %d out of order (%v)", i+1, (*pDict)["PageIndex"])
+			}
+		}
+	}
+
+	page, err := w.getPage(numPages)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	pDict := page.PdfObject.(*PdfObjectDictionary)
+	if (*pDict)["Parent"] != w.pages {
+		t.Errorf("Page parent not restored after writing")
+	}
+}
@@ -7,9 +7,15 @@ package pdf
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"sort"
 )
 
+// The objects of this package also implement io.WriterTo, writing the
+// default output format directly without building up the whole output as
+// a string.  Other implementations are written with DefaultWriteString.
 type PdfObject interface {
 	String() string
 	DefaultWriteString() string
@@ -20,6 +26,10 @@ type PdfObjectBool bool
 type PdfObjectInteger int64
 type PdfObjectFloat float64
 type PdfObjectString string
+
+// A string always written in the hexadecimal form, e.g. identifiers and
+// encrypted data.  Otherwise handled like PdfObjectString.
+type PdfObjectHexString string
 type PdfObjectName string
 type PdfObjectArray []PdfObject
 type PdfObjectDictionary map[PdfObjectName]PdfObject
@@ -56,6 +66,95 @@ func makeString(s string) *PdfObjectString {
 	return &str
 }
 
+func makeHexString(s string) *PdfObjectHexString {
+	str := PdfObjectHexString(s)
+	return &str
+}
+
+// Get a string object, literal or hex, as a *PdfObjectString sharing the
+// value of a hex string.
+func asString(obj PdfObject) (*PdfObjectString, bool) {
+	switch t := obj.(type) {
+	case *PdfObjectString:
+		return t, true
+	case *PdfObjectHexString:
+		return (*PdfObjectString)(t), true
+	}
+	return nil, false
+}
+
+func makeReal(val float64) *PdfObjectFloat {
+	num := PdfObjectFloat(val)
+	return &num
+}
+
+func makeBool(val bool) *PdfObjectBool {
+	b := PdfObjectBool(val)
+	return &b
+}
+
+func makeNull() *PdfObjectNull {
+	return &PdfObjectNull{}
+}
+
+// Keeps track of the bytes written and the first error that occurred, so
+// that a sequence of writes can be checked once at the end.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+	// Reused for writing the escape sequences.
+	scratch [3]byte
+}
+
+func (this *countingWriter) writeString(s string) {
+	if this.err != nil {
+		return
+	}
+	n, err := io.WriteString(this.w, s)
+	this.n += int64(n)
+	this.err = err
+}
+
+// Write a byte as a #xx escape sequence, e.g. in names.
+func (this *countingWriter) writeHexEscape(b byte) {
+	const digits = "0123456789abcdef"
+	this.scratch = [3]byte{'#', digits[b>>4], digits[b&0x0f]}
+	this.Write(this.scratch[:])
+}
+
+// Implements io.Writer, so that the output written through other writers
+// is counted as well.
+func (this *countingWriter) Write(p []byte) (int, error) {
+	if this.err != nil {
+		return 0, this.err
+	}
+	n, err := this.w.Write(p)
+	this.n += int64(n)
+	this.err = err
+	return n, err
+}
+
+func (this *countingWriter) writeObject(obj PdfObject) {
+	if this.err != nil {
+		return
+	}
+	wt, ok := obj.(io.WriterTo)
+	if !ok {
+		this.writeString(obj.DefaultWriteString())
+		return
+	}
+	n, err := wt.WriteTo(this.w)
+	this.n += n
+	this.err = err
+}
+
+// Write a string to w, returning the number of bytes written as int64.
+func writeStringTo(w io.Writer, s string) (int64, error) {
+	n, err := io.WriteString(w, s)
+	return int64(n), err
+}
+
 func (this *PdfObjectBool) String() string {
 	if *this {
 		return "true"
@@ -72,6 +171,10 @@ func (this *PdfObjectBool) DefaultWriteString() string {
 	}
 }
 
+func (this *PdfObjectBool) WriteTo(w io.Writer) (int64, error) {
+	return writeStringTo(w, this.DefaultWriteString())
+}
+
 func (this *PdfObjectInteger) String() string {
 	return fmt.Sprintf("%d", *this)
 }
@@ -80,6 +183,10 @@ func (this *PdfObjectInteger) DefaultWriteString() string {
 	return fmt.Sprintf("%d", *this)
 }
 
+func (this *PdfObjectInteger) WriteTo(w io.Writer) (int64, error) {
+	return writeStringTo(w, this.DefaultWriteString())
+}
+
 func (this *PdfObjectFloat) String() string {
 	return fmt.Sprintf("%f", *this)
 }
@@ -88,36 +195,98 @@ func (this *PdfObjectFloat) DefaultWriteString() string {
 	return fmt.Sprintf("%f", *this)
 }
 
+func (this *PdfObjectFloat) WriteTo(w io.Writer) (int64, error) {
+	return writeStringTo(w, this.DefaultWriteString())
+}
+
 func (this *PdfObjectString) String() string {
 	return fmt.Sprintf("%s", string(*this))
 }
 
 func (this *PdfObjectString) DefaultWriteString() string {
 	var output bytes.Buffer
+	this.WriteTo(&output)
+	return output.String()
+}
 
-	escapeSequences := map[byte]string{
-		'\n': "\\n",
-		'\r': "\\r",
-		'\t': "\\t",
-		'\b': "\\b",
-		'\f': "\\f",
-		'(':  "\\(",
-		')':  "\\)",
-		'\\': "\\\\",
-	}
-
-	output.WriteString("(")
+// Check whether the string contains binary data, i.e. bytes outside of
+// the printable ASCII range that cannot be written with an escape
+// sequence.  Such strings are written in the hexadecimal form, see also
+// PdfObjectHexString.
+func (this *PdfObjectString) isBinary() bool {
 	for i := 0; i < len(*this); i++ {
 		char := (*this)[i]
-		if escStr, useEsc := escapeSequences[char]; useEsc {
-			output.WriteString(escStr)
-		} else {
-			output.WriteByte(char)
+		if char >= 0x20 && char < 0x7F {
+			continue
 		}
+		switch char {
+		case '\n', '\r', '\t', '\b', '\f':
+			continue
+		}
+		return true
 	}
-	output.WriteString(")")
+	return false
+}
 
-	return output.String()
+// Write the string in the hexadecimal form, e.g. <48656c6c6f>.
+func (this *PdfObjectString) writeHex(w io.Writer) (int64, error) {
+	return (*PdfObjectHexString)(this).WriteTo(w)
+}
+
+// Escape sequences of the literal strings.
+var stringEscapeSequences = map[byte]string{
+	'\n': "\\n",
+	'\r': "\\r",
+	'\t': "\\t",
+	'\b': "\\b",
+	'\f': "\\f",
+	'(':  "\\(",
+	')':  "\\)",
+	'\\': "\\\\",
+}
+
+// Writes the string as a literal string, or in the hexadecimal form if
+// it contains binary data.
+func (this *PdfObjectString) WriteTo(w io.Writer) (int64, error) {
+	if this.isBinary() {
+		return this.writeHex(w)
+	}
+
+	output := countingWriter{w: w}
+	str := string(*this)
+
+	// Write the runs of characters between the escape sequences at once.
+	output.writeString("(")
+	start := 0
+	for i := 0; i < len(str); i++ {
+		escStr, useEsc := stringEscapeSequences[str[i]]
+		if !useEsc {
+			continue
+		}
+		if i > start {
+			output.writeString(str[start:i])
+		}
+		output.writeString(escStr)
+		start = i + 1
+	}
+	if start < len(str) {
+		output.writeString(str[start:])
+	}
+	output.writeString(")")
+
+	return output.n, output.err
+}
+
+func (this *PdfObjectHexString) String() string {
+	return string(*this)
+}
+
+func (this *PdfObjectHexString) DefaultWriteString() string {
+	return "<" + hex.EncodeToString([]byte(*this)) + ">"
+}
+
+func (this *PdfObjectHexString) WriteTo(w io.Writer) (int64, error) {
+	return writeStringTo(w, this.DefaultWriteString())
 }
 
 func (this *PdfObjectName) String() string {
@@ -158,22 +327,36 @@ func isDelimiter(char byte) bool {
 
 func (this *PdfObjectName) DefaultWriteString() string {
 	var output bytes.Buffer
+	this.WriteTo(&output)
+	return output.String()
+}
+
+func (this *PdfObjectName) WriteTo(w io.Writer) (int64, error) {
+	output := countingWriter{w: w}
 
 	if len(*this) > 127 {
 		log.Error("Name too long (%s)", *this)
 	}
 
-	output.WriteString("/")
-	for i := 0; i < len(*this); i++ {
-		char := (*this)[i]
-		if !isPrintable(char) || char == '#' || isDelimiter(char) {
-			output.WriteString(fmt.Sprintf("#%.2x", char))
-		} else {
-			output.WriteByte(char)
+	name := string(*this)
+	output.writeString("/")
+	start := 0
+	for i := 0; i < len(name); i++ {
+		char := name[i]
+		if isPrintable(char) && char != '#' && !isDelimiter(char) {
+			continue
 		}
+		if i > start {
+			output.writeString(name[start:i])
+		}
+		output.writeHexEscape(char)
+		start = i + 1
+	}
+	if start < len(name) {
+		output.writeString(name[start:])
 	}
 
-	return output.String()
+	return output.n, output.err
 }
 
 func (this *PdfObjectArray) String() string {
@@ -189,15 +372,22 @@ func (this *PdfObjectArray) String() string {
 }
 
 func (this *PdfObjectArray) DefaultWriteString() string {
-	outStr := "["
+	var output bytes.Buffer
+	this.WriteTo(&output)
+	return output.String()
+}
+
+func (this *PdfObjectArray) WriteTo(w io.Writer) (int64, error) {
+	output := countingWriter{w: w}
+	output.writeString("[")
 	for ind, o := range *this {
-		outStr += o.DefaultWriteString()
+		output.writeObject(o)
 		if ind < (len(*this) - 1) {
-			outStr += " "
+			output.writeString(" ")
 		}
 	}
-	outStr += "]"
-	return outStr
+	output.writeString("]")
+	return output.n, output.err
 }
 
 func (this *PdfObjectDictionary) String() string {
@@ -209,16 +399,108 @@ func (this *PdfObjectDictionary) String() string {
 	return outStr
 }
 
+// Get the dictionary keys in sorted order.  Used to get a deterministic
+// output when writing.
+func (this *PdfObjectDictionary) sortedKeys() []PdfObjectName {
+	keys := []string{}
+	for k, _ := range *this {
+		keys = append(keys, string(k))
+	}
+	sort.Strings(keys)
+
+	names := []PdfObjectName{}
+	for _, k := range keys {
+		names = append(names, PdfObjectName(k))
+	}
+	return names
+}
+
+// Set a value of the dictionary, replacing the current value if any.
+func (this *PdfObjectDictionary) Set(key string, val PdfObject) {
+	(*this)[PdfObjectName(key)] = val
+}
+
+// Remove a key of the dictionary, if set.
+func (this *PdfObjectDictionary) Remove(key string) {
+	delete(*this, PdfObjectName(key))
+}
+
+// Check if a key of the dictionary is set.
+func (this *PdfObjectDictionary) Has(key string) bool {
+	_, has := (*this)[PdfObjectName(key)]
+	return has
+}
+
+// Get the keys of the dictionary in sorted order, the order written.
+func (this *PdfObjectDictionary) Keys() []string {
+	keys := []string{}
+	for _, key := range this.sortedKeys() {
+		keys = append(keys, string(key))
+	}
+	return keys
+}
+
+// Get a name value, false if not a name.  References are not followed.
+func (this *PdfObjectDictionary) GetName(key string) (PdfObjectName, bool) {
+	name, ok := (*this)[PdfObjectName(key)].(*PdfObjectName)
+	if !ok {
+		return "", false
+	}
+	return *name, true
+}
+
+// Get an integer value, false if not an integer.  References are not
+// followed.
+func (this *PdfObjectDictionary) GetInt(key string) (int64, bool) {
+	val, ok := (*this)[PdfObjectName(key)].(*PdfObjectInteger)
+	if !ok {
+		return 0, false
+	}
+	return int64(*val), true
+}
+
+// Get the string bytes as is, literal or hex string, false if not a
+// string, see decodeTextString for text strings.  References are not
+// followed.
+func (this *PdfObjectDictionary) GetString(key string) (string, bool) {
+	str, ok := asString((*this)[PdfObjectName(key)])
+	if !ok {
+		return "", false
+	}
+	return string(*str), true
+}
+
+// Get an array value, false if not an array.  References are not followed.
+func (this *PdfObjectDictionary) GetArray(key string) (*PdfObjectArray, bool) {
+	arr, ok := (*this)[PdfObjectName(key)].(*PdfObjectArray)
+	return arr, ok
+}
+
+// Get a dictionary value, false if not a dictionary.  References are not
+// followed.
+func (this *PdfObjectDictionary) GetDict(key string) (*PdfObjectDictionary, bool) {
+	dict, ok := (*this)[PdfObjectName(key)].(*PdfObjectDictionary)
+	return dict, ok
+}
+
 func (this *PdfObjectDictionary) DefaultWriteString() string {
-	outStr := "<<"
-	for k, v := range *this {
+	var output bytes.Buffer
+	this.WriteTo(&output)
+	return output.String()
+}
+
+func (this *PdfObjectDictionary) WriteTo(w io.Writer) (int64, error) {
+	output := countingWriter{w: w}
+	output.writeString("<<")
+	for _, k := range this.sortedKeys() {
+		v := (*this)[k]
 		log.Debug("Writing k: %s %T", k, v)
-		outStr += k.DefaultWriteString()
-		outStr += " "
-		outStr += v.DefaultWriteString()
+		output.writeObject(&k)
+		output.writeString(" ")
+		output.writeObject(v)
 	}
-	outStr += ">>"
-	return outStr
+	output.writeString(">>")
+	return output.n, output.err
 }
 
 func (this *PdfObjectReference) String() string {
@@ -229,6 +511,10 @@ func (this *PdfObjectReference) DefaultWriteString() string {
 	return fmt.Sprintf("%d %d R", this.ObjectNumber, this.GenerationNumber)
 }
 
+func (this *PdfObjectReference) WriteTo(w io.Writer) (int64, error) {
+	return writeStringTo(w, this.DefaultWriteString())
+}
+
 func (this *PdfIndirectObject) String() string {
 	// Avoid printing out the object, can cause problems with circular
 	// references.
@@ -240,6 +526,11 @@ func (this *PdfIndirectObject) DefaultWriteString() string {
 	return outStr
 }
 
+// Writes the reference to the indirect object.
+func (this *PdfIndirectObject) WriteTo(w io.Writer) (int64, error) {
+	return writeStringTo(w, this.DefaultWriteString())
+}
+
 func (this *PdfObjectStream) String() string {
 	return fmt.Sprintf("Object stream %d: %s", this.ObjectNumber, this.PdfObjectDictionary)
 }
@@ -249,6 +540,11 @@ func (this *PdfObjectStream) DefaultWriteString() string {
 	return outStr
 }
 
+// Writes the reference to the stream object.
+func (this *PdfObjectStream) WriteTo(w io.Writer) (int64, error) {
+	return writeStringTo(w, this.DefaultWriteString())
+}
+
 func (this *PdfObjectNull) String() string {
 	return "null"
 }
@@ -256,3 +552,186 @@ func (this *PdfObjectNull) String() string {
 func (this *PdfObjectNull) DefaultWriteString() string {
 	return "null"
 }
+
+func (this *PdfObjectNull) WriteTo(w io.Writer) (int64, error) {
+	return writeStringTo(w, this.DefaultWriteString())
+}
+
+// Make a deep copy of an object graph.  References are copied as is and
+// not followed.  Objects that appear multiple times in the graph, such as
+// indirect objects linked back to by their kids, are copied only once so
+// the copy has the same structure as the original (including cycles).
+func DeepCopy(obj PdfObject) PdfObject {
+	return deepCopy(obj, map[PdfObject]PdfObject{})
+}
+
+func deepCopy(obj PdfObject, copies map[PdfObject]PdfObject) PdfObject {
+	if obj == nil {
+		return nil
+	}
+	if objCopy, isCopied := copies[obj]; isCopied {
+		return objCopy
+	}
+
+	switch t := obj.(type) {
+	case *PdfObjectBool:
+		val := *t
+		return &val
+	case *PdfObjectInteger:
+		val := *t
+		return &val
+	case *PdfObjectFloat:
+		val := *t
+		return &val
+	case *PdfObjectString:
+		val := *t
+		return &val
+	case *PdfObjectHexString:
+		val := *t
+		return &val
+	case *PdfObjectName:
+		val := *t
+		return &val
+	case *PdfObjectNull:
+		return &PdfObjectNull{}
+	case *PdfObjectReference:
+		ref := *t
+		return &ref
+	case *PdfObjectArray:
+		arr := make(PdfObjectArray, len(*t))
+		copies[obj] = &arr
+		for idx, v := range *t {
+			arr[idx] = deepCopy(v, copies)
+		}
+		return &arr
+	case *PdfObjectDictionary:
+		dict := PdfObjectDictionary{}
+		copies[obj] = &dict
+		for k, v := range *t {
+			dict[k] = deepCopy(v, copies)
+		}
+		return &dict
+	case *PdfIndirectObject:
+		ind := PdfIndirectObject{}
+		ind.PdfObjectReference = t.PdfObjectReference
+		copies[obj] = &ind
+		ind.PdfObject = deepCopy(t.PdfObject, copies)
+		return &ind
+	case *PdfObjectStream:
+		so := PdfObjectStream{}
+		so.PdfObjectReference = t.PdfObjectReference
+		copies[obj] = &so
+		if t.PdfObjectDictionary != nil {
+			so.PdfObjectDictionary = deepCopy(t.PdfObjectDictionary, copies).(*PdfObjectDictionary)
+		}
+		if t.Stream != nil {
+			so.Stream = make([]byte, len(t.Stream))
+			copy(so.Stream, t.Stream)
+		}
+		return &so
+	}
+
+	log.Debug("Unable to copy unknown object type (%T)", obj)
+	return obj
+}
+
+// Check whether two objects are structurally equal.  Dictionaries are
+// compared key by key, arrays element by element and primitive objects by
+// value.  References are equal if they refer to the same object and
+// generation number.  Indirect objects and streams are compared by their
+// contents (and stream data), regardless of the object numbers.
+func PdfObjectsEqual(a, b PdfObject) bool {
+	return pdfObjectsEqual(a, b, map[[2]PdfObject]bool{})
+}
+
+func pdfObjectsEqual(a, b PdfObject, compared map[[2]PdfObject]bool) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if a == b {
+		return true
+	}
+
+	// Pairs already being compared are assumed equal, to avoid infinite
+	// recursion on cycles.
+	pair := [2]PdfObject{a, b}
+	if _, isCompared := compared[pair]; isCompared {
+		return true
+	}
+	compared[pair] = true
+
+	switch t := a.(type) {
+	case *PdfObjectBool:
+		u, ok := b.(*PdfObjectBool)
+		return ok && *t == *u
+	case *PdfObjectInteger:
+		u, ok := b.(*PdfObjectInteger)
+		return ok && *t == *u
+	case *PdfObjectFloat:
+		u, ok := b.(*PdfObjectFloat)
+		return ok && *t == *u
+	case *PdfObjectString:
+		u, ok := asString(b)
+		return ok && *t == *u
+	case *PdfObjectHexString:
+		u, ok := asString(b)
+		return ok && string(*t) == string(*u)
+	case *PdfObjectName:
+		u, ok := b.(*PdfObjectName)
+		return ok && *t == *u
+	case *PdfObjectNull:
+		_, ok := b.(*PdfObjectNull)
+		return ok
+	case *PdfObjectReference:
+		u, ok := b.(*PdfObjectReference)
+		return ok && t.ObjectNumber == u.ObjectNumber && t.GenerationNumber == u.GenerationNumber
+	case *PdfObjectArray:
+		u, ok := b.(*PdfObjectArray)
+		if !ok || len(*t) != len(*u) {
+			return false
+		}
+		for idx := range *t {
+			if !pdfObjectsEqual((*t)[idx], (*u)[idx], compared) {
+				return false
+			}
+		}
+		return true
+	case *PdfObjectDictionary:
+		u, ok := b.(*PdfObjectDictionary)
+		if !ok {
+			return false
+		}
+		return pdfDictionariesEqual(t, u, compared)
+	case *PdfIndirectObject:
+		u, ok := b.(*PdfIndirectObject)
+		return ok && pdfObjectsEqual(t.PdfObject, u.PdfObject, compared)
+	case *PdfObjectStream:
+		u, ok := b.(*PdfObjectStream)
+		if !ok || !bytes.Equal(t.Stream, u.Stream) {
+			return false
+		}
+		return pdfDictionariesEqual(t.PdfObjectDictionary, u.PdfObjectDictionary, compared)
+	}
+
+	log.Debug("Unable to compare unknown object type (%T)", a)
+	return false
+}
+
+func pdfDictionariesEqual(a, b *PdfObjectDictionary, compared map[[2]PdfObject]bool) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if len(*a) != len(*b) {
+		return false
+	}
+	for k, v := range *a {
+		v2, has := (*b)[k]
+		if !has {
+			return false
+		}
+		if !pdfObjectsEqual(v, v2, compared) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,421 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDeepCopy(t *testing.T) {
+	reader := loadMinimalReader(t)
+	pageObj, err := reader.GetPage(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	page := pageObj.(*PdfIndirectObject)
+	pageDict := page.PdfObject.(*PdfObjectDictionary)
+
+	pageCopy, ok := DeepCopy(page).(*PdfIndirectObject)
+	if !ok || pageCopy == page {
+		t.Fatalf("Copy not a new indirect object")
+	}
+	copyDict, ok := pageCopy.PdfObject.(*PdfObjectDictionary)
+	if !ok || copyDict == pageDict {
+		t.Fatalf("Copy dictionary not a new dictionary")
+	}
+
+	// The page links back to itself via the Parent Kids, the cycle should
+	// lead to the copy.
+	parentCopy := (*copyDict)["Parent"].(*PdfIndirectObject)
+	kids := (*parentCopy.PdfObject.(*PdfObjectDictionary))["Kids"].(*PdfObjectArray)
+	if (*kids)[0] != pageCopy {
+		t.Errorf("Cycle in copy not pointing to the copied page")
+	}
+	if parentCopy == (*pageDict)["Parent"] {
+		t.Errorf("Parent not copied")
+	}
+
+	// Modifying the copy should not affect the original.
+	(*copyDict)["Rotate"] = makeInteger(90)
+	if _, has := (*pageDict)["Rotate"]; has {
+		t.Errorf("Original modified by adding to the copy")
+	}
+	fonts := (*(*copyDict)["Resources"].(*PdfObjectDictionary))["Font"].(*PdfObjectDictionary)
+	baseFont := (*(*fonts)["F1"].(*PdfObjectDictionary))["BaseFont"].(*PdfObjectName)
+	*baseFont = "Helvetica"
+	origFonts := (*(*pageDict)["Resources"].(*PdfObjectDictionary))["Font"].(*PdfObjectDictionary)
+	origBaseFont := (*(*origFonts)["F1"].(*PdfObjectDictionary))["BaseFont"].(*PdfObjectName)
+	if *origBaseFont != "Times-Roman" {
+		t.Errorf("Original modified via nested copy (%s)", *origBaseFont)
+	}
+
+	contents := (*copyDict)["Contents"].(*PdfObjectStream)
+	contents.Stream[0] = 'X'
+	origContents := (*pageDict)["Contents"].(*PdfObjectStream)
+	if origContents.Stream[0] == 'X' {
+		t.Errorf("Original stream data modified via copy")
+	}
+
+	// References are copied but not followed.
+	ref := &PdfObjectReference{ObjectNumber: 5, GenerationNumber: 1}
+	refCopy := DeepCopy(ref).(*PdfObjectReference)
+	if refCopy == ref || *refCopy != *ref {
+		t.Errorf("Invalid reference copy (%v)", refCopy)
+	}
+}
+
+func TestPdfObjectsEqual(t *testing.T) {
+	makeFont := func(name string) *PdfIndirectObject {
+		dict := PdfObjectDictionary{}
+		dict["Type"] = makeName("Font")
+		dict["BaseFont"] = makeName(name)
+		dict["Widths"] = &PdfObjectArray{makeInteger(500), makeInteger(250)}
+		dict["Ref"] = &PdfObjectReference{ObjectNumber: 10}
+		font := PdfIndirectObject{}
+		font.PdfObject = &dict
+		return &font
+	}
+
+	font1 := makeFont("Helvetica")
+	font2 := makeFont("Helvetica")
+	font2.ObjectNumber = 7
+	if !PdfObjectsEqual(font1, font2) {
+		t.Errorf("Identical fonts not equal")
+	}
+	if PdfObjectsEqual(font1, makeFont("Courier")) {
+		t.Errorf("Different fonts equal")
+	}
+
+	font3 := makeFont("Helvetica")
+	(*font3.PdfObject.(*PdfObjectDictionary))["Ref"] = &PdfObjectReference{ObjectNumber: 10, GenerationNumber: 1}
+	if PdfObjectsEqual(font1, font3) {
+		t.Errorf("References with different generation numbers equal")
+	}
+
+	font4 := makeFont("Helvetica")
+	(*font4.PdfObject.(*PdfObjectDictionary))["Extra"] = makeInteger(0)
+	if PdfObjectsEqual(font1, font4) || PdfObjectsEqual(font4, font1) {
+		t.Errorf("Dictionaries with different keys equal")
+	}
+
+	// Same value different types.
+	f := PdfObjectFloat(1)
+	if PdfObjectsEqual(makeInteger(1), &f) {
+		t.Errorf("Integer equal to float")
+	}
+	if !PdfObjectsEqual(&PdfObjectNull{}, &PdfObjectNull{}) || PdfObjectsEqual(&PdfObjectNull{}, nil) {
+		t.Errorf("Invalid null comparison")
+	}
+
+	// Streams compare by dictionary and data.
+	stream1 := PdfObjectStream{PdfObjectDictionary: &PdfObjectDictionary{"Length": makeInteger(3)}, Stream: []byte("abc")}
+	stream2 := PdfObjectStream{PdfObjectDictionary: &PdfObjectDictionary{"Length": makeInteger(3)}, Stream: []byte("abc")}
+	stream3 := PdfObjectStream{PdfObjectDictionary: &PdfObjectDictionary{"Length": makeInteger(3)}, Stream: []byte("abd")}
+	if !PdfObjectsEqual(&stream1, &stream2) || PdfObjectsEqual(&stream1, &stream3) {
+		t.Errorf("Invalid stream comparison")
+	}
+
+	// Cyclic structures (page trees) and deep copies.
+	reader := loadMinimalReader(t)
+	page, err := reader.GetPage(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	pageCopy := DeepCopy(page)
+	if !PdfObjectsEqual(page, pageCopy) {
+		t.Errorf("Page not equal to its copy")
+	}
+	(*pageCopy.(*PdfIndirectObject).PdfObject.(*PdfObjectDictionary))["Rotate"] = makeInteger(90)
+	if PdfObjectsEqual(page, pageCopy) {
+		t.Errorf("Modified page copy equal to the original")
+	}
+}
+
+// Booleans and nulls should round-trip exactly.
+func TestBoolNullRoundTrip(t *testing.T) {
+	rawText := "<< /A true /B false /C null /D [true false null] >>"
+	expected := "<</A true/B false/C null/D [true false null]>>"
+
+	parser := PdfParser{}
+	parser.reader = makeReaderForText(rawText)
+	dict, err := parser.parseDict()
+	if err != nil {
+		t.Fatalf("Error parsing dict (%s)", err)
+	}
+	if b, ok := (*dict)["A"].(*PdfObjectBool); !ok || !bool(*b) {
+		t.Errorf("Invalid true value (%v)", (*dict)["A"])
+	}
+	if b, ok := (*dict)["B"].(*PdfObjectBool); !ok || bool(*b) {
+		t.Errorf("Invalid false value (%v)", (*dict)["B"])
+	}
+	if _, ok := (*dict)["C"].(*PdfObjectNull); !ok {
+		t.Errorf("Invalid null value (%v)", (*dict)["C"])
+	}
+
+	output := dict.DefaultWriteString()
+	if output != expected {
+		t.Errorf("Unexpected output %q (expected %q)", output, expected)
+	}
+
+	// Parsing the output and writing again should be byte exact.
+	parser.reader = makeReaderForText(output)
+	dict2, err := parser.parseDict()
+	if err != nil {
+		t.Fatalf("Error parsing output (%s)", err)
+	}
+	if dict2.DefaultWriteString() != output {
+		t.Errorf("Output not round-tripping (%q)", dict2.DefaultWriteString())
+	}
+	if !PdfObjectsEqual(dict, dict2) {
+		t.Errorf("Parsed output not equal to original")
+	}
+
+	// Built with the make helpers.
+	built := PdfObjectDictionary{}
+	built["A"] = makeBool(true)
+	built["B"] = makeBool(false)
+	built["C"] = makeNull()
+	built["D"] = &PdfObjectArray{makeBool(true), makeBool(false), makeNull()}
+	if !PdfObjectsEqual(dict, &built) {
+		t.Errorf("Built dictionary not equal to parsed (%s)", built.DefaultWriteString())
+	}
+}
+
+func TestMakeReal(t *testing.T) {
+	num := makeReal(-1.5)
+	if float64(*num) != -1.5 || num.DefaultWriteString() != "-1.500000" {
+		t.Errorf("Unexpected real %s", num.DefaultWriteString())
+	}
+	if PdfObjectsEqual(makeReal(2), makeInteger(2)) {
+		t.Errorf("Real equal to integer")
+	}
+	if makeReal(1) == makeReal(1) {
+		t.Errorf("Objects not allocated separately")
+	}
+}
+
+// Binary strings should be written in hex form and read back losslessly.
+func TestHexStringRoundTrip(t *testing.T) {
+	testcases := []struct {
+		Value    string
+		Expected string
+	}{
+		{"Hello", "(Hello)"},
+		{"Tab\tNewline\n", "(Tab\\tNewline\\n)"},
+		{"\x00\x01\xfe\xff", "<0001feff>"},
+		{"\xfe\xffUTF16", "<feff5554463136>"},
+	}
+
+	for _, testcase := range testcases {
+		str := PdfObjectString(testcase.Value)
+		output := str.DefaultWriteString()
+		if output != testcase.Expected {
+			t.Errorf("Unexpected output %q (expected %q)", output, testcase.Expected)
+		}
+
+		parser := PdfParser{}
+		parser.reader = makeReaderForText(output)
+		obj, err := parser.parseObject()
+		if err != nil {
+			t.Fatalf("Error parsing %q (%s)", output, err)
+		}
+		parsed, ok := obj.(*PdfObjectString)
+		if !ok || string(*parsed) != testcase.Value {
+			t.Errorf("Round trip failed for %q (got %v)", testcase.Value, obj)
+		}
+	}
+
+	// Forced hex form.
+	if output := makeHexString("Hello").DefaultWriteString(); output != "<48656c6c6f>" {
+		t.Errorf("Unexpected hex string output %q", output)
+	}
+
+	// Odd number of digits and embedded white space.
+	hexCases := map[string]string{
+		"<48656C6C6F>":      "Hello",
+		"<48 65 6c\n6c 6f>": "Hello",
+		"<901FA>":           "\x90\x1f\xa0",
+		"<>":                "",
+	}
+	for input, expected := range hexCases {
+		parser := PdfParser{}
+		parser.reader = makeReaderForText(input)
+		str, err := parser.parseHexString()
+		if err != nil {
+			t.Errorf("Error parsing %q (%s)", input, err)
+			continue
+		}
+		if string(str) != expected {
+			t.Errorf("Hex string %q parsed as %q (expected %q)", input, str, expected)
+		}
+	}
+}
+
+// Literal string escape sequences.
+func TestStringEscapes(t *testing.T) {
+	testcases := []struct {
+		Value    string
+		Expected string
+	}{
+		{"Report (Final) \\ v2", "(Report \\(Final\\) \\\\ v2)"},
+		{"Unbalanced ) (", "(Unbalanced \\) \\()"},
+		{"Line1\nLine2\r\tTab", "(Line1\\nLine2\\r\\tTab)"},
+	}
+	for _, testcase := range testcases {
+		str := makeString(testcase.Value)
+		output := str.DefaultWriteString()
+		if output != testcase.Expected {
+			t.Errorf("Unexpected output %q (expected %q)", output, testcase.Expected)
+		}
+
+		parser := PdfParser{}
+		parser.reader = makeReaderForText(output)
+		parsed, err := parser.parseString()
+		if err != nil {
+			t.Fatalf("Error parsing %q (%s)", output, err)
+		}
+		if string(parsed) != testcase.Value {
+			t.Errorf("Round trip failed for %q (got %q)", testcase.Value, parsed)
+		}
+	}
+
+	// Escapes as written by other producers.
+	parseCases := map[string]string{
+		"(Balanced (parens) stay)": "Balanced (parens) stay",
+		"(Octal \\101\\60\\5)":     "Octal A0\x05",
+		"(\\7)":                    "\x07",
+		"(Unknown \\q escape)":     "Unknown q escape",
+		"(Line \\\ncontinued)":     "Line continued",
+		"(Line \\\r\ncontinued)":   "Line continued",
+	}
+	for input, expected := range parseCases {
+		parser := PdfParser{}
+		parser.reader = makeReaderForText(input)
+		parsed, err := parser.parseString()
+		if err != nil {
+			t.Errorf("Error parsing %q (%s)", input, err)
+			continue
+		}
+		if string(parsed) != expected {
+			t.Errorf("String %q parsed as %q (expected %q)", input, parsed, expected)
+		}
+	}
+}
+
+// Escapes at the start, middle and end of the strings and names.
+func TestWriteEscapeRuns(t *testing.T) {
+	if output := makeString("(a)b\\").DefaultWriteString(); output != "(\\(a\\)b\\\\)" {
+		t.Errorf("Unexpected string output %q", output)
+	}
+	if output := makeName("#A B/").DefaultWriteString(); output != "/#23A#20B#2f" {
+		t.Errorf("Unexpected name output %q", output)
+	}
+
+	// Not allocating per character.
+	long := makeString(strings.Repeat("abc(d)", 1000))
+	short := makeString("abc(d)")
+	allocs := func(obj PdfObject) float64 {
+		return testing.AllocsPerRun(10, func() {
+			obj.(io.WriterTo).WriteTo(ioutil.Discard)
+		})
+	}
+	if allocs(long) > allocs(short) {
+		t.Errorf("Allocations growing with the string length (%v > %v)", allocs(long), allocs(short))
+	}
+}
+
+// Objects implemented outside of the package, without WriteTo.
+type customObject struct{}
+
+func (this *customObject) String() string             { return "custom" }
+func (this *customObject) DefaultWriteString() string { return "(custom)" }
+
+func TestWriteCustomObject(t *testing.T) {
+	dict := PdfObjectDictionary{}
+	dict["Custom"] = &customObject{}
+	if output := dict.DefaultWriteString(); output != "<</Custom (custom)>>" {
+		t.Errorf("Unexpected output %q", output)
+	}
+}
+
+func TestDictionaryGetters(t *testing.T) {
+	dict := PdfObjectDictionary{}
+	dict["Type"] = makeName("Page")
+	dict["Count"] = makeInteger(3)
+	dict["Title"] = makeString("Report")
+	dict["Kids"] = &PdfObjectArray{makeInteger(1)}
+	dict["Resources"] = &PdfObjectDictionary{}
+	dict["Width"] = makeReal(1.5)
+	dict["Parent"] = &PdfObjectReference{ObjectNumber: 2}
+
+	if name, ok := dict.GetName("Type"); !ok || name != "Page" {
+		t.Errorf("GetName %q %v", name, ok)
+	}
+	if count, ok := dict.GetInt("Count"); !ok || count != 3 {
+		t.Errorf("GetInt %d %v", count, ok)
+	}
+	if title, ok := dict.GetString("Title"); !ok || title != "Report" {
+		t.Errorf("GetString %q %v", title, ok)
+	}
+	dict["ID"] = makeHexString("\x01\x02")
+	if id, ok := dict.GetString("ID"); !ok || id != "\x01\x02" {
+		t.Errorf("GetString hex %q %v", id, ok)
+	}
+	if kids, ok := dict.GetArray("Kids"); !ok || len(*kids) != 1 {
+		t.Errorf("GetArray %v %v", kids, ok)
+	}
+	if resources, ok := dict.GetDict("Resources"); !ok || resources != dict["Resources"] {
+		t.Errorf("GetDict %v %v", resources, ok)
+	}
+
+	// Missing keys, other types and references.
+	for _, key := range []string{"Missing", "Width", "Parent", "Type", "Count"} {
+		if _, ok := dict.GetInt(key); ok && key != "Count" {
+			t.Errorf("GetInt %s should fail", key)
+		}
+		if _, ok := dict.GetName(key); ok && key != "Type" {
+			t.Errorf("GetName %s should fail", key)
+		}
+		if _, ok := dict.GetString(key); ok {
+			t.Errorf("GetString %s should fail", key)
+		}
+		if _, ok := dict.GetArray(key); ok {
+			t.Errorf("GetArray %s should fail", key)
+		}
+		if _, ok := dict.GetDict(key); ok {
+			t.Errorf("GetDict %s should fail", key)
+		}
+	}
+}
+
+func TestDictionarySetRemove(t *testing.T) {
+	dict := PdfObjectDictionary{}
+	dict.Set("Type", makeName("Page"))
+	dict.Set("Count", makeInteger(1))
+	dict.Set("Count", makeInteger(2))
+	if !dict.Has("Type") || dict.Has("Kids") {
+		t.Errorf("Unexpected keys %v", dict.Keys())
+	}
+	if count, _ := dict.GetInt("Count"); count != 2 {
+		t.Errorf("Value not replaced (%d)", count)
+	}
+	if keys := dict.Keys(); !reflect.DeepEqual(keys, []string{"Count", "Type"}) {
+		t.Errorf("Keys %v", keys)
+	}
+
+	dict.Remove("Type")
+	dict.Remove("Missing")
+	if dict.Has("Type") || len(dict.Keys()) != 1 {
+		t.Errorf("Key not removed %v", dict.Keys())
+	}
+	if str := dict.DefaultWriteString(); str != "<</Count 2>>" {
+		t.Errorf("Unexpected output %q", str)
+	}
+}
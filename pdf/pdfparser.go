@@ -30,6 +30,26 @@ var reIndirectObject = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj`)
 var reXrefSubsection = regexp.MustCompile(`(\d+)\s+(\d+)\s*$`)
 var reXrefEntry = regexp.MustCompile(`(\d+)\s+(\d+)\s+([nf])\s*$`)
 
+// Default limits when not set in the parser options.
+const (
+	DefaultMaxDepth   = 100000
+	DefaultMaxObjects = 10000000
+)
+
+// Limits for parsing documents, protecting against crafted files
+// exhausting the stack or memory.  Zero values select the defaults.
+//
+// The depth counts nested arrays and dictionaries when parsing, as well
+// as the recursion when the reader resolves the references of objects.
+// Objects already traversed by the reader are not traversed again and
+// do not add to the depth.  The object count is the number of objects
+// loaded from the file; objects found in the object cache are not
+// counted again.
+type ParserOptions struct {
+	MaxDepth   int
+	MaxObjects int
+}
+
 type PdfParser struct {
 	rs       io.ReadSeeker
 	reader   *bufio.Reader
@@ -38,6 +58,53 @@ type PdfParser struct {
 	trailer  *PdfObjectDictionary
 	ObjCache ObjectCache
 	crypter  *PdfCrypt
+
+	// Object numbers with free entries in the cross reference tables.
+	freeObjects map[int]bool
+
+	// Version from the file header.
+	majorVersion int
+	minorVersion int
+
+	options ParserOptions
+	// Current nesting/recursion depth and number of objects loaded.
+	depth      int
+	numObjects int
+}
+
+// Enter a nested object or recursion level.  Returns an error if the
+// maximum depth is exceeded.  Each successful call must be paired with
+// leaveLevel.
+func (this *PdfParser) enterLevel() error {
+	maxDepth := this.options.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	if this.depth >= maxDepth {
+		log.Error("Maximum depth exceeded (%d)", maxDepth)
+		return wrapError(ErrLimitExceeded, "Maximum depth exceeded (%d)", maxDepth)
+	}
+	this.depth++
+	return nil
+}
+
+func (this *PdfParser) leaveLevel() {
+	this.depth--
+}
+
+// Count an object loaded from the file.  Returns an error if the
+// maximum number of objects is exceeded.
+func (this *PdfParser) countObject() error {
+	maxObjects := this.options.MaxObjects
+	if maxObjects <= 0 {
+		maxObjects = DefaultMaxObjects
+	}
+	if this.numObjects >= maxObjects {
+		log.Error("Maximum number of objects exceeded (%d)", maxObjects)
+		return wrapError(ErrLimitExceeded, "Maximum number of objects exceeded (%d)", maxObjects)
+	}
+	this.numObjects++
+	return nil
 }
 
 func isWhiteSpace(ch byte) bool {
@@ -266,10 +333,8 @@ func (this *PdfParser) parseString() (PdfObjectString, error) {
 
 			// Octal '\ddd' number (base 8).
 			if isOctalDigit(b) {
-				bb, err := this.reader.Peek(2)
-				if err != nil {
-					return PdfObjectString(bytes), err
-				}
+				// Up to 3 digits, may be fewer at the end of the data.
+				bb, _ := this.reader.Peek(2)
 
 				numeric := []byte{}
 				numeric = append(numeric, b)
@@ -308,6 +373,16 @@ func (this *PdfParser) parseString() (PdfObjectString, error) {
 				bytes = append(bytes, ')')
 			case '\\':
 				bytes = append(bytes, '\\')
+			case '\r':
+				// Line continuation, the end of line is not part of the string.
+				if bb, err := this.reader.Peek(1); err == nil && bb[0] == '\n' {
+					this.reader.ReadByte()
+				}
+			case '\n':
+				// Line continuation.
+			default:
+				// Unknown escape, the backslash is ignored.
+				bytes = append(bytes, b)
 			}
 
 			continue
@@ -366,6 +441,10 @@ func (this *PdfParser) parseHexString() (PdfObjectString, error) {
 // Starts with '[' ends with ']'.  Can contain any kinds of direct objects.
 func (this *PdfParser) parseArray() (PdfObjectArray, error) {
 	arr := make(PdfObjectArray, 0)
+	if err := this.enterLevel(); err != nil {
+		return arr, err
+	}
+	defer this.leaveLevel()
 
 	this.reader.ReadByte()
 
@@ -435,7 +514,14 @@ func parseReference(refStr string) (PdfObjectReference, error) {
 
 // Parse null object.
 func (this *PdfParser) parseNull() (PdfObjectNull, error) {
-	_, err := this.reader.Discard(4)
+	bb, err := this.reader.Peek(4)
+	if err != nil {
+		return PdfObjectNull{}, err
+	}
+	if string(bb) != "null" {
+		return PdfObjectNull{}, errors.New("Unexpected null string")
+	}
+	_, err = this.reader.Discard(4)
 	return PdfObjectNull{}, err
 }
 
@@ -531,6 +617,10 @@ func (this *PdfParser) parseObject() (PdfObject, error) {
 // Reads and parses a PDF dictionary object enclosed with '<<' and '>>'
 func (this *PdfParser) parseDict() (*PdfObjectDictionary, error) {
 	log.Debug("Reading PDF Dict!")
+	if err := this.enterLevel(); err != nil {
+		return nil, err
+	}
+	defer this.leaveLevel()
 
 	dict := make(PdfObjectDictionary)
 
@@ -598,7 +688,7 @@ func (this *PdfParser) parseDict() (*PdfObjectDictionary, error) {
 }
 
 // Parse the pdf version from the beginning of the file.
-func (this *PdfParser) parsePdfVersion() (float64, error) {
+func (this *PdfParser) parsePdfVersion() (int, int, error) {
 	this.rs.Seek(0, os.SEEK_SET)
 	var offset int64 = 20
 	b := make([]byte, offset)
@@ -607,18 +697,33 @@ func (this *PdfParser) parsePdfVersion() (float64, error) {
 	result1 := rePdfVersion.FindStringSubmatch(string(b))
 	if len(result1) < 2 {
 		log.Error("Error: PDF Version not found!")
-		return -1, errors.New("PDF version not found")
+		return 0, 0, errors.New("PDF version not found")
 	}
 
-	version, err := strconv.ParseFloat(result1[1], 64)
+	major, minor, err := parseVersionString(result1[1])
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
+	log.Debug("Pdf version %d.%d", major, minor)
 
-	//version, _ := strconv.Atoi(result1[1])
-	log.Debug("Pdf version %f", version)
+	return major, minor, nil
+}
 
-	return version, nil
+// Parse a version string of the form major.minor, e.g. 1.4.
+func parseVersionString(version string) (int, int, error) {
+	parts := strings.Split(version, ".")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("Invalid version (%s)", version)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid version (%s)", version)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid version (%s)", version)
+	}
+	return major, minor, nil
 }
 
 // Conventional xref table starting with 'xref'.
@@ -661,7 +766,7 @@ func (this *PdfParser) parseXrefTable() (*PdfObjectDictionary, error) {
 		if len(result2) == 4 {
 			if insideSubsection == false {
 				log.Error("Xref invalid format!\n")
-				return nil, errors.New("Xref invalid format")
+				return nil, wrapError(ErrCorruptXref, "Xref invalid format")
 			}
 
 			first, _ := strconv.ParseInt(result2[1], 10, 64)
@@ -690,6 +795,8 @@ func (this *PdfParser) parseXrefTable() (*PdfObjectDictionary, error) {
 						offset: first, generation: gen}
 					this.xrefs[curObjNum] = obj
 				}
+			} else {
+				this.markFreeObject(curObjNum)
 			}
 
 			curObjNum++
@@ -717,7 +824,7 @@ func (this *PdfParser) parseXrefTable() (*PdfObjectDictionary, error) {
 
 		if txt == "%%EOF" {
 			log.Error("end of file - trailer not found - error!")
-			return nil, errors.New("End of file - trailer not found!")
+			return nil, wrapError(ErrCorruptXref, "End of file - trailer not found!")
 		}
 
 		log.Debug("xref more : %s", txt)
@@ -739,14 +846,14 @@ func (this *PdfParser) parseXrefStream(xstm *PdfObjectInteger) (*PdfObjectDictio
 	xrefObj, err := this.parseIndirectObject()
 	if err != nil {
 		log.Error("Failed to read xref object")
-		return nil, errors.New("Failed to read xref object")
+		return nil, wrapError(ErrCorruptXref, "Failed to read xref object")
 	}
 
 	log.Debug("XRefStm object: %s", xrefObj)
 	xs, ok := xrefObj.(*PdfObjectStream)
 	if !ok {
 		log.Error("Error, XRefStm pointing to non-stream object!")
-		return nil, errors.New("XRefStm pointing to a non-stream object!")
+		return nil, wrapError(ErrCorruptXref, "XRefStm pointing to a non-stream object!")
 	}
 
 	trailerDict := xs.PdfObjectDictionary
@@ -754,19 +861,19 @@ func (this *PdfParser) parseXrefStream(xstm *PdfObjectInteger) (*PdfObjectDictio
 	sizeObj, ok := (*(xs.PdfObjectDictionary))["Size"].(*PdfObjectInteger)
 	if !ok {
 		log.Error("Missing size from xref stm")
-		return nil, errors.New("Missing Size from xref stm")
+		return nil, wrapError(ErrCorruptXref, "Missing Size from xref stm")
 	}
 
 	wObj := (*(xs.PdfObjectDictionary))["W"]
 	wArr, ok := wObj.(*PdfObjectArray)
 	if !ok {
-		return nil, errors.New("Invalid W in xref stream")
+		return nil, wrapError(ErrCorruptXref, "Invalid W in xref stream")
 	}
 
 	wLen := len(*wArr)
 	if wLen != 3 {
 		log.Error("Unsupported xref stm (len(W) != 3 - %d)", wLen)
-		return nil, errors.New("Unsupported xref stm len(W) != 3")
+		return nil, wrapError(ErrCorruptXref, "Unsupported xref stm len(W) != 3")
 	}
 
 	var b []int64
@@ -808,7 +915,7 @@ func (this *PdfParser) parseXrefStream(xstm *PdfObjectInteger) (*PdfObjectDictio
 		indices, ok := indexObj.(*PdfObjectArray)
 		if !ok {
 			log.Debug("Invalid Index object (should be an array)")
-			return nil, errors.New("Invalid Index object")
+			return nil, wrapError(ErrCorruptXref, "Invalid Index object")
 		}
 
 		// Expect indLen to be a multiple of 2.
@@ -845,7 +952,7 @@ func (this *PdfParser) parseXrefStream(xstm *PdfObjectInteger) (*PdfObjectDictio
 	if entries != len(indexList) {
 		// If mismatch -> error (already allowing mismatch of 1 if Index not specified).
 		log.Error("xref stm: num entries != len(indices) (%d != %d)", entries, len(indexList))
-		return nil, errors.New("Xref stm num entries != len(indices)")
+		return nil, wrapError(ErrCorruptXref, "Xref stm num entries != len(indices)")
 	}
 
 	log.Debug("Objects count %d", objCount)
@@ -887,6 +994,7 @@ func (this *PdfParser) parseXrefStream(xstm *PdfObjectInteger) (*PdfObjectDictio
 		log.Debug("%d. xref: %d %d %d", objNum, ftype, n2, n3)
 		if ftype == 0 {
 			log.Debug("- Free object - can probably ignore")
+			this.markFreeObject(objNum)
 		} else if ftype == 1 {
 			log.Debug("- In use - uncompressed via offset %b", p2)
 			// Object type 1: Objects that are in use but are not
@@ -924,6 +1032,33 @@ func (this *PdfParser) parseXrefStream(xstm *PdfObjectInteger) (*PdfObjectDictio
 	return trailerDict, nil
 }
 
+// Load the xref stream of a hybrid-reference file section (XRefStm in the
+// trailer of an xref table), listing the objects in object streams that
+// are hidden from older readers, e.g. marked free in the table.  The
+// table entries take precedence, followed by the stream entries and then
+// the previous sections (7.5.8.4).
+func (this *PdfParser) loadHybridXrefStream(trailerDict *PdfObjectDictionary) error {
+	xx, present := (*trailerDict)["XRefStm"]
+	if !present {
+		return nil
+	}
+	xo, ok := xx.(*PdfObjectInteger)
+	if !ok {
+		log.Error("Invalid XRefStm (%T)", xx)
+		return wrapError(ErrCorruptXref, "Invalid XRefStm")
+	}
+	_, err := this.parseXrefStream(xo)
+	return err
+}
+
+// Record a free cross reference entry.
+func (this *PdfParser) markFreeObject(objNum int) {
+	if this.freeObjects == nil {
+		this.freeObjects = map[int]bool{}
+	}
+	this.freeObjects[objNum] = true
+}
+
 // Parse xref table at the current file position.  Can either be a
 // standard xref table, or an xref stream.
 func (this *PdfParser) parseXref() (*PdfObjectDictionary, error) {
@@ -948,7 +1083,7 @@ func (this *PdfParser) parseXref() (*PdfObjectDictionary, error) {
 		}
 	} else {
 		log.Error("Invalid xref.... starting with \"%s\"", string(bb))
-		return nil, errors.New("Invalid xref format")
+		return nil, wrapError(ErrCorruptXref, "Invalid xref format")
 	}
 
 	return trailerDict, err
@@ -975,6 +1110,7 @@ func (this *PdfParser) parseXref() (*PdfObjectDictionary, error) {
 //
 func (this *PdfParser) loadXrefs() (*PdfObjectDictionary, error) {
 	this.xrefs = make(XrefTable)
+	this.freeObjects = map[int]bool{}
 	this.objstms = make(ObjectStreams)
 
 	// Look for EOF marker and seek to its beginning.
@@ -999,7 +1135,7 @@ func (this *PdfParser) loadXrefs() (*PdfObjectDictionary, error) {
 	ind := reEOF.FindAllStringIndex(string(b1), -1)
 	if ind == nil {
 		log.Error("Error: EOF marker not found!")
-		return nil, errors.New("EOF marker not found")
+		return nil, wrapError(ErrCorruptXref, "EOF marker not found")
 	}
 	lastInd := ind[len(ind)-1]
 	log.Debug("Ind: % d", ind)
@@ -1014,12 +1150,12 @@ func (this *PdfParser) loadXrefs() (*PdfObjectDictionary, error) {
 	result := reStartXref.FindStringSubmatch(string(b2))
 	if len(result) < 2 {
 		log.Error("Error: startxref not found!")
-		return nil, errors.New("Startxref not found")
+		return nil, wrapError(ErrCorruptXref, "Startxref not found")
 	}
 	if len(result) > 2 {
 		// GH: Take the last one?
 		log.Error("Multiple startxref (%s)!", b2)
-		return nil, errors.New("Multiple startxref entries?")
+		return nil, wrapError(ErrCorruptXref, "Multiple startxref entries?")
 	}
 	offsetXref, _ := strconv.Atoi(result[1])
 	log.Debug("startxref at %d", offsetXref)
@@ -1034,13 +1170,9 @@ func (this *PdfParser) loadXrefs() (*PdfObjectDictionary, error) {
 	}
 
 	// Check the XrefStm object also from the trailer.
-	xx, present := (*trailerDict)["XRefStm"]
-	if present {
-		xo := xx.(*PdfObjectInteger)
-		_, err = this.parseXrefStream(xo)
-		if err != nil {
-			return nil, err
-		}
+	err = this.loadHybridXrefStream(trailerDict)
+	if err != nil {
+		return nil, err
 	}
 
 	// Load old objects also.  Only if not already specified.
@@ -1056,7 +1188,7 @@ func (this *PdfParser) loadXrefs() (*PdfObjectDictionary, error) {
 
 	// Load any Previous xref tables (old versions), which can
 	// refer to objects also.
-	xx, present = (*trailerDict)["Prev"]
+	xx, present := (*trailerDict)["Prev"]
 	for present {
 		off := *(xx.(*PdfObjectInteger))
 		log.Debug("Another Prev xref table object at %d", off)
@@ -1070,6 +1202,10 @@ func (this *PdfParser) loadXrefs() (*PdfObjectDictionary, error) {
 			log.Error("Failed loading another (Prev) trailer")
 			return nil, err
 		}
+		err = this.loadHybridXrefStream(ptrailerDict)
+		if err != nil {
+			return nil, err
+		}
 
 		xx, present = (*ptrailerDict)["Prev"]
 		if present {
@@ -1249,7 +1385,7 @@ func Parse(filePath string) (*PdfParser, error) {
 	log.Debug("Trailer: %s", trailer)
 
 	if len(parser.xrefs) == 0 {
-		return nil, fmt.Errorf("Empty XREF table. Invalid.")
+		return nil, wrapError(ErrCorruptXref, "Empty XREF table. Invalid.")
 	}
 	printXrefTable(parser.xrefs)
 
@@ -1267,9 +1403,15 @@ func Parse(filePath string) (*PdfParser, error) {
 // Creates a new parser for a PDF file via ReadSeeker.  Loads the
 // cross reference stream and trailer.
 func NewParser(rs io.ReadSeeker) (*PdfParser, error) {
+	return NewParserWithOptions(rs, ParserOptions{})
+}
+
+// Creates a new parser with the specified limits.
+func NewParserWithOptions(rs io.ReadSeeker, options ParserOptions) (*PdfParser, error) {
 	parser := &PdfParser{}
 
 	parser.rs = rs
+	parser.options = options
 	parser.ObjCache = make(ObjectCache)
 
 	// Start by reading xrefs from bottom
@@ -1283,17 +1425,12 @@ func NewParser(rs io.ReadSeeker) (*PdfParser, error) {
 	log.Debug("Trailer: %s", trailer)
 
 	if len(parser.xrefs) == 0 {
-		return nil, fmt.Errorf("Empty XREF table. Invalid.")
+		return nil, wrapError(ErrCorruptXref, "Empty XREF table. Invalid.")
 	}
 
 	printXrefTable(parser.xrefs)
 
-	_, err = parser.parsePdfVersion()
-	if err != nil {
-		return nil, fmt.Errorf("Unable to parse version (%s)", err)
-	}
-
-	_, err = parser.parsePdfVersion()
+	parser.majorVersion, parser.minorVersion, err = parser.parsePdfVersion()
 	if err != nil {
 		return nil, fmt.Errorf("Unable to parse version (%s)", err)
 	}
@@ -1334,6 +1471,8 @@ func (this *PdfParser) IsEncrypted() (bool, error) {
 				return false, err
 			}
 
+			// The encryption dictionary itself is not encrypted.
+			crypter.decryptedObjects[encObj] = true
 			this.crypter = &crypter
 			log.Debug("Crypter object %b", crypter)
 			return true, nil
@@ -1362,3 +1501,17 @@ func (this *PdfParser) Decrypt(password []byte) (bool, error) {
 
 	return authenticated, err
 }
+
+// Get the size of the file, keeping the current position.
+func (this *PdfParser) fileSize() (int64, error) {
+	pos, err := this.rs.Seek(0, os.SEEK_CUR)
+	if err != nil {
+		return 0, err
+	}
+	size, err := this.rs.Seek(0, os.SEEK_END)
+	if err != nil {
+		return 0, err
+	}
+	_, err = this.rs.Seek(pos, os.SEEK_SET)
+	return size, err
+}
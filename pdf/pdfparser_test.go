@@ -403,7 +403,30 @@ func TestReferenceParsing(t *testing.T) {
 }
 
 func TestNullParsing(t *testing.T) {
-	// TODO
+	parser := PdfParser{}
+	parser.reader = makeReaderForText("null]")
+	_, err := parser.parseNull()
+	if err != nil {
+		t.Errorf("Error parsing null (%s)", err)
+	}
+
+	parser.reader = makeReaderForText("nul ")
+	_, err = parser.parseNull()
+	if err == nil {
+		t.Errorf("Invalid null should fail")
+	}
+
+	parser.reader = makeReaderForText("<< /Key null/Other true>>")
+	dict, err := parser.parseDict()
+	if err != nil {
+		t.Fatalf("Error parsing dict (%s)", err)
+	}
+	if _, ok := (*dict)["Key"].(*PdfObjectNull); !ok {
+		t.Errorf("Invalid object (should be PDF null)")
+	}
+	if _, ok := (*dict)["Other"].(*PdfObjectBool); !ok {
+		t.Errorf("Invalid object (should be PDF bool)")
+	}
 }
 
 func TestStreamParsing(t *testing.T) {
@@ -704,3 +727,91 @@ func TestMinimalPDFFile(t *testing.T) {
 		t.Error("Invalid base font (should be Times-Roman not %s)", *baseFont)
 	}
 }
+
+// Build a hybrid-reference file: objects 5 and 6 are in an object stream,
+// free in the xref table and listed in the xref stream (XRefStm).
+func makeHybridPdfFile() (data []byte, xrefOffset int) {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n")
+	offsets := []int{}
+	writeObj := func(num int, obj string) {
+		offsets = append(offsets, buf.Len())
+		buf.WriteString(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", num, obj))
+	}
+	writeObj(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	writeObj(2, "<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	writeObj(3, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] /Resources << /Font << /F1 5 0 R >> >> >>")
+	font := "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"
+	info := "<< /Title (Hybrid) >>"
+	header := fmt.Sprintf("5 0 6 %d ", len(font)+1)
+	objstm := header + font + " " + info
+	writeObj(4, fmt.Sprintf("<< /Type /ObjStm /N 2 /First %d /Length %d >>\nstream\n%s\nendstream", len(header), len(objstm), objstm))
+	xrefStm := "\x02\x00\x04\x00\x02\x00\x04\x01"
+	xrefStmOffset := buf.Len()
+	writeObj(7, fmt.Sprintf("<< /Type /XRef /Size 8 /W [1 2 1] /Index [5 2] /Length %d >>\nstream\n%s\nendstream", len(xrefStm), xrefStm))
+
+	xrefOffset = buf.Len()
+	buf.WriteString("xref\n0 8\n0000000000 65535 f \n")
+	for _, offset := range offsets[:4] {
+		buf.WriteString(fmt.Sprintf("%.10d 00000 n \n", offset))
+	}
+	buf.WriteString("0000000000 00001 f \n0000000000 00001 f \n")
+	buf.WriteString(fmt.Sprintf("%.10d 00000 n \n", xrefStmOffset))
+	buf.WriteString(fmt.Sprintf("trailer\n<< /Root 1 0 R /Info 6 0 R /Size 8 /XRefStm %d >>\n", xrefStmOffset))
+	buf.WriteString(fmt.Sprintf("startxref\n%d\n%%%%EOF\n", xrefOffset))
+	return buf.Bytes(), xrefOffset
+}
+
+func TestHybridXrefFile(t *testing.T) {
+	hybrid, hybridXrefOffset := makeHybridPdfFile()
+
+	// The hybrid section also reached with Prev from an incremental
+	// update.
+	var buf bytes.Buffer
+	buf.Write(hybrid)
+	objOffset := buf.Len()
+	buf.WriteString("8 0 obj\n(Added)\nendobj\n")
+	xrefOffset := buf.Len()
+	buf.WriteString(fmt.Sprintf("xref\n0 1\n0000000000 65535 f \n8 1\n%.10d 00000 n \n", objOffset))
+	buf.WriteString(fmt.Sprintf("trailer\n<< /Root 1 0 R /Info 6 0 R /Size 9 /Prev %d >>\n", hybridXrefOffset))
+	buf.WriteString(fmt.Sprintf("startxref\n%d\n%%%%EOF\n", xrefOffset))
+
+	for i, data := range [][]byte{hybrid, buf.Bytes()} {
+		reader, err := NewPdfReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("File %d: %v", i, err)
+		}
+		for num := 1; num <= 6; num++ {
+			obj, _, err := reader.resolveReference(&PdfObjectReference{ObjectNumber: int64(num)})
+			if err != nil {
+				t.Errorf("File %d: object %d: %v", i, num, err)
+				continue
+			}
+			if _, isNull := obj.(*PdfObjectNull); isNull {
+				t.Errorf("File %d: object %d not found", i, num)
+			}
+			if reader.IsFreeObject(num) {
+				t.Errorf("File %d: object %d free", i, num)
+			}
+		}
+
+		resources, err := reader.GetPageResources(1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		fontObj, err := reader.traceToDirectObject((*(*resources)["Font"].(*PdfObjectDictionary))["F1"])
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if font, ok := fontObj.(*PdfObjectDictionary); !ok || (*font)["BaseFont"].String() != "Helvetica" {
+			t.Errorf("File %d: unexpected font %v", i, fontObj)
+		}
+		infoObj, err := reader.traceToDirectObject((*reader.parser.trailer)["Info"])
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if info, ok := infoObj.(*PdfObjectDictionary); !ok || (*info)["Title"].String() != "Hybrid" {
+			t.Errorf("File %d: unexpected info %v", i, infoObj)
+		}
+	}
+}
@@ -9,50 +9,88 @@ import (
 	"bytes"
 	"compress/zlib"
 	"encoding/hex"
+	"errors"
 	"fmt"
 )
 
 // Decodes the stream.
-// Supports FlateDecode, ASCIIHexDecode.
+// Supports FlateDecode, LZWDecode, RunLengthDecode, ASCIIHexDecode and
+// ASCII85Decode.
+// With an array of filters, the filters are applied in order.  Streams
+// without a filter are returned as is.
 func (this *PdfParser) decodeStream(obj *PdfObjectStream) ([]byte, error) {
 	log.Debug("Decode stream")
 
 	log.Debug("filter %s", (*obj).PdfObjectDictionary)
-	method := (*(obj.PdfObjectDictionary))["Filter"].(*PdfObjectName)
-	if *method == "FlateDecode" {
-		// Refactor to a separate function.
-		// Revamp this support to handle TIFF predictor (2).
-		// Also handle more filter bytes and check
-		// BitsPerComponent.  Default value is 8, currently we are only
-		// supporting that one.
-		predictor := 1
-
-		decodeParams, hasDecodeParams := (*(obj.PdfObjectDictionary))["DecodeParms"].(*PdfObjectDictionary)
-		if hasDecodeParams {
-			log.Debug("decode params: %s", decodeParams.String())
-			predictor = int(*((*decodeParams)["Predictor"].(*PdfObjectInteger)))
-
-			obits, hasbits := (*decodeParams)["BitsPerComponent"]
-			if hasbits {
-				pbits, ok := obits.(*PdfObjectInteger)
-				if !ok {
-					log.Error("Invalid BitsPerComponent")
-					return nil, fmt.Errorf("Invalid BitsPerComponent")
-				}
-				if *pbits != 8 {
-					return nil, fmt.Errorf("Currently only 8 bits for flatedecode supported")
-				}
-			}
+	filters, decodeParams, err := getStreamFilters(obj.PdfObjectDictionary)
+	if err != nil {
+		return nil, err
+	}
+
+	data := obj.Stream
+	for idx, method := range filters {
+		data, err = decodeStreamData(method, data, decodeParams[idx])
+		if err != nil {
+			return nil, err
 		}
-		log.Debug("Predictor: %d", predictor)
+	}
+	return data, nil
+}
+
+// Get the filters of a stream in the order to apply them and the decode
+// parameters of each filter (nil if none).
+func getStreamFilters(dict *PdfObjectDictionary) ([]PdfObjectName, []*PdfObjectDictionary, error) {
+	filterObj, hasFilter := (*dict)["Filter"]
+	if !hasFilter {
+		return nil, nil, nil
+	}
+	decodeParamsObj := (*dict)["DecodeParms"]
+
+	if method, isName := filterObj.(*PdfObjectName); isName {
+		decodeParams, _ := decodeParamsObj.(*PdfObjectDictionary)
+		return []PdfObjectName{*method}, []*PdfObjectDictionary{decodeParams}, nil
+	}
+
+	filtersArray, ok := filterObj.(*PdfObjectArray)
+	if !ok {
+		log.Error("Unsupported filter object (%s)", filterObj)
+		return nil, nil, wrapError(ErrUnsupportedFilter, "Unsupported filter object (%s)", filterObj)
+	}
+	// The decode parameters, if any, are an array with an entry (possibly
+	// null) for each filter.
+	decodeParamsArray, _ := decodeParamsObj.(*PdfObjectArray)
+
+	filters := []PdfObjectName{}
+	decodeParams := []*PdfObjectDictionary{}
+	for idx, obj := range *filtersArray {
+		method, ok := obj.(*PdfObjectName)
+		if !ok {
+			log.Error("Unsupported filter object (%s)", obj)
+			return nil, nil, wrapError(ErrUnsupportedFilter, "Unsupported filter object (%s)", obj)
+		}
+		var params *PdfObjectDictionary
+		if decodeParamsArray != nil && idx < len(*decodeParamsArray) {
+			params, _ = (*decodeParamsArray)[idx].(*PdfObjectDictionary)
+		}
+		filters = append(filters, *method)
+		decodeParams = append(decodeParams, params)
+	}
+	return filters, decodeParams, nil
+}
 
+// Decode data encoded with a single filter.  The decode parameters are
+// nil if not specified.
+func decodeStreamData(method PdfObjectName, data []byte, decodeParams *PdfObjectDictionary) ([]byte, error) {
+	hasDecodeParams := decodeParams != nil
+
+	if method == "FlateDecode" {
 		log.Debug("Encoding method: %s", method)
 
-		bufReader := bytes.NewReader(obj.Stream)
+		bufReader := bytes.NewReader(data)
 		r, err := zlib.NewReader(bufReader)
 		if err != nil {
 			log.Error("Decoding error %s\n", err)
-			log.Debug("Stream (%d) % x", len(obj.Stream), obj.Stream)
+			log.Debug("Stream (%d) % x", len(data), data)
 			return nil, err
 		}
 		defer r.Close()
@@ -61,147 +99,528 @@ func (this *PdfParser) decodeStream(obj *PdfObjectStream) ([]byte, error) {
 		outBuf.ReadFrom(r)
 		outData := outBuf.Bytes()
 
-		if hasDecodeParams && predictor != 1 {
-			if predictor == 2 { // TIFF encoding: Needs some tests.
-				log.Debug("Tiff encoding")
+		if hasDecodeParams {
+			return applyPredictor(outData, decodeParams)
+		}
+		return outData, nil
+	} else if method == "LZWDecode" {
+		earlyChange := 1
+		if hasDecodeParams {
+			if ec, ok := (*decodeParams)["EarlyChange"].(*PdfObjectInteger); ok {
+				earlyChange = int(*ec)
+			}
+		}
+		if earlyChange != 0 && earlyChange != 1 {
+			return nil, fmt.Errorf("Invalid EarlyChange (%d)", earlyChange)
+		}
 
-				columns, ok := (*decodeParams)["Columns"].(*PdfObjectInteger)
-				if !ok {
-					log.Error("Predictor Column missing\n")
-					return nil, fmt.Errorf("Predictor column missing")
-				}
+		outData, err := decodeLZW(data, earlyChange)
+		if err != nil {
+			return nil, err
+		}
 
-				colors := 1
-				pcolors, hascolors := (*decodeParams)["Colors"].(*PdfObjectInteger)
-				if hascolors {
-					// Number of interleaved color components per sample
-					colors = int(*pcolors)
-				}
-				log.Debug("colors: %d", colors)
+		if hasDecodeParams {
+			return applyPredictor(outData, decodeParams)
+		}
+		return outData, nil
+	} else if method == "ASCIIHexDecode" {
+		return decodeASCIIHex(data)
+	} else if method == "ASCII85Decode" {
+		return decodeASCII85(data)
+	} else if method == "RunLengthDecode" {
+		return decodeRunLength(data)
+	}
 
-				rowLength := int(*columns) * colors
-				rows := len(outData) / rowLength
-				if len(outData)%rowLength != 0 {
-					log.Error("TIFF encoding: Invalid row length...")
-					return nil, fmt.Errorf("Invalid row length (%d/%d)", len(outData), rowLength)
-				}
+	log.Error("Unsupported encoding method! (%s)", method)
+	return nil, wrapError(ErrUnsupportedFilter, "Unsupported encoding method (%s)", method)
+}
 
-				if rowLength%colors != 0 {
-					return nil, fmt.Errorf("Invalid row length (%d) for colors %d", rowLength, colors)
-				}
-				log.Debug("inp outData (%d): % x", len(outData), outData)
-
-				pOutBuffer := bytes.NewBuffer(nil)
-
-				// 0-255  -255 255 ; 0-255=-255;
-				for i := 0; i < rows; i++ {
-					rowData := outData[rowLength*i : rowLength*(i+1)]
-					//log.Debug("RowData before: % d", rowData)
-					// Predicts the same as the sample to the left.
-					// Interleaved by colors.
-					for j := colors; j < rowLength; j++ {
-						rowData[j] = byte(int(rowData[j]+rowData[j-colors]) % 256)
-					}
-					// GH: Appears that this is not working as expected...
-					//log.Debug("RowData after: % d", rowData)
-
-					pOutBuffer.Write(rowData)
-				}
-				pOutData := pOutBuffer.Bytes()
-				log.Debug("POutData (%d): % x", len(pOutData), pOutData)
-				return pOutData, nil
-			} else if predictor >= 10 && predictor <= 15 {
-				log.Debug("PNG Encoding")
-				columns, ok := (*decodeParams)["Columns"].(*PdfObjectInteger)
-				if !ok {
-					log.Error("Predictor Column missing\n")
-					return nil, fmt.Errorf("Predictor column missing")
-				}
-				rowLength := int(*columns + 1) // 1 byte to specify predictor algorithms per row.
-				rows := len(outData) / rowLength
-				if len(outData)%rowLength != 0 {
-					log.Error("Invalid row length...")
-					return nil, fmt.Errorf("Invalid row length (%d/%d)", len(outData), rowLength)
-				}
+// Decode run length encoded data (7.4.5).  A length byte of 0-127 is
+// followed by 1-128 bytes to copy literally, 129-255 by a single byte to
+// repeat 257-length times, and 128 marks the end of the data.
+func decodeRunLength(data []byte) ([]byte, error) {
+	var outBuf bytes.Buffer
+	for i := 0; i < len(data); {
+		length := int(data[i])
+		i++
+		if length == 128 {
+			break
+		}
+		if length < 128 {
+			if i+length+1 > len(data) {
+				return nil, errors.New("Run length data truncated")
+			}
+			outBuf.Write(data[i : i+length+1])
+			i += length + 1
+		} else {
+			if i >= len(data) {
+				return nil, errors.New("Run length data truncated")
+			}
+			outBuf.Write(bytes.Repeat(data[i:i+1], 257-length))
+			i++
+		}
+	}
+	return outBuf.Bytes(), nil
+}
 
-				pOutBuffer := bytes.NewBuffer(nil)
+// Decode ASCII hexadecimal data, ending at > or the end of the data.
+// Whitespace is ignored and an odd final digit is taken as followed by 0.
+func decodeASCIIHex(data []byte) ([]byte, error) {
+	inb := []byte{}
+	for _, b := range data {
+		if b == '>' {
+			break
+		}
+		if isWhiteSpace(b) {
+			continue
+		}
+		if (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F') || (b >= '0' && b <= '9') {
+			inb = append(inb, b)
+		} else {
+			log.Error("Invalid ascii hex character (%c)", b)
+			return nil, fmt.Errorf("Invalid ascii hex character (%c)", b)
+		}
+	}
+	if len(inb)%2 == 1 {
+		inb = append(inb, '0')
+	}
+	log.Debug("Inbound %s", inb)
+	outb := make([]byte, hex.DecodedLen(len(inb)))
+	_, err := hex.Decode(outb, inb)
+	if err != nil {
+		return nil, err
+	}
+	return outb, nil
+}
 
-				log.Debug("Predictor columns: %d", columns)
-				log.Debug("Length: %d / %d = %d rows", len(outData), rowLength, rows)
-				prevRowData := make([]byte, rowLength)
-				for i := 0; i < rowLength; i++ {
-					prevRowData[i] = 0
-				}
+// Decode ASCII base-85 data, ending at ~> or the end of the data.  Handles
+// the optional <~ prefix, the z shortcut for 4 zero bytes and a partial
+// final group.
+func decodeASCII85(data []byte) ([]byte, error) {
+	data = bytes.TrimLeft(data, " \t\r\n\f\x00")
+	if bytes.HasPrefix(data, []byte("<~")) {
+		data = data[2:]
+	}
 
-				for i := 0; i < rows; i++ {
-					rowData := outData[rowLength*i : rowLength*(i+1)]
-
-					fb := rowData[0]
-					switch fb {
-					case 0:
-						// No prediction. (No operation).
-					case 1:
-						// Sub: Predicts the same as the sample to the left.
-						for j := 2; j < rowLength; j++ {
-							rowData[j] = byte(int(rowData[j]+rowData[j-1]) % 256)
-						}
-					case 2:
-						// Up: Predicts the same as the sample above
-						for j := 1; j < rowLength; j++ {
-							rowData[j] = byte(int(rowData[j]+prevRowData[j]) % 256)
-						}
-					default:
-						log.Error("Invalid filter byte (%d)", fb)
-						return nil, fmt.Errorf("Invalid filter byte (%d)", fb)
-					}
-
-					for i := 0; i < rowLength; i++ {
-						prevRowData[i] = rowData[i]
-					}
-					pOutBuffer.Write(rowData[1:])
-				}
-				pOutData := pOutBuffer.Bytes()
-				return pOutData, nil
-			} else {
-				log.Error("Unsupported predictor (%d)", predictor)
-				return nil, fmt.Errorf("Unsupported predictor (%d)", predictor)
+	var outBuf bytes.Buffer
+	group := []byte{}
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		if b == '~' {
+			if i+1 < len(data) && data[i+1] != '>' {
+				return nil, errors.New("Invalid ASCII85 end of data marker")
 			}
+			break
+		}
+		if isWhiteSpace(b) {
+			continue
 		}
+		if b == 'z' && len(group) == 0 {
+			outBuf.Write([]byte{0, 0, 0, 0})
+			continue
+		}
+		if b < '!' || b > 'u' {
+			log.Error("Invalid ASCII85 character (%c)", b)
+			return nil, fmt.Errorf("Invalid ASCII85 character (%c)", b)
+		}
+		group = append(group, b-'!')
+		if len(group) == 5 {
+			outBuf.Write(decodeASCII85Group(group))
+			group = group[:0]
+		}
+	}
+
+	// A final partial group of n characters gives n-1 bytes, padded with
+	// the highest digit.
+	if len(group) == 1 {
+		return nil, errors.New("Invalid ASCII85 final group")
+	}
+	if len(group) > 1 {
+		n := len(group)
+		for len(group) < 5 {
+			group = append(group, 'u'-'!')
+		}
+		outBuf.Write(decodeASCII85Group(group)[:n-1])
+	}
+
+	return outBuf.Bytes(), nil
+}
 
+// Decode a group of 5 base-85 digits to 4 bytes.
+func decodeASCII85Group(group []byte) []byte {
+	val := uint32(0)
+	for _, digit := range group {
+		val = val*85 + uint32(digit)
+	}
+	return []byte{byte(val >> 24), byte(val >> 16), byte(val >> 8), byte(val)}
+}
+
+// Apply the predictor specified in the decode parameters (TIFF or PNG) to
+// decoded Flate or LZW data.
+func applyPredictor(outData []byte, decodeParams *PdfObjectDictionary) ([]byte, error) {
+	log.Debug("decode params: %s", decodeParams.String())
+
+	// Revamp this support to handle TIFF predictor (2).
+	// Also handle more filter bytes and check
+	// BitsPerComponent.  Default value is 8, currently we are only
+	// supporting that one.
+	predictor := 1
+	if pred, ok := (*decodeParams)["Predictor"].(*PdfObjectInteger); ok {
+		predictor = int(*pred)
+	}
+	log.Debug("Predictor: %d", predictor)
+	if predictor == 1 {
 		return outData, nil
-	} else if *method == "ASCIIHexDecode" {
-		bufReader := bytes.NewReader(obj.Stream)
-		inb := []byte{}
-		for {
-			b, err := bufReader.ReadByte()
-			if err != nil {
-				return nil, err
-			}
-			if b == '>' {
-				break
+	}
+
+	obits, hasbits := (*decodeParams)["BitsPerComponent"]
+	if hasbits {
+		pbits, ok := obits.(*PdfObjectInteger)
+		if !ok {
+			log.Error("Invalid BitsPerComponent")
+			return nil, fmt.Errorf("Invalid BitsPerComponent")
+		}
+		if *pbits != 8 {
+			return nil, fmt.Errorf("Currently only 8 bits for predictors supported")
+		}
+	}
+
+	if predictor == 2 { // TIFF encoding: Needs some tests.
+		log.Debug("Tiff encoding")
+
+		columns, ok := (*decodeParams)["Columns"].(*PdfObjectInteger)
+		if !ok {
+			log.Error("Predictor Column missing\n")
+			return nil, fmt.Errorf("Predictor column missing")
+		}
+
+		colors := 1
+		pcolors, hascolors := (*decodeParams)["Colors"].(*PdfObjectInteger)
+		if hascolors {
+			// Number of interleaved color components per sample
+			colors = int(*pcolors)
+		}
+		log.Debug("colors: %d", colors)
+
+		rowLength := int(*columns) * colors
+		rows := len(outData) / rowLength
+		if len(outData)%rowLength != 0 {
+			log.Error("TIFF encoding: Invalid row length...")
+			return nil, fmt.Errorf("Invalid row length (%d/%d)", len(outData), rowLength)
+		}
+
+		if rowLength%colors != 0 {
+			return nil, fmt.Errorf("Invalid row length (%d) for colors %d", rowLength, colors)
+		}
+		log.Debug("inp outData (%d): % x", len(outData), outData)
+
+		pOutBuffer := bytes.NewBuffer(nil)
+
+		// 0-255  -255 255 ; 0-255=-255;
+		for i := 0; i < rows; i++ {
+			rowData := outData[rowLength*i : rowLength*(i+1)]
+			//log.Debug("RowData before: % d", rowData)
+			// Predicts the same as the sample to the left.
+			// Interleaved by colors.
+			for j := colors; j < rowLength; j++ {
+				rowData[j] = byte(int(rowData[j]+rowData[j-colors]) % 256)
 			}
-			if isWhiteSpace(b) {
-				continue
+			// GH: Appears that this is not working as expected...
+			//log.Debug("RowData after: % d", rowData)
+
+			pOutBuffer.Write(rowData)
+		}
+		pOutData := pOutBuffer.Bytes()
+		log.Debug("POutData (%d): % x", len(pOutData), pOutData)
+		return pOutData, nil
+	} else if predictor >= 10 && predictor <= 15 {
+		log.Debug("PNG Encoding")
+		columns, ok := (*decodeParams)["Columns"].(*PdfObjectInteger)
+		if !ok {
+			log.Error("Predictor Column missing\n")
+			return nil, fmt.Errorf("Predictor column missing")
+		}
+		rowLength := int(*columns + 1) // 1 byte to specify predictor algorithms per row.
+		rows := len(outData) / rowLength
+		if len(outData)%rowLength != 0 {
+			log.Error("Invalid row length...")
+			return nil, fmt.Errorf("Invalid row length (%d/%d)", len(outData), rowLength)
+		}
+
+		pOutBuffer := bytes.NewBuffer(nil)
+
+		log.Debug("Predictor columns: %d", columns)
+		log.Debug("Length: %d / %d = %d rows", len(outData), rowLength, rows)
+		prevRowData := make([]byte, rowLength)
+		for i := 0; i < rowLength; i++ {
+			prevRowData[i] = 0
+		}
+
+		for i := 0; i < rows; i++ {
+			rowData := outData[rowLength*i : rowLength*(i+1)]
+
+			fb := rowData[0]
+			switch fb {
+			case 0:
+				// No prediction. (No operation).
+			case 1:
+				// Sub: Predicts the same as the sample to the left.
+				for j := 2; j < rowLength; j++ {
+					rowData[j] = byte(int(rowData[j]+rowData[j-1]) % 256)
+				}
+			case 2:
+				// Up: Predicts the same as the sample above
+				for j := 1; j < rowLength; j++ {
+					rowData[j] = byte(int(rowData[j]+prevRowData[j]) % 256)
+				}
+			default:
+				log.Error("Invalid filter byte (%d)", fb)
+				return nil, fmt.Errorf("Invalid filter byte (%d)", fb)
 			}
-			if (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F') || (b >= '0' && b <= '9') {
-				inb = append(inb, b)
-			} else {
-				log.Error("Invalid ascii hex character (%c)", b)
-				return nil, fmt.Errorf("Invalid ascii hex character (%c)", b)
+
+			for i := 0; i < rowLength; i++ {
+				prevRowData[i] = rowData[i]
 			}
+			pOutBuffer.Write(rowData[1:])
+		}
+		pOutData := pOutBuffer.Bytes()
+		return pOutData, nil
+	} else {
+		log.Error("Unsupported predictor (%d)", predictor)
+		return nil, fmt.Errorf("Unsupported predictor (%d)", predictor)
+	}
+}
+
+// Decode LZW compressed data (7.4.4).  The code length increases one
+// code early if earlyChange is 1.
+func decodeLZW(data []byte, earlyChange int) ([]byte, error) {
+	const clearTable = 256
+	const endOfData = 257
+
+	var table [][]byte
+	resetTable := func() {
+		table = make([][]byte, 258, 4096)
+		for i := 0; i < 256; i++ {
+			table[i] = []byte{byte(i)}
+		}
+	}
+	resetTable()
+	codeLength := 9
+
+	var outBuf bytes.Buffer
+	var prev []byte
+	var bitBuf uint32
+	bitCount := uint(0)
+	pos := 0
+
+	for {
+		for bitCount < uint(codeLength) && pos < len(data) {
+			bitBuf = bitBuf<<8 | uint32(data[pos])
+			bitCount += 8
+			pos++
+		}
+		if bitCount < uint(codeLength) {
+			// End of data without an EOD marker.
+			break
+		}
+		code := int(bitBuf>>(bitCount-uint(codeLength))) & (1<<uint(codeLength) - 1)
+		bitCount -= uint(codeLength)
+
+		if code == clearTable {
+			resetTable()
+			codeLength = 9
+			prev = nil
+			continue
+		}
+		if code == endOfData {
+			break
+		}
+
+		var entry []byte
+		if code < len(table) && code != clearTable && code != endOfData {
+			entry = table[code]
+		} else if code == len(table) && prev != nil {
+			entry = append(append([]byte{}, prev...), prev[0])
+		} else {
+			log.Error("Invalid LZW code %d (table size %d)", code, len(table))
+			return nil, fmt.Errorf("Invalid LZW code (%d)", code)
+		}
+		outBuf.Write(entry)
+
+		if prev != nil && len(table) < 4096 {
+			table = append(table, append(append([]byte{}, prev...), entry[0]))
+		}
+		prev = entry
+
+		if len(table)+earlyChange >= 1<<uint(codeLength) && codeLength < 12 {
+			codeLength++
 		}
-		if len(inb)%2 == 1 {
-			inb = append(inb, '0')
+	}
+
+	return outBuf.Bytes(), nil
+}
+
+// Create a stream object with the data encoded with a filter, with the
+// Length and Filter entries set.  An empty filter name leaves the data
+// unfiltered.  Supports FlateDecode, LZWDecode, RunLengthDecode,
+// ASCIIHexDecode and ASCII85Decode.
+func NewStream(data []byte, filter PdfObjectName) (*PdfObjectStream, error) {
+	dict := PdfObjectDictionary{}
+	if filter != "" {
+		encoded, err := encodeStreamData(filter, data)
+		if err != nil {
+			return nil, err
 		}
-		log.Debug("Inbound %s", inb)
-		outb := make([]byte, hex.DecodedLen(len(inb)))
-		_, err := hex.Decode(outb, inb)
+		data = encoded
+		dict["Filter"] = makeName(string(filter))
+	}
+	dict["Length"] = makeInteger(int64(len(data)))
+
+	stream := PdfObjectStream{}
+	stream.PdfObjectDictionary = &dict
+	stream.Stream = data
+	return &stream, nil
+}
+
+// Encode data with a single filter, without parameters.
+func encodeStreamData(method PdfObjectName, data []byte) ([]byte, error) {
+	switch method {
+	case "FlateDecode":
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write(data)
+		err := zw.Close()
 		if err != nil {
 			return nil, err
 		}
-		return outb, nil
+		return buf.Bytes(), nil
+	case "LZWDecode":
+		return encodeLZW(data, 1), nil
+	case "RunLengthDecode":
+		return encodeRunLength(data), nil
+	case "ASCIIHexDecode":
+		return append([]byte(hex.EncodeToString(data)), '>'), nil
+	case "ASCII85Decode":
+		return encodeASCII85(data), nil
+	}
+
+	log.Error("Unsupported encoding method! (%s)", method)
+	return nil, wrapError(ErrUnsupportedFilter, "Unsupported encoding method (%s)", method)
+}
+
+// Encode data with LZW (7.4.4), emitting a clear table code at the start
+// and whenever the table fills up.
+func encodeLZW(data []byte, earlyChange int) []byte {
+	var out bytes.Buffer
+	var bitBuf uint32
+	bitCount := uint(0)
+	codeLength := 9
+	writeCode := func(code int) {
+		bitBuf = bitBuf<<uint(codeLength) | uint32(code)
+		bitCount += uint(codeLength)
+		for bitCount >= 8 {
+			out.WriteByte(byte(bitBuf >> (bitCount - 8)))
+			bitCount -= 8
+		}
+	}
+
+	var table map[string]int
+	nextCode := 0
+	resetTable := func() {
+		table = map[string]int{}
+		for i := 0; i < 256; i++ {
+			table[string([]byte{byte(i)})] = i
+		}
+		nextCode = 258
+	}
+	resetTable()
+	writeCode(256)
+
+	w := ""
+	for _, c := range data {
+		wc := w + string([]byte{c})
+		if _, has := table[wc]; has {
+			w = wc
+			continue
+		}
+		writeCode(table[w])
+		table[wc] = nextCode
+		nextCode++
+		if nextCode+earlyChange-1 >= 1<<uint(codeLength) && codeLength < 12 {
+			codeLength++
+		}
+		if nextCode >= 4095 {
+			writeCode(256)
+			resetTable()
+			codeLength = 9
+		}
+		w = string([]byte{c})
+	}
+	if w != "" {
+		writeCode(table[w])
+	}
+	writeCode(257)
+	if bitCount > 0 {
+		out.WriteByte(byte(bitBuf << (8 - bitCount)))
+	}
+	return out.Bytes()
+}
+
+// Run length encode data (7.4.5), using runs for 3 or more repeated
+// bytes.
+func encodeRunLength(data []byte) []byte {
+	var out bytes.Buffer
+	literal := []byte{}
+	flushLiteral := func() {
+		for len(literal) > 0 {
+			n := len(literal)
+			if n > 128 {
+				n = 128
+			}
+			out.WriteByte(byte(n - 1))
+			out.Write(literal[:n])
+			literal = literal[n:]
+		}
+	}
+	for i := 0; i < len(data); {
+		run := 1
+		for i+run < len(data) && data[i+run] == data[i] && run < 128 {
+			run++
+		}
+		if run >= 3 {
+			flushLiteral()
+			out.WriteByte(byte(257 - run))
+			out.WriteByte(data[i])
+		} else {
+			literal = append(literal, data[i:i+run]...)
+		}
+		i += run
 	}
+	flushLiteral()
+	out.WriteByte(128)
+	return out.Bytes()
+}
 
-	log.Error("Unsupported encoding method!")
-	return nil, fmt.Errorf("Unsupported encoding method")
+// Encode data as ASCII base-85, with the z shortcut for groups of 4 zero
+// bytes and the ~> end of data marker.
+func encodeASCII85(data []byte) []byte {
+	var outBuf bytes.Buffer
+	for i := 0; i < len(data); i += 4 {
+		group := make([]byte, 4)
+		n := copy(group, data[i:])
+		val := uint32(group[0])<<24 | uint32(group[1])<<16 | uint32(group[2])<<8 | uint32(group[3])
+		if n == 4 && val == 0 {
+			outBuf.WriteByte('z')
+			continue
+		}
+		digits := make([]byte, 5)
+		for j := 4; j >= 0; j-- {
+			digits[j] = byte(val%85) + '!'
+			val /= 85
+		}
+		// A final partial group of n bytes gives n+1 characters.
+		outBuf.Write(digits[:n+1])
+	}
+	outBuf.WriteString("~>")
+	return outBuf.Bytes()
 }
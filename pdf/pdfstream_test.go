@@ -8,6 +8,7 @@ package pdf
 import (
 	"bytes"
 	"compress/zlib"
+	"encoding/ascii85"
 	"testing"
 )
 
@@ -106,3 +107,269 @@ endobj`
 		return
 	}
 }
+
+func TestLZWDecode(t *testing.T) {
+	// Example from the PDF reference (7.4.4.2).
+	encoded := []byte("\x80\x0B\x60\x50\x22\x0C\x0C\x85\x01")
+	decoded, err := decodeLZW(encoded, 1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if string(decoded) != "-----A---B" {
+		t.Errorf("Unexpected LZW output %q", decoded)
+	}
+
+	// Enough data to grow the code length up to 12 bits and reset the table.
+	var data bytes.Buffer
+	for i := 0; i < 20000; i++ {
+		data.WriteByte(byte((i * i / 7) % 251))
+	}
+	for _, earlyChange := range []int{0, 1} {
+		decoded, err := decodeLZW(encodeLZW(data.Bytes(), earlyChange), earlyChange)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if !bytes.Equal(decoded, data.Bytes()) {
+			t.Errorf("LZW round trip failed (EarlyChange %d)", earlyChange)
+		}
+	}
+}
+
+func TestLZWDecodeStreamPredictor(t *testing.T) {
+	// PNG Up predictor, 2 rows of 3 columns.
+	rows := []byte("\x02\x01\x02\x03\x02\x01\x01\x01")
+	encoded := encodeLZW(rows, 0)
+
+	dict := PdfObjectDictionary{}
+	dict["Filter"] = makeName("LZWDecode")
+	decodeParams := PdfObjectDictionary{}
+	decodeParams["Predictor"] = makeInteger(12)
+	decodeParams["Columns"] = makeInteger(3)
+	decodeParams["EarlyChange"] = makeInteger(0)
+	dict["DecodeParms"] = &decodeParams
+	stream := PdfObjectStream{PdfObjectDictionary: &dict, Stream: encoded}
+
+	parser := PdfParser{}
+	decoded, err := parser.decodeStream(&stream)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if !bytes.Equal(decoded, []byte("\x01\x02\x03\x02\x03\x04")) {
+		t.Errorf("Unexpected output % x", decoded)
+	}
+}
+
+func TestASCIIHexDecode(t *testing.T) {
+	testcases := map[string]string{
+		"48656C6C6F>":        "Hello",
+		"48 65\n6c 6C 6f>  ": "Hello",
+		"414>":               "A@",
+		"4142":               "AB",
+		">":                  "",
+	}
+	for encoded, expected := range testcases {
+		decoded, err := decodeASCIIHex([]byte(encoded))
+		if err != nil {
+			t.Errorf("Error decoding %q: %v", encoded, err)
+			continue
+		}
+		if string(decoded) != expected {
+			t.Errorf("Decoded %q to %q (expected %q)", encoded, decoded, expected)
+		}
+	}
+	if _, err := decodeASCIIHex([]byte("4X>")); err == nil {
+		t.Errorf("Invalid character should fail")
+	}
+}
+
+func TestASCII85Decode(t *testing.T) {
+	testcases := map[string]string{
+		"87cURD]i,\"Ebo80~>":    "Hello World!",
+		"<~87cURD]i,\"Ebo80~>":  "Hello World!",
+		"87cUR\nD]i,\"Eb o80~>": "Hello World!",
+		"z~>":                   "\x00\x00\x00\x00",
+		"5l~>":                  "A",
+		"5sb~>":                 "AB",
+		"~>":                    "",
+	}
+	for encoded, expected := range testcases {
+		decoded, err := decodeASCII85([]byte(encoded))
+		if err != nil {
+			t.Errorf("Error decoding %q: %v", encoded, err)
+			continue
+		}
+		if string(decoded) != expected {
+			t.Errorf("Decoded %q to %q (expected %q)", encoded, decoded, expected)
+		}
+	}
+
+	// Round trip of all byte lengths modulo 4, including zero groups.
+	for n := 0; n < 12; n++ {
+		data := make([]byte, n)
+		for i := range data {
+			if i >= 4 {
+				data[i] = byte(i * 37)
+			}
+		}
+		encoded := make([]byte, ascii85.MaxEncodedLen(len(data)))
+		encoded = encoded[:ascii85.Encode(encoded, data)]
+		decoded, err := decodeASCII85(append(encoded, '~', '>'))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Errorf("Round trip failed for % x: % x", data, decoded)
+		}
+	}
+
+	if _, err := decodeASCII85([]byte("87c{~>")); err == nil {
+		t.Errorf("Invalid character should fail")
+	}
+}
+
+func TestDecodeFilterArray(t *testing.T) {
+	// PNG Sub predictor, 2 rows of 2 columns.
+	raw := []byte("\x01\x01\x01\x01\x02\x02")
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(raw)
+	zw.Close()
+
+	encoded := make([]byte, ascii85.MaxEncodedLen(compressed.Len()))
+	encoded = encoded[:ascii85.Encode(encoded, compressed.Bytes())]
+	encoded = append(encoded, []byte("~>")...)
+
+	dict := PdfObjectDictionary{}
+	dict["Filter"] = &PdfObjectArray{makeName("ASCII85Decode"), makeName("FlateDecode")}
+	decodeParams := PdfObjectDictionary{}
+	decodeParams["Predictor"] = makeInteger(11)
+	decodeParams["Columns"] = makeInteger(2)
+	dict["DecodeParms"] = &PdfObjectArray{&PdfObjectNull{}, &decodeParams}
+	stream := PdfObjectStream{PdfObjectDictionary: &dict, Stream: encoded}
+
+	parser := PdfParser{}
+	decoded, err := parser.decodeStream(&stream)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if !bytes.Equal(decoded, []byte("\x01\x02\x02\x04")) {
+		t.Errorf("Unexpected output % x", decoded)
+	}
+
+	// Hex encoded plain data.
+	dict = PdfObjectDictionary{}
+	dict["Filter"] = &PdfObjectArray{makeName("ASCIIHexDecode")}
+	stream = PdfObjectStream{PdfObjectDictionary: &dict, Stream: []byte("414243>")}
+	decoded, err = parser.decodeStream(&stream)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if string(decoded) != "ABC" {
+		t.Errorf("Unexpected output %q", decoded)
+	}
+
+	dict["Filter"] = &PdfObjectArray{makeName("ASCIIHexDecode"), makeName("Unknown")}
+	if _, err := parser.decodeStream(&stream); err == nil {
+		t.Errorf("Unsupported filter should fail")
+	}
+}
+
+func TestRunLengthDecode(t *testing.T) {
+	// Literal run of 3, repeat of 4, end of data.
+	encoded := []byte("\x02abc\xfdx\x80ignored")
+	decoded, err := decodeRunLength(encoded)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if string(decoded) != "abcxxxx" {
+		t.Errorf("Unexpected output %q", decoded)
+	}
+
+	var data bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		if i%100 < 60 {
+			data.WriteByte(byte(i / 100))
+		} else {
+			data.WriteByte(byte(i))
+		}
+	}
+	decoded, err = decodeRunLength(encodeRunLength(data.Bytes()))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if !bytes.Equal(decoded, data.Bytes()) {
+		t.Errorf("Run length round trip failed")
+	}
+
+	for _, truncated := range []string{"\x05abc", "\xfd"} {
+		if _, err := decodeRunLength([]byte(truncated)); err == nil {
+			t.Errorf("Truncated data %q should fail", truncated)
+		}
+	}
+
+	// Chained with Flate and a PNG predictor.
+	raw := []byte("\x02\x05\x05\x02\x00\x00\x02\x00\x00")
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(raw)
+	zw.Close()
+
+	dict := PdfObjectDictionary{}
+	dict["Filter"] = &PdfObjectArray{makeName("RunLengthDecode"), makeName("FlateDecode")}
+	decodeParams := PdfObjectDictionary{}
+	decodeParams["Predictor"] = makeInteger(12)
+	decodeParams["Columns"] = makeInteger(2)
+	dict["DecodeParms"] = &PdfObjectArray{&PdfObjectNull{}, &decodeParams}
+	stream := PdfObjectStream{PdfObjectDictionary: &dict, Stream: encodeRunLength(compressed.Bytes())}
+
+	parser := PdfParser{}
+	decoded, err = parser.decodeStream(&stream)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if !bytes.Equal(decoded, []byte("\x05\x05\x05\x05\x05\x05")) {
+		t.Errorf("Unexpected output % x", decoded)
+	}
+}
+
+func TestNewStream(t *testing.T) {
+	data := bytes.Repeat([]byte("BT /F1 12 Tf 72 720 Td (Hello) Tj ET\n\x00\x00\x00\x00\xff"), 50)
+	parser := PdfParser{}
+	for _, filter := range []PdfObjectName{"", "FlateDecode", "LZWDecode", "RunLengthDecode", "ASCIIHexDecode", "ASCII85Decode"} {
+		stream, err := NewStream(data, filter)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if length, ok := (*stream.PdfObjectDictionary)["Length"].(*PdfObjectInteger); !ok || int(*length) != len(stream.Stream) {
+			t.Errorf("%s: invalid Length", filter)
+		}
+		if filter == "" {
+			if _, hasFilter := (*stream.PdfObjectDictionary)["Filter"]; hasFilter || !bytes.Equal(stream.Stream, data) {
+				t.Errorf("Unfiltered stream modified")
+			}
+			continue
+		}
+		if filter == "FlateDecode" && len(stream.Stream) >= len(data) {
+			t.Errorf("Data not compressed")
+		}
+		decoded, err := parser.decodeStream(stream)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Errorf("%s: round trip failed", filter)
+		}
+	}
+
+	// ASCII85 final partial groups.
+	for n := 0; n <= 8; n++ {
+		decoded, err := decodeASCII85(encodeASCII85(data[36 : 36+n]))
+		if err != nil || !bytes.Equal(decoded, data[36:36+n]) {
+			t.Errorf("ASCII85 round trip of %d bytes failed (%v)", n, err)
+		}
+	}
+
+	if _, err := NewStream(data, "DCTDecode"); err == nil {
+		t.Errorf("Unsupported filter should fail")
+	}
+}
@@ -6,16 +6,27 @@
 package pdf
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"sync"
 )
 
+// Safe for concurrent use: parsing and resolving objects is serialized.
+// Loading a page replaces the references in the objects reachable from
+// the page in place before the page is returned, the returned objects are
+// not modified afterwards.  Objects obtained otherwise, e.g. by Resolve,
+// can still be resolved in place by a later traversal, unless the
+// document is loaded with ReaderOptions.Snapshot.
 type PdfReader struct {
 	parser    *PdfParser
 	root      PdfObject
 	pages     *PdfObjectDictionary
 	pageList  []*PdfIndirectObject
+	pagesObj  *PdfIndirectObject
 	pageCount int
 	catalog   *PdfObjectDictionary
 	outlines  []*PdfIndirectObject
@@ -23,18 +34,69 @@ type PdfReader struct {
 
 	// For tracking traversal (cache).
 	traversed map[PdfObject]bool
+
+	options ReaderOptions
+
+	// Context of the current operation, checked at the recursion
+	// boundaries when loading objects.  Nil if none.
+	ctx context.Context
+
+	// Serializes loading pages and traversing objects.
+	mu sync.Mutex
+	// Serializes parsing and the object cache, taken within mu.
+	cacheMu sync.Mutex
+}
+
+// Options for loading a document.
+type ReaderOptions struct {
+	// Resolve pages on demand in GetPage rather than traversing the whole
+	// page tree when loading the document.  The number of pages is then
+	// taken from the Count of the page tree root.
+	LazyPages bool
+	// Called as the pages are loaded from the page tree, with the number
+	// of pages loaded and the page count of the page tree root.  Not called
+	// with LazyPages.
+	PageProgress func(loaded, total int)
+	// Fully resolve the pages and the objects reachable from the catalog
+	// when loading (or decrypting) the document.  The objects are then no
+	// longer modified and can be read from several goroutines, at the cost
+	// of loading the whole document in memory.
+	Snapshot bool
+	// Limits for parsing and traversing the document.
+	ParserOptions
 }
 
 func NewPdfReader(rs io.ReadSeeker) (*PdfReader, error) {
+	return NewPdfReaderWithOptions(rs, ReaderOptions{})
+}
+
+// Create a reader with the specified options.
+func NewPdfReaderWithOptions(rs io.ReadSeeker, options ReaderOptions) (*PdfReader, error) {
+	return newPdfReader(context.Background(), rs, options)
+}
+
+// Create a reader, aborting with the context error if the context is
+// done before the document structure is loaded.
+func NewPdfReaderContext(ctx context.Context, rs io.ReadSeeker) (*PdfReader, error) {
+	return newPdfReader(ctx, rs, ReaderOptions{})
+}
+
+func newPdfReader(ctx context.Context, rs io.ReadSeeker, options ReaderOptions) (*PdfReader, error) {
 	pdfReader := &PdfReader{}
 	pdfReader.traversed = map[PdfObject]bool{}
+	pdfReader.options = options
+	pdfReader.ctx = ctx
+	defer func() { pdfReader.ctx = nil }()
 
 	// Create the parser, loads the cross reference table and trailer.
-	parser, err := NewParser(rs)
+	parser, err := NewParserWithOptions(rs, options.ParserOptions)
 	if err != nil {
 		return nil, err
 	}
 	pdfReader.parser = parser
+	if err := pdfReader.checkContext(); err != nil {
+		return nil, err
+	}
 
 	isEncrypted, err := pdfReader.IsEncrypted()
 	if err != nil {
@@ -52,10 +114,28 @@ func NewPdfReader(rs io.ReadSeeker) (*PdfReader, error) {
 	return pdfReader, nil
 }
 
+// Check if the context of the current operation is done, returning the
+// context error if so.
+func (this *PdfReader) checkContext() error {
+	if this.ctx == nil {
+		return nil
+	}
+	return this.ctx.Err()
+}
+
 func (this *PdfReader) IsEncrypted() (bool, error) {
 	return this.parser.IsEncrypted()
 }
 
+// Get the access permissions of the document.  Unencrypted documents
+// have all permissions.  The permissions are available before decrypting.
+func (this *PdfReader) GetPermissions() (AccessPermissions, error) {
+	if this.parser.crypter == nil {
+		return fullAccessPermissions(), nil
+	}
+	return this.parser.crypter.GetAccessPermissions(), nil
+}
+
 // Decrypt the PDF file with a specified password.  Also tries to
 // decrypt with an empty password.  Returns true if successful,
 // false otherwise.
@@ -79,12 +159,12 @@ func (this *PdfReader) Decrypt(password []byte) (bool, error) {
 
 func (this *PdfReader) loadStructure() error {
 	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
-		return fmt.Errorf("File need to be decrypted first")
+		return ErrEncrypted
 	}
 
 	root, ok := (*(this.parser.trailer))["Root"].(*PdfObjectReference)
 	if !ok {
-		return fmt.Errorf("Invalid Root (trailer: %s)", *(this.parser.trailer))
+		return wrapError(ErrInvalidCatalog, "Invalid Root (trailer: %s)", *(this.parser.trailer))
 	}
 
 	oc, err := this.parser.LookupByReference(*root)
@@ -97,19 +177,19 @@ func (this *PdfReader) loadStructure() error {
 	pcatalog, ok := oc.(*PdfIndirectObject)
 	if !ok {
 		log.Error("Missing catalog: (root %q) (trailer %s)", oc, *(this.parser.trailer))
-		return errors.New("Missing catalog")
+		return wrapError(ErrInvalidCatalog, "Missing catalog")
 	}
 
 	catalog, ok := (*pcatalog).PdfObject.(*PdfObjectDictionary)
 	if !ok {
 		log.Error("Invalid catalog (%s)", pcatalog.PdfObject)
-		return errors.New("Invalid catalog")
+		return ErrInvalidCatalog
 	}
 	log.Debug("Catalog: %s", catalog)
 
 	pagesRef, ok := (*catalog)["Pages"].(*PdfObjectReference)
 	if !ok {
-		return errors.New("Pages in catalog should be a reference")
+		return wrapError(ErrInvalidCatalog, "Pages in catalog should be a reference")
 	}
 
 	op, err := this.parser.LookupByReference(*pagesRef)
@@ -121,12 +201,12 @@ func (this *PdfReader) loadStructure() error {
 	if !ok {
 		log.Error("Pages object invalid")
 		log.Error("op: %p", ppages)
-		return errors.New("Pages object invalid")
+		return wrapError(ErrInvalidCatalog, "Pages object invalid")
 	}
 	pages, ok := ppages.PdfObject.(*PdfObjectDictionary)
 	if !ok {
 		log.Error("Pages object invalid (%s)", ppages)
-		return errors.New("Pages object invalid")
+		return wrapError(ErrInvalidCatalog, "Pages object invalid")
 	}
 
 	pageCount, ok := (*pages)["Count"].(*PdfObjectInteger)
@@ -140,10 +220,23 @@ func (this *PdfReader) loadStructure() error {
 	this.pages = pages
 	this.pageCount = int(*pageCount)
 	this.pageList = []*PdfIndirectObject{}
+	this.pagesObj = ppages
 
-	err = this.buildToc(ppages, nil)
-	if err != nil {
-		return err
+	if this.options.LazyPages {
+		if this.pageCount < 0 {
+			return errors.New("Pages count invalid")
+		}
+		// Slots filled as the pages are loaded.
+		this.pageList = make([]*PdfIndirectObject, this.pageCount)
+	} else {
+		err = this.buildToc(ppages, nil, map[*PdfIndirectObject]bool{})
+		if err != nil {
+			return err
+		}
+		if len(this.pageList) != this.pageCount {
+			// The traversed page list is authoritative.
+			log.Debug("Pages Count %d differs from the number of pages %d", this.pageCount, len(this.pageList))
+		}
 	}
 	log.Debug("---")
 	log.Debug("TOC")
@@ -161,9 +254,25 @@ func (this *PdfReader) loadStructure() error {
 		return err
 	}
 
+	if this.options.Snapshot {
+		return this.resolveAll()
+	}
 	return nil
 }
 
+// Resolve all the pages and the objects reachable from the catalog,
+// replacing the references in place.
+func (this *PdfReader) resolveAll() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for i := range this.pageList {
+		if _, err := this.getPage(i + 1); err != nil {
+			return err
+		}
+	}
+	return this.traverseObjectDataLocked(this.catalog, map[PdfObjectName]bool{"Parent": true})
+}
+
 // Load the document outlines.
 // Returns a list of the outermost layer of the Outlines dictionary,
 // which then has connections to the inner layers.
@@ -171,7 +280,7 @@ func (this *PdfReader) loadStructure() error {
 // to their objects which are fully loaded in memory.
 func (this *PdfReader) GetOutlines() ([]*PdfIndirectObject, error) {
 	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
-		return nil, fmt.Errorf("File need to be decrypted first")
+		return nil, ErrEncrypted
 	}
 	outlinesList := []*PdfIndirectObject{}
 
@@ -183,7 +292,7 @@ func (this *PdfReader) GetOutlines() ([]*PdfIndirectObject, error) {
 	}
 
 	log.Debug("Has outlines")
-	outlinesObj, err := this.parser.LookupByReference(*outlinesRef)
+	outlinesObj, err := this.lookupByReference(outlinesRef)
 	if err != nil {
 		log.Error("Failed to read outlines")
 		return outlinesList, err
@@ -233,10 +342,68 @@ func (this *PdfReader) GetOutlines() ([]*PdfIndirectObject, error) {
 	return outlinesList, nil
 }
 
+// Get a copy of the trailer dictionary.  References in the trailer, such
+// as Root and Info, are left unresolved.
+func (this *PdfReader) GetTrailer() (*PdfObjectDictionary, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+	trailer := PdfObjectDictionary{}
+	for key, val := range *this.parser.trailer {
+		trailer[key] = val
+	}
+	return &trailer, nil
+}
+
+// Get the PDF version of the document: the version in the file header,
+// or the catalog Version entry if later than the header version.
+func (this *PdfReader) GetPdfVersion() (major, minor int, err error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return 0, 0, ErrEncrypted
+	}
+	major, minor = this.parser.majorVersion, this.parser.minorVersion
+
+	obj, err := this.traceToDirectObject((*this.catalog)["Version"])
+	if err != nil {
+		return 0, 0, err
+	}
+	if name, ok := obj.(*PdfObjectName); ok {
+		catalogMajor, catalogMinor, err := parseVersionString(string(*name))
+		if err != nil {
+			log.Debug("Ignoring invalid catalog version (%s)", err)
+		} else if catalogMajor > major || (catalogMajor == major && catalogMinor > minor) {
+			major, minor = catalogMajor, catalogMinor
+		}
+	}
+	return major, minor, nil
+}
+
+// Get the document catalog.  Nil if the document has not been decrypted.
+func (this *PdfReader) GetCatalog() *PdfObjectDictionary {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil
+	}
+	return this.catalog
+}
+
+// Get an entry of the document catalog, such as Lang or OpenAction.
+// A reference is resolved to the object it refers to.  Returns nil if the
+// entry is missing.
+func (this *PdfReader) GetCatalogEntry(name string) (PdfObject, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+	obj, has := (*this.catalog)[PdfObjectName(name)]
+	if !has {
+		return nil, nil
+	}
+	return this.traceToDirectObject(obj)
+}
+
 // Get document form data.
 func (this *PdfReader) GetForms() (*PdfObjectDictionary, error) {
 	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
-		return nil, fmt.Errorf("File need to be decrypted first")
+		return nil, ErrEncrypted
 	}
 	// Has forms?
 	catalog := this.catalog
@@ -248,7 +415,7 @@ func (this *PdfReader) GetForms() (*PdfObjectDictionary, error) {
 		formsDict = dict
 	} else if formsRef, hasFormsRef := (*catalog)["AcroForm"].(*PdfObjectReference); hasFormsRef {
 		log.Debug("Has Acro forms - Indirect object")
-		formsObj, err := this.parser.LookupByReference(*formsRef)
+		formsObj, err := this.lookupByReference(formsRef)
 		if err != nil {
 			log.Error("Failed to read forms")
 			return nil, err
@@ -282,10 +449,24 @@ func (this *PdfReader) GetForms() (*PdfObjectDictionary, error) {
 // Build the table of contents.
 // tree, ex: Pages -> Pages -> Pages -> Page
 // Traverse through the whole thing recursively.
-func (this *PdfReader) buildToc(node *PdfIndirectObject, parent *PdfIndirectObject) error {
+// The Pages nodes on the current recursion path are tracked to detect
+// circular references.
+func (this *PdfReader) buildToc(node *PdfIndirectObject, parent *PdfIndirectObject, traversedPageNodes map[*PdfIndirectObject]bool) error {
 	if node == nil {
 		return nil
 	}
+	if err := this.checkContext(); err != nil {
+		return err
+	}
+	if err := this.enterLevel(); err != nil {
+		return err
+	}
+	defer this.leaveLevel()
+
+	if _, alreadyTraversed := traversedPageNodes[node]; alreadyTraversed {
+		log.Error("Circular Pages reference")
+		return errors.New("Circular Pages reference")
+	}
 
 	nodeDict, ok := node.PdfObject.(*PdfObjectDictionary)
 	if !ok {
@@ -303,6 +484,9 @@ func (this *PdfReader) buildToc(node *PdfIndirectObject, parent *PdfIndirectObje
 			(*nodeDict)["Parent"] = parent
 		}
 		this.pageList = append(this.pageList, node)
+		if this.options.PageProgress != nil {
+			this.options.PageProgress(len(this.pageList), this.pageCount)
+		}
 		return nil
 	}
 	if *objType != "Pages" {
@@ -315,6 +499,9 @@ func (this *PdfReader) buildToc(node *PdfIndirectObject, parent *PdfIndirectObje
 		(*nodeDict)["Parent"] = parent
 	}
 
+	traversedPageNodes[node] = true
+	defer delete(traversedPageNodes, node)
+
 	// Resolve the object recursively, not following Parents or Kids fields.
 	// Later can refactor and use only one smart recursive function.
 	nofollowList := map[PdfObjectName]bool{
@@ -355,8 +542,8 @@ func (this *PdfReader) buildToc(node *PdfIndirectObject, parent *PdfIndirectObje
 		log.Debug("look up ref %s", childRef)
 		pchild, err := this.parser.LookupByReference(*childRef)
 		if err != nil {
-			log.Error("Unable to lookup page ref")
-			return errors.New("Unable to lookup page ref")
+			log.Error("Unable to lookup page ref (%s)", err)
+			return wrapError(err, "Unable to lookup page ref")
 		}
 		child, ok := pchild.(*PdfIndirectObject)
 		if !ok {
@@ -364,7 +551,7 @@ func (this *PdfReader) buildToc(node *PdfIndirectObject, parent *PdfIndirectObje
 			return errors.New("Page not indirect object")
 		}
 		(*kids)[idx] = child
-		err = this.buildToc(child, node)
+		err = this.buildToc(child, node, traversedPageNodes)
 		if err != nil {
 			return err
 		}
@@ -373,17 +560,73 @@ func (this *PdfReader) buildToc(node *PdfIndirectObject, parent *PdfIndirectObje
 	return nil
 }
 
-// Get the number of pages in the document.
+// Get the number of pages in the document.  This is the number of pages
+// found when traversing the page tree, or the Count of the page tree root
+// when the pages are loaded lazily.
 func (this *PdfReader) GetNumPages() (int, error) {
 	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
-		return -1, fmt.Errorf("File need to be decrypted first")
+		return -1, ErrEncrypted
 	}
 	return len(this.pageList), nil
 }
 
+// Get the number of pages without loading the document structure, also
+// before an encrypted document is decrypted.  Reads the Count of the page
+// tree root.  In the rare case of a Count disagreeing with the actual
+// number of pages, the number from the page tree traversal is returned
+// once the structure is loaded (see GetNumPages).
+func (this *PdfReader) GetPageCount() (int, error) {
+	if this.pages != nil {
+		return len(this.pageList), nil
+	}
+
+	// Look up the objects without decrypting, the Count is an integer and
+	// never encrypted.
+	lookup := func(obj PdfObject) (*PdfObjectDictionary, error) {
+		ref, ok := obj.(*PdfObjectReference)
+		if !ok {
+			return nil, errors.New("Expecting a reference")
+		}
+		this.cacheMu.Lock()
+		obj, _, err := this.parser.lookupByNumber(int(ref.ObjectNumber), false)
+		this.cacheMu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		ind, ok := obj.(*PdfIndirectObject)
+		if !ok {
+			return nil, errors.New("Expecting an indirect object")
+		}
+		dict, ok := ind.PdfObject.(*PdfObjectDictionary)
+		if !ok {
+			return nil, errors.New("Expecting a dictionary")
+		}
+		return dict, nil
+	}
+
+	catalog, err := lookup((*this.parser.trailer)["Root"])
+	if err != nil {
+		log.Error("Unable to load catalog (%s)", err)
+		return -1, err
+	}
+	pages, err := lookup((*catalog)["Pages"])
+	if err != nil {
+		log.Error("Unable to load pages (%s)", err)
+		return -1, err
+	}
+	pageCount, ok := (*pages)["Count"].(*PdfObjectInteger)
+	if !ok {
+		log.Error("Pages count object invalid")
+		return -1, errors.New("Pages count invalid")
+	}
+	return int(*pageCount), nil
+}
+
 // Resolves a reference, returning the object and indicates whether or not
 // it was cached.
 func (this *PdfReader) resolveReference(ref *PdfObjectReference) (PdfObject, bool, error) {
+	this.cacheMu.Lock()
+	defer this.cacheMu.Unlock()
 	cachedObj, isCached := this.parser.ObjCache[int(ref.ObjectNumber)]
 	if !isCached {
 		log.Debug("Reader Lookup ref: %s", ref)
@@ -394,9 +637,172 @@ func (this *PdfReader) resolveReference(ref *PdfObjectReference) (PdfObject, boo
 		this.parser.ObjCache[int(ref.ObjectNumber)] = obj
 		return obj, false, nil
 	}
+	// Objects cached before the document was decrypted.
+	if crypter := this.parser.crypter; crypter != nil && crypter.authenticated && !crypter.isDecrypted(cachedObj) {
+		err := crypter.Decrypt(cachedObj, 0, 0)
+		if err != nil {
+			return nil, true, err
+		}
+	}
 	return cachedObj, true, nil
 }
 
+// Enter a recursion level of the parser, shared with the parsing.
+func (this *PdfReader) enterLevel() error {
+	this.cacheMu.Lock()
+	defer this.cacheMu.Unlock()
+	return this.parser.enterLevel()
+}
+
+func (this *PdfReader) leaveLevel() {
+	this.cacheMu.Lock()
+	defer this.cacheMu.Unlock()
+	this.parser.leaveLevel()
+}
+
+// Look up a reference without caching the object.
+func (this *PdfReader) lookupByReference(ref *PdfObjectReference) (PdfObject, error) {
+	this.cacheMu.Lock()
+	defer this.cacheMu.Unlock()
+	return this.parser.LookupByReference(*ref)
+}
+
+// Get an object directly by its object and generation number, as listed
+// in the cross reference table.  Intended for low-level access and
+// diagnostics, the object is cached like objects resolved by reference.
+func (this *PdfReader) GetObject(number int, generation int) (PdfObject, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+	xref, has := this.parser.xrefs[number]
+	if !has {
+		log.Error("Object %d not in the xref table", number)
+		return nil, fmt.Errorf("Object %d not in the xref table", number)
+	}
+	// Objects in object streams always have generation 0.
+	if xref.xtype == XREF_TABLE_ENTRY && xref.generation != generation {
+		log.Error("Object %d has generation %d, not %d", number, xref.generation, generation)
+		return nil, fmt.Errorf("Object %d %d not in the xref table (generation %d)", number, generation, xref.generation)
+	}
+	if xref.xtype == XREF_OBJECT_STREAM && generation != 0 {
+		return nil, fmt.Errorf("Object %d %d not in the xref table (generation 0)", number, generation)
+	}
+
+	ref := PdfObjectReference{ObjectNumber: int64(number), GenerationNumber: int64(generation)}
+	obj, _, err := this.resolveReference(&ref)
+	return obj, err
+}
+
+// Get the numbers of the objects listed in the cross reference table,
+// including the previous (Prev) tables, sorted ascending.  Both the
+// objects in use and the free entries are listed, see IsFreeObject.
+func (this *PdfReader) ObjectNumbers() ([]int, error) {
+	if this.parser.xrefs == nil {
+		return nil, errors.New("Cross reference table not loaded")
+	}
+	numbers := []int{}
+	for number := range this.parser.xrefs {
+		numbers = append(numbers, number)
+	}
+	for number := range this.parser.freeObjects {
+		if _, inUse := this.parser.xrefs[number]; !inUse {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// Check if an object number has a free entry in the cross reference
+// table, i.e. is not in use.
+func (this *PdfReader) IsFreeObject(number int) bool {
+	_, inUse := this.parser.xrefs[number]
+	return !inUse && this.parser.freeObjects[number]
+}
+
+// Clear the object cache of the parser, e.g. after processing each page
+// of a large document, to bound the memory use.  Objects already returned
+// remain valid, but objects resolved afterwards are parsed again and are
+// distinct from the objects returned before clearing.  The parser limits
+// (MaxObjects) apply to the objects loaded after clearing.
+func (this *PdfReader) ClearObjectCache() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.cacheMu.Lock()
+	defer this.cacheMu.Unlock()
+	this.parser.ObjCache = ObjectCache{}
+	this.parser.objstms = ObjectStreams{}
+	// Counted again from here on, the depth is 0 between operations.
+	this.parser.numObjects = 0
+	this.parser.depth = 0
+	if this.parser.crypter != nil {
+		this.parser.crypter.decryptedObjects = map[PdfObject]bool{}
+	}
+	this.traversed = map[PdfObject]bool{}
+}
+
+// Get the number of objects in the object cache and an approximation of
+// the memory used by the cached objects and decoded object streams.
+func (this *PdfReader) CacheStats() (entries int, approxBytes int) {
+	this.cacheMu.Lock()
+	defer this.cacheMu.Unlock()
+	visited := map[PdfObject]bool{}
+	for _, obj := range this.parser.ObjCache {
+		approxBytes += approxObjectSize(obj, visited)
+	}
+	for _, objstm := range this.parser.objstms {
+		approxBytes += len(objstm.ds) + 16*len(objstm.offsets)
+	}
+	return len(this.parser.ObjCache), approxBytes
+}
+
+// Approximate the memory used by an object, counting shared objects once.
+func approxObjectSize(obj PdfObject, visited map[PdfObject]bool) int {
+	// Overhead of an object value and its interface.
+	const overhead = 16
+	switch t := obj.(type) {
+	case *PdfIndirectObject:
+		if visited[obj] {
+			return 0
+		}
+		visited[obj] = true
+		return overhead + 16 + approxObjectSize(t.PdfObject, visited)
+	case *PdfObjectStream:
+		if visited[obj] {
+			return 0
+		}
+		visited[obj] = true
+		return overhead + 16 + len(t.Stream) + approxObjectSize(t.PdfObjectDictionary, visited)
+	case *PdfObjectDictionary:
+		if visited[obj] {
+			return 0
+		}
+		visited[obj] = true
+		size := overhead
+		for k, v := range *t {
+			size += overhead + len(k) + approxObjectSize(v, visited)
+		}
+		return size
+	case *PdfObjectArray:
+		if visited[obj] {
+			return 0
+		}
+		visited[obj] = true
+		size := overhead
+		for _, v := range *t {
+			size += approxObjectSize(v, visited)
+		}
+		return size
+	case *PdfObjectString:
+		return overhead + len(*t)
+	case *PdfObjectHexString:
+		return overhead + len(*t)
+	case *PdfObjectName:
+		return overhead + len(*t)
+	}
+	return overhead
+}
+
 /*
  * Recursively traverse through the page object data and look up
  * references to indirect objects.
@@ -405,21 +811,41 @@ func (this *PdfReader) resolveReference(ref *PdfObjectReference) (PdfObject, boo
  * GH: Are we fully protected against circular references?
  */
 func (this *PdfReader) traverseObjectData(o PdfObject, nofollowKeys map[PdfObjectName]bool) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.traverseObjectDataLocked(o, nofollowKeys)
+}
+
+// Traverse with mu held.
+func (this *PdfReader) traverseObjectDataLocked(o PdfObject, nofollowKeys map[PdfObjectName]bool) (err error) {
 	log.Debug("Traverse object data")
+	if err := this.checkContext(); err != nil {
+		return err
+	}
 	if _, isTraversed := this.traversed[o]; isTraversed {
 		return nil
 	}
+	if err := this.enterLevel(); err != nil {
+		return err
+	}
+	defer this.leaveLevel()
 	this.traversed[o] = true
+	// Traverse again next time if aborted.
+	defer func() {
+		if err != nil {
+			delete(this.traversed, o)
+		}
+	}()
 
-	if io, isIndirectObj := o.(*PdfIndirectObject); isIndirectObj {
-		log.Debug("io: %s", io)
-		log.Debug("- %s", io.PdfObject)
-		err := this.traverseObjectData(io.PdfObject, nofollowKeys)
+	if ind, isIndirectObj := o.(*PdfIndirectObject); isIndirectObj {
+		log.Debug("ind: %s", ind)
+		log.Debug("- %s", ind.PdfObject)
+		err := this.traverseObjectDataLocked(ind.PdfObject, nofollowKeys)
 		return err
 	}
 
 	if so, isStreamObj := o.(*PdfObjectStream); isStreamObj {
-		err := this.traverseObjectData(so.PdfObjectDictionary, nofollowKeys)
+		err := this.traverseObjectDataLocked(so.PdfObjectDictionary, nofollowKeys)
 		return err
 	}
 
@@ -439,12 +865,12 @@ func (this *PdfReader) traverseObjectData(o PdfObject, nofollowKeys map[PdfObjec
 					return err
 				}
 				(*dict)[name] = resolvedObj
-				err = this.traverseObjectData(resolvedObj, nofollowKeys)
+				err = this.traverseObjectDataLocked(resolvedObj, nofollowKeys)
 				if err != nil {
 					return err
 				}
 			} else {
-				err := this.traverseObjectData(v, nofollowKeys)
+				err := this.traverseObjectDataLocked(v, nofollowKeys)
 				if err != nil {
 					return err
 				}
@@ -463,12 +889,12 @@ func (this *PdfReader) traverseObjectData(o PdfObject, nofollowKeys map[PdfObjec
 				}
 				(*arr)[idx] = resolvedObj
 
-				err = this.traverseObjectData(resolvedObj, nofollowKeys)
+				err = this.traverseObjectDataLocked(resolvedObj, nofollowKeys)
 				if err != nil {
 					return err
 				}
 			} else {
-				err := this.traverseObjectData(v, nofollowKeys)
+				err := this.traverseObjectDataLocked(v, nofollowKeys)
 				if err != nil {
 					return err
 				}
@@ -489,7 +915,7 @@ func (this *PdfReader) traverseObjectData(o PdfObject, nofollowKeys map[PdfObjec
 // outlines.
 func (this *PdfReader) GetOutlinesForPage(page PdfObject) ([]*PdfIndirectObject, error) {
 	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
-		return nil, fmt.Errorf("File need to be decrypted first")
+		return nil, ErrEncrypted
 	}
 	pageOutlines := []*PdfIndirectObject{}
 
@@ -500,66 +926,91 @@ func (this *PdfReader) GetOutlinesForPage(page PdfObject) ([]*PdfIndirectObject,
 			return pageOutlines, fmt.Errorf("Invalid outlines entry")
 		}
 
-		if dest, hasDest := (*dict)["Dest"].(*PdfObjectArray); hasDest {
-			if len(*dest) > 0 {
-				if (*dest)[0] == page {
-					pageOutlines = append(pageOutlines, outlineObj)
-				}
+		var dest *PdfObjectArray
+		if destObj, hasDest := (*dict)["Dest"]; hasDest {
+			var err error
+			dest, err = this.resolveDestination(destObj)
+			if err != nil {
+				return pageOutlines, err
 			}
-		}
-		// Action: GoTo destination (page) can refer directly to a page.
-		// TODO: Support more potential actions.  Make generic.
-		// Can we make those sub conditionals cleaner?  Some kind of
-		// generic tree traversal / unmarshalling.
-		if dict, hasAdict := (*dict)["A"].(*PdfObjectDictionary); hasAdict {
-			if s, hasS := (*dict)["S"].(*PdfObjectName); hasS {
-				if *s == "GoTo" {
-					if d, hasD := (*dict)["D"].(*PdfObjectArray); hasD {
-						if len(*d) > 0 {
-							if (*d)[0] == page {
-								pageOutlines = append(pageOutlines, outlineObj)
-							}
-						}
-					}
-				}
+		} else if actionObj, hasA := (*dict)["A"]; hasA {
+			// Action: GoTo destination (page) can refer directly to a page.
+			action, err := this.resolveAction(actionObj)
+			if err != nil {
+				return pageOutlines, err
+			}
+			if action.Type == "GoTo" {
+				dest = action.Dest
 			}
 		}
 
-		if a, hasA := (*dict)["A"].(*PdfIndirectObject); hasA {
-			if dict, ok := a.PdfObject.(*PdfObjectDictionary); ok {
-				if s, hasS := (*dict)["S"].(*PdfObjectName); hasS {
-					if *s == "GoTo" {
-						if d, hasD := (*dict)["D"].(*PdfObjectArray); hasD {
-							if len(*d) > 0 {
-								if (*d)[0] == page {
-									pageOutlines = append(pageOutlines, outlineObj)
-								}
-							}
-						}
-					}
-				}
-			}
+		if dest != nil && len(*dest) > 0 && (*dest)[0] == page {
+			pageOutlines = append(pageOutlines, outlineObj)
 		}
 	}
 	return pageOutlines, nil
 }
 
+// Get a page by the page number, aborting with the context error if the
+// context is done before the page is loaded.
+func (this *PdfReader) GetPageContext(ctx context.Context, pageNumber int) (PdfObject, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	prevCtx := this.ctx
+	this.ctx = ctx
+	defer func() { this.ctx = prevCtx }()
+	return this.getPage(pageNumber)
+}
+
 // Get a page by the page number.
 // Indirect object with type /Page.
 func (this *PdfReader) GetPage(pageNumber int) (PdfObject, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.getPage(pageNumber)
+}
+
+// Get all the pages in order, each resolved like by GetPage.
+func (this *PdfReader) GetAllPages() ([]*PdfIndirectObject, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
 	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
-		return nil, fmt.Errorf("File need to be decrypted first")
+		return nil, ErrEncrypted
 	}
-	if len(this.pageList) < pageNumber {
-		return nil, errors.New("Invalid page number (page count too short)")
+	pages := make([]*PdfIndirectObject, len(this.pageList))
+	for i := range this.pageList {
+		page, err := this.getPage(i + 1)
+		if err != nil {
+			return nil, err
+		}
+		pages[i] = page.(*PdfIndirectObject)
+	}
+	return pages, nil
+}
+
+func (this *PdfReader) getPage(pageNumber int) (PdfObject, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+	if pageNumber < 1 || pageNumber > len(this.pageList) {
+		return nil, fmt.Errorf("Invalid page number %d (valid range 1-%d)", pageNumber, len(this.pageList))
 	}
 	page := this.pageList[pageNumber-1]
+	if page == nil {
+		// Not loaded yet (lazy mode).
+		var err error
+		page, err = this.lookupPage(this.pagesObj, pageNumber-1, map[*PdfIndirectObject]bool{})
+		if err != nil {
+			return nil, err
+		}
+		this.pageList[pageNumber-1] = page
+	}
 
 	nofollowList := map[PdfObjectName]bool{
 		"Parent": true,
 	}
 	// Look up all references related to page and load everything.
-	err := this.traverseObjectData(page, nofollowList)
+	err := this.traverseObjectDataLocked(page, nofollowList)
 	if err != nil {
 		return nil, err
 	}
@@ -568,3 +1019,523 @@ func (this *PdfReader) GetPage(pageNumber int) (PdfObject, error) {
 
 	return page, nil
 }
+
+// Look up a page by index (starting from 0) in a Pages node, descending
+// only into the subtree containing the page based on the Count of the
+// Pages nodes.
+func (this *PdfReader) lookupPage(node *PdfIndirectObject, index int, traversedPageNodes map[*PdfIndirectObject]bool) (*PdfIndirectObject, error) {
+	if err := this.checkContext(); err != nil {
+		return nil, err
+	}
+	if _, alreadyTraversed := traversedPageNodes[node]; alreadyTraversed {
+		log.Error("Circular Pages reference")
+		return nil, errors.New("Circular Pages reference")
+	}
+	traversedPageNodes[node] = true
+
+	nodeDict, ok := node.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return nil, errors.New("Node not a dictionary")
+	}
+	kidsObj, err := this.traceToDirectObject((*nodeDict)["Kids"])
+	if err != nil {
+		return nil, err
+	}
+	kids, ok := kidsObj.(*PdfObjectArray)
+	if !ok {
+		return nil, errors.New("Invalid Kids object")
+	}
+
+	for idx, kidObj := range *kids {
+		if ref, isRef := kidObj.(*PdfObjectReference); isRef {
+			kidObj, _, err = this.resolveReference(ref)
+			if err != nil {
+				return nil, err
+			}
+		}
+		kid, ok := kidObj.(*PdfIndirectObject)
+		if !ok {
+			log.Error("Page not indirect object (%T)", kidObj)
+			return nil, errors.New("Page not indirect object")
+		}
+		// Only written once, the nodes are read concurrently afterwards.
+		if kidObj != (*kids)[idx] {
+			(*kids)[idx] = kid
+		}
+		kidDict, ok := kid.PdfObject.(*PdfObjectDictionary)
+		if !ok {
+			return nil, errors.New("Node not a dictionary")
+		}
+
+		objType, ok := (*kidDict)["Type"].(*PdfObjectName)
+		if !ok {
+			return nil, errors.New("Node missing Type (Required)")
+		}
+		switch *objType {
+		case "Page":
+			if index == 0 {
+				// Set the parent (in case missing or incorrect).
+				if (*kidDict)["Parent"] != node {
+					(*kidDict)["Parent"] = node
+				}
+				return kid, nil
+			}
+			index--
+		case "Pages":
+			count, ok := (*kidDict)["Count"].(*PdfObjectInteger)
+			if !ok {
+				return nil, errors.New("Pages count invalid")
+			}
+			if index < int(*count) {
+				if (*kidDict)["Parent"] != node {
+					(*kidDict)["Parent"] = node
+				}
+				return this.lookupPage(kid, index, traversedPageNodes)
+			}
+			index -= int(*count)
+		default:
+			log.Error("Table of content containing non Page/Pages object! (%s)", *objType)
+			return nil, errors.New("Table of content containing non Page/Pages object!")
+		}
+	}
+
+	log.Error("Page not found in page tree (Count inconsistent)")
+	return nil, errors.New("Page not found")
+}
+
+// Resolve an object to its value: references are resolved (cached like
+// all the loaded objects) and indirect objects unwrapped one level.  Other
+// objects, including streams, are returned as is.
+func (this *PdfReader) Resolve(obj PdfObject) (PdfObject, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+	return this.traceToDirectObject(obj)
+}
+
+// Trace an object to the direct object, resolving references and
+// unwrapping indirect objects.
+func (this *PdfReader) traceToDirectObject(obj PdfObject) (PdfObject, error) {
+	if ref, isRef := obj.(*PdfObjectReference); isRef {
+		resolvedObj, _, err := this.resolveReference(ref)
+		if err != nil {
+			return nil, err
+		}
+		obj = resolvedObj
+	}
+	if ind, isIndirectObj := obj.(*PdfIndirectObject); isIndirectObj {
+		return ind.PdfObject, nil
+	}
+	return obj, nil
+}
+
+// Look up an inheritable page attribute (Resources, MediaBox, CropBox or
+// Rotate).  If missing in the page, the attribute is inherited from the
+// closest Pages node up the tree that has it.
+// Returns nil if the attribute is not found.
+func (this *PdfReader) getInheritedAttribute(page *PdfIndirectObject, name PdfObjectName) (PdfObject, error) {
+	traversed := map[*PdfIndirectObject]bool{}
+
+	node := page
+	for node != nil {
+		if _, alreadyTraversed := traversed[node]; alreadyTraversed {
+			log.Error("Circular Parent reference")
+			return nil, errors.New("Circular Parent reference")
+		}
+		traversed[node] = true
+
+		dict, ok := node.PdfObject.(*PdfObjectDictionary)
+		if !ok {
+			return nil, errors.New("Page tree node not a dictionary")
+		}
+		if obj, has := (*dict)[name]; has {
+			return this.traceToDirectObject(obj)
+		}
+
+		parentObj := (*dict)["Parent"]
+		if ref, isRef := parentObj.(*PdfObjectReference); isRef {
+			resolvedObj, _, err := this.resolveReference(ref)
+			if err != nil {
+				return nil, err
+			}
+			parentObj = resolvedObj
+		}
+		node, _ = parentObj.(*PdfIndirectObject)
+	}
+
+	return nil, nil
+}
+
+// Get the rotation of a page in degrees (clockwise), taking into account
+// the Rotate entry inherited from the page tree.  Returns one of 0, 90,
+// 180 or 270.
+func (this *PdfReader) GetPageRotation(pageNumber int) (int, error) {
+	pageObj, err := this.GetPage(pageNumber)
+	if err != nil {
+		return 0, err
+	}
+	page, ok := pageObj.(*PdfIndirectObject)
+	if !ok {
+		return 0, errors.New("Page not an indirect object")
+	}
+
+	obj, err := this.getInheritedAttribute(page, "Rotate")
+	if err != nil {
+		return 0, err
+	}
+	if obj == nil {
+		return 0, nil
+	}
+
+	rotate, ok := obj.(*PdfObjectInteger)
+	if !ok {
+		log.Error("Invalid Rotate object (%T)", obj)
+		return 0, errors.New("Invalid Rotate object")
+	}
+
+	return normalizeRotation(int(*rotate))
+}
+
+// Get the content of a page as decoded bytes.  If the page has multiple
+// content streams, they are concatenated with a separating newline.
+func (this *PdfReader) GetPageContents(pageNumber int) ([]byte, error) {
+	pageObj, err := this.GetPage(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	page, ok := pageObj.(*PdfIndirectObject)
+	if !ok {
+		return nil, errors.New("Page not an indirect object")
+	}
+	pDict, ok := page.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return nil, errors.New("Page not a dictionary")
+	}
+
+	contentsObj, hasContents := (*pDict)["Contents"]
+	if !hasContents {
+		// No contents, an empty page.
+		return []byte{}, nil
+	}
+	if ref, isRef := contentsObj.(*PdfObjectReference); isRef {
+		contentsObj, _, err = this.resolveReference(ref)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if ind, isIndirectObj := contentsObj.(*PdfIndirectObject); isIndirectObj {
+		contentsObj = ind.PdfObject
+	}
+
+	// Contents is either a single stream or an array of streams.
+	var contentsList []PdfObject
+	if arr, isArray := contentsObj.(*PdfObjectArray); isArray {
+		contentsList = *arr
+	} else {
+		contentsList = []PdfObject{contentsObj}
+	}
+
+	var buf bytes.Buffer
+	for idx, obj := range contentsList {
+		if ref, isRef := obj.(*PdfObjectReference); isRef {
+			obj, _, err = this.resolveReference(ref)
+			if err != nil {
+				return nil, err
+			}
+		}
+		stream, ok := obj.(*PdfObjectStream)
+		if !ok {
+			log.Error("Page contents not a stream (%T)", obj)
+			return nil, errors.New("Page contents not a stream")
+		}
+
+		data, err := this.parser.decodeStream(stream)
+		if err != nil {
+			log.Error("Unable to decode page content stream (%s)", err)
+			return nil, err
+		}
+
+		if idx > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.Write(data)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Get the resources dictionary of a page.  If the page does not have
+// Resources, they are inherited from the closest ancestor Pages node that
+// has them.
+func (this *PdfReader) GetPageResources(pageNumber int) (*PdfObjectDictionary, error) {
+	pageObj, err := this.GetPage(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	page, ok := pageObj.(*PdfIndirectObject)
+	if !ok {
+		return nil, errors.New("Page not an indirect object")
+	}
+
+	obj, err := this.getInheritedAttribute(page, "Resources")
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		log.Error("Page %d missing Resources", pageNumber)
+		return nil, fmt.Errorf("Page %d missing Resources", pageNumber)
+	}
+
+	resources, ok := obj.(*PdfObjectDictionary)
+	if !ok {
+		log.Error("Invalid Resources object (%T)", obj)
+		return nil, errors.New("Invalid Resources object")
+	}
+	return resources, nil
+}
+
+// Look up a key in a name tree (7.9.6), following the Kids and checking
+// the Limits of the intermediate nodes.  Returns nil if not found.
+func (this *PdfReader) lookupNameTree(node PdfObject, key string, traversed map[*PdfObjectDictionary]bool) (PdfObject, error) {
+	obj, err := this.traceToDirectObject(node)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := obj.(*PdfObjectDictionary)
+	if !ok {
+		log.Error("Invalid name tree node (%T)", obj)
+		return nil, errors.New("Invalid name tree node")
+	}
+	if _, alreadyTraversed := traversed[dict]; alreadyTraversed {
+		log.Error("Circular name tree reference")
+		return nil, errors.New("Circular name tree reference")
+	}
+	traversed[dict] = true
+
+	if limitsObj, hasLimits := (*dict)["Limits"]; hasLimits {
+		obj, err := this.traceToDirectObject(limitsObj)
+		if err != nil {
+			return nil, err
+		}
+		limits, ok := obj.(*PdfObjectArray)
+		if ok && len(*limits) == 2 {
+			low, ok1 := asString((*limits)[0])
+			high, ok2 := asString((*limits)[1])
+			if ok1 && ok2 && (key < string(*low) || key > string(*high)) {
+				return nil, nil
+			}
+		}
+	}
+
+	if namesObj, hasNames := (*dict)["Names"]; hasNames {
+		obj, err := this.traceToDirectObject(namesObj)
+		if err != nil {
+			return nil, err
+		}
+		names, ok := obj.(*PdfObjectArray)
+		if !ok {
+			return nil, errors.New("Invalid name tree Names array")
+		}
+		for i := 0; i+1 < len(*names); i += 2 {
+			name, ok := asString((*names)[i])
+			if ok && string(*name) == key {
+				return this.traceToDirectObject((*names)[i+1])
+			}
+		}
+	}
+
+	if kidsObj, hasKids := (*dict)["Kids"]; hasKids {
+		obj, err := this.traceToDirectObject(kidsObj)
+		if err != nil {
+			return nil, err
+		}
+		kids, ok := obj.(*PdfObjectArray)
+		if !ok {
+			return nil, errors.New("Invalid name tree Kids array")
+		}
+		for _, kid := range *kids {
+			val, err := this.lookupNameTree(kid, key, traversed)
+			if err != nil {
+				return nil, err
+			}
+			if val != nil {
+				return val, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// An entry in a name tree.
+type nameTreeEntry struct {
+	key   string
+	value PdfObject
+}
+
+// Collect all the entries of a name tree (7.9.6) in the order of the tree.
+func (this *PdfReader) getNameTreeEntries(node PdfObject) ([]nameTreeEntry, error) {
+	entries := []nameTreeEntry{}
+	err := this.collectNameTreeEntries(node, &entries, map[*PdfObjectDictionary]bool{})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (this *PdfReader) collectNameTreeEntries(node PdfObject, entries *[]nameTreeEntry, traversed map[*PdfObjectDictionary]bool) error {
+	obj, err := this.traceToDirectObject(node)
+	if err != nil {
+		return err
+	}
+	dict, ok := obj.(*PdfObjectDictionary)
+	if !ok {
+		log.Error("Invalid name tree node (%T)", obj)
+		return errors.New("Invalid name tree node")
+	}
+	if _, alreadyTraversed := traversed[dict]; alreadyTraversed {
+		log.Error("Circular name tree reference")
+		return errors.New("Circular name tree reference")
+	}
+	traversed[dict] = true
+
+	if namesObj, hasNames := (*dict)["Names"]; hasNames {
+		obj, err := this.traceToDirectObject(namesObj)
+		if err != nil {
+			return err
+		}
+		names, ok := obj.(*PdfObjectArray)
+		if !ok {
+			return errors.New("Invalid name tree Names array")
+		}
+		for i := 0; i+1 < len(*names); i += 2 {
+			name, ok := asString((*names)[i])
+			if !ok {
+				return errors.New("Invalid name tree key")
+			}
+			*entries = append(*entries, nameTreeEntry{string(*name), (*names)[i+1]})
+		}
+	}
+
+	if kidsObj, hasKids := (*dict)["Kids"]; hasKids {
+		obj, err := this.traceToDirectObject(kidsObj)
+		if err != nil {
+			return err
+		}
+		kids, ok := obj.(*PdfObjectArray)
+		if !ok {
+			return errors.New("Invalid name tree Kids array")
+		}
+		for _, kid := range *kids {
+			err := this.collectNameTreeEntries(kid, entries, traversed)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Resolve a named destination to the explicit destination array, such as
+// [page /Fit].  Looks in the Dests name tree in the catalog Names
+// dictionary and in the Dests dictionary of the catalog (PDF 1.1).
+func (this *PdfReader) ResolveNamedDestination(name string) (PdfObject, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+
+	var dest PdfObject
+
+	namesObj, err := this.traceToDirectObject((*this.catalog)["Names"])
+	if err != nil {
+		return nil, err
+	}
+	if names, ok := namesObj.(*PdfObjectDictionary); ok {
+		if destsTree, hasDests := (*names)["Dests"]; hasDests {
+			dest, err = this.lookupNameTree(destsTree, name, map[*PdfObjectDictionary]bool{})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if dest == nil {
+		destsObj, err := this.traceToDirectObject((*this.catalog)["Dests"])
+		if err != nil {
+			return nil, err
+		}
+		if dests, ok := destsObj.(*PdfObjectDictionary); ok {
+			if destObj, has := (*dests)[PdfObjectName(name)]; has {
+				dest, err = this.traceToDirectObject(destObj)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if dest == nil {
+		log.Debug("Named destination %s not found", name)
+		return nil, fmt.Errorf("Named destination not found (%s)", name)
+	}
+
+	// The value can also be a dictionary with the destination in D.
+	if dict, isDict := dest.(*PdfObjectDictionary); isDict {
+		dest, err = this.traceToDirectObject((*dict)["D"])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	arr, ok := dest.(*PdfObjectArray)
+	if !ok {
+		log.Error("Invalid named destination %s (%T)", name, dest)
+		return nil, fmt.Errorf("Invalid named destination (%s)", name)
+	}
+
+	// Resolve the page reference in a copy, the cached array is shared.
+	if len(*arr) > 0 {
+		if ref, isRef := (*arr)[0].(*PdfObjectReference); isRef {
+			page, _, err := this.resolveReference(ref)
+			if err != nil {
+				return nil, err
+			}
+			resolved := append(PdfObjectArray{page}, (*arr)[1:]...)
+			return &resolved, nil
+		}
+	}
+
+	return arr, nil
+}
+
+// Resolve a destination, either an explicit destination array or a named
+// destination (name or string).  Returns an empty array for unresolvable
+// named destinations.
+func (this *PdfReader) resolveDestination(destObj PdfObject) (*PdfObjectArray, error) {
+	obj, err := this.traceToDirectObject(destObj)
+	if err != nil {
+		return nil, err
+	}
+
+	var name string
+	switch t := obj.(type) {
+	case *PdfObjectArray:
+		return t, nil
+	case *PdfObjectName:
+		name = string(*t)
+	case *PdfObjectString:
+		name = string(*t)
+	case *PdfObjectHexString:
+		name = string(*t)
+	default:
+		return &PdfObjectArray{}, nil
+	}
+
+	dest, err := this.ResolveNamedDestination(name)
+	if err != nil {
+		log.Debug("Unable to resolve named destination (%s)", err)
+		return &PdfObjectArray{}, nil
+	}
+	return dest.(*PdfObjectArray), nil
+}
@@ -0,0 +1,994 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Load the minimal test file.
+func loadMinimalReader(t *testing.T) *PdfReader {
+	data, err := ioutil.ReadFile(file1)
+	if err != nil {
+		t.Fatalf("Unable to open minimal test file (%s)", err)
+	}
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unable to read test file (%s)", err)
+	}
+	return reader
+}
+
+func TestGetPageBounds(t *testing.T) {
+	reader := loadMinimalReader(t)
+
+	_, err := reader.GetPage(1)
+	if err != nil {
+		t.Errorf("Page 1 should be valid (%s)", err)
+	}
+
+	for _, pageNumber := range []int{0, -1, 2} {
+		page, err := reader.GetPage(pageNumber)
+		if err == nil {
+			t.Errorf("Page %d should be invalid (got %v)", pageNumber, page)
+		}
+	}
+}
+
+// Build a PDF file from a list of object contents, numbered from 1 and up.
+// The catalog is expected to be object 1.
+func makePdfFile(objects []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+
+	offsets := []int{}
+	for i, obj := range objects {
+		offsets = append(offsets, buf.Len())
+		buf.WriteString(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", i+1, obj))
+	}
+
+	xrefOffset := buf.Len()
+	buf.WriteString(fmt.Sprintf("xref\n0 %d\n", len(objects)+1))
+	buf.WriteString("0000000000 65535 f \n")
+	for _, offset := range offsets {
+		buf.WriteString(fmt.Sprintf("%.10d 00000 n \n", offset))
+	}
+	buf.WriteString(fmt.Sprintf("trailer\n<< /Root 1 0 R /Size %d >>\n", len(objects)+1))
+	buf.WriteString(fmt.Sprintf("startxref\n%d\n%%%%EOF\n", xrefOffset))
+
+	return buf.Bytes()
+}
+
+func TestCircularPagesReference(t *testing.T) {
+	// Pages node listing itself as a kid.
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 2 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+	})
+
+	_, err := NewPdfReader(bytes.NewReader(data))
+	if err == nil {
+		t.Fatalf("Circular Pages reference should fail")
+	}
+	if err.Error() != "Circular Pages reference" {
+		t.Errorf("Unexpected error (%s)", err)
+	}
+
+	// Indirect cycle through a child Pages node.
+	data = makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Pages /Parent 2 0 R /Kids [2 0 R] /Count 1 >>",
+	})
+
+	_, err = NewPdfReader(bytes.NewReader(data))
+	if err == nil {
+		t.Fatalf("Circular Pages reference should fail")
+	}
+}
+
+// Rotate inherited from the Pages node.
+func TestGetPageRotationInherited(t *testing.T) {
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /Rotate 90 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] /Rotate -180 >>",
+	})
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	for pageNumber, expected := range map[int]int{1: 90, 2: 180} {
+		rotation, err := reader.GetPageRotation(pageNumber)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if rotation != expected {
+			t.Errorf("Page %d rotation %d != %d", pageNumber, rotation, expected)
+		}
+	}
+}
+
+func TestGetPageContents(t *testing.T) {
+	reader := loadMinimalReader(t)
+	contents, err := reader.GetPageContents(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if !bytes.Contains(contents, []byte("(Hello World) Tj")) {
+		t.Errorf("Unexpected page contents (%q)", contents)
+	}
+
+	// Contents as an array of streams, one of which is compressed.
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte("(World) Tj ET"))
+	zw.Close()
+
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 6 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] /Contents [4 0 R 5 0 R] >>",
+		"<< /Length 13 >>\nstream\nBT (Hello) Tj\nendstream",
+		fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.Bytes()),
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] /Contents 7 0 R >>",
+		"<< /Length 5 /Filter /JBIG2Decode >>\nstream\nxxxxx\nendstream",
+	})
+
+	reader, err = NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	contents, err = reader.GetPageContents(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if string(contents) != "BT (Hello) Tj\n(World) Tj ET" {
+		t.Errorf("Unexpected page contents (%q)", contents)
+	}
+
+	_, err = reader.GetPageContents(2)
+	if err == nil {
+		t.Errorf("Unsupported filter should fail")
+	}
+}
+
+func TestGetPageResources(t *testing.T) {
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /Resources 5 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] /Resources << /ProcSet [/PDF] >> >>",
+		"<< /Font << /F1 << /Type /Font /Subtype /Type1 /BaseFont /Courier >> >> >>",
+	})
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	// Inherited via an indirect object in the Pages node.
+	resources, err := reader.GetPageResources(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if _, has := (*resources)["Font"]; !has {
+		t.Errorf("Inherited resources missing Font (%s)", resources)
+	}
+
+	// Own resources take precedence.
+	resources, err = reader.GetPageResources(2)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if _, has := (*resources)["ProcSet"]; !has {
+		t.Errorf("Page resources missing ProcSet (%s)", resources)
+	}
+
+	data = makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+	})
+	reader, err = NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	_, err = reader.GetPageResources(1)
+	if err == nil {
+		t.Errorf("Missing resources should fail")
+	}
+}
+
+// Document with named destinations in a name tree and the old style Dests
+// dictionary, and outlines pointing to them.
+func makeNamedDestsPdf() []byte {
+	return makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R /Names << /Dests 5 0 R >> /Dests << /OldDest [4 0 R /Fit] >> /Outlines 8 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+		"<< /Kids [6 0 R 7 0 R] >>",
+		"<< /Limits [(a) (m)] /Names [(chapter1) [3 0 R /Fit] (intro) << /D [3 0 R /XYZ 0 0 0] >>] >>",
+		"<< /Limits [(n) (z)] /Names [(summary) [4 0 R /Fit]] >>",
+		"<< /Type /Outlines /First 9 0 R /Last 10 0 R /Count 2 >>",
+		"<< /Title (Summary) /Parent 8 0 R /Next 10 0 R /Dest (summary) >>",
+		"<< /Title (Old) /Parent 8 0 R /Prev 9 0 R /Dest /OldDest >>",
+	})
+}
+
+func TestResolveNamedDestination(t *testing.T) {
+	reader, err := NewPdfReader(bytes.NewReader(makeNamedDestsPdf()))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	page1, _ := reader.GetPage(1)
+	page2, _ := reader.GetPage(2)
+
+	testcases := []struct {
+		Name string
+		Page PdfObject
+		Type string
+	}{
+		{"chapter1", page1, "Fit"},
+		{"intro", page1, "XYZ"},
+		{"summary", page2, "Fit"},
+		{"OldDest", page2, "Fit"},
+	}
+	for _, testcase := range testcases {
+		obj, err := reader.ResolveNamedDestination(testcase.Name)
+		if err != nil {
+			t.Errorf("Error resolving %s: %v", testcase.Name, err)
+			continue
+		}
+		dest, ok := obj.(*PdfObjectArray)
+		if !ok || len(*dest) < 2 {
+			t.Errorf("Invalid destination for %s (%v)", testcase.Name, obj)
+			continue
+		}
+		if (*dest)[0] != testcase.Page {
+			t.Errorf("Destination %s pointing to wrong page", testcase.Name)
+		}
+		if destType, ok := (*dest)[1].(*PdfObjectName); !ok || string(*destType) != testcase.Type {
+			t.Errorf("Destination %s wrong type (%v)", testcase.Name, (*dest)[1])
+		}
+	}
+
+	// The cached destination arrays are not modified.
+	leafObj, err := reader.Resolve(&PdfObjectReference{ObjectNumber: 6})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	leafNames, _ := leafObj.(*PdfObjectDictionary).GetArray("Names")
+	if cached, ok := (*leafNames)[1].(*PdfObjectArray); !ok {
+		t.Errorf("Unexpected name tree value %v", (*leafNames)[1])
+	} else if _, isRef := (*cached)[0].(*PdfObjectReference); !isRef {
+		t.Errorf("Cached destination modified (%v)", cached)
+	}
+
+	for _, name := range []string{"missing", "b"} {
+		_, err = reader.ResolveNamedDestination(name)
+		if err == nil {
+			t.Errorf("Missing destination %s should fail", name)
+		}
+	}
+
+	// Outlines with named destinations.
+	outlines, err := reader.GetOutlinesForPage(page2)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(outlines) != 2 {
+		t.Errorf("Expected 2 outlines for page 2 (%d)", len(outlines))
+	}
+	outlines, err = reader.GetOutlinesForPage(page1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(outlines) != 0 {
+		t.Errorf("Expected no outlines for page 1 (%d)", len(outlines))
+	}
+}
+
+// Make a document with a two level page tree, the pages rotated by
+// 90 degrees times the page index.
+func makePageTreePdf() []byte {
+	return makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 5 /MediaBox [0 0 100 100] >>",
+		"<< /Type /Pages /Parent 2 0 R /Kids [6 0 R 7 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /Rotate 180 >>",
+		"<< /Type /Pages /Parent 2 0 R /Kids [8 0 R 9 0 R] /Count 2 /Rotate 270 >>",
+		"<< /Type /Page /Parent 3 0 R >>",
+		"<< /Type /Page /Parent 3 0 R /Rotate 90 >>",
+		"<< /Type /Page /Parent 5 0 R /Rotate 0 >>",
+		"<< /Type /Page /Parent 5 0 R >>",
+	})
+}
+
+func TestLazyPages(t *testing.T) {
+	reader, err := NewPdfReaderWithOptions(bytes.NewReader(makePageTreePdf()), ReaderOptions{LazyPages: true})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	numPages, err := reader.GetNumPages()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if numPages != 5 {
+		t.Fatalf("Expected 5 pages, got %d", numPages)
+	}
+
+	page, err := reader.GetPage(5)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if page.(*PdfIndirectObject).ObjectNumber != 9 {
+		t.Errorf("Wrong page loaded (%s)", page)
+	}
+	for i := 0; i < 4; i++ {
+		if reader.pageList[i] != nil {
+			t.Errorf("Page %d should not be loaded", i+1)
+		}
+	}
+
+	// Inherited attributes resolved for the loaded pages.
+	expected := []int{0, 90, 180, 0, 270}
+	for i, exp := range expected {
+		rotation, err := reader.GetPageRotation(i + 1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if rotation != exp {
+			t.Errorf("Page %d rotation %d (expected %d)", i+1, rotation, exp)
+		}
+	}
+	if _, err := reader.GetPage(6); err == nil {
+		t.Errorf("Page out of range should fail")
+	}
+
+	// Same pages when loading eagerly.
+	eager, err := NewPdfReader(bytes.NewReader(makePageTreePdf()))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	for i := range expected {
+		lazyPage, _ := reader.GetPage(i + 1)
+		eagerPage, _ := eager.GetPage(i + 1)
+		if lazyPage.(*PdfIndirectObject).ObjectNumber != eagerPage.(*PdfIndirectObject).ObjectNumber {
+			t.Errorf("Page %d differs from eager loading", i+1)
+		}
+	}
+}
+
+func TestGetPageCount(t *testing.T) {
+	w := NewPdfWriter()
+	for i := 0; i < 3; i++ {
+		err := w.AddPage(loadMinimalPage(t))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+	err := w.Encrypt([]byte("user"), []byte("owner"), nil)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if _, err := reader.GetNumPages(); err == nil {
+		t.Errorf("GetNumPages should require decryption")
+	}
+	count, err := reader.GetPageCount()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 pages, got %d", count)
+	}
+
+	// Reading the count should not interfere with the decryption.
+	success, err := reader.Decrypt([]byte("user"))
+	if err != nil || !success {
+		t.Fatalf("Unable to decrypt (%v)", err)
+	}
+	text, err := reader.ExtractPageText(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if text != "Hello World" {
+		t.Errorf("Unexpected text %q", text)
+	}
+
+	// The traversed pages are preferred over an incorrect Count.
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 5 /MediaBox [0 0 100 100] >>",
+		"<< /Type /Page /Parent 2 0 R >>",
+		"<< /Type /Page /Parent 2 0 R >>",
+	})
+	reader, err = NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	count, err = reader.GetPageCount()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 pages, got %d", count)
+	}
+}
+
+func TestGetTrailer(t *testing.T) {
+	w := NewPdfWriter()
+	err := w.SetDocumentID([]byte("id0"), []byte("id1"))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	err = w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	err = w.Encrypt([]byte("user"), nil, nil)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if _, err := reader.GetTrailer(); err == nil {
+		t.Errorf("GetTrailer should require decryption")
+	}
+	success, err := reader.Decrypt([]byte("user"))
+	if err != nil || !success {
+		t.Fatalf("Unable to decrypt (%v)", err)
+	}
+
+	trailer, err := reader.GetTrailer()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if _, isRef := (*trailer)["Root"].(*PdfObjectReference); !isRef {
+		t.Errorf("Root should be a reference (%T)", (*trailer)["Root"])
+	}
+	if _, isRef := (*trailer)["Encrypt"].(*PdfObjectReference); !isRef {
+		t.Errorf("Encrypt should be a reference (%T)", (*trailer)["Encrypt"])
+	}
+	ids, ok := (*trailer)["ID"].(*PdfObjectArray)
+	if !ok || len(*ids) != 2 {
+		t.Fatalf("Invalid ID (%v)", (*trailer)["ID"])
+	}
+	if id0, ok := (*ids)[0].(*PdfObjectString); !ok || string(*id0) != "id0" {
+		t.Errorf("Unexpected ID %s", (*ids)[0])
+	}
+
+	// Modifying the copy does not affect the reader.
+	delete(*trailer, "Root")
+	trailer, _ = reader.GetTrailer()
+	if _, hasRoot := (*trailer)["Root"]; !hasRoot {
+		t.Errorf("Trailer should be a copy")
+	}
+}
+
+func TestGetCatalogEntry(t *testing.T) {
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R /Lang (en-US) /ViewerPreferences 4 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+		"<< /HideToolbar true >>",
+	})
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	catalog := reader.GetCatalog()
+	if catalog == nil {
+		t.Fatalf("Missing catalog")
+	}
+	if typ, ok := (*catalog)["Type"].(*PdfObjectName); !ok || *typ != "Catalog" {
+		t.Errorf("Invalid catalog %s", catalog)
+	}
+
+	obj, err := reader.GetCatalogEntry("Lang")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if lang, ok := obj.(*PdfObjectString); !ok || string(*lang) != "en-US" {
+		t.Errorf("Unexpected Lang %v", obj)
+	}
+
+	obj, err = reader.GetCatalogEntry("ViewerPreferences")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	prefs, ok := obj.(*PdfObjectDictionary)
+	if !ok {
+		t.Fatalf("ViewerPreferences not resolved (%T)", obj)
+	}
+	if hide, ok := (*prefs)["HideToolbar"].(*PdfObjectBool); !ok || !bool(*hide) {
+		t.Errorf("Unexpected ViewerPreferences %s", prefs)
+	}
+
+	obj, err = reader.GetCatalogEntry("OpenAction")
+	if err != nil || obj != nil {
+		t.Errorf("Missing entry should give nil (%v, %v)", obj, err)
+	}
+}
+
+func TestReaderContextCanceled(t *testing.T) {
+	data, err := ioutil.ReadFile(file1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = NewPdfReaderContext(ctx, bytes.NewReader(data))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled (%v)", err)
+	}
+
+	reader, err := NewPdfReaderContext(context.Background(), bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	_, err = reader.GetPageContext(ctx, 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled (%v)", err)
+	}
+
+	// Not affected by the aborted operation.
+	page, err := reader.GetPageContext(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	pDict := page.(*PdfIndirectObject).PdfObject.(*PdfObjectDictionary)
+	for name, obj := range *pDict {
+		if _, isRef := obj.(*PdfObjectReference); isRef && name != "Parent" {
+			t.Errorf("Unresolved page %s reference", name)
+		}
+	}
+}
+
+func TestParserLimits(t *testing.T) {
+	// A page with a deeply nested array.
+	depth := DefaultMaxDepth + 10
+	nested := strings.Repeat("[", depth) + strings.Repeat("]", depth)
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] /Nested " + nested + " >>",
+	})
+	_, err := NewPdfReader(bytes.NewReader(data))
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected ErrLimitExceeded for nested array (%v)", err)
+	}
+
+	// A chain of references resolved when traversing the page.
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] /Chain 4 0 R >>",
+	}
+	for i := 5; i < 35; i++ {
+		objects = append(objects, fmt.Sprintf("[%d 0 R]", i))
+	}
+	objects = append(objects, "null")
+	data = makePdfFile(objects)
+
+	reader, err := NewPdfReaderWithOptions(bytes.NewReader(data), ReaderOptions{ParserOptions: ParserOptions{MaxDepth: 40}})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	_, err = reader.GetPage(1)
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected ErrLimitExceeded for reference chain (%v)", err)
+	}
+
+	reader, err = NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if _, err = reader.GetPage(1); err != nil {
+		t.Errorf("Error with default limits: %v", err)
+	}
+
+	_, err = NewPdfReaderWithOptions(bytes.NewReader(makePageTreePdf()), ReaderOptions{ParserOptions: ParserOptions{MaxObjects: 5}})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected ErrLimitExceeded for object count (%v)", err)
+	}
+}
+
+func TestObjectCacheStats(t *testing.T) {
+	reader := loadMinimalReader(t)
+	entries, approxBytes := reader.CacheStats()
+	if entries == 0 || approxBytes == 0 {
+		t.Fatalf("Expected cached objects (%d, %d)", entries, approxBytes)
+	}
+	page, err := reader.GetPage(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	reader.ClearObjectCache()
+	entries, approxBytes = reader.CacheStats()
+	if entries != 0 || approxBytes != 0 {
+		t.Errorf("Cache not cleared (%d, %d)", entries, approxBytes)
+	}
+
+	// Previously returned objects are still usable and objects are
+	// loaded again as needed.
+	if _, ok := page.(*PdfIndirectObject).PdfObject.(*PdfObjectDictionary); !ok {
+		t.Errorf("Invalid page after clearing the cache")
+	}
+	text, err := reader.ExtractPageText(1)
+	if err != nil || text != "Hello World" {
+		t.Errorf("Unexpected text %q (%v)", text, err)
+	}
+	obj, _, err := reader.resolveReference(&PdfObjectReference{ObjectNumber: 3})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if obj == page {
+		t.Errorf("Expected the page object to be parsed again")
+	}
+	entries, _ = reader.CacheStats()
+	if entries == 0 {
+		t.Errorf("Objects not cached again")
+	}
+}
+
+// The object limit applies to the objects loaded since the cache was
+// last cleared, a long-lived reader can load the objects again.
+func TestClearObjectCacheLimits(t *testing.T) {
+	data, err := writePdfToBytes(makeLinearizeTestWriter(t, 4))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	probe, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	numbers, err := probe.ObjectNumbers()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	options := ReaderOptions{ParserOptions: ParserOptions{MaxObjects: 2 * len(numbers)}}
+	reader, err := NewPdfReaderWithOptions(bytes.NewReader(data), options)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	for round := 0; round < 5; round++ {
+		for _, number := range numbers {
+			if reader.IsFreeObject(number) {
+				continue
+			}
+			if _, err := reader.Resolve(&PdfObjectReference{ObjectNumber: int64(number)}); err != nil {
+				t.Fatalf("Round %d, object %d: %v", round, number, err)
+			}
+		}
+		reader.ClearObjectCache()
+	}
+}
+
+func TestGetObject(t *testing.T) {
+	reader := loadMinimalReader(t)
+
+	obj, err := reader.GetObject(1, 0)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	ind, ok := obj.(*PdfIndirectObject)
+	if !ok || ind.ObjectNumber != 1 {
+		t.Fatalf("Invalid object %v", obj)
+	}
+	again, err := reader.GetObject(1, 0)
+	if err != nil || again != obj {
+		t.Errorf("Object not cached (%v)", err)
+	}
+
+	if _, err := reader.GetObject(1000, 0); err == nil {
+		t.Errorf("Expected an error for an object outside the xref table")
+	}
+	if _, err := reader.GetObject(1, 3); err == nil {
+		t.Errorf("Expected an error for a generation mismatch")
+	}
+}
+
+func TestGetPdfVersion(t *testing.T) {
+	testcases := []struct {
+		header         string
+		catalogVersion string
+		major, minor   int
+	}{
+		{"%PDF-1.4", "", 1, 4},
+		{"%PDF-1.7", "", 1, 7},
+		{"%PDF-2.0", "", 2, 0},
+		{"%PDF-1.3", "/Version /1.6", 1, 6},
+		// The header wins when later than the catalog version.
+		{"%PDF-1.7", "/Version /1.5", 1, 7},
+		{"%PDF-1.4", "/Version /invalid", 1, 4},
+	}
+
+	for _, tcase := range testcases {
+		data := makePdfFile([]string{
+			"<< /Type /Catalog /Pages 2 0 R " + tcase.catalogVersion + " >>",
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+		})
+		data = bytes.Replace(data, []byte("%PDF-1.4"), []byte(tcase.header), 1)
+
+		reader, err := NewPdfReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		major, minor, err := reader.GetPdfVersion()
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if major != tcase.major || minor != tcase.minor {
+			t.Errorf("%s %s: version %d.%d (expected %d.%d)", tcase.header, tcase.catalogVersion,
+				major, minor, tcase.major, tcase.minor)
+		}
+	}
+}
+
+func TestReaderPageProgress(t *testing.T) {
+	progress := [][2]int{}
+	options := ReaderOptions{PageProgress: func(loaded, total int) {
+		progress = append(progress, [2]int{loaded, total})
+	}}
+	_, err := NewPdfReaderWithOptions(bytes.NewReader(makePageTreePdf()), options)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(progress) != 5 || progress[0] != [2]int{1, 5} || progress[4] != [2]int{5, 5} {
+		t.Errorf("Unexpected progress %v", progress)
+	}
+}
+
+func TestObjectNumbers(t *testing.T) {
+	base := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+	})
+	prevOffset := bytes.LastIndex(base, []byte("\nxref")) + 1
+
+	// Incremental update with a free entry for object 4 and a new object 5.
+	var buf bytes.Buffer
+	buf.Write(base)
+	objOffset := buf.Len()
+	buf.WriteString("5 0 obj\n(Added)\nendobj\n")
+	xrefOffset := buf.Len()
+	buf.WriteString("xref\n0 1\n0000000000 65535 f \n4 2\n0000000000 00001 f \n")
+	buf.WriteString(fmt.Sprintf("%.10d 00000 n \n", objOffset))
+	buf.WriteString(fmt.Sprintf("trailer\n<< /Root 1 0 R /Size 6 /Prev %d >>\n", prevOffset))
+	buf.WriteString(fmt.Sprintf("startxref\n%d\n%%%%EOF\n", xrefOffset))
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	numbers, err := reader.ObjectNumbers()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if fmt.Sprint(numbers) != "[0 1 2 3 4 5]" {
+		t.Errorf("Unexpected object numbers %v", numbers)
+	}
+	free := []int{}
+	for _, number := range numbers {
+		if reader.IsFreeObject(number) {
+			free = append(free, number)
+		}
+	}
+	if fmt.Sprint(free) != "[0 4]" {
+		t.Errorf("Unexpected free objects %v", free)
+	}
+	if reader.IsFreeObject(6) {
+		t.Errorf("Object not in the xref table reported free")
+	}
+}
+
+// Loading pages and reading their contents from several goroutines, run
+// with -race.  With a snapshot the shared resources of the returned pages
+// are read as well.
+func TestConcurrentAccess(t *testing.T) {
+	const numPages = 16
+	data, err := writePdfToBytes(makeLinearizeTestWriter(t, numPages))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	for _, options := range []ReaderOptions{{}, {LazyPages: true}, {Snapshot: true}, {LazyPages: true, Snapshot: true}} {
+		reader, err := NewPdfReaderWithOptions(bytes.NewReader(data), options)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		objects, err := reader.ObjectNumbers()
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		errs := make(chan error, 8*4*numPages)
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				<-start
+				// Each goroutine starts at a different page.
+				for n := 0; n < numPages; n++ {
+					i := (n+g*numPages/4)%numPages + 1
+					page, err := reader.GetPage(i)
+					if err != nil {
+						errs <- err
+						continue
+					}
+					if contents, err := reader.GetPageContents(i); err != nil || len(contents) == 0 {
+						errs <- fmt.Errorf("Page %d: contents %q (%v)", i, contents, err)
+					}
+					if rotation, err := reader.GetPageRotation(i); err != nil || rotation != 0 {
+						errs <- fmt.Errorf("Page %d: rotation %d (%v)", i, rotation, err)
+					}
+					if _, err := reader.GetPageResources(i); err != nil {
+						errs <- fmt.Errorf("Page %d: resources (%v)", i, err)
+					}
+					if text, err := reader.ExtractPageText(i); err != nil || !strings.Contains(text, fmt.Sprintf("Page %d", i)) {
+						errs <- fmt.Errorf("Page %d: text %q (%v)", i, text, err)
+					}
+					if _, err := reader.GetPageImages(i); err != nil {
+						errs <- fmt.Errorf("Page %d: images (%v)", i, err)
+					}
+					for _, number := range objects[(i-1)*len(objects)/numPages : i*len(objects)/numPages] {
+						if _, err := reader.Resolve(&PdfObjectReference{ObjectNumber: int64(number)}); err != nil {
+							errs <- fmt.Errorf("Object %d: %v", number, err)
+						}
+						// Interleave the goroutines even on a single CPU.
+						runtime.Gosched()
+					}
+					if !options.Snapshot {
+						continue
+					}
+					dict := page.(*PdfIndirectObject).PdfObject.(*PdfObjectDictionary)
+					resources, _ := reader.traceToDirectObject((*dict)["Resources"])
+					fonts, _ := reader.traceToDirectObject((*resources.(*PdfObjectDictionary))["Font"])
+					for name, font := range *fonts.(*PdfObjectDictionary) {
+						if _, isRef := font.(*PdfObjectReference); isRef {
+							errs <- fmt.Errorf("Page %d: font %s not resolved", i, name)
+						}
+					}
+				}
+			}(g)
+		}
+		close(start)
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Errorf("Options %+v: %v", options, err)
+		}
+	}
+}
+
+func TestGetAllPages(t *testing.T) {
+	for _, options := range []ReaderOptions{{}, {LazyPages: true}} {
+		reader, err := NewPdfReaderWithOptions(bytes.NewReader(makePageTreePdf()), options)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		pages, err := reader.GetAllPages()
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		numPages, err := reader.GetNumPages()
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if len(pages) != numPages {
+			t.Fatalf("%d pages (expected %d)", len(pages), numPages)
+		}
+		for i, page := range pages {
+			expected, err := reader.GetPage(i + 1)
+			if err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+			if page != expected {
+				t.Errorf("Page %d differs from GetPage", i+1)
+			}
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R /Lang 4 0 R /Metadata 5 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+		"(en-US)",
+		"<< /Type /Metadata /Subtype /XML /Length 5 >>\nstream\n<x/>\n\nendstream",
+	})
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	// References.
+	lang, err := reader.Resolve(&PdfObjectReference{ObjectNumber: 4})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if str, ok := lang.(*PdfObjectString); !ok || string(*str) != "en-US" {
+		t.Errorf("Unexpected value %v", lang)
+	}
+	again, err := reader.Resolve(&PdfObjectReference{ObjectNumber: 4})
+	if err != nil || again != lang {
+		t.Errorf("Resolved object not cached (%v)", err)
+	}
+	metadata, err := reader.Resolve(&PdfObjectReference{ObjectNumber: 5})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if _, ok := metadata.(*PdfObjectStream); !ok {
+		t.Errorf("Stream expected (%T)", metadata)
+	}
+	// A reference to a missing object is to the null object (7.3.10).
+	missing, err := reader.Resolve(&PdfObjectReference{ObjectNumber: 9})
+	if _, isNull := missing.(*PdfObjectNull); err != nil || !isNull {
+		t.Errorf("Missing object resolved to %v (%v)", missing, err)
+	}
+
+	// Indirect objects are unwrapped.
+	page, err := reader.GetPage(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	pageDict, err := reader.Resolve(page)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if pageDict != page.(*PdfIndirectObject).PdfObject {
+		t.Errorf("Indirect object not unwrapped (%T)", pageDict)
+	}
+
+	// Direct objects as is.
+	for _, obj := range []PdfObject{makeInteger(1), makeName("Name"), &PdfObjectArray{}, metadata, nil} {
+		resolved, err := reader.Resolve(obj)
+		if err != nil || resolved != obj {
+			t.Errorf("Direct object %v resolved to %v (%v)", obj, resolved, err)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Digital signature fields (12.8).
+
+package pdf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A range of bytes of the file, from an offset.
+type ByteRange struct {
+	Offset int64
+	Length int64
+}
+
+// A signature form field and its signature, if signed.  The signature is
+// exposed for verification by the caller, it is not verified.
+type SignatureField struct {
+	// Fully qualified field name.
+	Name string
+	// The field dictionary, merged with the widget annotation if the
+	// field has a single widget.
+	Object *PdfIndirectObject
+	// The signature dictionary (V), nil if the field is not signed.
+	Signature *PdfObjectDictionary
+	// Signature handler (Filter), e.g. Adobe.PPKLite, and the encoding of
+	// the signature value (SubFilter), e.g. adbe.pkcs7.detached.
+	Filter    PdfObjectName
+	SubFilter PdfObjectName
+	// The signature value (Contents), e.g. a DER-encoded PKCS#7 object.
+	Contents []byte
+	// The byte ranges of the file covered by the signature (ByteRange).
+	ByteRanges []ByteRange
+	// Whether the byte ranges extend to the end of the file.  Signatures
+	// of earlier revisions of an incrementally updated document do not.
+	CoversWholeDocument bool
+}
+
+// Get the signature fields (FT Sig) of the AcroForm in the order of the
+// field tree, signed or not.  A document signed several times, with
+// incremental updates, has a signed field for each signature.
+func (this *PdfReader) GetSignatureFields() ([]SignatureField, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+
+	fields, err := this.getFormFields()
+	if err != nil {
+		return nil, err
+	}
+	this.cacheMu.Lock()
+	fileSize, err := this.parser.fileSize()
+	this.cacheMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	sigFields := []SignatureField{}
+	for _, field := range fields {
+		if field.Type != "Sig" {
+			continue
+		}
+		sigField := SignatureField{Name: field.Name, Object: field.Object}
+		if field.Value != nil {
+			sig, ok := field.Value.(*PdfObjectDictionary)
+			if !ok {
+				log.Error("Signature %s not a dictionary (%T)", field.Name, field.Value)
+				return nil, errors.New("Signature not a dictionary")
+			}
+			err = this.loadSignature(&sigField, sig, fileSize)
+			if err != nil {
+				return nil, err
+			}
+		}
+		sigFields = append(sigFields, sigField)
+	}
+	return sigFields, nil
+}
+
+// Load the signature data of a field from a signature dictionary.
+func (this *PdfReader) loadSignature(field *SignatureField, sig *PdfObjectDictionary, fileSize int64) error {
+	field.Signature = sig
+	if filter, ok := (*sig)["Filter"].(*PdfObjectName); ok {
+		field.Filter = *filter
+	}
+	if subFilter, ok := (*sig)["SubFilter"].(*PdfObjectName); ok {
+		field.SubFilter = *subFilter
+	}
+
+	obj, err := this.traceToDirectObject((*sig)["Contents"])
+	if err != nil {
+		return err
+	}
+	if contents, ok := asString(obj); ok {
+		field.Contents = []byte(*contents)
+	}
+
+	obj, err = this.traceToDirectObject((*sig)["ByteRange"])
+	if err != nil {
+		return err
+	}
+	if obj == nil {
+		return nil
+	}
+	arr, ok := obj.(*PdfObjectArray)
+	if !ok || len(*arr)%2 != 0 {
+		return errors.New("Invalid signature ByteRange")
+	}
+	for i := 0; i < len(*arr); i += 2 {
+		offset, ok1 := (*arr)[i].(*PdfObjectInteger)
+		length, ok2 := (*arr)[i+1].(*PdfObjectInteger)
+		if !ok1 || !ok2 || *offset < 0 || *length < 0 || int64(*offset)+int64(*length) > fileSize {
+			log.Error("Invalid signature ByteRange %s", arr)
+			return errors.New("Invalid signature ByteRange")
+		}
+		field.ByteRanges = append(field.ByteRanges, ByteRange{int64(*offset), int64(*length)})
+	}
+	if n := len(field.ByteRanges); n > 0 {
+		last := field.ByteRanges[n-1]
+		field.CoversWholeDocument = last.Offset+last.Length == fileSize
+	}
+	return nil
+}
+
+// Add an empty signature field on a page (from 1), for signing the
+// document later, e.g. with an external tool.  The field is merged with
+// its widget annotation at the rectangle, which can be empty for an
+// invisible signature, and SigFlags of the AcroForm is set to 3
+// (SignaturesExist and AppendOnly).  The field name must be unique and
+// cannot contain periods.
+func (this *PdfWriter) AddSignatureField(pageNumber int, rect PdfRectangle, fieldName string) error {
+	if fieldName == "" || strings.Contains(fieldName, ".") {
+		return fmt.Errorf("Invalid field name %q", fieldName)
+	}
+	for _, field := range this.fields {
+		if ind, isIndirect := field.(*PdfIndirectObject); isIndirect {
+			if dict, ok := ind.PdfObject.(*PdfObjectDictionary); ok {
+				if t, ok := asString((*dict)["T"]); ok && decodeTextString(t) == fieldName {
+					return fmt.Errorf("Field %q already exists", fieldName)
+				}
+			}
+		}
+	}
+
+	page, err := this.getPage(pageNumber)
+	if err != nil {
+		return err
+	}
+	pDict, ok := page.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return errors.New("Page not a dictionary")
+	}
+	annots, err := getPageAnnotsForUpdate(pDict)
+	if err != nil {
+		return err
+	}
+
+	fieldDict := PdfObjectDictionary{}
+	fieldDict["FT"] = makeName("Sig")
+	fieldDict["T"] = encodeTextString(fieldName)
+	fieldDict["Type"] = makeName("Annot")
+	fieldDict["Subtype"] = makeName("Widget")
+	fieldDict["Rect"] = rect.normalized().toArray()
+	// Printed (12.5.3).
+	fieldDict["F"] = makeInteger(4)
+	fieldDict["P"] = page
+
+	field := PdfIndirectObject{}
+	field.PdfObject = &fieldDict
+	*annots = append(*annots, &field)
+	this.fields = append(this.fields, &field)
+	this.sigFlags = 3
+
+	return this.addObjects(&field)
+}
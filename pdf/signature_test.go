@@ -0,0 +1,130 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestGetSignatureFields(t *testing.T) {
+	// A signature covering the whole file, one covering an earlier
+	// revision and an empty signature field.
+	data := makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [4 0 R 5 0 R 6 0 R 7 0 R] /SigFlags 3 >> >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] /Annots [4 0 R 5 0 R 6 0 R] >>",
+		"<< /FT /Sig /T (Author) /Type /Annot /Subtype /Widget /Rect [0 0 0 0] /V 8 0 R >>",
+		"<< /FT /Sig /T (Approval) /Type /Annot /Subtype /Widget /Rect [0 0 0 0] /V 9 0 R >>",
+		"<< /FT /Sig /T (Empty) /Type /Annot /Subtype /Widget /Rect [0 0 0 0] >>",
+		"<< /FT /Tx /T (Text) /Type /Annot /Subtype /Widget /Rect [0 0 0 0] >>",
+		"<< /Type /Sig /Filter /Adobe.PPKLite /SubFilter /adbe.pkcs7.detached /ByteRange [0 100 120 200] /Contents <3082> >>",
+		"<< /Type /Sig /Filter /Adobe.PPKLite /SubFilter /ETSI.CAdES.detached /ByteRange [0 100 120 0000000000] /Contents <308203> >>",
+	})
+	data = bytes.Replace(data, []byte("0000000000]"), []byte(fmt.Sprintf("%010d]", len(data)-120)), 1)
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	fields, err := reader.GetSignatureFields()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(fields) != 3 {
+		t.Fatalf("Unexpected fields %v", fields)
+	}
+
+	author := fields[0]
+	if author.Name != "Author" || author.SubFilter != "adbe.pkcs7.detached" || author.Filter != "Adobe.PPKLite" {
+		t.Errorf("Unexpected field %+v", author)
+	}
+	if !bytes.Equal(author.Contents, []byte{0x30, 0x82}) {
+		t.Errorf("Unexpected Contents % x", author.Contents)
+	}
+	if len(author.ByteRanges) != 2 || author.ByteRanges[1] != (ByteRange{120, 200}) || author.CoversWholeDocument {
+		t.Errorf("Unexpected byte ranges %v (whole document %v)", author.ByteRanges, author.CoversWholeDocument)
+	}
+
+	approval := fields[1]
+	if approval.Name != "Approval" || approval.SubFilter != "ETSI.CAdES.detached" || !approval.CoversWholeDocument {
+		t.Errorf("Unexpected field %+v", approval)
+	}
+
+	if empty := fields[2]; empty.Name != "Empty" || empty.Signature != nil || empty.ByteRanges != nil {
+		t.Errorf("Unexpected field %+v", empty)
+	}
+
+	// Contents as a hex string object, e.g. written by the writer.
+	sig := PdfObjectDictionary{}
+	sig["Contents"] = makeHexString("\x30\x82")
+	field := SignatureField{}
+	if err := reader.loadSignature(&field, &sig, 0); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if !bytes.Equal(field.Contents, []byte{0x30, 0x82}) {
+		t.Errorf("Unexpected hex string Contents % x", field.Contents)
+	}
+
+	// Negative offset.
+	data = bytes.Replace(data, []byte("[0 100 120 200]"), []byte("[0 100 -12 200]"), 1)
+	reader, err = NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if _, err := reader.GetSignatureFields(); err == nil {
+		t.Errorf("Invalid ByteRange should fail")
+	}
+}
+
+func TestAddSignatureField(t *testing.T) {
+	w := makeLinearizeTestWriter(t, 2)
+	if err := w.AddSignatureField(3, PdfRectangle{}, "Signature1"); err == nil {
+		t.Errorf("Invalid page number should fail")
+	}
+	if err := w.AddSignatureField(1, PdfRectangle{}, "Sig.1"); err == nil {
+		t.Errorf("Field name with a period should fail")
+	}
+	if err := w.AddSignatureField(2, PdfRectangle{72, 72, 272, 122}, "Signature1"); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if err := w.AddSignatureField(1, PdfRectangle{}, "Signature1"); err == nil {
+		t.Errorf("Duplicate field name should fail")
+	}
+	if err := w.AddSignatureField(1, PdfRectangle{}, "Signature2"); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if !bytes.Contains(out, []byte("/SigFlags 3")) {
+		t.Errorf("SigFlags not set")
+	}
+
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	fields, err := reader.GetSignatureFields()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(fields) != 2 || fields[0].Name != "Signature1" || fields[1].Name != "Signature2" {
+		t.Fatalf("Unexpected fields %+v", fields)
+	}
+	if fields[0].Signature != nil {
+		t.Errorf("New field signed")
+	}
+	pageFields, err := reader.GetFormFieldsForPage(2)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(pageFields) != 1 || pageFields[0].Name != "Signature1" || pageFields[0].Type != "Sig" {
+		t.Errorf("Unexpected page fields %+v", pageFields)
+	}
+}
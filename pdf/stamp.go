@@ -0,0 +1,184 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Stamping pages with additional content, e.g. watermarks.
+
+package pdf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Make an unfiltered content stream.
+func makeContentStream(data []byte) *PdfObjectStream {
+	dict := PdfObjectDictionary{}
+	dict["Length"] = makeInteger(int64(len(data)))
+
+	stream := PdfObjectStream{}
+	stream.PdfObjectDictionary = &dict
+	stream.Stream = data
+	return &stream
+}
+
+// Stamp a page (from 1) with content drawn over the existing page
+// content, e.g. a watermark.  The content stream is appended to the page
+// Contents and the resources it uses are merged into the page Resources.
+// The existing content is wrapped in q/Q so its graphics state does not
+// affect the stamp.  Returns an error if a resource name is already used
+// by the page for a different object.
+func (this *PdfWriter) StampPage(pageNumber int, content []byte, resources *PdfObjectDictionary) error {
+	page, err := this.getPage(pageNumber)
+	if err != nil {
+		return err
+	}
+	pDict, ok := page.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return errors.New("Page not a dictionary")
+	}
+
+	if resources != nil {
+		pageResources, err := getPageResourcesForUpdate(pDict)
+		if err != nil {
+			return err
+		}
+		err = mergeResources(pageResources, resources)
+		if err != nil {
+			return err
+		}
+	}
+
+	contents, err := getPageContentsForUpdate(pDict)
+	if err != nil {
+		return err
+	}
+
+	stamp := []byte("q\n")
+	if len(*contents) > 0 {
+		*contents = append(PdfObjectArray{makeContentStream([]byte("q\n"))}, *contents...)
+		stamp = []byte("Q\nq\n")
+	}
+	stamp = append(stamp, content...)
+	stamp = append(stamp, []byte("\nQ\n")...)
+	*contents = append(*contents, makeContentStream(stamp))
+
+	return this.addObjects(pDict)
+}
+
+// Get the Contents of a page as an array of streams for adding content
+// streams.  Contents can be a single stream, an array of streams (direct
+// or indirect) or absent.
+func getPageContentsForUpdate(pDict *PdfObjectDictionary) (*PdfObjectArray, error) {
+	switch t := (*pDict)["Contents"].(type) {
+	case nil:
+		contents := &PdfObjectArray{}
+		(*pDict)["Contents"] = contents
+		return contents, nil
+	case *PdfObjectStream:
+		contents := &PdfObjectArray{t}
+		(*pDict)["Contents"] = contents
+		return contents, nil
+	case *PdfObjectArray:
+		return t, nil
+	case *PdfIndirectObject:
+		if contents, ok := t.PdfObject.(*PdfObjectArray); ok {
+			return contents, nil
+		}
+		return nil, errors.New("Invalid page Contents")
+	default:
+		log.Error("Invalid page Contents (%T)", t)
+		return nil, errors.New("Invalid page Contents")
+	}
+}
+
+// Get the Resources dictionary of a page for adding resources, creating
+// it if missing.
+func getPageResourcesForUpdate(pDict *PdfObjectDictionary) (*PdfObjectDictionary, error) {
+	switch t := (*pDict)["Resources"].(type) {
+	case nil:
+		resources := &PdfObjectDictionary{}
+		(*pDict)["Resources"] = resources
+		return resources, nil
+	case *PdfObjectDictionary:
+		return t, nil
+	case *PdfIndirectObject:
+		if resources, ok := t.PdfObject.(*PdfObjectDictionary); ok {
+			return resources, nil
+		}
+	}
+	return nil, errors.New("Invalid page Resources")
+}
+
+// Merge resources into a resource dictionary.  The resource names of each
+// category (Font, XObject, ...) are added, ProcSet names are combined.
+func mergeResources(dst, src *PdfObjectDictionary) error {
+	for _, category := range src.sortedKeys() {
+		srcObj := (*src)[category]
+		if ind, isInd := srcObj.(*PdfIndirectObject); isInd {
+			srcObj = ind.PdfObject
+		}
+
+		if category == "ProcSet" {
+			srcSet, ok := srcObj.(*PdfObjectArray)
+			if !ok {
+				return errors.New("Invalid ProcSet")
+			}
+			dstSet, ok := (*dst)["ProcSet"].(*PdfObjectArray)
+			if !ok {
+				dstSet = &PdfObjectArray{}
+				(*dst)["ProcSet"] = dstSet
+			}
+			for _, obj := range *srcSet {
+				name, ok := obj.(*PdfObjectName)
+				if !ok {
+					return errors.New("Invalid ProcSet")
+				}
+				if !containsName(dstSet, *name) {
+					*dstSet = append(*dstSet, name)
+				}
+			}
+			continue
+		}
+
+		srcDict, ok := srcObj.(*PdfObjectDictionary)
+		if !ok {
+			return fmt.Errorf("Invalid %s resources (%T)", category, srcObj)
+		}
+		var dstDict *PdfObjectDictionary
+		switch t := (*dst)[category].(type) {
+		case nil:
+			dstDict = &PdfObjectDictionary{}
+			(*dst)[category] = dstDict
+		case *PdfObjectDictionary:
+			dstDict = t
+		case *PdfIndirectObject:
+			dstDict, ok = t.PdfObject.(*PdfObjectDictionary)
+			if !ok {
+				return fmt.Errorf("Invalid %s resources", category)
+			}
+		default:
+			return fmt.Errorf("Invalid %s resources (%T)", category, t)
+		}
+
+		for _, name := range srcDict.sortedKeys() {
+			if existing, has := (*dstDict)[name]; has && existing != (*srcDict)[name] {
+				log.Error("Resource %s %s already used", category, name)
+				return fmt.Errorf("Resource name conflict (%s %s)", category, name)
+			}
+			(*dstDict)[name] = (*srcDict)[name]
+		}
+	}
+	return nil
+}
+
+// Check if an array contains a name.
+func containsName(arr *PdfObjectArray, name PdfObjectName) bool {
+	for _, obj := range *arr {
+		if n, ok := obj.(*PdfObjectName); ok && *n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStampPage(t *testing.T) {
+	original, err := loadMinimalReader(t).GetPageContents(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	w := NewPdfWriter()
+	err = w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	font := PdfObjectDictionary{}
+	font["Type"] = makeName("Font")
+	font["Subtype"] = makeName("Type1")
+	font["BaseFont"] = makeName("Helvetica")
+	fonts := PdfObjectDictionary{}
+	fonts["FStamp"] = &font
+	resources := PdfObjectDictionary{}
+	resources["Font"] = &fonts
+
+	stamp := []byte("BT /FStamp 36 Tf 20 60 Td (DRAFT) Tj ET")
+	if err := w.StampPage(2, stamp, &resources); err == nil {
+		t.Errorf("Invalid page number should fail")
+	}
+	err = w.StampPage(1, stamp, &resources)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	// A different font with the same name conflicts.
+	conflicting := PdfObjectDictionary{}
+	conflicting["Font"] = &PdfObjectDictionary{"F1": &font}
+	if err := w.StampPage(1, stamp, &conflicting); err == nil {
+		t.Errorf("Resource name conflict should fail")
+	}
+
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	contents, err := reader.GetPageContents(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if !bytes.Contains(contents, original) {
+		t.Errorf("Original content not preserved: %q", contents)
+	}
+	if !bytes.HasSuffix(bytes.TrimSpace(contents), append(stamp, []byte("\nQ")...)) {
+		t.Errorf("Stamp not appended: %q", contents)
+	}
+	if !strings.HasPrefix(string(contents), "q\n") {
+		t.Errorf("Original content not wrapped: %q", contents)
+	}
+
+	text, err := reader.ExtractPageText(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if text != "Hello World\nDRAFT" {
+		t.Errorf("Unexpected text %q", text)
+	}
+}
+
+func TestStampPageWithoutContents(t *testing.T) {
+	w := NewPdfWriter()
+	page := PdfIndirectObject{}
+	page.PdfObject = &PdfObjectDictionary{
+		"Type":     makeName("Page"),
+		"MediaBox": PdfRectangle{0, 0, 100, 100}.toArray(),
+	}
+	err := w.AddPage(&page)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	err = w.StampPage(1, []byte("0 0 10 10 re f"), nil)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	pDict := page.PdfObject.(*PdfObjectDictionary)
+	contents, ok := (*pDict)["Contents"].(*PdfObjectArray)
+	if !ok || len(*contents) != 1 {
+		t.Fatalf("Invalid contents %v", (*pDict)["Contents"])
+	}
+	stream := (*contents)[0].(*PdfObjectStream)
+	if string(stream.Stream) != "q\n0 0 10 10 re f\nQ\n" {
+		t.Errorf("Unexpected stamp %q", stream.Stream)
+	}
+}
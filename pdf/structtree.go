@@ -0,0 +1,96 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Tagged PDF (14.8) detection and passthrough of the structure tree.
+
+package pdf
+
+import (
+	"errors"
+)
+
+// Check if the document is tagged, i.e. the catalog MarkInfo has Marked
+// set and the document has a structure tree (StructTreeRoot).
+func (this *PdfReader) IsTagged() (bool, error) {
+	obj, err := this.GetCatalogEntry("MarkInfo")
+	if err != nil {
+		return false, err
+	}
+	markInfo, ok := obj.(*PdfObjectDictionary)
+	if !ok {
+		return false, nil
+	}
+	marked, err := this.traceToDirectObject((*markInfo)["Marked"])
+	if err != nil {
+		return false, err
+	}
+	if b, ok := marked.(*PdfObjectBool); !ok || !bool(*b) {
+		return false, nil
+	}
+
+	obj, err = this.GetCatalogEntry("StructTreeRoot")
+	if err != nil {
+		return false, err
+	}
+	_, hasRoot := obj.(*PdfObjectDictionary)
+	return hasRoot, nil
+}
+
+// Get the structure tree root of the document with all the references
+// in the tree resolved, for passing to the writer.  Returns nil if the
+// document has no structure tree.
+func (this *PdfReader) GetStructTreeRoot() (*PdfIndirectObject, error) {
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return nil, ErrEncrypted
+	}
+
+	obj, has := (*this.catalog)["StructTreeRoot"]
+	if !has {
+		return nil, nil
+	}
+	if ref, isRef := obj.(*PdfObjectReference); isRef {
+		var err error
+		obj, _, err = this.resolveReference(ref)
+		if err != nil {
+			return nil, err
+		}
+	}
+	root, ok := obj.(*PdfIndirectObject)
+	if !ok {
+		log.Error("StructTreeRoot not an indirect object (%T)", obj)
+		return nil, errors.New("StructTreeRoot not an indirect object")
+	}
+
+	// Parents of the structure elements (P) are within the tree, the
+	// pages (Pg) are the pages loaded by the reader.
+	nofollowList := map[PdfObjectName]bool{
+		"Parent": true,
+	}
+	err := this.traverseObjectData(root, nofollowList)
+	if err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// Carry over the structure tree of a tagged document to the writer, as
+// obtained from GetStructTreeRoot, and mark the document as tagged.  The
+// tree is written as is: the pages referenced by the structure elements
+// should be added to the writer.
+func (this *PdfWriter) SetStructTreeRoot(root *PdfIndirectObject) error {
+	if root == nil {
+		return errors.New("Structure tree root cannot be nil")
+	}
+	if _, ok := root.PdfObject.(*PdfObjectDictionary); !ok {
+		return errors.New("Structure tree root not a dictionary")
+	}
+
+	markInfo := PdfObjectDictionary{}
+	markInfo["Marked"] = makeBool(true)
+	(*this.catalog)["MarkInfo"] = &markInfo
+	(*this.catalog)["StructTreeRoot"] = root
+
+	return this.addObjects(root)
+}
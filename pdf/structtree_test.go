@@ -0,0 +1,87 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func makeTaggedPdf() []byte {
+	return makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R /MarkInfo << /Marked true >> /StructTreeRoot 4 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] /StructParents 0 >>",
+		"<< /Type /StructTreeRoot /K 5 0 R /ParentTree << /Nums [0 [5 0 R]] >> >>",
+		"<< /Type /StructElem /S /P /P 4 0 R /Pg 3 0 R /K 0 >>",
+	})
+}
+
+func TestIsTagged(t *testing.T) {
+	reader, err := NewPdfReader(bytes.NewReader(makeTaggedPdf()))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	tagged, err := reader.IsTagged()
+	if err != nil || !tagged {
+		t.Errorf("Expected tagged document (%v)", err)
+	}
+
+	tagged, err = loadMinimalReader(t).IsTagged()
+	if err != nil || tagged {
+		t.Errorf("Expected untagged document (%v)", err)
+	}
+}
+
+func TestStructTreePassthrough(t *testing.T) {
+	reader, err := NewPdfReader(bytes.NewReader(makeTaggedPdf()))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	page, err := reader.GetPage(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	root, err := reader.GetStructTreeRoot()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	w := NewPdfWriter()
+	err = w.AddPage(page)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	err = w.SetStructTreeRoot(root)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	reader, err = NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error reading output: %v", err)
+	}
+	tagged, err := reader.IsTagged()
+	if err != nil || !tagged {
+		t.Errorf("Output not tagged (%v)", err)
+	}
+	root, err = reader.GetStructTreeRoot()
+	if err != nil || root == nil {
+		t.Fatalf("Output missing structure tree (%v)", err)
+	}
+	elem, err := reader.traceToDirectObject((*root.PdfObject.(*PdfObjectDictionary))["K"])
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	page, _ = reader.GetPage(1)
+	if pg := (*elem.(*PdfObjectDictionary))["Pg"]; pg != page {
+		t.Errorf("Structure element not referring to the page (%v)", pg)
+	}
+}
@@ -0,0 +1,66 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Simple font encodings for text extraction (Annex D).
+
+package pdf
+
+// A single byte font encoding.  Maps the character codes that differ
+// from Latin-1 to the Unicode code point, other codes map directly.
+type simpleEncoding map[byte]rune
+
+// Decode character codes to a unicode string.
+func (this simpleEncoding) decode(data []byte) string {
+	runes := make([]rune, 0, len(data))
+	for _, b := range data {
+		if r, has := this[b]; has {
+			runes = append(runes, r)
+		} else {
+			runes = append(runes, rune(b))
+		}
+	}
+	return string(runes)
+}
+
+// WinAnsiEncoding (Windows code page 1252).
+var winAnsiEncoding = simpleEncoding{
+	0x80: 0x20AC, 0x82: 0x201A, 0x83: 0x0192, 0x84: 0x201E,
+	0x85: 0x2026, 0x86: 0x2020, 0x87: 0x2021, 0x88: 0x02C6,
+	0x89: 0x2030, 0x8A: 0x0160, 0x8B: 0x2039, 0x8C: 0x0152,
+	0x8E: 0x017D, 0x91: 0x2018, 0x92: 0x2019, 0x93: 0x201C,
+	0x94: 0x201D, 0x95: 0x2022, 0x96: 0x2013, 0x97: 0x2014,
+	0x98: 0x02DC, 0x99: 0x2122, 0x9A: 0x0161, 0x9B: 0x203A,
+	0x9C: 0x0153, 0x9E: 0x017E, 0x9F: 0x0178,
+}
+
+// StandardEncoding (Adobe standard Latin-text encoding).
+var standardEncoding = simpleEncoding{
+	0x27: 0x2019, 0x60: 0x2018,
+	0xA1: 0x00A1, 0xA2: 0x00A2, 0xA3: 0x00A3, 0xA4: 0x2044,
+	0xA5: 0x00A5, 0xA6: 0x0192, 0xA7: 0x00A7, 0xA8: 0x00A4,
+	0xA9: 0x0027, 0xAA: 0x201C, 0xAB: 0x00AB, 0xAC: 0x2039,
+	0xAD: 0x203A, 0xAE: 0xFB01, 0xAF: 0xFB02, 0xB1: 0x2013,
+	0xB2: 0x2020, 0xB3: 0x2021, 0xB4: 0x00B7, 0xB6: 0x00B6,
+	0xB7: 0x2022, 0xB8: 0x201A, 0xB9: 0x201E, 0xBA: 0x201D,
+	0xBB: 0x00BB, 0xBC: 0x2026, 0xBD: 0x2030, 0xBF: 0x00BF,
+	0xC1: 0x0060, 0xC2: 0x00B4, 0xC3: 0x02C6, 0xC4: 0x02DC,
+	0xC5: 0x00AF, 0xC6: 0x02D8, 0xC7: 0x02D9, 0xC8: 0x00A8,
+	0xCA: 0x02DA, 0xCB: 0x00B8, 0xCD: 0x02DD, 0xCE: 0x02DB,
+	0xCF: 0x02C7, 0xD0: 0x2014, 0xE1: 0x00C6, 0xE3: 0x00AA,
+	0xE8: 0x0141, 0xE9: 0x00D8, 0xEA: 0x0152, 0xEB: 0x00BA,
+	0xF1: 0x00E6, 0xF5: 0x0131, 0xF8: 0x0142, 0xF9: 0x00F8,
+	0xFA: 0x0153, 0xFB: 0x00DF,
+}
+
+// Get a simple encoding by name.  Returns nil if not supported.
+func getSimpleEncoding(name PdfObjectName) simpleEncoding {
+	switch name {
+	case "WinAnsiEncoding":
+		return winAnsiEncoding
+	case "StandardEncoding":
+		return standardEncoding
+	}
+	return nil
+}
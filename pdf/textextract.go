@@ -0,0 +1,330 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Basic text extraction from page content streams.
+
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"math"
+)
+
+// TJ displacements (in thousandths of text space units) beyond this
+// are considered to separate words.
+const textWordSpacingThreshold = 200
+
+// Font information needed to decode shown text.
+type textFont struct {
+	encoding  simpleEncoding
+	toUnicode *cmap
+	composite bool
+}
+
+// Decode the codes in a shown string to text.  Uses the ToUnicode CMap
+// when present, then the font encoding and finally the raw code bytes.
+func (this *textFont) decode(data []byte) string {
+	fallback := func(code []byte) string {
+		if this.encoding != nil && !this.composite {
+			return this.encoding.decode(code)
+		}
+		return string(code)
+	}
+
+	if this.toUnicode != nil {
+		codeLength := 1
+		if this.composite {
+			codeLength = 2
+		}
+		return this.toUnicode.decode(data, codeLength, fallback)
+	}
+	return fallback(data)
+}
+
+// Load the font information from a font dictionary.
+func (this *PdfReader) loadTextFont(fontObj PdfObject) (*textFont, error) {
+	obj, err := this.traceToDirectObject(fontObj)
+	if err != nil {
+		return nil, err
+	}
+	fontDict, ok := obj.(*PdfObjectDictionary)
+	if !ok {
+		log.Error("Font not a dictionary (%T)", obj)
+		return nil, errors.New("Font not a dictionary")
+	}
+
+	font := textFont{}
+	if subtype, ok := (*fontDict)["Subtype"].(*PdfObjectName); ok && *subtype == "Type0" {
+		font.composite = true
+	}
+
+	// Differences are not applied, only the base encoding.
+	encObj, err := this.traceToDirectObject((*fontDict)["Encoding"])
+	if err != nil {
+		return nil, err
+	}
+	switch enc := encObj.(type) {
+	case *PdfObjectName:
+		font.encoding = getSimpleEncoding(*enc)
+	case *PdfObjectDictionary:
+		if base, ok := (*enc)["BaseEncoding"].(*PdfObjectName); ok {
+			font.encoding = getSimpleEncoding(*base)
+		} else {
+			font.encoding = standardEncoding
+		}
+	case nil:
+		font.encoding = standardEncoding
+	}
+
+	if toUnicodeObj, hasToUnicode := (*fontDict)["ToUnicode"]; hasToUnicode {
+		if ref, isRef := toUnicodeObj.(*PdfObjectReference); isRef {
+			toUnicodeObj, _, err = this.resolveReference(ref)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if stream, ok := toUnicodeObj.(*PdfObjectStream); ok {
+			data, err := this.parser.decodeStream(stream)
+			if err != nil {
+				return nil, err
+			}
+			font.toUnicode, err = parseCMap(data)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &font, nil
+}
+
+// State of the text extraction.
+type textExtractor struct {
+	reader    *PdfReader
+	fontsDict *PdfObjectDictionary
+	fonts     map[PdfObjectName]*textFont
+	font      *textFont
+	leading   float64
+	// Text matrix and text line matrix.
+	tm  [6]float64
+	tlm [6]float64
+	// Position of the last shown text.
+	lastX, lastY float64
+	output       bytes.Buffer
+}
+
+var identityMatrix = [6]float64{1, 0, 0, 1, 0, 0}
+
+// Extract the text of a page.  Interprets the text showing and positioning
+// operators, inserting newlines when moving to a new line and spaces when
+// moving along a line.
+func (this *PdfReader) ExtractPageText(pageNumber int) (string, error) {
+	contents, err := this.GetPageContents(pageNumber)
+	if err != nil {
+		return "", err
+	}
+
+	pageObj, err := this.GetPage(pageNumber)
+	if err != nil {
+		return "", err
+	}
+	page, ok := pageObj.(*PdfIndirectObject)
+	if !ok {
+		return "", errors.New("Page not an indirect object")
+	}
+
+	te := textExtractor{}
+	te.reader = this
+	te.fonts = map[PdfObjectName]*textFont{}
+	te.tm = identityMatrix
+	te.tlm = identityMatrix
+
+	resources, err := this.getInheritedAttribute(page, "Resources")
+	if err != nil {
+		return "", err
+	}
+	if resDict, ok := resources.(*PdfObjectDictionary); ok {
+		fonts, err := this.traceToDirectObject((*resDict)["Font"])
+		if err != nil {
+			return "", err
+		}
+		te.fontsDict, _ = fonts.(*PdfObjectDictionary)
+	}
+
+	operations, err := NewContentStreamParser(contents).Parse()
+	if err != nil {
+		return "", err
+	}
+
+	for _, op := range operations {
+		err = te.processOperation(op)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return te.output.String(), nil
+}
+
+// Get the numeric operands of an operation.
+func getNumericOperands(op *ContentStreamOperation, num int) ([]float64, error) {
+	if len(op.Operands) < num {
+		log.Error("Too few operands for %s (%d)", op.Operator, len(op.Operands))
+		return nil, errors.New("Too few operands")
+	}
+	vals := []float64{}
+	for _, obj := range op.Operands[len(op.Operands)-num:] {
+		val, err := getNumberAsFloat(obj)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, val)
+	}
+	return vals, nil
+}
+
+func (this *textExtractor) processOperation(op *ContentStreamOperation) error {
+	switch op.Operator {
+	case "BT":
+		this.tm = identityMatrix
+		this.tlm = identityMatrix
+	case "Tf":
+		if len(op.Operands) < 2 {
+			return errors.New("Too few operands for Tf")
+		}
+		name, ok := op.Operands[0].(*PdfObjectName)
+		if !ok {
+			return errors.New("Invalid font name")
+		}
+		return this.setFont(*name)
+	case "TL":
+		vals, err := getNumericOperands(op, 1)
+		if err != nil {
+			return err
+		}
+		this.leading = vals[0]
+	case "Td":
+		vals, err := getNumericOperands(op, 2)
+		if err != nil {
+			return err
+		}
+		this.moveTextLine(vals[0], vals[1])
+	case "TD":
+		vals, err := getNumericOperands(op, 2)
+		if err != nil {
+			return err
+		}
+		this.leading = -vals[1]
+		this.moveTextLine(vals[0], vals[1])
+	case "Tm":
+		vals, err := getNumericOperands(op, 6)
+		if err != nil {
+			return err
+		}
+		copy(this.tm[:], vals)
+		this.tlm = this.tm
+	case "T*":
+		this.moveTextLine(0, -this.leading)
+	case "Tj":
+		if len(op.Operands) < 1 {
+			return errors.New("Too few operands for Tj")
+		}
+		this.showText(op.Operands[0])
+	case "'", "\"":
+		if len(op.Operands) < 1 {
+			return errors.New("Too few operands for " + op.Operator)
+		}
+		this.moveTextLine(0, -this.leading)
+		this.showText(op.Operands[len(op.Operands)-1])
+	case "TJ":
+		if len(op.Operands) < 1 {
+			return errors.New("Too few operands for TJ")
+		}
+		arr, ok := op.Operands[0].(*PdfObjectArray)
+		if !ok {
+			return errors.New("Invalid TJ operand")
+		}
+		for _, obj := range *arr {
+			if _, isString := asString(obj); isString {
+				this.showText(obj)
+				continue
+			}
+			displacement, err := getNumberAsFloat(obj)
+			if err == nil && -displacement > textWordSpacingThreshold {
+				this.addSpace()
+			}
+		}
+	}
+
+	return nil
+}
+
+// Move to the start of the next line, offset by (tx, ty).
+func (this *textExtractor) moveTextLine(tx, ty float64) {
+	m := this.tlm
+	this.tlm[4] = tx*m[0] + ty*m[2] + m[4]
+	this.tlm[5] = tx*m[1] + ty*m[3] + m[5]
+	this.tm = this.tlm
+}
+
+func (this *textExtractor) setFont(name PdfObjectName) error {
+	if font, has := this.fonts[name]; has {
+		this.font = font
+		return nil
+	}
+
+	this.font = nil
+	if this.fontsDict == nil {
+		log.Debug("No font resources, font %s missing", name)
+		return nil
+	}
+	fontObj, has := (*this.fontsDict)[name]
+	if !has {
+		log.Debug("Font %s missing", name)
+		return nil
+	}
+
+	font, err := this.reader.loadTextFont(fontObj)
+	if err != nil {
+		return err
+	}
+	this.fonts[name] = font
+	this.font = font
+	return nil
+}
+
+func (this *textExtractor) addSpace() {
+	data := this.output.Bytes()
+	if len(data) > 0 && data[len(data)-1] != ' ' && data[len(data)-1] != '\n' {
+		this.output.WriteByte(' ')
+	}
+}
+
+// Show a text string at the current position, separating it from the
+// previous text based on the position.
+func (this *textExtractor) showText(obj PdfObject) {
+	str, ok := asString(obj)
+	if !ok {
+		log.Debug("Invalid text string (%T)", obj)
+		return
+	}
+
+	x, y := this.tm[4], this.tm[5]
+	if this.output.Len() > 0 {
+		if math.Abs(y-this.lastY) > 0.1 {
+			this.output.WriteByte('\n')
+		} else if math.Abs(x-this.lastX) > 0.1 {
+			this.addSpace()
+		}
+	}
+	this.lastX, this.lastY = x, y
+
+	if this.font != nil {
+		this.output.WriteString(this.font.decode([]byte(*str)))
+	} else {
+		this.output.Write([]byte(*str))
+	}
+}
@@ -0,0 +1,100 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// Make a single page PDF with the given content stream and font resources.
+func makeTextPdf(content string, extraObjects ...string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R " +
+			"/Resources << /Font << /F1 5 0 R /F2 6 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+	}
+	objects = append(objects, extraObjects...)
+	return makePdfFile(objects)
+}
+
+func TestExtractPageTextMinimal(t *testing.T) {
+	reader := loadMinimalReader(t)
+	text, err := reader.ExtractPageText(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if text != "Hello World" {
+		t.Errorf("Unexpected text %q", text)
+	}
+}
+
+func TestExtractPageTextPositioning(t *testing.T) {
+	content := `BT /F1 12 Tf 72 720 Td (First) Tj (line) ' 14 TL
+(Second) ' T* [(Wo) -30 (rd) -500 (spaced)] TJ
+100 0 Td (x) Tj ET
+BT 1 0 0 1 72 600 Tm (\223Quoted\224) Tj ET`
+	data := makeTextPdf(content,
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	text, err := reader.ExtractPageText(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	expected := "Firstline\nSecond\nWord spaced x\n“Quoted”"
+	if text != expected {
+		t.Errorf("Unexpected text %q (expected %q)", text, expected)
+	}
+}
+
+func TestExtractPageTextToUnicode(t *testing.T) {
+	cmapData := `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+/CMapName /Adobe-Identity-UCS def
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+3 beginbfchar
+<0003> <0020>
+<0010> <00480065>
+<0011> <0064>
+endbfchar
+2 beginbfrange
+<0020> <0023> <006C>
+<0030> <0031> [<0057> <0072>]
+endbfrange
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end`
+	content := "BT /F2 12 Tf 10 10 Td <0010002000200023000300300023003100200011> Tj ET"
+	data := makeTextPdf(content,
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /Test /Encoding /Identity-H /ToUnicode 7 0 R >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(cmapData), cmapData))
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	text, err := reader.ExtractPageText(1)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if text != "Hello World" {
+		t.Errorf("Unexpected text %q", text)
+	}
+}
@@ -0,0 +1,335 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Parsing of the TrueType font tables needed for embedding (head, hhea,
+// maxp, hmtx, cmap and name).
+
+package pdf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// Font information from the TrueType tables.
+type trueTypeFont struct {
+	postScriptName string
+	unitsPerEm     int
+	// Font bounding box, ascender and descender in font units.
+	bbox      [4]int
+	ascender  int
+	descender int
+	// Advance widths by glyph ID in font units.
+	advanceWidths []int
+	// Glyph IDs by character (Unicode cmap).
+	runeToGID map[rune]int
+}
+
+// Reads big endian values from the table data, with bounds checks.
+type trueTypeReader struct {
+	data []byte
+}
+
+func (this trueTypeReader) u16(offset int) (int, error) {
+	if offset < 0 || offset+2 > len(this.data) {
+		return 0, errors.New("TrueType data out of bounds")
+	}
+	return int(binary.BigEndian.Uint16(this.data[offset:])), nil
+}
+
+func (this trueTypeReader) i16(offset int) (int, error) {
+	val, err := this.u16(offset)
+	return int(int16(val)), err
+}
+
+func (this trueTypeReader) u32(offset int) (int, error) {
+	if offset < 0 || offset+4 > len(this.data) {
+		return 0, errors.New("TrueType data out of bounds")
+	}
+	return int(binary.BigEndian.Uint32(this.data[offset:])), nil
+}
+
+// Parse the TrueType font data.
+func parseTrueType(data []byte) (*trueTypeFont, error) {
+	r := trueTypeReader{data}
+	version, err := r.u32(0)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0x00010000 && version != 0x74727565 { // 'true'
+		log.Error("Not a TrueType font (version %x)", version)
+		return nil, errors.New("Not a TrueType font")
+	}
+
+	numTables, err := r.u16(4)
+	if err != nil {
+		return nil, err
+	}
+	tables := map[string]trueTypeReader{}
+	for i := 0; i < numTables; i++ {
+		record := 12 + 16*i
+		if record+16 > len(data) {
+			return nil, errors.New("Invalid TrueType table directory")
+		}
+		tag := string(data[record : record+4])
+		offset, _ := r.u32(record + 8)
+		length, _ := r.u32(record + 12)
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("Invalid TrueType table %s", tag)
+		}
+		tables[tag] = trueTypeReader{data[offset : offset+length]}
+	}
+	for _, tag := range []string{"head", "hhea", "maxp", "hmtx", "cmap"} {
+		if _, has := tables[tag]; !has {
+			return nil, fmt.Errorf("Missing TrueType table %s", tag)
+		}
+	}
+
+	font := trueTypeFont{}
+	if err := font.parseHead(tables["head"]); err != nil {
+		return nil, err
+	}
+	if err := font.parseMetrics(tables["hhea"], tables["maxp"], tables["hmtx"]); err != nil {
+		return nil, err
+	}
+	if err := font.parseCmap(tables["cmap"]); err != nil {
+		return nil, err
+	}
+	if name, has := tables["name"]; has {
+		font.postScriptName = parsePostScriptName(name)
+	}
+	if font.postScriptName == "" {
+		font.postScriptName = "TrueTypeFont"
+	}
+	return &font, nil
+}
+
+func (this *trueTypeFont) parseHead(head trueTypeReader) error {
+	var err error
+	this.unitsPerEm, err = head.u16(18)
+	if err != nil {
+		return err
+	}
+	if this.unitsPerEm == 0 {
+		return errors.New("Invalid TrueType unitsPerEm")
+	}
+	for i := range this.bbox {
+		this.bbox[i], err = head.i16(36 + 2*i)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *trueTypeFont) parseMetrics(hhea, maxp, hmtx trueTypeReader) error {
+	var err error
+	this.ascender, err = hhea.i16(4)
+	if err != nil {
+		return err
+	}
+	this.descender, err = hhea.i16(6)
+	if err != nil {
+		return err
+	}
+	numHMetrics, err := hhea.u16(34)
+	if err != nil {
+		return err
+	}
+	numGlyphs, err := maxp.u16(4)
+	if err != nil {
+		return err
+	}
+	if numHMetrics == 0 || numHMetrics > numGlyphs {
+		return errors.New("Invalid TrueType numberOfHMetrics")
+	}
+
+	// Glyphs after the last long metric have the same advance width.
+	this.advanceWidths = make([]int, numGlyphs)
+	for gid := 0; gid < numGlyphs; gid++ {
+		if gid < numHMetrics {
+			this.advanceWidths[gid], err = hmtx.u16(4 * gid)
+			if err != nil {
+				return err
+			}
+		} else {
+			this.advanceWidths[gid] = this.advanceWidths[numHMetrics-1]
+		}
+	}
+	return nil
+}
+
+// Parse the Unicode cmap subtable, preferring the full Unicode range
+// (format 12) over the basic multilingual plane (format 4).
+func (this *trueTypeFont) parseCmap(cmapTable trueTypeReader) error {
+	numSubtables, err := cmapTable.u16(2)
+	if err != nil {
+		return err
+	}
+	format4, format12 := -1, -1
+	for i := 0; i < numSubtables; i++ {
+		platform, _ := cmapTable.u16(4 + 8*i)
+		encoding, _ := cmapTable.u16(6 + 8*i)
+		offset, err := cmapTable.u32(8 + 8*i)
+		if err != nil {
+			return err
+		}
+		format, err := cmapTable.u16(offset)
+		if err != nil {
+			return err
+		}
+		unicode := platform == 0 || (platform == 3 && (encoding == 1 || encoding == 10))
+		if !unicode {
+			continue
+		}
+		if format == 4 && format4 < 0 {
+			format4 = offset
+		} else if format == 12 && format12 < 0 {
+			format12 = offset
+		}
+	}
+
+	this.runeToGID = map[rune]int{}
+	if format12 >= 0 {
+		return this.parseCmapFormat12(cmapTable, format12)
+	}
+	if format4 >= 0 {
+		return this.parseCmapFormat4(cmapTable, format4)
+	}
+	return errors.New("No Unicode cmap in TrueType font")
+}
+
+func (this *trueTypeFont) parseCmapFormat4(r trueTypeReader, offset int) error {
+	segCountX2, err := r.u16(offset + 6)
+	if err != nil {
+		return err
+	}
+	endCodes := offset + 14
+	startCodes := endCodes + segCountX2 + 2
+	idDeltas := startCodes + segCountX2
+	idRangeOffsets := idDeltas + segCountX2
+
+	for seg := 0; seg < segCountX2/2; seg++ {
+		end, err := r.u16(endCodes + 2*seg)
+		if err != nil {
+			return err
+		}
+		start, _ := r.u16(startCodes + 2*seg)
+		delta, _ := r.u16(idDeltas + 2*seg)
+		rangeOffsetPos := idRangeOffsets + 2*seg
+		rangeOffset, err := r.u16(rangeOffsetPos)
+		if err != nil {
+			return err
+		}
+
+		for c := start; c <= end && c != 0xFFFF; c++ {
+			gid := 0
+			if rangeOffset == 0 {
+				gid = (c + delta) & 0xFFFF
+			} else {
+				gid, err = r.u16(rangeOffsetPos + rangeOffset + 2*(c-start))
+				if err != nil {
+					return err
+				}
+				if gid != 0 {
+					gid = (gid + delta) & 0xFFFF
+				}
+			}
+			if gid != 0 && gid < len(this.advanceWidths) {
+				this.runeToGID[rune(c)] = gid
+			}
+		}
+	}
+	return nil
+}
+
+func (this *trueTypeFont) parseCmapFormat12(r trueTypeReader, offset int) error {
+	numGroups, err := r.u32(offset + 12)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numGroups; i++ {
+		group := offset + 16 + 12*i
+		startChar, err := r.u32(group)
+		if err != nil {
+			return err
+		}
+		endChar, _ := r.u32(group + 4)
+		startGID, err := r.u32(group + 8)
+		if err != nil {
+			return err
+		}
+		if endChar < startChar || endChar > 0x10FFFF {
+			return errors.New("Invalid cmap group")
+		}
+		for c := startChar; c <= endChar; c++ {
+			gid := startGID + c - startChar
+			if gid >= len(this.advanceWidths) {
+				break
+			}
+			if gid != 0 {
+				this.runeToGID[rune(c)] = gid
+			}
+		}
+	}
+	return nil
+}
+
+// Get the PostScript name (name ID 6) from the name table, with
+// characters not allowed in names removed.  Empty if not found.
+func parsePostScriptName(r trueTypeReader) string {
+	count, err := r.u16(2)
+	if err != nil {
+		return ""
+	}
+	stringOffset, _ := r.u16(4)
+	for i := 0; i < count; i++ {
+		record := 6 + 12*i
+		platform, _ := r.u16(record)
+		nameID, _ := r.u16(record + 6)
+		length, _ := r.u16(record + 8)
+		offset, err := r.u16(record + 10)
+		if err != nil || nameID != 6 {
+			continue
+		}
+		start := stringOffset + offset
+		if start+length > len(r.data) {
+			continue
+		}
+		raw := r.data[start : start+length]
+
+		var name string
+		switch platform {
+		case 0, 3:
+			name = string(utf16.Decode(bytesToUTF16(raw)))
+		case 1:
+			name = string(raw)
+		default:
+			continue
+		}
+		name = strings.Map(func(c rune) rune {
+			if c <= ' ' || c > '~' || strings.ContainsRune("()<>[]{}/%#", c) {
+				return -1
+			}
+			return c
+		}, name)
+		if name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// Scale a value in font units to glyph space units (1/1000 em).
+func (this *trueTypeFont) scale(val int) int64 {
+	scaled := float64(val) * 1000 / float64(this.unitsPerEm)
+	if scaled < 0 {
+		return int64(scaled - 0.5)
+	}
+	return int64(scaled + 0.5)
+}
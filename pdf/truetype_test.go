@@ -0,0 +1,176 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+// Build a minimal TrueType font mapping the printable ASCII characters
+// (0x20-0x7E) to glyphs 1-95, with 2048 units per em.  Glyph 0 has width
+// 1024, the others 512.
+func makeTestTrueType() []byte {
+	u16 := func(vals ...int) []byte {
+		b := make([]byte, 2*len(vals))
+		for i, val := range vals {
+			binary.BigEndian.PutUint16(b[2*i:], uint16(val))
+		}
+		return b
+	}
+
+	head := make([]byte, 54)
+	copy(head[18:], u16(2048))
+	copy(head[36:], u16(-100, -400, 2000, 1800))
+	hhea := make([]byte, 36)
+	copy(hhea[4:], u16(1638, -410))
+	copy(hhea[34:], u16(2))
+	maxp := u16(0, 0x5000, 96)
+	hmtx := u16(1024, 0, 512, 0)
+
+	// Segments 0x20-0x7E and the final 0xFFFF.
+	var cmap []byte
+	cmap = append(cmap, u16(0, 1, 3, 1)...)
+	cmap = append(cmap, 0, 0, 0, 12)
+	cmap = append(cmap, u16(4, 32, 0, 4, 4, 1, 0)...)
+	cmap = append(cmap, u16(0x7E, 0xFFFF, 0, 0x20, 0xFFFF, 1-0x20, 1, 0, 0)...)
+
+	psName := utf16.Encode([]rune("Test-Regular"))
+	name := u16(0, 1, 18, 3, 1, 0x409, 6, 2*len(psName), 0)
+	for _, unit := range psName {
+		name = append(name, u16(int(unit))...)
+	}
+
+	tables := []struct {
+		tag  string
+		data []byte
+	}{{"cmap", cmap}, {"head", head}, {"hhea", hhea}, {"hmtx", hmtx}, {"maxp", maxp}, {"name", name}}
+	font := []byte{0, 1, 0, 0}
+	font = append(font, u16(len(tables), 0, 0, 0)...)
+	offset := 12 + 16*len(tables)
+	var data []byte
+	for _, table := range tables {
+		record := make([]byte, 16)
+		copy(record, table.tag)
+		binary.BigEndian.PutUint32(record[8:], uint32(offset+len(data)))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(table.data)))
+		font = append(font, record...)
+		data = append(data, table.data...)
+	}
+	return append(font, data...)
+}
+
+func TestParseTrueType(t *testing.T) {
+	ttf, err := parseTrueType(makeTestTrueType())
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if ttf.postScriptName != "Test-Regular" {
+		t.Errorf("Unexpected name %q", ttf.postScriptName)
+	}
+	if len(ttf.advanceWidths) != 96 || ttf.scale(ttf.advanceWidths[0]) != 500 || ttf.scale(ttf.advanceWidths[95]) != 250 {
+		t.Errorf("Unexpected widths %v", ttf.advanceWidths)
+	}
+	if ttf.runeToGID['A'] != 0x22 || ttf.runeToGID['~'] != 95 || len(ttf.runeToGID) != 95 {
+		t.Errorf("Unexpected cmap %v", ttf.runeToGID)
+	}
+	if ttf.scale(ttf.descender) != -200 || ttf.scale(ttf.bbox[2]) != 977 {
+		t.Errorf("Unexpected metrics %v %v", ttf.descender, ttf.bbox)
+	}
+
+	if _, err := parseTrueType([]byte("OTTO0000")); err == nil {
+		t.Errorf("Non TrueType data should fail")
+	}
+	if _, err := parseTrueType(makeTestTrueType()[:100]); err == nil {
+		t.Errorf("Truncated font should fail")
+	}
+}
+
+func TestEmbedTrueTypeFont(t *testing.T) {
+	fontData := makeTestTrueType()
+	w := NewPdfWriter()
+	page := NewPage(PageWidthLetter, PageHeightLetter)
+	fontName, err := w.EmbedTrueTypeFont(page, fontData)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if again, err := w.EmbedTrueTypeFont(page, fontData); err != nil || again != fontName {
+		t.Errorf("Registration not reused (%s, %v)", again, err)
+	}
+	text, err := w.EncodeText(fontName, "Hi!")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if text != "\x00\x29\x00\x4a\x00\x02" {
+		t.Errorf("Unexpected encoding %q", text)
+	}
+	if _, err := w.EncodeText(fontName, "é"); err == nil {
+		t.Errorf("Character not in the font should fail")
+	}
+
+	content := NewContentStreamBuilder().
+		BeginText().SetFont(string(fontName), 12).MoveText(72, 720).ShowText(text).EndText().Bytes()
+	(*page.PdfObject.(*PdfObjectDictionary))["Contents"] = makeContentStream(content)
+	if err := w.AddPage(page); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	// Read back, copy and check the font survives.
+	for round := 0; round < 2; round++ {
+		reader, err := NewPdfReader(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		extracted, err := reader.ExtractPageText(1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if extracted != "Hi!" {
+			t.Errorf("Round %d: unexpected text %q", round, extracted)
+		}
+
+		resources, err := reader.GetPageResources(1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		fonts := (*resources)["Font"].(*PdfObjectDictionary)
+		fontObj, err := reader.resolveObject((*fonts)[fontName])
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		fontDict := fontObj.(*PdfIndirectObject).PdfObject.(*PdfObjectDictionary)
+		descendants := (*fontDict)["DescendantFonts"].(*PdfObjectArray)
+		cidFont := (*descendants)[0].(*PdfIndirectObject).PdfObject.(*PdfObjectDictionary)
+		widths := (*cidFont)["W"].(*PdfObjectArray)
+		if len(*(*widths)[1].(*PdfObjectArray)) != 96 {
+			t.Errorf("Round %d: unexpected widths %v", round, widths)
+		}
+		descriptor := (*cidFont)["FontDescriptor"].(*PdfIndirectObject).PdfObject.(*PdfObjectDictionary)
+		fontFile := (*descriptor)["FontFile2"].(*PdfObjectStream)
+		embedded, err := reader.parser.decodeStream(fontFile)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if !bytes.Equal(embedded, fontData) {
+			t.Errorf("Round %d: embedded font data differs", round)
+		}
+
+		copied, err := NewPdfWriterFromReader(reader)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		out, err = writePdfToBytes(copied)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+}
@@ -6,6 +6,7 @@
 package pdf
 
 import (
+	"fmt"
 	"sort"
 
 	"github.com/unidoc/unidoc/common"
@@ -120,3 +121,27 @@ func (this *PdfParser) inspect() {
 		log.Debug("This document is valid for extraction!")
 	}
 }
+
+// Normalize a page rotation angle to one of 0, 90, 180 or 270 degrees.
+// The angle must be a multiple of 90.
+func normalizeRotation(angle int) (int, error) {
+	if angle%90 != 0 {
+		return 0, fmt.Errorf("Invalid rotation %d (not a multiple of 90)", angle)
+	}
+	angle = angle % 360
+	if angle < 0 {
+		angle += 360
+	}
+	return angle, nil
+}
+
+// Get the value of a numeric object (integer or float) as a float64.
+func getNumberAsFloat(obj PdfObject) (float64, error) {
+	if fObj, ok := obj.(*PdfObjectFloat); ok {
+		return float64(*fObj), nil
+	}
+	if iObj, ok := obj.(*PdfObjectInteger); ok {
+		return float64(*iObj), nil
+	}
+	return 0, fmt.Errorf("Not a number (%T)", obj)
+}
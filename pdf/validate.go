@@ -0,0 +1,135 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Sanity checks of the writer contents prior to writing.
+
+package pdf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Check the document for common problems before writing: the catalog
+// entries, the page tree counts, the page media boxes, references to
+// objects not added to the writer and unresolved references.  Returns
+// all the problems found, or nil if none.
+func (this *PdfWriter) Validate() []error {
+	issues := []error{}
+
+	if t, ok := (*this.catalog)["Type"].(*PdfObjectName); !ok || *t != "Catalog" {
+		issues = append(issues, errors.New("Catalog Type is not Catalog"))
+	}
+	if pages, ok := (*this.catalog)["Pages"].(*PdfIndirectObject); !ok || pages != this.pages {
+		issues = append(issues, errors.New("Catalog Pages is not the page tree root"))
+	}
+
+	this.validatePageTree(this.pages, &issues, map[*PdfIndirectObject]bool{})
+
+	added := map[PdfObject]bool{}
+	for _, obj := range this.objects {
+		added[obj] = true
+	}
+	for _, obj := range this.objects {
+		var contents PdfObject
+		switch t := obj.(type) {
+		case *PdfIndirectObject:
+			contents = t.PdfObject
+		case *PdfObjectStream:
+			contents = t.PdfObjectDictionary
+		}
+		validateReferences(contents, added, &issues)
+	}
+
+	if len(issues) == 0 {
+		return nil
+	}
+	return issues
+}
+
+// Check a node of the page tree, returning the number of pages under it.
+func (this *PdfWriter) validatePageTree(node *PdfIndirectObject, issues *[]error, traversed map[*PdfIndirectObject]bool) int {
+	if traversed[node] {
+		*issues = append(*issues, errors.New("Circular page tree reference"))
+		return 0
+	}
+	traversed[node] = true
+
+	dict, ok := node.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		*issues = append(*issues, errors.New("Page tree node not a dictionary"))
+		return 0
+	}
+	t, _ := (*dict)["Type"].(*PdfObjectName)
+	if t != nil && *t == "Page" {
+		if !hasInheritedMediaBox(node) {
+			*issues = append(*issues, fmt.Errorf("Page (object %d) missing MediaBox", node.ObjectNumber))
+		}
+		return 1
+	}
+	if t == nil || *t != "Pages" {
+		*issues = append(*issues, errors.New("Page tree node not Page or Pages"))
+		return 0
+	}
+
+	numPages := 0
+	if kids, ok := (*dict)["Kids"].(*PdfObjectArray); ok {
+		for _, kid := range *kids {
+			kidNode, ok := kid.(*PdfIndirectObject)
+			if !ok {
+				*issues = append(*issues, fmt.Errorf("Page tree kid not an indirect object (%T)", kid))
+				continue
+			}
+			numPages += this.validatePageTree(kidNode, issues, traversed)
+		}
+	} else {
+		*issues = append(*issues, errors.New("Pages node missing Kids"))
+	}
+
+	count, ok := (*dict)["Count"].(*PdfObjectInteger)
+	if !ok || int(*count) != numPages {
+		*issues = append(*issues, fmt.Errorf("Pages Count %v does not match the number of pages %d", (*dict)["Count"], numPages))
+	}
+	return numPages
+}
+
+// Check if a page or one of its ancestors has a MediaBox.
+func hasInheritedMediaBox(page *PdfIndirectObject) bool {
+	traversed := map[*PdfIndirectObject]bool{}
+	node := page
+	for node != nil && !traversed[node] {
+		traversed[node] = true
+		dict, ok := node.PdfObject.(*PdfObjectDictionary)
+		if !ok {
+			return false
+		}
+		if _, has := (*dict)["MediaBox"]; has {
+			return true
+		}
+		node, _ = (*dict)["Parent"].(*PdfIndirectObject)
+	}
+	return false
+}
+
+// Check that the indirect objects referred to by direct object contents
+// have been added and no references remain.
+func validateReferences(obj PdfObject, added map[PdfObject]bool, issues *[]error) {
+	switch t := obj.(type) {
+	case *PdfIndirectObject, *PdfObjectStream:
+		if !added[obj] {
+			*issues = append(*issues, fmt.Errorf("Object not added to the writer (%T)", obj))
+		}
+	case *PdfObjectReference:
+		*issues = append(*issues, fmt.Errorf("Unresolved reference %s", t.String()))
+	case *PdfObjectDictionary:
+		for _, k := range t.sortedKeys() {
+			validateReferences((*t)[k], added, issues)
+		}
+	case *PdfObjectArray:
+		for _, v := range *t {
+			validateReferences(v, added, issues)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriterValidate(t *testing.T) {
+	w := NewPdfWriter()
+	for i := 0; i < 2; i++ {
+		err := w.AddPage(loadMinimalPage(t))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+	if issues := w.Validate(); issues != nil {
+		t.Fatalf("Unexpected issues %v", issues)
+	}
+
+	page, _ := w.getPage(1)
+	pDict := page.PdfObject.(*PdfObjectDictionary)
+	delete(*pDict, "MediaBox")
+	(*pDict)["Unresolved"] = &PdfObjectReference{ObjectNumber: 99}
+	(*pDict)["NotAdded"] = &PdfIndirectObject{PdfObject: makeInteger(1)}
+	pagesDict := w.pages.PdfObject.(*PdfObjectDictionary)
+	(*pagesDict)["Count"] = makeInteger(3)
+	delete(*w.catalog, "Type")
+
+	issues := w.Validate()
+	expected := []string{
+		"Catalog Type",
+		"missing MediaBox",
+		"Pages Count 3 does not match the number of pages 2",
+		"Object not added",
+		"Unresolved reference",
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("Expected %d issues, got %v", len(expected), issues)
+	}
+	for i, issue := range issues {
+		if !strings.Contains(issue.Error(), expected[i]) {
+			t.Errorf("Issue %d: %q (expected %q)", i, issue, expected[i])
+		}
+	}
+}
@@ -0,0 +1,156 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+// Viewer preferences (12.2) and the catalog page layout and page mode.
+
+package pdf
+
+import (
+	"fmt"
+)
+
+// Viewer preferences of a document.  The flags default to false and the
+// names are empty when not specified.
+type ViewerPreferences struct {
+	HideToolbar     bool
+	HideMenubar     bool
+	HideWindowUI    bool
+	FitWindow       bool
+	CenterWindow    bool
+	DisplayDocTitle bool
+	// Page mode when exiting full screen mode, e.g. UseOutlines.
+	NonFullScreenPageMode PdfObjectName
+	// Reading order, L2R or R2L.
+	Direction    PdfObjectName
+	PrintScaling PdfObjectName
+	Duplex       PdfObjectName
+
+	// Catalog page layout (e.g. SinglePage, TwoColumnLeft) and page mode
+	// (e.g. UseNone, UseOutlines, FullScreen).
+	PageLayout PdfObjectName
+	PageMode   PdfObjectName
+}
+
+var viewerPreferencesFlags = []PdfObjectName{"HideToolbar", "HideMenubar", "HideWindowUI", "FitWindow", "CenterWindow", "DisplayDocTitle"}
+var viewerPreferencesNames = []PdfObjectName{"NonFullScreenPageMode", "Direction", "PrintScaling", "Duplex"}
+
+var validPageLayouts = map[PdfObjectName]bool{
+	"SinglePage": true, "OneColumn": true, "TwoColumnLeft": true,
+	"TwoColumnRight": true, "TwoPageLeft": true, "TwoPageRight": true,
+}
+
+var validPageModes = map[PdfObjectName]bool{
+	"UseNone": true, "UseOutlines": true, "UseThumbs": true,
+	"FullScreen": true, "UseOC": true, "UseAttachments": true,
+}
+
+// Get a pointer to the flag field with the specified key.
+func (this *ViewerPreferences) flag(key PdfObjectName) *bool {
+	switch key {
+	case "HideToolbar":
+		return &this.HideToolbar
+	case "HideMenubar":
+		return &this.HideMenubar
+	case "HideWindowUI":
+		return &this.HideWindowUI
+	case "FitWindow":
+		return &this.FitWindow
+	case "CenterWindow":
+		return &this.CenterWindow
+	case "DisplayDocTitle":
+		return &this.DisplayDocTitle
+	}
+	return nil
+}
+
+// Get a pointer to the name field with the specified key.
+func (this *ViewerPreferences) name(key PdfObjectName) *PdfObjectName {
+	switch key {
+	case "NonFullScreenPageMode":
+		return &this.NonFullScreenPageMode
+	case "Direction":
+		return &this.Direction
+	case "PrintScaling":
+		return &this.PrintScaling
+	case "Duplex":
+		return &this.Duplex
+	}
+	return nil
+}
+
+// Get the viewer preferences of the document from the catalog
+// ViewerPreferences dictionary, PageLayout and PageMode.
+func (this *PdfReader) GetViewerPreferences() (ViewerPreferences, error) {
+	prefs := ViewerPreferences{}
+	if this.parser.crypter != nil && !this.parser.crypter.authenticated {
+		return prefs, ErrEncrypted
+	}
+
+	if layout, ok := (*this.catalog)["PageLayout"].(*PdfObjectName); ok {
+		prefs.PageLayout = *layout
+	}
+	if mode, ok := (*this.catalog)["PageMode"].(*PdfObjectName); ok {
+		prefs.PageMode = *mode
+	}
+
+	obj, err := this.traceToDirectObject((*this.catalog)["ViewerPreferences"])
+	if err != nil {
+		return prefs, err
+	}
+	dict, ok := obj.(*PdfObjectDictionary)
+	if !ok {
+		return prefs, nil
+	}
+	for _, key := range viewerPreferencesFlags {
+		if val, ok := (*dict)[key].(*PdfObjectBool); ok {
+			*prefs.flag(key) = bool(*val)
+		}
+	}
+	for _, key := range viewerPreferencesNames {
+		if val, ok := (*dict)[key].(*PdfObjectName); ok {
+			*prefs.name(key) = *val
+		}
+	}
+
+	return prefs, nil
+}
+
+// Set the viewer preferences of the document.  Only the flags that are set
+// and the names that are not empty are written.
+func (this *PdfWriter) SetViewerPreferences(prefs ViewerPreferences) error {
+	if prefs.PageLayout != "" && !validPageLayouts[prefs.PageLayout] {
+		return fmt.Errorf("Invalid page layout (%s)", prefs.PageLayout)
+	}
+	if prefs.PageMode != "" && !validPageModes[prefs.PageMode] {
+		return fmt.Errorf("Invalid page mode (%s)", prefs.PageMode)
+	}
+
+	dict := PdfObjectDictionary{}
+	for _, key := range viewerPreferencesFlags {
+		if *prefs.flag(key) {
+			dict[key] = makeBool(true)
+		}
+	}
+	for _, key := range viewerPreferencesNames {
+		if val := *prefs.name(key); val != "" {
+			dict[key] = makeName(string(val))
+		}
+	}
+
+	delete(*this.catalog, "ViewerPreferences")
+	delete(*this.catalog, "PageLayout")
+	delete(*this.catalog, "PageMode")
+	if len(dict) > 0 {
+		(*this.catalog)["ViewerPreferences"] = &dict
+	}
+	if prefs.PageLayout != "" {
+		(*this.catalog)["PageLayout"] = makeName(string(prefs.PageLayout))
+	}
+	if prefs.PageMode != "" {
+		(*this.catalog)["PageMode"] = makeName(string(prefs.PageMode))
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestViewerPreferencesRoundTrip(t *testing.T) {
+	w := NewPdfWriter()
+	err := w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	if err := w.SetViewerPreferences(ViewerPreferences{PageMode: "Invalid"}); err == nil {
+		t.Errorf("Invalid page mode should fail")
+	}
+	prefs := ViewerPreferences{DisplayDocTitle: true, Direction: "R2L", PageMode: "UseOutlines"}
+	err = w.SetViewerPreferences(prefs)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if bytes.Contains(out, []byte("HideToolbar")) {
+		t.Errorf("Flags not set should not be written")
+	}
+
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error reading output: %v", err)
+	}
+	readPrefs, err := reader.GetViewerPreferences()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if readPrefs != prefs {
+		t.Errorf("Viewer preferences %+v (expected %+v)", readPrefs, prefs)
+	}
+
+	// No preferences.
+	readPrefs, err = loadMinimalReader(t).GetViewerPreferences()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if readPrefs != (ViewerPreferences{}) {
+		t.Errorf("Unexpected viewer preferences %+v", readPrefs)
+	}
+}
@@ -10,10 +10,13 @@ package pdf
 
 import (
 	"bufio"
+	"bytes"
+	"compress/zlib"
 	"crypto/md5"
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"os"
 	"time"
@@ -31,18 +34,44 @@ type PdfWriter struct {
 	catalog    *PdfObjectDictionary
 	fields     []PdfObject
 	infoObj    *PdfIndirectObject
+	// AcroForm signature flags (SigFlags), 0 if not set.
+	sigFlags int64
+	// Embedded file specifications by name.
+	embeddedFiles map[string]*PdfIndirectObject
+	// Named destinations by name, written in the catalog Dests dictionary
+	// or, if destsNameTree, in the Dests name tree.
+	namedDests    map[string]*PdfObjectArray
+	destsNameTree bool
+	// Fonts registered for writing text.
+	fonts []*writerFont
 	// Encryption
 	crypter     *PdfCrypt
 	encryptDict *PdfObjectDictionary
 	encryptObj  *PdfIndirectObject
 	ids         *PdfObjectArray
+	// Maximum kids of the page tree nodes when writing, 0 for a flat tree.
+	pageTreeBranching int
+	// Flate compression of unfiltered streams when writing.
+	compressStreams  bool
+	compressionLevel int
+	// Write linearized for fast web view.
+	linearize bool
+	// Called as the objects are written, nil if not set.
+	progress func(written, total int)
+	// Comment line after the header, nil if omitted.
+	binaryComment []byte
 }
 
+// Binary comment written after the header by default, marking the file
+// as binary for file transfer applications (7.5.2).
+var defaultBinaryComment = []byte("âãÏÓ")
+
 func NewPdfWriter() PdfWriter {
 	w := PdfWriter{}
 
 	w.objectsMap = map[PdfObject]bool{}
 	w.objects = []PdfObject{}
+	w.binaryComment = defaultBinaryComment
 
 	licenseKey := license.GetLicenseKey()
 
@@ -51,7 +80,6 @@ func NewPdfWriter() PdfWriter {
 	// Creation info.
 	infoDict := PdfObjectDictionary{}
 	infoDict[PdfObjectName("Producer")] = makeString(producer)
-	infoDict[PdfObjectName("Creator")] = makeString("FoxyUtils Online PDF https://foxyutils.com")
 	infoObj := PdfIndirectObject{}
 	infoObj.PdfObject = &infoDict
 	w.infoObj = &infoObj
@@ -115,12 +143,12 @@ func (this *PdfWriter) addObject(obj PdfObject) bool {
 func (this *PdfWriter) addObjects(obj PdfObject) error {
 	log.Debug("Adding objects!")
 
-	if io, isIndirectObj := obj.(*PdfIndirectObject); isIndirectObj {
+	if ind, isIndirectObj := obj.(*PdfIndirectObject); isIndirectObj {
 		log.Debug("Indirect")
 		log.Debug("- %s", obj)
-		log.Debug("- %s", io.PdfObject)
-		if this.addObject(io) {
-			err := this.addObjects(io.PdfObject)
+		log.Debug("- %s", ind.PdfObject)
+		if this.addObject(ind) {
+			err := this.addObjects(ind.PdfObject)
 			if err != nil {
 				return err
 			}
@@ -143,7 +171,8 @@ func (this *PdfWriter) addObjects(obj PdfObject) error {
 	if dict, isDict := obj.(*PdfObjectDictionary); isDict {
 		log.Debug("Dict")
 		log.Debug("- %s", obj)
-		for k, v := range *dict {
+		for _, k := range dict.sortedKeys() {
+			v := (*dict)[k]
 			log.Debug("Key %s", k)
 			if k != "Parent" {
 				err := this.addObjects(v)
@@ -190,9 +219,13 @@ func (this *PdfWriter) AddPage(pageObj PdfObject) error {
 	log.Debug("%s", page)
 	log.Debug("%s", page.PdfObject)
 
-	pDict := page.PdfObject.(*PdfObjectDictionary)
-	otype := (*pDict)["Type"].(*PdfObjectName)
-	if *otype != "Page" {
+	pDict, ok := page.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		log.Error("Page not a dictionary (%T)", page.PdfObject)
+		return errors.New("Page not a dictionary")
+	}
+	otype, ok := (*pDict)["Type"].(*PdfObjectName)
+	if !ok || *otype != "Page" {
 		return errors.New("Type != Page (Required).")
 	}
 
@@ -200,12 +233,19 @@ func (this *PdfWriter) AddPage(pageObj PdfObject) error {
 	inheritedFields := []PdfObjectName{"Resources", "MediaBox", "CropBox", "Rotate"}
 	parent, hasParent := (*pDict)["Parent"].(*PdfIndirectObject)
 	log.Debug("Page Parent: %T (%v)", (*pDict)["Parent"], hasParent)
+	// Keep track of the traversed parents to detect cycles.
+	traversedParents := map[*PdfObjectDictionary]bool{}
 	for hasParent {
 		log.Debug("Page Parent: %T", parent)
 		parentDict, ok := parent.PdfObject.(*PdfObjectDictionary)
 		if !ok {
 			return errors.New("Invalid Parent object")
 		}
+		if _, alreadyTraversed := traversedParents[parentDict]; alreadyTraversed || parentDict == pDict {
+			log.Error("Circular Parent reference")
+			return errors.New("Circular Parent reference")
+		}
+		traversedParents[parentDict] = true
 		for _, field := range inheritedFields {
 			log.Debug("Field %s", field)
 			if _, hasAlready := (*pDict)[field]; hasAlready {
@@ -225,22 +265,31 @@ func (this *PdfWriter) AddPage(pageObj PdfObject) error {
 
 	log.Debug("Traversal done")
 
+	// Check the page tree before updating.
+	kids, err := this.getPageTreeKids()
+	if err != nil {
+		return err
+	}
+	pagesDict := this.pages.PdfObject.(*PdfObjectDictionary)
+	pageCount, ok := (*pagesDict)["Count"].(*PdfObjectInteger)
+	if !ok {
+		log.Error("Invalid page tree Count (%T)", (*pagesDict)["Count"])
+		return errors.New("Invalid page tree Count")
+	}
+
 	// Update the dictionary.
 	// Reuses the input object, updating the fields.
 	(*pDict)["Parent"] = this.pages
 	page.PdfObject = pDict
 
 	// Add to Pages.
-	pagesDict := this.pages.PdfObject.(*PdfObjectDictionary)
-	kids := (*pagesDict)["Kids"].(*PdfObjectArray)
 	*kids = append(*kids, page)
-	pageCount := (*pagesDict)["Count"].(*PdfObjectInteger)
 	*pageCount = *pageCount + 1
 
 	this.addObject(page)
 
 	// Traverse the page and record all object references.
-	err := this.addObjects(pDict)
+	err = this.addObjects(pDict)
 	if err != nil {
 		return err
 	}
@@ -248,6 +297,104 @@ func (this *PdfWriter) AddPage(pageObj PdfObject) error {
 	return nil
 }
 
+// Get the Kids array of the page tree root, where the pages are added.
+func (this *PdfWriter) getPageTreeKids() (*PdfObjectArray, error) {
+	pagesDict, ok := this.pages.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return nil, errors.New("Page tree root not a dictionary")
+	}
+	kids, ok := (*pagesDict)["Kids"].(*PdfObjectArray)
+	if !ok {
+		log.Error("Invalid page tree Kids (%T)", (*pagesDict)["Kids"])
+		return nil, errors.New("Invalid page tree Kids")
+	}
+	return kids, nil
+}
+
+// Get a page that has been added to the writer by the page number
+// (starting from 1).
+func (this *PdfWriter) getPage(pageNumber int) (*PdfIndirectObject, error) {
+	kids, err := this.getPageTreeKids()
+	if err != nil {
+		return nil, err
+	}
+	if pageNumber < 1 || pageNumber > len(*kids) {
+		return nil, fmt.Errorf("Invalid page number %d (valid range 1-%d)", pageNumber, len(*kids))
+	}
+
+	page, ok := (*kids)[pageNumber-1].(*PdfIndirectObject)
+	if !ok {
+		return nil, errors.New("Page not an indirect object")
+	}
+	return page, nil
+}
+
+// Set the rotation of a page in degrees (clockwise).  The angle must be a
+// multiple of 90 and is normalized to one of 0, 90, 180 or 270.
+func (this *PdfWriter) SetPageRotation(pageNumber int, angle int) error {
+	rotation, err := normalizeRotation(angle)
+	if err != nil {
+		return err
+	}
+
+	page, err := this.getPage(pageNumber)
+	if err != nil {
+		return err
+	}
+	pDict, ok := page.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return errors.New("Page not a dictionary")
+	}
+
+	(*pDict)["Rotate"] = makeInteger(int64(rotation))
+	return nil
+}
+
+// Rotate all the pages by an angle in degrees (clockwise), which must be
+// a multiple of 90.  The angle is added to the existing rotation of each
+// page, e.g. rotating a page with Rotate 90 by 270 gives 0.
+func (this *PdfWriter) RotateAllPages(angle int) error {
+	rotation, err := normalizeRotation(angle)
+	if err != nil {
+		return err
+	}
+
+	kids, err := this.getPageTreeKids()
+	if err != nil {
+		return err
+	}
+	for _, kid := range *kids {
+		page, ok := kid.(*PdfIndirectObject)
+		if !ok {
+			return errors.New("Page not an indirect object")
+		}
+		pDict, ok := page.PdfObject.(*PdfObjectDictionary)
+		if !ok {
+			return errors.New("Page not a dictionary")
+		}
+
+		// Inherited rotations are copied to the page when added.
+		current := 0
+		rotateObj := (*pDict)["Rotate"]
+		if ind, isIndirect := rotateObj.(*PdfIndirectObject); isIndirect {
+			rotateObj = ind.PdfObject
+		}
+		if rotateObj != nil {
+			rotate, ok := rotateObj.(*PdfObjectInteger)
+			if !ok {
+				log.Error("Invalid Rotate object (%T)", rotateObj)
+				return errors.New("Invalid Rotate object")
+			}
+			current, err = normalizeRotation(int(*rotate))
+			if err != nil {
+				return err
+			}
+		}
+		(*pDict)["Rotate"] = makeInteger(int64((current + rotation) % 360))
+	}
+	return nil
+}
+
 // Add outlines to a PDF file.
 func (this *PdfWriter) AddOutlines(outlinesList []*PdfIndirectObject) error {
 	// Add the outlines.
@@ -262,8 +409,8 @@ func (this *PdfWriter) AddOutlines(outlinesList []*PdfIndirectObject) error {
 func (this *PdfWriter) seekByName(obj PdfObject, followKeys []string, key string) ([]PdfObject, error) {
 	log.Debug("Seek by name.. %T", obj)
 	list := []PdfObject{}
-	if io, isIndirectObj := obj.(*PdfIndirectObject); isIndirectObj {
-		return this.seekByName(io.PdfObject, followKeys, key)
+	if ind, isIndirectObj := obj.(*PdfIndirectObject); isIndirectObj {
+		return this.seekByName(ind.PdfObject, followKeys, key)
 	}
 
 	if so, isStreamObj := obj.(*PdfObjectStream); isStreamObj {
@@ -293,13 +440,35 @@ func (this *PdfWriter) seekByName(obj PdfObject, followKeys []string, key string
 		return list, nil
 	}
 
-	// Ignore arrays.
-	//if arr, isArray := obj.(*PdfObjectArray); isArray {
-	//}
+	// Arrays are reached through the followed keys, e.g. Kids.
+	if arr, isArray := obj.(*PdfObjectArray); isArray {
+		for _, v := range *arr {
+			items, err := this.seekByName(v, followKeys, key)
+			if err != nil {
+				return list, err
+			}
+			list = append(list, items...)
+		}
+		return list, nil
+	}
 
 	return list, nil
 }
 
+// Check if a page has been added to the writer.
+func (this *PdfWriter) hasPage(obj PdfObject) bool {
+	kids, err := this.getPageTreeKids()
+	if err != nil {
+		return false
+	}
+	for _, page := range *kids {
+		if page == obj {
+			return true
+		}
+	}
+	return false
+}
+
 // Add Acroforms to a PDF file.
 func (this *PdfWriter) AddForms(forms *PdfObjectDictionary) error {
 	// Traverse the forms object...
@@ -345,23 +514,22 @@ func (this *PdfWriter) AddForms(forms *PdfObjectDictionary) error {
 			continue
 		}
 
+		// Include the field if any of its widget annotations is on a
+		// page added to the writer.
 		includeField := false
 		for _, p := range list {
-			if po, ok := p.(*PdfIndirectObject); ok {
-				log.Debug("P entry is an indirect object (page)")
-				if this.hasObject(po) {
-					includeField = true
-				} else {
-					return errors.New("P pointing outside of write pages")
-				}
-			} else {
+			po, ok := p.(*PdfIndirectObject)
+			if !ok {
 				log.Error("P entry not an indirect object (%T)", p)
+				continue
+			}
+			if this.hasPage(po) {
+				includeField = true
+				break
 			}
+			log.Debug("P entry pointing outside of the written pages")
 		}
 
-		// This won't work.  There can be many sub objects.
-		// Need to specifically go and check the page object!
-		// P or the appearance dictionary.
 		if includeField {
 			log.Debug("Add the field! (%T)", field)
 			// Add if nothing referenced outside of the writer.
@@ -375,83 +543,420 @@ func (this *PdfWriter) AddForms(forms *PdfObjectDictionary) error {
 	return nil
 }
 
-// Write out an indirect / stream object.
-func (this *PdfWriter) writeObject(num int, obj PdfObject) {
+// Write out an indirect / stream object.  The object data is streamed
+// directly to the output without building up an intermediate string.
+func (this *PdfWriter) writeObject(num int, obj PdfObject) error {
 	log.Debug("Write obj #%d\n", num)
+	output := countingWriter{w: this.writer}
 
 	if pobj, isIndirect := obj.(*PdfIndirectObject); isIndirect {
-		outStr := fmt.Sprintf("%d 0 obj\n", num)
-		outStr += pobj.PdfObject.DefaultWriteString()
-		outStr += "\nendobj\n"
-		this.writer.WriteString(outStr)
-		return
+		output.writeString(fmt.Sprintf("%d 0 obj\n", num))
+		output.writeObject(pobj.PdfObject)
+		output.writeString("\nendobj\n")
+		return output.err
 	}
 
 	if pobj, isStream := obj.(*PdfObjectStream); isStream {
-		outStr := fmt.Sprintf("%d 0 obj\n", num)
-		outStr += pobj.PdfObjectDictionary.DefaultWriteString()
-		outStr += "\nstream\n"
-		this.writer.WriteString(outStr)
-		this.writer.Write(pobj.Stream)
-		this.writer.WriteString("\nendstream\nendobj\n")
-		return
+		output.writeString(fmt.Sprintf("%d 0 obj\n", num))
+		output.writeObject(pobj.PdfObjectDictionary)
+		output.writeString("\nstream\n")
+		if output.err == nil {
+			_, output.err = this.writer.Write(pobj.Stream)
+		}
+		output.writeString("\nendstream\nendobj\n")
+		return output.err
+	}
+
+	output.writeObject(obj)
+	return output.err
+}
+
+// Stream dictionary entries related to the encoding, ignored when
+// comparing streams by decoded content.
+var streamEncodingKeys = map[PdfObjectName]bool{
+	"Length":      true,
+	"Filter":      true,
+	"DecodeParms": true,
+}
+
+// Check whether an object can be collapsed with its duplicates.  The
+// document structure objects and page tree nodes are kept as is, as are
+// objects with a back-reference to their parent or page (Parent, P), which
+// are tied to it even when structurally identical to another.
+func (this *PdfWriter) isDeduplicatable(obj PdfObject) bool {
+	if obj == this.root || obj == this.pages || obj == this.infoObj || obj == this.encryptObj {
+		return false
+	}
+	for _, outline := range this.outlines {
+		if obj == outline {
+			return false
+		}
+	}
+	for _, field := range this.fields {
+		if obj == field {
+			return false
+		}
+	}
+
+	var dict *PdfObjectDictionary
+	if ind, isIndirect := obj.(*PdfIndirectObject); isIndirect {
+		dict, _ = ind.PdfObject.(*PdfObjectDictionary)
+	} else if so, isStream := obj.(*PdfObjectStream); isStream {
+		dict = so.PdfObjectDictionary
+	}
+	if dict != nil {
+		for _, key := range []PdfObjectName{"Parent", "P"} {
+			if _, has := (*dict)[key]; has {
+				return false
+			}
+		}
+		if otype, ok := (*dict)["Type"].(*PdfObjectName); ok && (*otype == "Page" || *otype == "Pages") {
+			return false
+		}
+	}
+	return true
+}
+
+// Check whether two streams are duplicates: same dictionary (apart from
+// the encoding related entries) and same decoded content.
+func streamsDuplicate(a, b *PdfObjectStream, decoded map[*PdfObjectStream][]byte) bool {
+	dictA := PdfObjectDictionary{}
+	for k, v := range *a.PdfObjectDictionary {
+		if !streamEncodingKeys[k] {
+			dictA[k] = v
+		}
+	}
+	dictB := PdfObjectDictionary{}
+	for k, v := range *b.PdfObjectDictionary {
+		if !streamEncodingKeys[k] {
+			dictB[k] = v
+		}
+	}
+	if !PdfObjectsEqual(&dictA, &dictB) {
+		return false
+	}
+	return bytes.Equal(decodedStreamData(a, decoded), decodedStreamData(b, decoded))
+}
+
+// Get the decoded data of a stream for comparison, cached in decoded.
+func decodedStreamData(so *PdfObjectStream, decoded map[*PdfObjectStream][]byte) []byte {
+	if data, has := decoded[so]; has {
+		return data
+	}
+	parser := PdfParser{}
+	data, err := parser.decodeStream(so)
+	if err != nil {
+		// Compare the raw data if unable to decode.
+		log.Debug("Unable to decode stream for comparison (%s)", err)
+		data = so.Stream
+	}
+	decoded[so] = data
+	return data
+}
+
+// Hash the structure of an indirect object or stream for finding the
+// candidate duplicates: the types, the dictionary keys and the scalar
+// values, and the decoded stream data.  Duplicates have the same hash.
+func hashDeduplicatable(obj PdfObject, decoded map[*PdfObjectStream][]byte) uint64 {
+	h := fnv.New64a()
+	switch t := obj.(type) {
+	case *PdfIndirectObject:
+		hashObjectStructure(h, t.PdfObject)
+	case *PdfObjectStream:
+		dict := PdfObjectDictionary{}
+		for k, v := range *t.PdfObjectDictionary {
+			if !streamEncodingKeys[k] {
+				dict[k] = v
+			}
+		}
+		hashObjectStructure(h, &dict)
+		h.Write(decodedStreamData(t, decoded))
+	}
+	return h.Sum64()
+}
+
+// Write the structure of a direct object to a hash.  Nested indirect
+// objects and streams are only hashed by type, not descending into them,
+// as they are compared by content.
+func hashObjectStructure(h io.Writer, obj PdfObject) {
+	switch t := obj.(type) {
+	case *PdfObjectBool:
+		fmt.Fprintf(h, "b%t;", bool(*t))
+	case *PdfObjectInteger:
+		fmt.Fprintf(h, "i%d;", *t)
+	case *PdfObjectFloat:
+		val := float64(*t)
+		if val == 0 {
+			// Same hash for -0.
+			val = 0
+		}
+		fmt.Fprintf(h, "f%v;", val)
+	case *PdfObjectString, *PdfObjectHexString:
+		// Literal and hex strings with the same value are equal.
+		str, _ := asString(t)
+		fmt.Fprintf(h, "s%d:%s;", len(*str), string(*str))
+	case *PdfObjectName:
+		fmt.Fprintf(h, "n%d:%s;", len(*t), string(*t))
+	case *PdfObjectNull:
+		fmt.Fprintf(h, "z;")
+	case *PdfObjectReference:
+		fmt.Fprintf(h, "r%d %d;", t.ObjectNumber, t.GenerationNumber)
+	case *PdfObjectArray:
+		fmt.Fprintf(h, "a%d[", len(*t))
+		for _, v := range *t {
+			hashObjectStructure(h, v)
+		}
+		fmt.Fprintf(h, "]")
+	case *PdfObjectDictionary:
+		fmt.Fprintf(h, "d%d{", len(*t))
+		for _, key := range t.sortedKeys() {
+			fmt.Fprintf(h, "%d:%s=", len(key), string(key))
+			hashObjectStructure(h, (*t)[key])
+		}
+		fmt.Fprintf(h, "}")
+	case *PdfIndirectObject:
+		fmt.Fprintf(h, "o;")
+	case *PdfObjectStream:
+		fmt.Fprintf(h, "S;")
+	default:
+		fmt.Fprintf(h, "?;")
+	}
+}
+
+// Replace references to duplicate objects within an object, not
+// descending into other indirect objects.
+func replaceDuplicateObjects(obj PdfObject, replacements map[PdfObject]PdfObject) {
+	switch t := obj.(type) {
+	case *PdfIndirectObject:
+		replaceDuplicateObjects(t.PdfObject, replacements)
+	case *PdfObjectStream:
+		replaceDuplicateObjects(t.PdfObjectDictionary, replacements)
+	case *PdfObjectDictionary:
+		for k, v := range *t {
+			if replacement, has := replacements[v]; has {
+				(*t)[k] = replacement
+			} else if isDirectContainer(v) {
+				replaceDuplicateObjects(v, replacements)
+			}
+		}
+	case *PdfObjectArray:
+		for idx, v := range *t {
+			if replacement, has := replacements[v]; has {
+				(*t)[idx] = replacement
+			} else if isDirectContainer(v) {
+				replaceDuplicateObjects(v, replacements)
+			}
+		}
 	}
+}
 
-	this.writer.WriteString(obj.DefaultWriteString())
+func isDirectContainer(obj PdfObject) bool {
+	switch obj.(type) {
+	case *PdfObjectDictionary, *PdfObjectArray:
+		return true
+	}
+	return false
 }
 
-// Update all the object numbers prior to writing.
-func (this *PdfWriter) updateObjectNumbers() {
-	// Update numbers
+// Collapse structurally identical objects (such as fonts and images
+// repeated on multiple pages) into a single object and update all
+// references to point to it.  Streams are compared by the decoded content
+// and the dictionary.  Returns the number of objects removed.
+func (this *PdfWriter) DeduplicateObjects() (int, error) {
+	replacements := map[PdfObject]PdfObject{}
+	decoded := map[*PdfObjectStream][]byte{}
+	// Unique objects by structural hash, only objects with the same hash
+	// are compared.
+	unique := map[uint64][]PdfObject{}
+
+	for _, obj := range this.objects {
+		if !this.isDeduplicatable(obj) {
+			continue
+		}
+
+		hash := hashDeduplicatable(obj, decoded)
+		isDuplicate := false
+		for _, u := range unique[hash] {
+			if so, isStream := obj.(*PdfObjectStream); isStream {
+				if uso, ok := u.(*PdfObjectStream); ok && streamsDuplicate(so, uso, decoded) {
+					isDuplicate = true
+				}
+			} else if ind, isIndirect := obj.(*PdfIndirectObject); isIndirect {
+				if uio, ok := u.(*PdfIndirectObject); ok && PdfObjectsEqual(ind, uio) {
+					isDuplicate = true
+				}
+			}
+			if isDuplicate {
+				log.Debug("Duplicate object %s", obj)
+				replacements[obj] = u
+				break
+			}
+		}
+		if !isDuplicate {
+			unique[hash] = append(unique[hash], obj)
+		}
+	}
+
+	if len(replacements) == 0 {
+		return 0, nil
+	}
+
+	objects := []PdfObject{}
+	for _, obj := range this.objects {
+		if _, isDuplicate := replacements[obj]; isDuplicate {
+			continue
+		}
+		replaceDuplicateObjects(obj, replacements)
+		objects = append(objects, obj)
+	}
+	this.objects = objects
+
+	return len(replacements), nil
+}
+
+// Update all the object numbers prior to writing.  Returns the highest
+// object number assigned.
+func (this *PdfWriter) updateObjectNumbers() int64 {
+	maxNumber := int64(0)
 	for idx, obj := range this.objects {
-		if io, isIndirect := obj.(*PdfIndirectObject); isIndirect {
-			io.ObjectNumber = int64(idx + 1)
-			io.GenerationNumber = 0
+		number := int64(idx + 1)
+		if ind, isIndirect := obj.(*PdfIndirectObject); isIndirect {
+			ind.ObjectNumber = number
+			ind.GenerationNumber = 0
 		}
 		if so, isStream := obj.(*PdfObjectStream); isStream {
-			so.ObjectNumber = int64(idx + 1)
+			so.ObjectNumber = number
 			so.GenerationNumber = 0
 		}
+		if number > maxNumber {
+			maxNumber = number
+		}
+	}
+	return maxNumber
+}
+
+// Set the creator of the document in the document information
+// dictionary, i.e. the application that created the original document.
+// The creator is omitted unless set, an empty string removes it.
+func (this *PdfWriter) SetCreator(creator string) {
+	infoDict := this.infoObj.PdfObject.(*PdfObjectDictionary)
+	if creator == "" {
+		infoDict.Remove("Creator")
+		return
 	}
+	infoDict.Set("Creator", makeString(creator))
 }
 
+// Set the document identifier, written as the /ID array in the trailer.
+// The first identifier (id0) is permanent and should be based on the
+// original document, the second one (id1) changes when the document is
+// modified.  When set, the output is fully reproducible.
+//
+// Needs to be set prior to Encrypt as the encryption key is derived from
+// id0.  If not set, the identifiers are generated in Encrypt or, for
+// unencrypted documents, from a hash of the document content in Write.
+func (this *PdfWriter) SetDocumentID(id0, id1 []byte) error {
+	if len(id0) == 0 || len(id1) == 0 {
+		return errors.New("Document ID cannot be empty")
+	}
+	if this.crypter != nil {
+		return errors.New("Document ID needs to be set prior to encryption")
+	}
+
+	this.ids = makeIDArray(id0, id1)
+	return nil
+}
+
+// Make the trailer /ID array from the two identifiers.
+func makeIDArray(id0, id1 []byte) *PdfObjectArray {
+	return &PdfObjectArray{makeHexString(string(id0)), makeHexString(string(id1))}
+}
+
+// Generate the document identifiers for the trailer /ID.  The first
+// identifier is the creation hash, the second the modification hash, both
+// MD5 hashes of the corresponding input data.
+func generateDocumentIDs(creationData, modificationData []byte) *PdfObjectArray {
+	creationHash := md5.Sum(creationData)
+	modificationHash := md5.Sum(modificationData)
+	return makeIDArray(creationHash[:], modificationHash[:])
+}
+
+// Encryption algorithms for writing.
+type EncryptionAlgorithm int
+
+const (
+	// RC4 with a 128 bit key (V2, R3), the default.
+	RC4_128bit EncryptionAlgorithm = iota
+	// RC4 with a 40 bit key (V1, R2) for legacy readers.
+	RC4_40bit
+)
+
 type EncryptOptions struct {
 	Permissions AccessPermissions
+	Algorithm   EncryptionAlgorithm
+	// Leave the document metadata stream unencrypted (EncryptMetadata
+	// false), e.g. for indexing by search engines.  Requires 128 bit RC4
+	// and is written with a crypt filter (V4).  Metadata is encrypted by
+	// default.
+	LeaveMetadataUnencrypted bool
 }
 
 // Encrypt the output file with a specified user/owner password.
 func (this *PdfWriter) Encrypt(userPass, ownerPass []byte, options *EncryptOptions) error {
 	crypter := PdfCrypt{}
-	this.crypter = &crypter
 
 	crypter.encryptedObjects = map[PdfObject]bool{}
 
-	crypter.cryptFilters = CryptFilters{}
-	crypter.cryptFilters["Default"] = CryptFilter{cfm: "V2", length: 128}
-
 	// Set
-	crypter.P = -1
+	crypter.P = int(fullAccessPermissions().GetP())
 	crypter.V = 2
 	crypter.R = 3
 	crypter.length = 128
 	crypter.encryptMetadata = true
 	if options != nil {
 		crypter.P = int(options.Permissions.GetP())
+		switch options.Algorithm {
+		case RC4_128bit:
+		case RC4_40bit:
+			crypter.V = 1
+			crypter.R = 2
+			crypter.length = 40
+		default:
+			return fmt.Errorf("Unsupported encryption algorithm (%d)", options.Algorithm)
+		}
+		crypter.encryptMetadata = !options.LeaveMetadataUnencrypted
 	}
 
-	// Prepare the ID object for the trailer.
-	hashcode := md5.Sum([]byte(time.Now().Format(time.RFC850)))
-	id0 := PdfObjectString(hashcode[:])
-	b := make([]byte, 100)
-	rand.Read(b)
-	hashcode = md5.Sum(b)
-	id1 := PdfObjectString(hashcode[:])
-	log.Debug("Random b: % x", b)
+	crypter.cryptFilters = CryptFilters{}
+	crypter.cryptFilters["Default"] = CryptFilter{cfm: "V2", length: crypter.length}
+	if !crypter.encryptMetadata {
+		// Only meaningful with crypt filters (V4).
+		if crypter.length != 128 {
+			return errors.New("Unencrypted metadata requires 128 bit encryption")
+		}
+		crypter.V = 4
+		crypter.R = 4
+		crypter.cryptFilters["StdCF"] = CryptFilter{cfm: "V2", length: 16}
+		crypter.cryptFilters["Identity"] = CryptFilter{}
+		crypter.streamFilter = "StdCF"
+		crypter.stringFilter = "StdCF"
+	}
+
+	// Prepare the ID object for the trailer, unless specified by the user.
+	if this.ids == nil {
+		b := make([]byte, 100)
+		rand.Read(b)
+		log.Debug("Random b: % x", b)
+		this.ids = generateDocumentIDs([]byte(time.Now().Format(time.RFC850)), b)
+	}
 
-	this.ids = &PdfObjectArray{&id0, &id1}
-	log.Debug("Gen Id 0: % x", id0)
+	id0, ok := (*this.ids)[0].(*PdfObjectHexString)
+	if !ok {
+		return errors.New("Invalid document ID")
+	}
+	log.Debug("Gen Id 0: % x", *id0)
 
-	crypter.id0 = string(id0)
+	crypter.id0 = string(*id0)
 
 	// Make the O and U objects.
 	O, err := crypter.alg3(userPass, ownerPass)
@@ -461,7 +966,13 @@ func (this *PdfWriter) Encrypt(userPass, ownerPass []byte, options *EncryptOptio
 	}
 	crypter.O = []byte(O)
 	log.Debug("gen O: % x", O)
-	U, key, err := crypter.alg5(userPass)
+	var U PdfObjectString
+	var key []byte
+	if crypter.R == 2 {
+		U, key, err = crypter.alg4(userPass)
+	} else {
+		U, key, err = crypter.alg5(userPass)
+	}
 	if err != nil {
 		log.Error("Error generating O for encryption (%s)", err)
 		return err
@@ -477,21 +988,61 @@ func (this *PdfWriter) Encrypt(userPass, ownerPass []byte, options *EncryptOptio
 	(*encDict)[PdfObjectName("V")] = makeInteger(int64(crypter.V))
 	(*encDict)[PdfObjectName("R")] = makeInteger(int64(crypter.R))
 	(*encDict)[PdfObjectName("Length")] = makeInteger(int64(crypter.length))
-	(*encDict)[PdfObjectName("O")] = &O
-	(*encDict)[PdfObjectName("U")] = &U
+	(*encDict)[PdfObjectName("O")] = makeHexString(string(O))
+	(*encDict)[PdfObjectName("U")] = makeHexString(string(U))
+	if crypter.V == 4 {
+		stdCF := PdfObjectDictionary{}
+		stdCF["Type"] = makeName("CryptFilter")
+		stdCF["CFM"] = makeName("V2")
+		stdCF["AuthEvent"] = makeName("DocOpen")
+		stdCF["Length"] = makeInteger(16)
+		cf := PdfObjectDictionary{}
+		cf["StdCF"] = &stdCF
+		(*encDict)["CF"] = &cf
+		(*encDict)["StmF"] = makeName("StdCF")
+		(*encDict)["StrF"] = makeName("StdCF")
+		(*encDict)["EncryptMetadata"] = makeBool(false)
+	}
 	this.encryptDict = encDict
 
 	// Make an object to contain it.
-	io := &PdfIndirectObject{}
-	io.PdfObject = encDict
-	this.encryptObj = io
-	this.addObject(io)
+	ind := &PdfIndirectObject{}
+	ind.PdfObject = encDict
+	this.encryptObj = ind
+	this.addObject(ind)
+	this.crypter = &crypter
 
 	return nil
 }
 
+// Encrypt the output file with an owner password only.  The document can
+// be opened without a password, restricted to the specified permissions.
+func (this *PdfWriter) EncryptRestricted(ownerPass []byte, perms AccessPermissions) error {
+	if len(ownerPass) == 0 {
+		return errors.New("Owner password required")
+	}
+	return this.Encrypt([]byte(""), ownerPass, &EncryptOptions{Permissions: perms})
+}
+
 // Write the pdf out.
 func (this *PdfWriter) Write(ws io.WriteSeeker) error {
+	// The offsets are relative to the start of the file.
+	startOffset, err := ws.Seek(0, os.SEEK_CUR)
+	if err != nil {
+		return err
+	}
+	return this.write(ws, startOffset)
+}
+
+// Write the PDF file to a plain writer, which does not need to support
+// seeking, e.g. a pipe or an HTTP response.
+func (this *PdfWriter) WriteToWriter(w io.Writer) error {
+	return this.write(w, 0)
+}
+
+// Write the PDF file.  The object offsets for the xref table are tracked
+// by counting the bytes written, starting from startOffset.
+func (this *PdfWriter) write(out io.Writer, startOffset int64) error {
 	log.Debug("Write()")
 	if len(this.outlines) > 0 {
 		// Add the outlines dictionary if some outlines added.
@@ -499,11 +1050,11 @@ func (this *PdfWriter) Write(ws io.WriteSeeker) error {
 		// for writing.
 		outlines := PdfIndirectObject{}
 		outlinesDict := PdfObjectDictionary{}
-		outlinesDict[PdfObjectName("Type")] = makeName("Outlines")
-		outlinesDict[PdfObjectName("First")] = this.outlines[0]
-		outlinesDict[PdfObjectName("Last")] = this.outlines[len(this.outlines)-1]
+		outlinesDict.Set("Type", makeName("Outlines"))
+		outlinesDict.Set("First", this.outlines[0])
+		outlinesDict.Set("Last", this.outlines[len(this.outlines)-1])
 		outlines.PdfObject = &outlinesDict
-		(*this.catalog)[PdfObjectName("Outlines")] = &outlines
+		this.catalog.Set("Outlines", &outlines)
 
 		for idx, outline := range this.outlines {
 			dict, ok := outline.PdfObject.(*PdfObjectDictionary)
@@ -511,12 +1062,12 @@ func (this *PdfWriter) Write(ws io.WriteSeeker) error {
 				continue
 			}
 			if idx < len(this.outlines)-1 {
-				(*dict)[PdfObjectName("Next")] = this.outlines[idx+1]
+				dict.Set("Next", this.outlines[idx+1])
 			}
 			if idx > 0 {
-				(*dict)[PdfObjectName("Prev")] = this.outlines[idx-1]
+				dict.Set("Prev", this.outlines[idx-1])
 			}
-			(*dict)[PdfObjectName("Parent")] = &outlines
+			dict.Set("Parent", &outlines)
 		}
 		err := this.addObjects(&outlines)
 		if err != nil {
@@ -531,56 +1082,111 @@ func (this *PdfWriter) Write(ws io.WriteSeeker) error {
 		for _, field := range this.fields {
 			fieldsArray = append(fieldsArray, field)
 		}
-		formsDict[PdfObjectName("Fields")] = &fieldsArray
-		(*this.catalog)[PdfObjectName("AcroForm")] = &forms
+		formsDict.Set("Fields", &fieldsArray)
+		if this.sigFlags != 0 {
+			formsDict.Set("SigFlags", makeInteger(this.sigFlags))
+		}
+		this.catalog.Set("AcroForm", &forms)
 		err := this.addObjects(&forms)
 		if err != nil {
 			return err
 		}
 	}
 
-	w := bufio.NewWriter(ws)
+	restorePageTree, err := this.balancePageTree()
+	if err != nil {
+		return err
+	}
+	defer restorePageTree()
+
+	if this.linearize {
+		return this.writeLinearized(out, startOffset)
+	}
+
+	// Hash the content as it is written, used for generating the document
+	// ID if not specified.
+	hasher := md5.New()
+	output := &countingWriter{w: out, n: startOffset}
+	w := bufio.NewWriter(io.MultiWriter(output, hasher))
 	this.writer = w
 
-	w.WriteString("%PDF-1.3\n")
-	w.WriteString("%âãÏÓ\n")
+	w.WriteString(this.header())
 	w.Flush()
 
-	this.updateObjectNumbers()
+	maxNumber := this.updateObjectNumbers()
 
-	offsets := []int64{}
+	// Offsets by object number.
+	offsets := map[int64]int64{}
 
 	// Write objects
 	log.Debug("Writing %d obj", len(this.objects))
 	for idx, obj := range this.objects {
 		log.Debug("Writing %d", idx)
-		this.writer.Flush()
-		offset, _ := ws.Seek(0, os.SEEK_CUR)
-		offsets = append(offsets, offset)
+		number, _, err := getObjectNumber(obj)
+		if err != nil {
+			return err
+		}
+		offsets[number] = output.n + int64(w.Buffered())
+
+		if so, isStream := obj.(*PdfObjectStream); isStream && this.compressStreams {
+			err := this.compressStream(so)
+			if err != nil {
+				return err
+			}
+		}
 
 		// Encrypt prior to writing.
 		// Encrypt dictionary should not be encrypted.
 		if this.crypter != nil && obj != this.encryptObj {
-			err := this.crypter.Encrypt(obj, int64(idx+1), 0)
+			err := this.crypter.Encrypt(obj, number, 0)
 			if err != nil {
 				log.Error("Failed encrypting (%s)", err)
 				return err
 			}
 
 		}
-		this.writeObject(idx+1, obj)
+		err = this.writeObject(int(number), obj)
+		if err != nil {
+			return err
+		}
+		if this.progress != nil {
+			this.progress(idx+1, len(this.objects))
+		}
 	}
 	w.Flush()
 
-	xrefOffset, _ := ws.Seek(0, os.SEEK_CUR)
+	ids := this.ids
+	if ids == nil {
+		// Derive a stable ID from the content.  Both identifiers are the
+		// same when the file is first written.
+		contentHash := hasher.Sum(nil)
+		ids = generateDocumentIDs(contentHash, contentHash)
+	}
+
+	xrefOffset := output.n + int64(w.Buffered())
 	// Write xref table.
 	this.writer.WriteString("xref\r\n")
-	outStr := fmt.Sprintf("%d %d\r\n", 0, len(this.objects)+1)
+	// Object numbers not assigned are free, linked from object 0.
+	free := []int64{0}
+	for number := int64(1); number <= maxNumber; number++ {
+		if _, has := offsets[number]; !has {
+			free = append(free, number)
+		}
+	}
+	nextFree := map[int64]int64{}
+	for i := 0; i+1 < len(free); i++ {
+		nextFree[free[i]] = free[i+1]
+	}
+	outStr := fmt.Sprintf("%d %d\r\n", 0, maxNumber+1)
 	this.writer.WriteString(outStr)
-	outStr = fmt.Sprintf("%.10d %.5d f\r\n", 0, 65535)
+	outStr = fmt.Sprintf("%.10d %.5d f\r\n", nextFree[0], 65535)
 	this.writer.WriteString(outStr)
-	for _, offset := range offsets {
-		outStr = fmt.Sprintf("%.10d %.5d n\r\n", offset, 0)
+	for number := int64(1); number <= maxNumber; number++ {
+		if offset, has := offsets[number]; has {
+			outStr = fmt.Sprintf("%.10d %.5d n\r\n", offset, 0)
+		} else {
+			outStr = fmt.Sprintf("%.10d %.5d f\r\n", nextFree[number], 1)
+		}
 		this.writer.WriteString(outStr)
 	}
 
@@ -588,22 +1194,109 @@ func (this *PdfWriter) Write(ws io.WriteSeeker) error {
 	trailer := PdfObjectDictionary{}
 	trailer["Info"] = this.infoObj
 	trailer["Root"] = this.root
-	trailer["Size"] = makeInteger(int64(len(this.objects) + 1))
+	// One more than the highest object number.
+	trailer["Size"] = makeInteger(maxNumber + 1)
+	trailer[PdfObjectName("ID")] = ids
+	log.Debug("Ids: %s", ids)
 	// If encrypted!
 	if this.crypter != nil {
 		trailer["Encrypt"] = this.encryptObj
-		trailer[PdfObjectName("ID")] = this.ids
-		log.Debug("Ids: %s", this.ids)
 	}
 	this.writer.WriteString("trailer\n")
-	this.writer.WriteString(trailer.DefaultWriteString())
+	trailer.WriteTo(this.writer)
 	this.writer.WriteString("\n")
 
 	// Make offset reference.
 	outStr = fmt.Sprintf("startxref\n%d\n", xrefOffset)
 	this.writer.WriteString(outStr)
 	this.writer.WriteString("%%EOF\n")
-	w.Flush()
 
+	return w.Flush()
+}
+
+// Set the comment line written after the header, by default bytes above
+// 127 so that the file is treated as binary.  Nil omits the comment line.
+// The comment cannot contain line breaks.
+func (this *PdfWriter) SetBinaryHeaderComment(comment []byte) error {
+	if bytes.ContainsAny(comment, "\r\n") {
+		return errors.New("Header comment with a line break")
+	}
+	this.binaryComment = comment
+	return nil
+}
+
+// Get the file header: the version and the comment line.
+func (this *PdfWriter) header() string {
+	header := "%PDF-1.3\n"
+	if this.binaryComment != nil {
+		header += "%" + string(this.binaryComment) + "\n"
+	}
+	return header
+}
+
+// Set a callback for reporting the progress of writing, called after each
+// object is written with the number of objects written and the total.
+// Nil removes the callback.
+func (this *PdfWriter) SetProgressCallback(callback func(written, total int)) {
+	this.progress = callback
+}
+
+// Compress the streams without a filter with FlateDecode when writing,
+// at the specified compress/flate level: -1 for the default compression
+// (level 6), 0 for no compression (stored blocks), 1 for the fastest to
+// 9 for the best compression.  Streams are written as is unless a
+// compression level is set.  Metadata streams are not compressed.
+func (this *PdfWriter) SetCompressionLevel(level int) error {
+	if level < zlib.DefaultCompression || level > zlib.BestCompression {
+		return fmt.Errorf("Invalid compression level (%d)", level)
+	}
+	this.compressStreams = true
+	this.compressionLevel = level
+	return nil
+}
+
+// Compress a stream with FlateDecode unless already filtered.
+func (this *PdfWriter) compressStream(so *PdfObjectStream) error {
+	dict := so.PdfObjectDictionary
+	if _, hasFilter := (*dict)["Filter"]; hasFilter {
+		return nil
+	}
+	if t, ok := (*dict)["Type"].(*PdfObjectName); ok && *t == "Metadata" {
+		// Kept readable for non PDF aware tools.
+		return nil
+	}
+
+	var buf bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&buf, this.compressionLevel)
+	if err != nil {
+		return err
+	}
+	zw.Write(so.Stream)
+	err = zw.Close()
+	if err != nil {
+		return err
+	}
+
+	so.Stream = buf.Bytes()
+	(*dict)["Filter"] = makeName("FlateDecode")
+	(*dict)["Length"] = makeInteger(int64(len(so.Stream)))
 	return nil
 }
+
+// Write the PDF file to the specified path, creating or truncating the
+// file.
+func (this *PdfWriter) WriteToFile(path string) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Do not mask a write error with the close error.
+		closeErr := f.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	return this.Write(f)
+}
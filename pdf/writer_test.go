@@ -0,0 +1,1168 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.txt', which is part of this source code package.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// In-memory WriteSeeker for capturing the writer output.
+type testWriteSeeker struct {
+	buf    []byte
+	offset int64
+}
+
+func (this *testWriteSeeker) Write(p []byte) (int, error) {
+	end := this.offset + int64(len(p))
+	if end > int64(len(this.buf)) {
+		newBuf := make([]byte, end)
+		copy(newBuf, this.buf)
+		this.buf = newBuf
+	}
+	copy(this.buf[this.offset:], p)
+	this.offset = end
+	return len(p), nil
+}
+
+func (this *testWriteSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case os.SEEK_SET:
+		this.offset = offset
+	case os.SEEK_CUR:
+		this.offset += offset
+	case os.SEEK_END:
+		this.offset = int64(len(this.buf)) + offset
+	}
+	if this.offset < 0 {
+		return 0, errors.New("Negative offset")
+	}
+	return this.offset, nil
+}
+
+// Write out the writer contents to a byte slice.
+func writePdfToBytes(w *PdfWriter) ([]byte, error) {
+	ws := &testWriteSeeker{}
+	err := w.Write(ws)
+	if err != nil {
+		return nil, err
+	}
+	return ws.buf, nil
+}
+
+// Load the first page of the minimal test file.
+func loadMinimalPage(t *testing.T) PdfObject {
+	file, err := os.Open(file1)
+	if err != nil {
+		t.Fatalf("Unable to open minimal test file (%s)", err)
+	}
+	defer file.Close()
+
+	reader, err := NewPdfReader(file)
+	if err != nil {
+		t.Fatalf("Unable to read test file (%s)", err)
+	}
+
+	page, err := reader.GetPage(1)
+	if err != nil {
+		t.Fatalf("Unable to get page (%s)", err)
+	}
+	return page
+}
+
+// Two writes with the same content and document ID should be identical.
+func TestWriterDocumentIDReproducible(t *testing.T) {
+	outputs := [][]byte{}
+	for i := 0; i < 2; i++ {
+		w := NewPdfWriter()
+		err := w.SetDocumentID([]byte("id0-0123456789ab"), []byte("id1-0123456789ab"))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		err = w.AddPage(loadMinimalPage(t))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		err = w.Encrypt([]byte("user"), []byte("owner"), nil)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if w.crypter.id0 != "id0-0123456789ab" {
+			t.Errorf("Encryption not using the specified ID (%s)", w.crypter.id0)
+		}
+
+		out, err := writePdfToBytes(&w)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		outputs = append(outputs, out)
+	}
+
+	if !bytes.Equal(outputs[0], outputs[1]) {
+		t.Errorf("Output not reproducible")
+	}
+	// Identifiers are written as hex strings.
+	if !bytes.Contains(outputs[0], []byte(fmt.Sprintf("<%x>", "id0-0123456789ab"))) {
+		t.Errorf("Trailer missing the document ID")
+	}
+}
+
+// Without a specified ID, unencrypted output should still be stable.
+func TestWriterContentHashID(t *testing.T) {
+	outputs := [][]byte{}
+	for i := 0; i < 2; i++ {
+		w := NewPdfWriter()
+		err := w.AddPage(loadMinimalPage(t))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		out, err := writePdfToBytes(&w)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		outputs = append(outputs, out)
+	}
+
+	if !bytes.Equal(outputs[0], outputs[1]) {
+		t.Errorf("Output not reproducible")
+	}
+
+	w := NewPdfWriter()
+	err := w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	err = w.SetDocumentID([]byte("a"), []byte("b"))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if bytes.Equal(outputs[0], out) {
+		t.Errorf("Specified ID not used")
+	}
+}
+
+// The trailer should contain an /ID also for unencrypted documents.
+func TestWriterTrailerID(t *testing.T) {
+	w := NewPdfWriter()
+	err := w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error reading output: %v", err)
+	}
+
+	ids, ok := (*reader.parser.trailer)["ID"].(*PdfObjectArray)
+	if !ok {
+		t.Fatalf("Trailer missing ID array")
+	}
+	if len(*ids) != 2 {
+		t.Fatalf("ID array should have 2 entries (%d)", len(*ids))
+	}
+	for i, obj := range *ids {
+		id, ok := obj.(*PdfObjectString)
+		if !ok {
+			t.Errorf("ID entry %d not a string (%T)", i, obj)
+			continue
+		}
+		if len(*id) != 16 {
+			t.Errorf("ID entry %d should be a 16 byte hash (%d)", i, len(*id))
+		}
+	}
+}
+
+// Discards the output while keeping track of the offset.
+type discardWriteSeeker struct {
+	offset int64
+	size   int64
+}
+
+func (this *discardWriteSeeker) Write(p []byte) (int, error) {
+	this.offset += int64(len(p))
+	if this.offset > this.size {
+		this.size = this.offset
+	}
+	return len(p), nil
+}
+
+func (this *discardWriteSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case os.SEEK_SET:
+		this.offset = offset
+	case os.SEEK_CUR:
+		this.offset += offset
+	case os.SEEK_END:
+		this.offset = this.size + offset
+	}
+	return this.offset, nil
+}
+
+// Benchmark writing a document with a 50MB content stream.  Run with
+// -benchmem to see the allocations, the stream data should not be copied
+// into intermediate strings.
+func BenchmarkWriteLargeStream(b *testing.B) {
+	data := bytes.Repeat([]byte("0 0 m 100 100 l S\n"), 50*1024*1024/18)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stream := PdfObjectStream{}
+		dict := PdfObjectDictionary{}
+		dict["Length"] = makeInteger(int64(len(data)))
+		stream.PdfObjectDictionary = &dict
+		stream.Stream = data
+
+		pageDict := PdfObjectDictionary{}
+		pageDict["Type"] = makeName("Page")
+		pageDict["MediaBox"] = &PdfObjectArray{makeInteger(0), makeInteger(0), makeInteger(612), makeInteger(792)}
+		pageDict["Contents"] = &stream
+		page := PdfIndirectObject{}
+		page.PdfObject = &pageDict
+
+		w := NewPdfWriter()
+		err := w.AddPage(&page)
+		if err != nil {
+			b.Fatalf("Error: %v", err)
+		}
+		err = w.Write(&discardWriteSeeker{})
+		if err != nil {
+			b.Fatalf("Error: %v", err)
+		}
+	}
+}
+
+// A cyclic Parent chain should give an error rather than loop forever.
+func TestAddPageParentCycle(t *testing.T) {
+	parent1Dict := PdfObjectDictionary{}
+	parent1Dict["Type"] = makeName("Pages")
+	parent1 := PdfIndirectObject{}
+	parent1.PdfObject = &parent1Dict
+
+	parent2Dict := PdfObjectDictionary{}
+	parent2Dict["Type"] = makeName("Pages")
+	parent2 := PdfIndirectObject{}
+	parent2.PdfObject = &parent2Dict
+
+	parent1Dict["Parent"] = &parent2
+	parent2Dict["Parent"] = &parent1
+
+	pageDict := PdfObjectDictionary{}
+	pageDict["Type"] = makeName("Page")
+	pageDict["Parent"] = &parent1
+	page := PdfIndirectObject{}
+	page.PdfObject = &pageDict
+
+	w := NewPdfWriter()
+	err := w.AddPage(&page)
+	if err == nil {
+		t.Errorf("Cyclic parent chain should fail")
+	}
+}
+
+// Malformed pages and page trees should give errors rather than panic.
+func TestAddPageMalformed(t *testing.T) {
+	makeObj := func(dict PdfObjectDictionary) *PdfIndirectObject {
+		obj := PdfIndirectObject{}
+		obj.PdfObject = &dict
+		return &obj
+	}
+	arrayPage := PdfIndirectObject{}
+	arrayPage.PdfObject = &PdfObjectArray{}
+	testcases := []struct {
+		name string
+		page PdfObject
+	}{
+		{"not a dictionary", &arrayPage},
+		{"missing Type", makeObj(PdfObjectDictionary{})},
+		{"Type not a name", makeObj(PdfObjectDictionary{"Type": makeString("Page")})},
+		{"Pages with invalid Kids", makeObj(PdfObjectDictionary{"Type": makeName("Pages"), "Kids": makeInteger(1)})},
+	}
+	for _, tcase := range testcases {
+		w := NewPdfWriter()
+		if err := w.AddPage(tcase.page); err == nil {
+			t.Errorf("%s: should fail", tcase.name)
+		}
+	}
+
+	// Page tree root with Kids of the wrong type.
+	w := NewPdfWriter()
+	pagesDict := w.pages.PdfObject.(*PdfObjectDictionary)
+	(*pagesDict)["Kids"] = makeObj(PdfObjectDictionary{})
+	if err := w.AddPage(loadMinimalPage(t)); err == nil {
+		t.Errorf("Invalid page tree Kids should fail")
+	}
+	if _, err := writePdfToBytes(&w); err == nil {
+		t.Errorf("Writing invalid page tree Kids should fail")
+	}
+	if err := w.SetPageRotation(1, 90); err == nil {
+		t.Errorf("Invalid page tree Kids should fail")
+	}
+
+	w = NewPdfWriter()
+	pagesDict = w.pages.PdfObject.(*PdfObjectDictionary)
+	(*pagesDict)["Count"] = makeName("One")
+	if err := w.AddPage(loadMinimalPage(t)); err == nil {
+		t.Errorf("Invalid page tree Count should fail")
+	}
+}
+
+// Set the page rotation and read it back.
+func TestPageRotationRoundTrip(t *testing.T) {
+	testcases := []struct {
+		Angle    int
+		Expected int
+	}{
+		{0, 0},
+		{90, 90},
+		{-90, 270},
+		{450, 90},
+		{-540, 180},
+	}
+
+	for _, testcase := range testcases {
+		w := NewPdfWriter()
+		err := w.AddPage(loadMinimalPage(t))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		err = w.SetPageRotation(1, testcase.Angle)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		out, err := writePdfToBytes(&w)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+
+		reader, err := NewPdfReader(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("Error reading output: %v", err)
+		}
+		rotation, err := reader.GetPageRotation(1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if rotation != testcase.Expected {
+			t.Errorf("Rotation %d: got %d, expected %d", testcase.Angle, rotation, testcase.Expected)
+		}
+	}
+
+	w := NewPdfWriter()
+	err := w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if err = w.SetPageRotation(1, 45); err == nil {
+		t.Errorf("Rotation by 45 should fail")
+	}
+	if err = w.SetPageRotation(2, 90); err == nil {
+		t.Errorf("Rotating a non-existing page should fail")
+	}
+}
+
+func TestRotateAllPages(t *testing.T) {
+	w := makeLinearizeTestWriter(t, 3)
+	if err := w.SetPageRotation(2, 270); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if err := w.RotateAllPages(45); err == nil {
+		t.Errorf("Rotation by 45 should fail")
+	}
+	if err := w.RotateAllPages(90); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	// Rotating the copy again by -180.
+	var rotated bytes.Buffer
+	if err := WriteRotatedCopy(bytes.NewReader(out), -180, &rotated); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	for i, data := range [][]byte{out, rotated.Bytes()} {
+		reader, err := NewPdfReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		expected := [][]int{{90, 0, 90}, {270, 180, 270}}[i]
+		for j, rotation := range expected {
+			actual, err := reader.GetPageRotation(j + 1)
+			if err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+			if actual != rotation {
+				t.Errorf("Copy %d page %d: rotation %d (expected %d)", i, j+1, actual, rotation)
+			}
+		}
+	}
+}
+
+// Build a page with its own (identical) font and content objects.
+func makeDuplicateResourcesPage() *PdfIndirectObject {
+	fontDict := PdfObjectDictionary{}
+	fontDict["Type"] = makeName("Font")
+	fontDict["Subtype"] = makeName("Type1")
+	fontDict["BaseFont"] = makeName("Helvetica")
+	font := PdfIndirectObject{}
+	font.PdfObject = &fontDict
+
+	fonts := PdfObjectDictionary{}
+	fonts["F1"] = &font
+	resources := PdfObjectDictionary{}
+	resources["Font"] = &fonts
+
+	content := []byte("BT /F1 12 Tf 10 10 Td (Hello) Tj ET")
+	stream := PdfObjectStream{}
+	stream.PdfObjectDictionary = &PdfObjectDictionary{"Length": makeInteger(int64(len(content)))}
+	stream.Stream = content
+
+	pageDict := PdfObjectDictionary{}
+	pageDict["Type"] = makeName("Page")
+	pageDict["MediaBox"] = &PdfObjectArray{makeInteger(0), makeInteger(0), makeInteger(612), makeInteger(792)}
+	pageDict["Resources"] = &resources
+	pageDict["Contents"] = &stream
+	page := PdfIndirectObject{}
+	page.PdfObject = &pageDict
+	return &page
+}
+
+func TestDeduplicateObjects(t *testing.T) {
+	w := NewPdfWriter()
+	for i := 0; i < 2; i++ {
+		err := w.AddPage(makeDuplicateResourcesPage())
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+
+	numObjects := len(w.objects)
+	removed, err := w.DeduplicateObjects()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	// The font and the content stream.
+	if removed != 2 {
+		t.Errorf("Expected 2 objects removed, got %d", removed)
+	}
+	if len(w.objects) != numObjects-removed {
+		t.Errorf("Object count %d, expected %d", len(w.objects), numObjects-removed)
+	}
+
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if bytes.Count(out, []byte("/BaseFont")) != 1 {
+		t.Errorf("Font should be written once")
+	}
+
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error reading output: %v", err)
+	}
+	numPages, _ := reader.GetNumPages()
+	if numPages != 2 {
+		t.Fatalf("Expected 2 pages (%d)", numPages)
+	}
+	fontNumbers := []int64{}
+	for i := 1; i <= 2; i++ {
+		page, err := reader.GetPage(i)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		resources := (*page.(*PdfIndirectObject).PdfObject.(*PdfObjectDictionary))["Resources"].(*PdfObjectDictionary)
+		fonts := (*resources)["Font"].(*PdfObjectDictionary)
+		font, ok := (*fonts)["F1"].(*PdfIndirectObject)
+		if !ok {
+			t.Fatalf("Font not an indirect object (%T)", (*fonts)["F1"])
+		}
+		fontNumbers = append(fontNumbers, font.ObjectNumber)
+
+		text, err := reader.ExtractPageText(i)
+		if err != nil || text != "Hello" {
+			t.Errorf("Unexpected page text %q (%v)", text, err)
+		}
+	}
+	if fontNumbers[0] != fontNumbers[1] {
+		t.Errorf("Pages not sharing the font (%v)", fontNumbers)
+	}
+
+	// Nothing more to remove.
+	removed, err = w.DeduplicateObjects()
+	if err != nil || removed != 0 {
+		t.Errorf("Second pass removed %d (%v)", removed, err)
+	}
+}
+
+// Annotations on identical pages are not collapsed, as their P entries
+// refer to different pages.
+func TestDeduplicateObjectsPageBackReference(t *testing.T) {
+	w := NewPdfWriter()
+	annots := []*PdfIndirectObject{}
+	for i := 0; i < 2; i++ {
+		page := makeDuplicateResourcesPage()
+		annotDict := PdfObjectDictionary{}
+		annotDict["Type"] = makeName("Annot")
+		annotDict["Subtype"] = makeName("Text")
+		annotDict["Rect"] = &PdfObjectArray{makeInteger(10), makeInteger(10), makeInteger(30), makeInteger(30)}
+		annotDict["P"] = page
+		annot := &PdfIndirectObject{PdfObject: &annotDict}
+		(*page.PdfObject.(*PdfObjectDictionary))["Annots"] = &PdfObjectArray{annot}
+		if err := w.AddPage(page); err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		annots = append(annots, annot)
+	}
+
+	if _, err := w.DeduplicateObjects(); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	for i, annot := range annots {
+		found := false
+		for _, obj := range w.objects {
+			if obj == annot {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Annotation %d collapsed", i)
+		}
+	}
+
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error reading output: %v", err)
+	}
+	for i := 1; i <= 2; i++ {
+		page, err := reader.GetPage(i)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		pageDict := page.(*PdfIndirectObject).PdfObject.(*PdfObjectDictionary)
+		annot := (*(*pageDict)["Annots"].(*PdfObjectArray))[0].(*PdfIndirectObject)
+		p := (*annot.PdfObject.(*PdfObjectDictionary))["P"].(*PdfIndirectObject)
+		if p != page {
+			t.Errorf("Page %d: annotation refers to object %d", i, p.ObjectNumber)
+		}
+	}
+}
+
+// Duplicates have the same structural hash, differing objects generally
+// not.
+func TestHashDeduplicatable(t *testing.T) {
+	makeObj := func(size PdfObject, name string) *PdfIndirectObject {
+		dict := PdfObjectDictionary{}
+		dict["Type"] = makeName("Font")
+		dict["BaseFont"] = makeName(name)
+		dict["Size"] = size
+		return &PdfIndirectObject{PdfObject: &dict}
+	}
+	decoded := map[*PdfObjectStream][]byte{}
+	hash := func(obj PdfObject) uint64 {
+		return hashDeduplicatable(obj, decoded)
+	}
+
+	a, b := makeObj(makeReal(0), "Helvetica"), makeObj(makeReal(math.Copysign(0, -1)), "Helvetica")
+	if !PdfObjectsEqual(a, b) || hash(a) != hash(b) {
+		t.Errorf("Duplicates with different hashes")
+	}
+	if hash(a) == hash(makeObj(makeReal(0), "Times-Roman")) || hash(a) == hash(makeObj(makeInteger(0), "Helvetica")) {
+		t.Errorf("Same hash for different objects")
+	}
+
+	// Streams by the decoded data.
+	raw := makeContentStream([]byte("BT ET"))
+	encoded := makeContentStream([]byte("BT ET"))
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(encoded.Stream)
+	zw.Close()
+	encoded.Stream = buf.Bytes()
+	(*encoded.PdfObjectDictionary)["Filter"] = makeName("FlateDecode")
+	(*encoded.PdfObjectDictionary)["Length"] = makeInteger(int64(buf.Len()))
+	if hash(raw) != hash(encoded) {
+		t.Errorf("Duplicate streams with different hashes")
+	}
+	if hash(raw) == hash(makeContentStream([]byte("BT 1 Tc ET"))) {
+		t.Errorf("Same hash for different streams")
+	}
+}
+
+// The trailer Size is one more than the highest object number, also when
+// objects are renumbered by deduplication and writing again.
+func TestTrailerSize(t *testing.T) {
+	w := NewPdfWriter()
+	for i := 0; i < 3; i++ {
+		if err := w.AddPage(makeDuplicateResourcesPage()); err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+	checkSize := func(out []byte, expected int) {
+		reader, err := NewPdfReader(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		numbers, err := reader.ObjectNumbers()
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		size, ok := (*reader.parser.trailer)["Size"].(*PdfObjectInteger)
+		if !ok || int(*size) != numbers[len(numbers)-1]+1 || int(*size) != expected {
+			t.Errorf("Size %v, highest object number %d, expected size %d", (*reader.parser.trailer)["Size"], numbers[len(numbers)-1], expected)
+		}
+	}
+
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	checkSize(out, len(w.objects)+1)
+
+	// Renumbered after removing the duplicate fonts and content streams.
+	if removed, err := w.DeduplicateObjects(); err != nil || removed != 4 {
+		t.Fatalf("Removed %d (%v)", removed, err)
+	}
+	out, err = writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	checkSize(out, len(w.objects)+1)
+
+	// Objects numbered from the writer objects, skipping none.
+	if maxNumber := w.updateObjectNumbers(); maxNumber != int64(len(w.objects)) {
+		t.Errorf("Highest object number %d with %d objects", maxNumber, len(w.objects))
+	}
+}
+
+// Info strings with special characters should be read back intact.
+func TestWriterInfoTitleEscaping(t *testing.T) {
+	title := "Report (Final) \\ v2"
+
+	w := NewPdfWriter()
+	err := w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	infoDict := w.infoObj.PdfObject.(*PdfObjectDictionary)
+	(*infoDict)["Title"] = makeString(title)
+
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error reading output: %v", err)
+	}
+	infoObj, err := reader.parser.Trace((*reader.parser.trailer)["Info"])
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	info, ok := infoObj.(*PdfObjectDictionary)
+	if !ok {
+		t.Fatalf("Info not a dictionary (%T)", infoObj)
+	}
+	readTitle, ok := (*info)["Title"].(*PdfObjectString)
+	if !ok || string(*readTitle) != title {
+		t.Errorf("Title not read back intact (%v)", (*info)["Title"])
+	}
+}
+
+// Write the minimal page encrypted with the specified passwords and
+// options.
+func writeEncryptedMinimal(t *testing.T, userPass, ownerPass []byte, options *EncryptOptions) []byte {
+	w := NewPdfWriter()
+	err := w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	err = w.Encrypt(userPass, ownerPass, options)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	return out
+}
+
+func TestEncryptRC4_40bit(t *testing.T) {
+	for _, userPass := range []string{"", "user"} {
+		out := writeEncryptedMinimal(t, []byte(userPass), []byte("owner"), &EncryptOptions{Algorithm: RC4_40bit})
+
+		reader, err := NewPdfReader(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		crypter := reader.parser.crypter
+		if crypter == nil || crypter.V != 1 || crypter.R != 2 || crypter.length != 40 {
+			t.Fatalf("Expected 40 bit RC4 encryption (%+v)", crypter)
+		}
+
+		if userPass != "" {
+			success, err := reader.Decrypt([]byte("wrong"))
+			if err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+			if success {
+				t.Errorf("Decrypting with a wrong password should fail")
+			}
+		}
+		success, err := reader.Decrypt([]byte(userPass))
+		if err != nil || !success {
+			t.Fatalf("Unable to decrypt with %q (%v)", userPass, err)
+		}
+		text, err := reader.ExtractPageText(1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if text != "Hello World" {
+			t.Errorf("Unexpected text %q", text)
+		}
+	}
+
+	w := NewPdfWriter()
+	if err := w.Encrypt(nil, nil, &EncryptOptions{Algorithm: 99}); err == nil {
+		t.Errorf("Unsupported algorithm should fail")
+	}
+}
+
+// Encrypted strings are binary and written as hex strings.
+func TestEncryptStringsHex(t *testing.T) {
+	w := NewPdfWriter()
+	if err := w.Encrypt([]byte("user"), []byte("owner"), nil); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	dict := PdfObjectDictionary{}
+	dict["T"] = makeString("Hello")
+	dict["Kids"] = &PdfObjectArray{makeString("World")}
+	obj := &PdfIndirectObject{PdfObjectReference: PdfObjectReference{ObjectNumber: 5}, PdfObject: &dict}
+	if err := w.crypter.Encrypt(obj, 0, 0); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if str, ok := dict["T"].(*PdfObjectHexString); !ok || string(*str) == "Hello" {
+		t.Errorf("Unexpected encrypted string %#v", dict["T"])
+	}
+	if str, ok := (*dict["Kids"].(*PdfObjectArray))[0].(*PdfObjectHexString); !ok || string(*str) == "World" {
+		t.Errorf("Unexpected encrypted string %#v", (*dict["Kids"].(*PdfObjectArray))[0])
+	}
+	if _, ok := (*w.encryptDict)["O"].(*PdfObjectHexString); !ok {
+		t.Errorf("O not a hex string")
+	}
+
+	// Still strings for the string handling, decrypted back in place.
+	if str, ok := dict.GetString("T"); !ok || str == "Hello" {
+		t.Errorf("Unexpected encrypted string %q (%v)", str, ok)
+	}
+	w.crypter.decryptedObjects = map[PdfObject]bool{}
+	if err := w.crypter.Decrypt(obj, 0, 0); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if str, ok := dict.GetString("T"); !ok || str != "Hello" {
+		t.Errorf("Unexpected decrypted string %q (%v)", str, ok)
+	}
+	if !PdfObjectsEqual(dict["T"], makeString("Hello")) || !PdfObjectsEqual(makeString("Hello"), dict["T"]) {
+		t.Errorf("Hex and literal strings with the same value should be equal")
+	}
+}
+
+func TestEncryptPermissionsRoundTrip(t *testing.T) {
+	perms := AccessPermissions{Printing: true, FillForms: true, DisabilityExtract: true}
+	out := writeEncryptedMinimal(t, []byte("user"), []byte("owner"), &EncryptOptions{Permissions: perms})
+
+	reader, err := NewPdfReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	readPerms, err := reader.GetPermissions()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if readPerms != perms {
+		t.Errorf("Permissions %+v (expected %+v)", readPerms, perms)
+	}
+
+	reader = loadMinimalReader(t)
+	readPerms, err = reader.GetPermissions()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if readPerms != fullAccessPermissions() {
+		t.Errorf("Unencrypted document should have all permissions (%+v)", readPerms)
+	}
+}
+
+func TestEncryptRestricted(t *testing.T) {
+	perms := AccessPermissions{DisabilityExtract: true}
+	w := NewPdfWriter()
+	err := w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if err := w.EncryptRestricted(nil, perms); err == nil {
+		t.Errorf("Missing owner password should fail")
+	}
+	err = w.EncryptRestricted([]byte("owner"), perms)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	// Opens without a password, reporting the restrictions.
+	for _, password := range []string{"", "owner"} {
+		reader, err := NewPdfReader(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		success, err := reader.Decrypt([]byte(password))
+		if err != nil || !success {
+			t.Fatalf("Unable to decrypt with %q (%v)", password, err)
+		}
+		readPerms, err := reader.GetPermissions()
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if readPerms != perms {
+			t.Errorf("Permissions %+v (expected %+v)", readPerms, perms)
+		}
+		text, err := reader.ExtractPageText(1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if text != "Hello World" {
+			t.Errorf("Unexpected text %q", text)
+		}
+	}
+}
+
+func TestDecryptWithOwnerPassword(t *testing.T) {
+	for _, options := range []*EncryptOptions{nil, {Algorithm: RC4_40bit}} {
+		out := writeEncryptedMinimal(t, []byte("user"), []byte("owner"), options)
+		reader, err := NewPdfReader(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		success, err := reader.Decrypt([]byte("owner"))
+		if err != nil || !success {
+			t.Fatalf("Unable to decrypt with the owner password (%v)", err)
+		}
+		text, err := reader.ExtractPageText(1)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if text != "Hello World" {
+			t.Errorf("Unexpected text %q", text)
+		}
+	}
+}
+
+func TestWriteToFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unidoc")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	w := NewPdfWriter()
+	err = w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	path := filepath.Join(dir, "out.pdf")
+	err = w.WriteToFile(path)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	defer f.Close()
+	reader, err := NewPdfReader(f)
+	if err != nil {
+		t.Fatalf("Error reading output: %v", err)
+	}
+	numPages, err := reader.GetNumPages()
+	if err != nil || numPages != 1 {
+		t.Errorf("Expected 1 page, got %d (%v)", numPages, err)
+	}
+
+	err = w.WriteToFile(filepath.Join(dir, "missing", "out.pdf"))
+	if err == nil {
+		t.Errorf("Writing to a missing directory should fail")
+	}
+}
+
+// Writing to a plain writer should give the same output as with seeking.
+func TestWriteToWriter(t *testing.T) {
+	outputs := [][]byte{}
+	for i := 0; i < 2; i++ {
+		w := NewPdfWriter()
+		err := w.AddPage(loadMinimalPage(t))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+
+		var out []byte
+		if i == 0 {
+			out, err = writePdfToBytes(&w)
+		} else {
+			var buf bytes.Buffer
+			err = w.WriteToWriter(&buf)
+			out = buf.Bytes()
+		}
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		outputs = append(outputs, out)
+	}
+
+	if !bytes.Equal(outputs[0], outputs[1]) {
+		t.Errorf("Output differs from the seeking writer")
+	}
+	reader, err := NewPdfReader(bytes.NewReader(outputs[1]))
+	if err != nil {
+		t.Fatalf("Error reading output: %v", err)
+	}
+	page, err := reader.GetPage(1)
+	if err != nil || page == nil {
+		t.Errorf("Unable to read page (%v)", err)
+	}
+}
+
+// Make a 3 page PDF with a radio button group (widgets on pages 1 and 2)
+// and a text field merged with its widget on page 3.
+func makeFormPdf() []byte {
+	return makePdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [6 0 R 9 0 R] >> >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 3 /MediaBox [0 0 100 100] >>",
+		"<< /Type /Page /Parent 2 0 R /Annots [7 0 R] >>",
+		"<< /Type /Page /Parent 2 0 R /Annots [8 0 R] >>",
+		"<< /Type /Page /Parent 2 0 R /Annots [9 0 R] >>",
+		"<< /FT /Btn /Ff 49152 /T (Choice) /Kids [7 0 R 8 0 R] >>",
+		"<< /Type /Annot /Subtype /Widget /Parent 6 0 R /P 3 0 R /Rect [0 0 10 10] >>",
+		"<< /Type /Annot /Subtype /Widget /Parent 6 0 R /P 4 0 R /Rect [0 0 10 10] >>",
+		"<< /Type /Annot /Subtype /Widget /FT /Tx /T (Name) /P 5 0 R /Rect [0 0 50 10] >>",
+	})
+}
+
+func TestAddFormsPageMembership(t *testing.T) {
+	reader, err := NewPdfReader(bytes.NewReader(makeFormPdf()))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	forms, err := reader.GetForms()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	testcases := []struct {
+		pages  []int
+		fields []string
+	}{
+		{[]int{1, 2, 3}, []string{"Choice", "Name"}},
+		{[]int{2}, []string{"Choice"}},
+		{[]int{3}, []string{"Name"}},
+	}
+	for _, tcase := range testcases {
+		w := NewPdfWriter()
+		for _, pageNumber := range tcase.pages {
+			page, err := reader.GetPage(pageNumber)
+			if err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+			err = w.AddPage(page)
+			if err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+		}
+		err = w.AddForms(forms)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+
+		names := []string{}
+		for _, field := range w.fields {
+			dict := field.(*PdfIndirectObject).PdfObject.(*PdfObjectDictionary)
+			names = append(names, string(*(*dict)["T"].(*PdfObjectString)))
+		}
+		if !reflect.DeepEqual(names, tcase.fields) {
+			t.Errorf("Pages %v: fields %v (expected %v)", tcase.pages, names, tcase.fields)
+		}
+	}
+}
+
+func TestCompressionLevel(t *testing.T) {
+	w := NewPdfWriter()
+	if err := w.SetCompressionLevel(10); err == nil {
+		t.Errorf("Invalid compression level should fail")
+	}
+
+	data := bytes.Repeat([]byte("Compressible stream data. "), 4000)
+	sizes := []int{}
+	for _, level := range []int{0, 9} {
+		w := NewPdfWriter()
+		err := w.AddPage(loadMinimalPage(t))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		err = w.AttachFile("data.txt", data, "text/plain")
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		err = w.SetCompressionLevel(level)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		out, err := writePdfToBytes(&w)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		sizes = append(sizes, len(out))
+
+		reader, err := NewPdfReader(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("Error reading output: %v", err)
+		}
+		files, err := reader.GetAttachments()
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if len(files) != 1 || !bytes.Equal(files[0].Data, data) {
+			t.Errorf("Level %d: compressed data not read back", level)
+		}
+	}
+
+	if sizes[0] <= sizes[1] || sizes[1] >= len(data) {
+		t.Errorf("Unexpected output sizes %v (data %d)", sizes, len(data))
+	}
+}
+
+func TestWriterCreator(t *testing.T) {
+	w := NewPdfWriter()
+	err := w.AddPage(loadMinimalPage(t))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	out, err := writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if bytes.Contains(out, []byte("/Creator")) {
+		t.Errorf("Creator should be omitted by default")
+	}
+	if !bytes.Contains(out, []byte("/Producer")) {
+		t.Errorf("Producer missing")
+	}
+
+	w.SetCreator("Test Application")
+	out, err = writePdfToBytes(&w)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if !bytes.Contains(out, []byte("/Creator (Test Application)")) {
+		t.Errorf("Creator not written")
+	}
+}
+
+func TestWriterProgressCallback(t *testing.T) {
+	for _, linearize := range []bool{false, true} {
+		w := NewPdfWriter()
+		err := w.AddPage(loadMinimalPage(t))
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		w.SetLinearize(linearize)
+		calls, lastWritten, lastTotal := 0, 0, 0
+		w.SetProgressCallback(func(written, total int) {
+			calls++
+			if written != lastWritten+1 {
+				t.Errorf("Progress %d after %d", written, lastWritten)
+			}
+			lastWritten, lastTotal = written, total
+		})
+		if _, err := writePdfToBytes(&w); err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		if calls == 0 || lastWritten != lastTotal || lastTotal != len(w.objects) {
+			t.Errorf("Linearize %v: %d calls, %d of %d written", linearize, calls, lastWritten, lastTotal)
+		}
+	}
+}
+
+func TestSetBinaryHeaderComment(t *testing.T) {
+	testcases := []struct {
+		comment []byte
+		header  string
+	}{
+		{defaultBinaryComment, "%PDF-1.3\n%âãÏÓ\n"},
+		{nil, "%PDF-1.3\n"},
+		{[]byte("\xe2\xe3\xcf\xd3"), "%PDF-1.3\n%\xe2\xe3\xcf\xd3\n"},
+	}
+	for _, linearize := range []bool{false, true} {
+		for _, tcase := range testcases {
+			w := makeLinearizeTestWriter(t, 2)
+			w.SetLinearize(linearize)
+			if err := w.SetBinaryHeaderComment(tcase.comment); err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+			data, err := writePdfToBytes(w)
+			if err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+			// Followed by the first object.
+			if !bytes.HasPrefix(data, []byte(tcase.header)) || data[len(tcase.header)] < '1' || data[len(tcase.header)] > '9' {
+				t.Errorf("Comment %q: header %q", tcase.comment, data[:20])
+			}
+			reader, err := NewPdfReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+			if isLinearized, err := reader.IsLinearized(); err != nil || isLinearized != linearize {
+				t.Errorf("Comment %q: linearized %v (%v)", tcase.comment, isLinearized, err)
+			}
+			if text, err := reader.ExtractPageText(2); err != nil || text != "Page 2\nTimes" {
+				t.Errorf("Comment %q: text %q (%v)", tcase.comment, text, err)
+			}
+		}
+	}
+
+	w := NewPdfWriter()
+	if err := w.SetBinaryHeaderComment([]byte("two\nlines")); err == nil {
+		t.Errorf("Comment with a line break should fail")
+	}
+}